@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Dorrrke/g2-books/internal/calibre"
+	"github.com/Dorrrke/g2-books/internal/config"
+	"github.com/Dorrrke/g2-books/internal/importer"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const calibreImportCmd = "calibre-import"
+
+// runCalibreImport imports a Calibre library for one user, into the default library or the one
+// of an organization, and prints the report as JSON.
+func runCalibreImport(args []string) error {
+	cfg, err := config.ReadCalibreImportConfig(args)
+	if err != nil {
+		return err
+	}
+	log := logger.Get(cfg.Debug)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	repo, err := storage.NewRepo(ctx, cfg.DBDsn)
+	if err != nil {
+		return err
+	}
+	if err = storage.Migrations(cfg.DBDsn, cfg.MigratePath); err != nil {
+		return err
+	}
+	rows, err := calibre.Read(ctx, cfg.Library, cfg.Source)
+	if err != nil {
+		return err
+	}
+	log.Debug().Int("books", len(rows)).Msg("calibre library read")
+	report, err := importer.Import(repo.WithTenant(cfg.TenantID), cfg.UserID, rows, cfg.DryRun)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
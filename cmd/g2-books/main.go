@@ -3,23 +3,42 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"golang.org/x/sync/errgroup"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/Dorrrke/g2-books/internal/authclient"
+	"github.com/Dorrrke/g2-books/internal/authsvc"
+	"github.com/Dorrrke/g2-books/internal/blobstore"
 	"github.com/Dorrrke/g2-books/internal/config"
 	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
 	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/oidc"
 	"github.com/Dorrrke/g2-books/internal/server"
 	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == calibreImportCmd {
+		if err := runCalibreImport(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == setRoleCmd {
+		if err := runSetRole(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	cfg := config.ReadConfig()
 	log := logger.Get(cfg.Debug)
 	log.Debug().Msg("logger was inited")
@@ -32,23 +51,106 @@ func main() {
 		cancel()
 	}()
 	var stor server.Storage
-	stor, err := storage.NewRepo(context.Background(), cfg.DBDsn)
-	if err != nil {
-		log.Fatal().Err(err).Msg("init storage failed")
-	}
-	if err = storage.Migrations(cfg.DBDsn, cfg.MigratePath); err != nil {
-		log.Fatal().Err(err).Msg("migrations failed")
+	var tenants func(string) server.Storage
+	if cfg.InMemory {
+		mem := storage.New()
+		stor = mem
+		tenants = func(tenant string) server.Storage { return mem.WithTenant(tenant) }
+	} else {
+		repo, err := storage.NewRepo(context.Background(), cfg.DBDsn)
+		if err != nil {
+			log.Fatal().Err(err).Msg("init storage failed")
+		}
+		if err = storage.Migrations(cfg.DBDsn, cfg.MigratePath); err != nil {
+			log.Fatal().Err(err).Msg("migrations failed")
+		}
+		bypass, err := repo.BypassesRowSecurity(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("check database role failed")
+		}
+		if bypass {
+			if !cfg.DBAllowSuperuser {
+				log.Fatal().Msg("the database role bypasses row-level security, which keeps organizations apart; " +
+					"connect as an ordinary role or set DB_ALLOW_SUPERUSER=true")
+			}
+			log.Warn().Msg("the database role bypasses row-level security; organizations are kept apart by queries only")
+		}
+		stor = repo
+		tenants = func(tenant string) server.Storage { return repo.WithTenant(tenant) }
 	}
-	// grpc.Dial("localhost:5050", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	conn, err := grpc.NewClient(cfg.AuthAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	verifier, err := newVerifier(ctx, cfg.JWT)
 	if err != nil {
-		log.Fatal().Err(err).Msg("creation grpc connection failed")
+		log.Fatal().Err(err).Msg("init token verification failed")
 	}
-	defer conn.Close()
+	var authClien authservicev1.AuthServiceClient
+	if cfg.EmbeddedAuth {
+		// The embedded auth service is only reachable in-process; it is not served on AuthAddr.
+		if cfg.JWT.Secret == "" {
+			log.Fatal().Msg("the embedded auth service signs tokens with JWT_SECRET, which is not set")
+		}
+		auth, err := authsvc.New(stor, verifier, authsvc.Config{
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+			Mailer:   newMailer(cfg.Mail),
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("init auth service failed")
+		}
+		authClien = authsvc.Client(auth)
+	} else {
+		conn, err := authclient.Dial(cfg.AuthAddr, authclient.Config{Timeout: cfg.AuthTimeout})
+		if err != nil {
+			log.Fatal().Err(err).Msg("creation grpc connection failed")
+		}
+		defer conn.Close()
 
-	authClien := authservicev1.NewAuthServiceClient(conn)
+		authClien = authservicev1.NewAuthServiceClient(conn)
+	}
 
-	server := server.New(cfg.Host, stor, authClien)
+	if cfg.ShareSecret == "" {
+		log.Warn().Msg("SHARE_SECRET is not set; share links are signed with a random key and stop working on restart")
+	}
+	opts := []server.Option{
+		server.WithVerifier(verifier),
+		server.WithCalibreRoot(cfg.CalibreRoot),
+		server.WithOAIAdminEmail(cfg.OAIAdmin),
+		server.WithShareSecret(cfg.ShareSecret),
+		server.WithTenants(tenants),
+	}
+	if cfg.EmbeddedAuth {
+		opts = append(opts, server.WithLocalUsers())
+	}
+	if cfg.OIDC.Issuer != "" {
+		if !cfg.EmbeddedAuth {
+			// Users of an external auth service are not stored here, so the provider's
+			// identities could not be mapped to them.
+			log.Fatal().Msg("logins through the OpenID provider need the embedded auth service")
+		}
+		if cfg.JWT.Secret == "" && (cfg.JWT.PublicKeyFile != "" || cfg.JWT.JWKS != "") {
+			log.Fatal().Msg("logins through the OpenID provider are signed with JWT_SECRET, which is not set")
+		}
+		rp, err := oidc.New(ctx, oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("init oidc login failed")
+		}
+		opts = append(opts, server.WithOIDC(rp, server.SessionConfig{
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+		}))
+	}
+	if cfg.FilesDir != "" {
+		blobs, err := blobstore.NewLocal(cfg.FilesDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("init file store failed")
+		}
+		opts = append(opts, server.WithBlobStore(blobs))
+	}
+	server := server.New(cfg.Host, stor, authClien, opts...)
 
 	group, gCtx := errgroup.WithContext(ctx)
 
@@ -80,3 +182,43 @@ func main() {
 		}
 	}
 }
+
+// jwtLeeway is the clock skew between the auth service and this server that tokens tolerate.
+const jwtLeeway = 30 * time.Second
+
+// newVerifier sets up token verification. One of JWT_SECRET, JWT_PUBLIC_KEY or JWKS_URL
+// must be set; there is no built-in secret to fall back to.
+func newVerifier(ctx context.Context, cfg config.JWTConfig) (*tokens.Verifier, error) {
+	if cfg.Secret == "" && cfg.PublicKeyFile == "" && cfg.JWKS == "" {
+		return nil, errors.New("no token keys configured: set JWT_SECRET, JWT_PUBLIC_KEY or JWKS_URL")
+	}
+	return tokens.New(ctx, tokens.Config{
+		Secret:        cfg.Secret,
+		PublicKeyFile: cfg.PublicKeyFile,
+		JWKS:          cfg.JWKS,
+		Issuer:        cfg.Issuer,
+		Audience:      cfg.Audience,
+		RequireExpiry: cfg.RequireExpiry,
+		Leeway:        jwtLeeway,
+	})
+}
+
+// newMailer returns the mailer of the embedded auth service. Without an SMTP server there is
+// none, so password resets and e-mail verification are off rather than leaking tokens.
+func newMailer(cfg config.MailConfig) authsvc.Mailer {
+	log := logger.Get()
+	switch {
+	case cfg.Addr != "":
+		if cfg.From == "" {
+			log.Fatal().Msg("SMTP_ADDR is set, but MAIL_FROM, the sender of the mails, is not")
+		}
+		return authsvc.SMTPMailer{Addr: cfg.Addr, Username: cfg.Username, Password: cfg.Password, From: cfg.From}
+	case cfg.Log:
+		log.Warn().Msg("MAIL_LOG is set; reset and verification tokens are written to the log, where anyone " +
+			"reading it can take over accounts")
+		return authsvc.LogMailer{}
+	default:
+		log.Warn().Msg("SMTP_ADDR is not set; password resets and e-mail verification are disabled")
+		return nil
+	}
+}
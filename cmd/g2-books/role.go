@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Dorrrke/g2-books/internal/config"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/rbac"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const setRoleCmd = "set-role"
+
+// runSetRole gives the user with an e-mail address an account role, which is how the first
+// admin of a service comes to be. The role reaches the user with their next login or token
+// refresh.
+func runSetRole(args []string) error {
+	cfg, err := config.ReadSetRoleConfig(args)
+	if err != nil {
+		return err
+	}
+	if !rbac.Valid(cfg.Role) {
+		return fmt.Errorf("unknown role %q: use user, librarian or admin", cfg.Role)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	repo, err := storage.NewRepo(ctx, cfg.DBDsn)
+	if err != nil {
+		return err
+	}
+	if err = storage.Migrations(cfg.DBDsn, cfg.MigratePath); err != nil {
+		return err
+	}
+	uid, _, err := repo.ValidateUser(models.User{Email: strings.ToLower(strings.TrimSpace(cfg.Email))})
+	if err != nil {
+		return fmt.Errorf("look up %s: %w", cfg.Email, err)
+	}
+	// Plain users are stored without a role.
+	role := cfg.Role
+	if role == models.RoleUser {
+		role = ""
+	}
+	if err = repo.SetUserRole(uid, role); err != nil {
+		return err
+	}
+	fmt.Printf("%s (%s) is now %s\n", cfg.Email, uid, cfg.Role)
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package access resolves the roles users hold on books they do not own. Roles are granted on
+// single books or on whole shelves; a shelf grant covers every book currently on the shelf.
+package access
+
+import (
+	"slices"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+// ranks orders the roles; each role may do everything the weaker ones may.
+var ranks = map[string]int{ //nolint: gochecknoglobals //read-only table
+	models.RoleViewer: 1,
+	models.RoleEditor: 2, //nolint: gomnd //rank
+	models.RoleOwner:  3, //nolint: gomnd //rank
+}
+
+// Valid reports whether role is one of the roles that can be granted.
+func Valid(role string) bool {
+	_, ok := ranks[role]
+	return ok
+}
+
+// Allows reports whether role permits what need permits. An empty role permits nothing.
+func Allows(role, need string) bool {
+	return role != "" && ranks[role] >= ranks[need]
+}
+
+// BookRole returns the strongest role uid holds on book: owner for their own books, otherwise
+// the strongest of grants that name the book or one of its shelves. It returns an empty string
+// when uid has no access. grants may contain grants of other owners or users; they are ignored.
+func BookRole(book models.Book, uid string, grants []models.Grant) string {
+	if uid == "" {
+		return ""
+	}
+	if book.UID == uid {
+		return models.RoleOwner
+	}
+	role := ""
+	for _, g := range grants {
+		if g.Owner != book.UID || g.UID != uid {
+			continue
+		}
+		if g.Kind == models.GrantBook && g.Target == book.BID ||
+			g.Kind == models.GrantShelf && slices.Contains(book.Shelves, g.Target) {
+			role = stronger(role, g.Role)
+		}
+	}
+	return role
+}
+
+// ShelfRole returns the strongest role uid holds on a shelf of owner, or an empty string.
+func ShelfRole(owner, shelf, uid string, grants []models.Grant) string {
+	if uid == "" {
+		return ""
+	}
+	if owner == uid {
+		return models.RoleOwner
+	}
+	role := ""
+	for _, g := range grants {
+		if g.Owner == owner && g.UID == uid && g.Kind == models.GrantShelf && g.Target == shelf {
+			role = stronger(role, g.Role)
+		}
+	}
+	return role
+}
+
+func stronger(a, b string) string {
+	if ranks[b] > ranks[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,68 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestBookRole(t *testing.T) {
+	book := models.Book{BID: "bid1", UID: "owner", Shelves: []string{"family"}}
+	type test struct {
+		name   string
+		uid    string
+		grants []models.Grant
+		want   string
+	}
+	tests := []test{
+		{name: "Test BookRole() func; Case 1:", uid: "owner", want: models.RoleOwner},
+		{name: "Test BookRole() func; Case 2:", uid: "", want: ""},
+		{name: "Test BookRole() func; Case 3:", uid: "reader", want: ""},
+		{
+			name: "Test BookRole() func; Case 4:",
+			uid:  "reader",
+			grants: []models.Grant{
+				{Owner: "owner", Kind: models.GrantBook, Target: "bid1", UID: "reader", Role: models.RoleViewer},
+				{Owner: "owner", Kind: models.GrantShelf, Target: "family", UID: "reader", Role: models.RoleEditor},
+			},
+			want: models.RoleEditor,
+		},
+		{
+			name: "Test BookRole() func; Case 5:",
+			uid:  "reader",
+			grants: []models.Grant{
+				{Owner: "owner", Kind: models.GrantBook, Target: "bid2", UID: "reader", Role: models.RoleOwner},
+				{Owner: "owner", Kind: models.GrantShelf, Target: "work", UID: "reader", Role: models.RoleOwner},
+				{Owner: "other", Kind: models.GrantBook, Target: "bid1", UID: "reader", Role: models.RoleOwner},
+				{Owner: "owner", Kind: models.GrantBook, Target: "bid1", UID: "someone", Role: models.RoleOwner},
+			},
+			want: "",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, BookRole(book, tc.uid, tc.grants))
+		})
+	}
+}
+
+func TestShelfRole(t *testing.T) {
+	grants := []models.Grant{
+		{Owner: "owner", Kind: models.GrantShelf, Target: "family", UID: "reader", Role: models.RoleViewer},
+		{Owner: "owner", Kind: models.GrantBook, Target: "family", UID: "reader", Role: models.RoleOwner},
+	}
+	assert.Equal(t, models.RoleOwner, ShelfRole("owner", "family", "owner", nil))
+	assert.Equal(t, models.RoleViewer, ShelfRole("owner", "family", "reader", grants))
+	assert.Equal(t, "", ShelfRole("owner", "work", "reader", grants))
+}
+
+func TestAllows(t *testing.T) {
+	assert.True(t, Allows(models.RoleOwner, models.RoleEditor))
+	assert.True(t, Allows(models.RoleEditor, models.RoleEditor))
+	assert.False(t, Allows(models.RoleViewer, models.RoleEditor))
+	assert.False(t, Allows("", models.RoleViewer))
+	assert.True(t, Valid(models.RoleViewer))
+	assert.False(t, Valid("admin"))
+}
@@ -0,0 +1,175 @@
+// Package authclient connects to the auth service so that its outages do not take the server
+// down with it. Every call gets a deadline, calls of idempotent methods failing with
+// codes.Unavailable are retried with backoff, and once the service keeps failing a circuit
+// breaker fails calls at once with codes.Unavailable until it has had time to recover.
+package authclient
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // enables the health checks of the service config
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultRetries      = 2
+	defaultBackoff      = 100 * time.Millisecond
+	defaultMaxBackoff   = time.Second
+	defaultThreshold    = 5
+	defaultCooldown     = 30 * time.Second
+	keepaliveTime       = 30 * time.Second
+	keepaliveTimeout    = 10 * time.Second
+	healthServiceConfig = `{"loadBalancingConfig":[{"round_robin":{}}],"healthCheckConfig":{"serviceName":""}}`
+)
+
+// idempotent lists the methods that are safe to call again when an attempt failed with
+// codes.Unavailable. The service may have handled the failed attempt already, so methods that
+// create, change or delete data or consume one-time tokens are never retried.
+var idempotent = map[string]bool{
+	authservicev1.AuthService_Login_FullMethodName:      true,
+	authservicev1.AuthService_Logout_FullMethodName:     true,
+	authservicev1.AuthService_GetProfile_FullMethodName: true,
+}
+
+// Config tunes the resilience of calls. Zero fields take defaults.
+type Config struct {
+	// Timeout bounds each attempt. Deadlines of the request context still apply when shorter.
+	Timeout time.Duration
+	// Retries is how often calls of idempotent methods failing with codes.Unavailable are tried
+	// again.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles with every further one up to
+	// MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Threshold is the number of failed calls in a row that opens the circuit breaker, and
+	// Cooldown how long it stays open before a call is let through to try the service again.
+	Threshold int
+	Cooldown  time.Duration
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = defaultRetries
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.Threshold == 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	return cfg
+}
+
+// Dial creates a connection to the auth service at addr. Besides the interceptor it keeps the
+// connection alive during calls and checks the health of the service, where it implements
+// the gRPC health protocol.
+func Dial(addr string, cfg Config) (*grpc.ClientConn, error) {
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: keepaliveTime, Timeout: keepaliveTimeout}),
+		grpc.WithDefaultServiceConfig(healthServiceConfig),
+		grpc.WithUnaryInterceptor(Interceptor(cfg)),
+	)
+}
+
+// Interceptor returns the unary client interceptor making calls resilient. Its circuit
+// breaker is shared by all calls made through it.
+func Interceptor(cfg Config) grpc.UnaryClientInterceptor {
+	r := &resilience{cfg: cfg.withDefaults(), now: time.Now}
+	return r.intercept
+}
+
+type resilience struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (r *resilience) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !r.allow() {
+		return status.Error(codes.Unavailable, "auth service is unavailable, circuit breaker is open")
+	}
+	retries := r.cfg.Retries
+	if !idempotent[method] {
+		retries = 0
+	}
+	backoff := r.cfg.Backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+		err = invoker(callCtx, method, req, reply, cc, opts...)
+		cancel()
+		if status.Code(err) != codes.Unavailable || attempt == retries {
+			break
+		}
+		// Jitter keeps clients that failed together from retrying together.
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)) //nolint: gosec //jitter only
+		select {
+		case <-ctx.Done():
+			r.record(err)
+			return err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, r.cfg.MaxBackoff)
+	}
+	r.record(err)
+	return err
+}
+
+// allow reports whether a call may go out. Once the breaker has cooled down, a single call is
+// let through; its outcome closes the breaker or opens it again.
+func (r *resilience) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures < r.cfg.Threshold {
+		return true
+	}
+	if r.probing || r.now().Before(r.openUntil) {
+		return false
+	}
+	r.probing = true
+	return true
+}
+
+// record counts the outcome of a call towards the breaker. Only failures of the service
+// count; rejected credentials and the like are answers like any other.
+func (r *resilience) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probing = false
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		r.failures++
+		if r.failures >= r.cfg.Threshold {
+			r.openUntil = r.now().Add(r.cfg.Cooldown)
+		}
+	case codes.Canceled:
+	default:
+		r.failures = 0
+	}
+}
@@ -0,0 +1,145 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+)
+
+// fakeInvoker answers calls with the codes in answers, then with codes.OK.
+type fakeInvoker struct {
+	answers []codes.Code
+	calls   int
+}
+
+func (f *fakeInvoker) invoke(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+	f.calls++
+	if len(f.answers) == 0 {
+		return nil
+	}
+	code := f.answers[0]
+	f.answers = f.answers[1:]
+	if code == codes.DeadlineExceeded {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if code == codes.OK {
+		return nil
+	}
+	return status.Error(code, code.String())
+}
+
+func TestInterceptor(t *testing.T) {
+	cfg := Config{Timeout: 20 * time.Millisecond, Retries: 2, Backoff: time.Millisecond, Threshold: 2,
+		Cooldown: time.Minute}
+	type want struct {
+		code  codes.Code
+		calls int
+	}
+	type test struct {
+		name    string
+		method  string
+		answers []codes.Code
+		want    want
+	}
+	tests := []test{
+		{
+			name:    "Test Interceptor; Case 1:",
+			method:  authservicev1.AuthService_Login_FullMethodName,
+			answers: []codes.Code{codes.Unavailable, codes.Unavailable},
+			want:    want{code: codes.OK, calls: 3},
+		},
+		{
+			name:    "Test Interceptor; Case 2:",
+			method:  authservicev1.AuthService_Login_FullMethodName,
+			answers: []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable},
+			want:    want{code: codes.Unavailable, calls: 3},
+		},
+		{
+			name:    "Test Interceptor; Case 3:",
+			method:  authservicev1.AuthService_Login_FullMethodName,
+			answers: []codes.Code{codes.Unauthenticated},
+			want:    want{code: codes.Unauthenticated, calls: 1},
+		},
+		{
+			name:    "Test Interceptor; Case 4:",
+			method:  authservicev1.AuthService_Login_FullMethodName,
+			answers: []codes.Code{codes.DeadlineExceeded},
+			want:    want{code: codes.DeadlineExceeded, calls: 1},
+		},
+		{
+			name:    "Test Interceptor; Case 5:",
+			method:  authservicev1.AuthService_Register_FullMethodName,
+			answers: []codes.Code{codes.Unavailable, codes.Unavailable},
+			want:    want{code: codes.Unavailable, calls: 1},
+		},
+		{
+			name:    "Test Interceptor; Case 6:",
+			method:  authservicev1.AuthService_DeleteUser_FullMethodName,
+			answers: []codes.Code{codes.Unavailable},
+			want:    want{code: codes.Unavailable, calls: 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			intercept := Interceptor(cfg)
+			f := &fakeInvoker{answers: tc.answers}
+			err := intercept(context.Background(), tc.method, nil, nil, nil, f.invoke)
+			assert.Equal(t, tc.want.code, status.Code(err))
+			assert.Equal(t, tc.want.calls, f.calls)
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	r := &resilience{cfg: Config{Retries: 1, Threshold: 2, Cooldown: time.Minute}.withDefaults()}
+	r.cfg.Backoff = time.Millisecond
+	clock := time.Now()
+	r.now = func() time.Time { return clock }
+	f := &fakeInvoker{answers: []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable,
+		codes.Unavailable, codes.Unavailable, codes.Unavailable}}
+	call := func() error {
+		return r.intercept(context.Background(), "/authservice.AuthService/Login", nil, nil, nil, f.invoke)
+	}
+
+	assert.Equal(t, codes.Unavailable, status.Code(call()))
+	assert.Equal(t, codes.Unavailable, status.Code(call()))
+	assert.Equal(t, 4, f.calls)
+	// The breaker is open: calls fail without reaching the service.
+	assert.Equal(t, codes.Unavailable, status.Code(call()))
+	assert.Equal(t, 4, f.calls)
+
+	// After the cooldown a single call tries the service; it fails and the breaker opens again.
+	clock = clock.Add(2 * time.Minute)
+	assert.Equal(t, codes.Unavailable, status.Code(call()))
+	assert.Equal(t, 6, f.calls)
+	assert.Equal(t, codes.Unavailable, status.Code(call()))
+	assert.Equal(t, 6, f.calls)
+
+	// Once the service answers again, the breaker closes.
+	clock = clock.Add(2 * time.Minute)
+	assert.NoError(t, call())
+	assert.NoError(t, call())
+	assert.Equal(t, 8, f.calls)
+}
+
+func TestInterceptorContext(t *testing.T) {
+	intercept := Interceptor(Config{Backoff: time.Second})
+	f := &fakeInvoker{answers: []codes.Code{codes.Unavailable}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := intercept(ctx, "/authservice.AuthService/Login", nil, nil, nil, f.invoke)
+	// Retries give up with the request.
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.False(t, errors.Is(err, context.DeadlineExceeded))
+}
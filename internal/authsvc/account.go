@@ -0,0 +1,378 @@
+package authsvc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const (
+	resetTTL  = time.Hour
+	verifyTTL = 24 * time.Hour
+	// AuthorizationKey is the metadata key account calls carry the token of their user under,
+	// as "Bearer <token>" like the Authorization header of HTTP.
+	AuthorizationKey = "authorization"
+)
+
+// Kinds of one-time tokens.
+const (
+	ticketReset  = "reset"
+	ticketVerify = "verify"
+)
+
+// errNoMailer is returned by the calls that need e-mail when the service has no Mailer.
+var errNoMailer = status.Error(codes.FailedPrecondition, "e-mail delivery is not configured")
+
+// Mailer delivers the one-time tokens users confirm password resets and e-mail addresses with.
+type Mailer interface {
+	SendPasswordReset(email, token string) error
+	SendEmailVerification(email, token string) error
+}
+
+// LogMailer writes the tokens to the log instead of sending them. It is meant for tests and
+// development only; anyone reading the log can reset passwords.
+type LogMailer struct{}
+
+func (LogMailer) SendPasswordReset(email, token string) error {
+	log := logger.Get()
+	log.Info().Str("email", email).Str("token", token).Msg("password reset requested")
+	return nil
+}
+
+func (LogMailer) SendEmailVerification(email, token string) error {
+	log := logger.Get()
+	log.Info().Str("email", email).Str("token", token).Msg("e-mail verification requested")
+	return nil
+}
+
+// ticket is a one-time token handed out by e-mail. Verification tickets are for one address,
+// so changing it again makes them useless.
+type ticket struct {
+	kind   string
+	uid    string
+	email  string
+	expiry time.Time
+}
+
+// GetProfile returns the profile of the user the call is made for.
+func (s *Service) GetProfile(ctx context.Context, in *authservicev1.UserRequest) (*authservicev1.Profile, error) {
+	uid, err := s.caller(ctx, in.GetUid())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(uid)
+	if err != nil {
+		return nil, err
+	}
+	return profile(user), nil
+}
+
+// UpdateProfile changes the name or e-mail address of the user the call is made for. A new
+// address needs verifying, and addresses of other users yield codes.Aborted.
+func (s *Service) UpdateProfile(ctx context.Context, in *authservicev1.UpdateProfileRequest) (*authservicev1.Profile,
+	error) {
+	uid, err := s.caller(ctx, in.GetUid())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(uid)
+	if err != nil {
+		return nil, err
+	}
+	if name := strings.TrimSpace(in.GetName()); name != "" {
+		user.Name = name
+	}
+	email := normalizeEmail(in.GetEmail())
+	changed := email != "" && email != user.Email
+	if changed {
+		other, _, err := s.users.ValidateUser(models.User{Email: email})
+		switch {
+		case err == nil && other != user.UID:
+			return nil, status.Error(codes.Aborted, storage.ErrUserExists.Error())
+		case err != nil && !errors.Is(err, storage.ErrUserNotFound):
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		user.Email, user.EmailVerified = email, false
+	}
+	if err = s.users.UpdateUser(user); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if changed {
+		s.sendVerification(user.UID, user.Email)
+	}
+	return profile(user), nil
+}
+
+// ChangePassword sets a new password for the user the call is made for after checking the
+// current one. Other sessions of the user end.
+func (s *Service) ChangePassword(ctx context.Context, in *authservicev1.ChangePasswordRequest) (
+	*authservicev1.StatusResponse, error) {
+	uid, err := s.caller(ctx, in.GetUid())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(uid)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Pass), []byte(in.GetOldPass())) != nil {
+		return nil, status.Error(codes.Unauthenticated, storage.ErrInvalidAuthData.Error())
+	}
+	if err = s.setPassword(user.UID, in.GetNewPass()); err != nil {
+		return nil, err
+	}
+	return &authservicev1.StatusResponse{Message: "password changed"}, nil
+}
+
+// RequestPasswordReset mails a reset token to the address. Unknown addresses get the same
+// answer, so the call does not tell who has an account.
+func (s *Service) RequestPasswordReset(_ context.Context, in *authservicev1.PasswordResetRequest) (
+	*authservicev1.StatusResponse, error) {
+	if s.cfg.Mailer == nil {
+		return nil, errNoMailer
+	}
+	email := normalizeEmail(in.GetEmail())
+	if email == "" {
+		return nil, status.Error(codes.InvalidArgument, "e-mail is required")
+	}
+	answer := &authservicev1.StatusResponse{Message: "if the address is registered, a reset token was sent to it"}
+	uid, _, err := s.users.ValidateUser(models.User{Email: email})
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return answer, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	token, err := s.ticket(ticketReset, uid, email, resetTTL)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err = s.cfg.Mailer.SendPasswordReset(email, token); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return answer, nil
+}
+
+// ResetPassword sets a new password with a reset token. Every session of the user ends.
+func (s *Service) ResetPassword(_ context.Context, in *authservicev1.ResetPasswordRequest) (
+	*authservicev1.StatusResponse, error) {
+	if s.cfg.Mailer == nil {
+		return nil, errNoMailer
+	}
+	if in.GetNewPass() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+	t, ok := s.redeem(ticketReset, in.GetToken())
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "reset token is invalid or expired")
+	}
+	if err := s.setPassword(t.uid, in.GetNewPass()); err != nil {
+		return nil, err
+	}
+	return &authservicev1.StatusResponse{Message: "password reset"}, nil
+}
+
+// RequestEmailVerification mails a verification token to the address of the user the call is
+// made for.
+func (s *Service) RequestEmailVerification(ctx context.Context, in *authservicev1.UserRequest) (
+	*authservicev1.StatusResponse, error) {
+	if s.cfg.Mailer == nil {
+		return nil, errNoMailer
+	}
+	uid, err := s.caller(ctx, in.GetUid())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.user(uid)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerified {
+		return &authservicev1.StatusResponse{Message: "e-mail address is verified already"}, nil
+	}
+	token, err := s.ticket(ticketVerify, user.UID, user.Email, verifyTTL)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err = s.cfg.Mailer.SendEmailVerification(user.Email, token); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &authservicev1.StatusResponse{Message: "verification token sent"}, nil
+}
+
+// VerifyEmail marks the address a verification token was sent to as verified, unless the user
+// has changed it since.
+func (s *Service) VerifyEmail(_ context.Context, in *authservicev1.VerifyEmailRequest) (
+	*authservicev1.StatusResponse, error) {
+	if s.cfg.Mailer == nil {
+		return nil, errNoMailer
+	}
+	t, ok := s.redeem(ticketVerify, in.GetToken())
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "verification token is invalid or expired")
+	}
+	user, err := s.user(t.uid)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != t.email {
+		return nil, status.Error(codes.Unauthenticated, "verification token is invalid or expired")
+	}
+	user.EmailVerified = true
+	if err = s.users.UpdateUser(user); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &authservicev1.StatusResponse{Message: "e-mail address verified"}, nil
+}
+
+// DeleteUser deletes the account of the user the call is made for and ends their sessions.
+func (s *Service) DeleteUser(ctx context.Context, in *authservicev1.UserRequest) (*authservicev1.StatusResponse,
+	error) {
+	uid, err := s.caller(ctx, in.GetUid())
+	if err != nil {
+		return nil, err
+	}
+	if err = s.users.DeleteUser(uid); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.forget(uid)
+	return &authservicev1.StatusResponse{Message: "user deleted"}, nil
+}
+
+// caller returns the uid of the user whose token the call carries under AuthorizationKey.
+// Account calls act on that user only: the uid of the request may be left empty, and naming
+// another user yields codes.PermissionDenied.
+func (s *Service) caller(ctx context.Context, uid string) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AuthorizationKey)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "a user token is required")
+	}
+	var c claims
+	if err := s.signer.Parse(ctx, strings.TrimPrefix(values[0], "Bearer "), &c); err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	if c.UserID == "" {
+		return "", status.Error(codes.Unauthenticated, "the token names no user")
+	}
+	if uid != "" && uid != c.UserID {
+		return "", status.Error(codes.PermissionDenied, "the token is for another user")
+	}
+	return c.UserID, nil
+}
+
+func (s *Service) user(uid string) (models.User, error) {
+	user, err := s.users.GetUser(uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, status.Error(codes.NotFound, err.Error())
+		}
+		return models.User{}, status.Error(codes.Internal, err.Error())
+	}
+	return user, nil
+}
+
+// setPassword stores a new password of uid and ends the sessions it had.
+func (s *Service) setPassword(uid, pass string) error {
+	if pass == "" {
+		return status.Error(codes.InvalidArgument, "password is required")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	if err = s.users.SetPassword(uid, string(hash)); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	s.forget(uid)
+	return nil
+}
+
+// sendVerification mails a verification token for a new address. Failures are only logged;
+// users can ask for another token. Without a Mailer addresses stay unverified.
+func (s *Service) sendVerification(uid, email string) {
+	log := logger.Get()
+	if s.cfg.Mailer == nil {
+		return
+	}
+	token, err := s.ticket(ticketVerify, uid, email, verifyTTL)
+	if err == nil {
+		err = s.cfg.Mailer.SendEmailVerification(email, token)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("uid", uid).Msg("send e-mail verification failed")
+	}
+}
+
+// ticket hands out a one-time token of kind for uid.
+func (s *Service) ticket(kind, uid, email string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, t := range s.tickets {
+		if t.expiry.Before(now) {
+			delete(s.tickets, key)
+		}
+	}
+	s.tickets[tokenKey(token)] = ticket{kind: kind, uid: uid, email: email, expiry: now.Add(ttl)}
+	return token, nil
+}
+
+// redeem uses up a one-time token of kind.
+func (s *Service) redeem(kind, token string) (ticket, bool) {
+	key := tokenKey(token)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tickets[key]
+	if !ok || t.kind != kind {
+		return ticket{}, false
+	}
+	delete(s.tickets, key)
+	return t, !t.expiry.Before(s.now())
+}
+
+// forget ends the sessions of uid and voids the reset tokens handed out to it.
+func (s *Service) forget(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, sess := range s.sessions {
+		if sess.uid == uid {
+			delete(s.sessions, key)
+		}
+	}
+	for key, t := range s.tickets {
+		if t.uid == uid && t.kind == ticketReset {
+			delete(s.tickets, key)
+		}
+	}
+}
+
+func profile(user models.User) *authservicev1.Profile {
+	return &authservicev1.Profile{
+		Uid:           user.UID,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+	}
+}
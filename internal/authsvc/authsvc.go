@@ -0,0 +1,236 @@
+// Package authsvc implements the auth service in-process, so the server can run without a
+// separate one. Users are kept in the users table with bcrypt hashed passwords; tokens are
+// signed with the HMAC secret the server verifies them with. Refresh tokens, like the tokens
+// of password resets and e-mail verifications, live in memory, so users log in again after a
+// restart.
+package authsvc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+const (
+	defaultTokenTTL   = 15 * time.Minute
+	defaultRefreshTTL = 30 * 24 * time.Hour
+	randomTokenSize   = 32
+)
+
+// Users stores the accounts. server.Storage implements it.
+type Users interface {
+	SaveUser(models.User) (string, error)
+	ValidateUser(models.User) (string, string, error)
+	GetUser(string) (models.User, error)
+	UpdateUser(models.User) error
+	SetPassword(string, string) error
+	DeleteUser(string) error
+}
+
+// Signer signs the tokens handed out and verifies the tokens account calls are made with.
+// tokens.Verifier implements it.
+type Signer interface {
+	Sign(jwt.Claims) (string, error)
+	Parse(context.Context, string, tokens.Claims) error
+}
+
+// Config sets the lifetime and the iss and aud claims of the tokens handed out, and how
+// tokens reach users by e-mail. Without a Mailer password resets and e-mail verification are
+// off and yield codes.FailedPrecondition.
+type Config struct {
+	TokenTTL   time.Duration
+	RefreshTTL time.Duration
+	Issuer     string
+	Audience   string
+	Mailer     Mailer
+}
+
+// claims are the claims of the tokens handed out, shaped like server.Claims. Role is the
+// account role stored with the user.
+type claims struct {
+	jwt.RegisteredClaims
+	UserID string
+	Role   string `json:",omitempty"`
+}
+
+type session struct {
+	uid    string
+	expiry time.Time
+}
+
+// Service implements authservicev1.AuthServiceServer.
+type Service struct {
+	authservicev1.UnimplementedAuthServiceServer
+	users  Users
+	signer Signer
+	cfg    Config
+	// dummyHash is compared against for unknown e-mails, so logins take as long for them.
+	dummyHash []byte
+
+	mu       sync.Mutex
+	sessions map[string]session
+	tickets  map[string]ticket
+	now      func() time.Time
+}
+
+func New(users Users, signer Signer, cfg Config) (*Service, error) {
+	if cfg.TokenTTL == 0 {
+		cfg.TokenTTL = defaultTokenTTL
+	}
+	if cfg.RefreshTTL == 0 {
+		cfg.RefreshTTL = defaultRefreshTTL
+	}
+	dummy, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		users:     users,
+		signer:    signer,
+		cfg:       cfg,
+		dummyHash: dummy,
+		sessions:  make(map[string]session),
+		tickets:   make(map[string]ticket),
+		now:       time.Now,
+	}, nil
+}
+
+// Register creates an account and logs it in. Taken e-mail addresses yield codes.Aborted.
+func (s *Service) Register(_ context.Context, in *authservicev1.User) (*authservicev1.AuthResponse, error) {
+	email := normalizeEmail(in.GetEmail())
+	if email == "" || in.GetPass() == "" {
+		return nil, status.Error(codes.InvalidArgument, "e-mail and password are required")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.GetPass()), bcrypt.DefaultCost)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	uid, err := s.users.SaveUser(models.User{Name: in.GetName(), Email: email, Pass: string(hash)})
+	if err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.sendVerification(uid, email)
+	return s.issue(uid, "user registered")
+}
+
+// Login checks the password of an account. Unknown e-mails yield codes.NotFound, wrong
+// passwords codes.Unauthenticated.
+func (s *Service) Login(_ context.Context, in *authservicev1.UserCreds) (*authservicev1.AuthResponse, error) {
+	uid, hash, err := s.users.ValidateUser(models.User{Email: normalizeEmail(in.GetEmail())})
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(in.GetPass()))
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(in.GetPass())) != nil {
+		return nil, status.Error(codes.Unauthenticated, storage.ErrInvalidAuthData.Error())
+	}
+	return s.issue(uid, "user logged in")
+}
+
+// Refresh trades a refresh token for a new token and refresh token. Each refresh token works
+// once.
+func (s *Service) Refresh(_ context.Context, in *authservicev1.RefreshRequest) (*authservicev1.AuthResponse, error) {
+	key := tokenKey(in.GetRefreshToken())
+	s.mu.Lock()
+	sess, ok := s.sessions[key]
+	delete(s.sessions, key)
+	s.mu.Unlock()
+	if !ok || sess.expiry.Before(s.now()) {
+		return nil, status.Error(codes.Unauthenticated, "refresh token is invalid or expired")
+	}
+	return s.issue(sess.uid, "token refreshed")
+}
+
+// Logout revokes a refresh token. Unknown refresh tokens are no error.
+func (s *Service) Logout(_ context.Context, in *authservicev1.LogoutRequest) (*authservicev1.LogoutResponse, error) {
+	s.mu.Lock()
+	delete(s.sessions, tokenKey(in.GetRefreshToken()))
+	s.mu.Unlock()
+	return &authservicev1.LogoutResponse{Message: "logged out"}, nil
+}
+
+// issue hands uid a token and a refresh token. The account role is read anew each time, so a
+// changed role reaches the user with their next refresh.
+func (s *Service) issue(uid, message string) (*authservicev1.AuthResponse, error) {
+	user, err := s.users.GetUser(uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	now := s.now()
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uid,
+			Issuer:    s.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.TokenTTL)),
+		},
+		UserID: uid,
+		Role:   user.Role,
+	}
+	if s.cfg.Audience != "" {
+		c.Audience = jwt.ClaimStrings{s.cfg.Audience}
+	}
+	token, err := s.signer.Sign(c)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.mu.Lock()
+	for key, sess := range s.sessions {
+		if sess.expiry.Before(now) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[tokenKey(refresh)] = session{uid: uid, expiry: now.Add(s.cfg.RefreshTTL)}
+	s.mu.Unlock()
+	return &authservicev1.AuthResponse{Token: token, Message: message, RefreshToken: refresh}, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// tokenKey identifies a refresh or one-time token without keeping the token itself around.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, randomTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
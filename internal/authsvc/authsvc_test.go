@@ -0,0 +1,223 @@
+package authsvc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+func TestService(t *testing.T) {
+	logger.Get(true)
+	ctx := context.Background()
+	users := storage.New()
+	verifier := tokens.NewHMAC("secret")
+	svc, err := New(users, verifier, Config{})
+	require.NoError(t, err)
+	client := Client(svc)
+
+	_, err = client.Register(ctx, &authservicev1.User{Name: "Alice", Email: " ", Pass: "pass"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	registered, err := client.Register(ctx, &authservicev1.User{Name: "Alice", Email: "Alice@example.org", Pass: "pass"})
+	require.NoError(t, err)
+	_, err = client.Register(ctx, &authservicev1.User{Name: "Alice", Email: "alice@example.org", Pass: "other"})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	// Passwords are stored hashed.
+	uid, hash, err := users.ValidateUser(models.User{Email: "alice@example.org"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "pass", hash)
+	// Tokens carry the stored account role.
+	require.NoError(t, users.SetUserRole(uid, models.RoleLibrarian))
+
+	type test struct {
+		name  string
+		email string
+		pass  string
+		want  codes.Code
+	}
+	tests := []test{
+		{name: "Test Login; Case 1:", email: "alice@example.org", pass: "pass", want: codes.OK},
+		{name: "Test Login; Case 2:", email: "ALICE@example.org ", pass: "pass", want: codes.OK},
+		{name: "Test Login; Case 3:", email: "alice@example.org", pass: "wrong", want: codes.Unauthenticated},
+		{name: "Test Login; Case 4:", email: "bob@example.org", pass: "pass", want: codes.NotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.Login(ctx, &authservicev1.UserCreds{Email: tc.email, Pass: tc.pass})
+			assert.Equal(t, tc.want, status.Code(err))
+			if tc.want != codes.OK {
+				return
+			}
+			var claims struct {
+				jwt.RegisteredClaims
+				UserID string
+				Role   string
+			}
+			require.NoError(t, verifier.Parse(ctx, resp.GetToken(), &claims))
+			assert.Equal(t, uid, claims.UserID)
+			assert.Equal(t, models.RoleLibrarian, claims.Role)
+			assert.NotNil(t, claims.ExpiresAt)
+		})
+	}
+
+	// Refresh tokens work once, and not after logout.
+	refreshed, err := client.Refresh(ctx, &authservicev1.RefreshRequest{RefreshToken: registered.GetRefreshToken()})
+	require.NoError(t, err)
+	assert.NotEqual(t, registered.GetRefreshToken(), refreshed.GetRefreshToken())
+	_, err = client.Refresh(ctx, &authservicev1.RefreshRequest{RefreshToken: registered.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.Logout(ctx, &authservicev1.LogoutRequest{RefreshToken: refreshed.GetRefreshToken()})
+	require.NoError(t, err)
+	_, err = client.Refresh(ctx, &authservicev1.RefreshRequest{RefreshToken: refreshed.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// mailbox keeps the last token mailed to each address.
+type mailbox map[string]string
+
+func (m mailbox) SendPasswordReset(email, token string) error {
+	m["reset "+email] = token
+	return nil
+}
+
+func (m mailbox) SendEmailVerification(email, token string) error {
+	m["verify "+email] = token
+	return nil
+}
+
+func TestAccount(t *testing.T) {
+	logger.Get(true)
+	ctx := context.Background()
+	users := storage.New()
+	mail := mailbox{}
+	svc, err := New(users, tokens.NewHMAC("secret"), Config{Mailer: mail})
+	require.NoError(t, err)
+	client := Client(svc)
+
+	alice, err := client.Register(ctx, &authservicev1.User{Name: "Alice", Email: "alice@example.org", Pass: "pass"})
+	require.NoError(t, err)
+	_, err = client.Register(ctx, &authservicev1.User{Name: "Bob", Email: "bob@example.org", Pass: "pass"})
+	require.NoError(t, err)
+	uid, _, err := users.ValidateUser(models.User{Email: "alice@example.org"})
+	require.NoError(t, err)
+	// Account calls act on the user whose token they carry.
+	aliceCtx := metadata.AppendToOutgoingContext(ctx, AuthorizationKey, "Bearer "+alice.GetToken())
+	_, err = client.GetProfile(ctx, &authservicev1.UserRequest{Uid: uid})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.DeleteUser(metadata.AppendToOutgoingContext(ctx, AuthorizationKey, "Bearer forged"),
+		&authservicev1.UserRequest{Uid: uid})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Registering mails a verification token.
+	_, err = client.VerifyEmail(ctx, &authservicev1.VerifyEmailRequest{Token: "guess"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.VerifyEmail(ctx, &authservicev1.VerifyEmailRequest{Token: mail["verify alice@example.org"]})
+	require.NoError(t, err)
+	profile, err := client.GetProfile(aliceCtx, &authservicev1.UserRequest{Uid: uid})
+	require.NoError(t, err)
+	assert.True(t, profile.GetEmailVerified())
+
+	type test struct {
+		name string
+		in   *authservicev1.UpdateProfileRequest
+		want codes.Code
+	}
+	tests := []test{
+		{name: "Test UpdateProfile; Case 1:", in: &authservicev1.UpdateProfileRequest{Uid: uid, Name: "Alicia"},
+			want: codes.OK},
+		{name: "Test UpdateProfile; Case 2:", in: &authservicev1.UpdateProfileRequest{Uid: uid,
+			Email: "BOB@example.org"}, want: codes.Aborted},
+		{name: "Test UpdateProfile; Case 3:", in: &authservicev1.UpdateProfileRequest{Uid: "nobody", Name: "X"},
+			want: codes.PermissionDenied},
+		{name: "Test UpdateProfile; Case 4:", in: &authservicev1.UpdateProfileRequest{Name: "Alicia"},
+			want: codes.OK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.UpdateProfile(aliceCtx, tc.in)
+			assert.Equal(t, tc.want, status.Code(err))
+		})
+	}
+
+	// A new address is unverified, and tokens for the old one stop working.
+	_, err = client.RequestEmailVerification(aliceCtx, &authservicev1.UserRequest{Uid: uid})
+	require.NoError(t, err)
+	profile, err = client.UpdateProfile(aliceCtx, &authservicev1.UpdateProfileRequest{Uid: uid, Email: "al@example.org"})
+	require.NoError(t, err)
+	assert.Equal(t, "Alicia", profile.GetName())
+	assert.False(t, profile.GetEmailVerified())
+	_, err = client.VerifyEmail(ctx, &authservicev1.VerifyEmailRequest{Token: mail["verify alice@example.org"]})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.VerifyEmail(ctx, &authservicev1.VerifyEmailRequest{Token: mail["verify al@example.org"]})
+	require.NoError(t, err)
+
+	// Changing the password checks the old one and ends the sessions.
+	_, err = client.ChangePassword(aliceCtx, &authservicev1.ChangePasswordRequest{Uid: uid, OldPass: "wrong",
+		NewPass: "new"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.ChangePassword(aliceCtx, &authservicev1.ChangePasswordRequest{Uid: uid, OldPass: "pass",
+		NewPass: "new"})
+	require.NoError(t, err)
+	_, err = client.Refresh(ctx, &authservicev1.RefreshRequest{RefreshToken: alice.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.Login(ctx, &authservicev1.UserCreds{Email: "al@example.org", Pass: "new"})
+	require.NoError(t, err)
+
+	// Unknown addresses get the same answer, but no token.
+	_, err = client.RequestPasswordReset(ctx, &authservicev1.PasswordResetRequest{Email: "eve@example.org"})
+	require.NoError(t, err)
+	assert.Empty(t, mail["reset eve@example.org"])
+	_, err = client.RequestPasswordReset(ctx, &authservicev1.PasswordResetRequest{Email: "AL@example.org"})
+	require.NoError(t, err)
+	token := mail["reset al@example.org"]
+	require.NotEmpty(t, token)
+	_, err = client.ResetPassword(ctx, &authservicev1.ResetPasswordRequest{Token: token})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.ResetPassword(ctx, &authservicev1.ResetPasswordRequest{Token: token, NewPass: "reset"})
+	require.NoError(t, err)
+	_, err = client.ResetPassword(ctx, &authservicev1.ResetPasswordRequest{Token: token, NewPass: "again"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.Login(ctx, &authservicev1.UserCreds{Email: "al@example.org", Pass: "reset"})
+	require.NoError(t, err)
+
+	_, err = client.DeleteUser(aliceCtx, &authservicev1.UserRequest{Uid: uid})
+	require.NoError(t, err)
+	_, err = client.Login(ctx, &authservicev1.UserCreds{Email: "al@example.org", Pass: "reset"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.DeleteUser(aliceCtx, &authservicev1.UserRequest{Uid: uid})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAccountWithoutMailer(t *testing.T) {
+	logger.Get(true)
+	ctx := context.Background()
+	users := storage.New()
+	svc, err := New(users, tokens.NewHMAC("secret"), Config{})
+	require.NoError(t, err)
+	client := Client(svc)
+
+	// Registering works, the address just stays unverified.
+	alice, err := client.Register(ctx, &authservicev1.User{Name: "Alice", Email: "alice@example.org", Pass: "pass"})
+	require.NoError(t, err)
+	aliceCtx := metadata.AppendToOutgoingContext(ctx, AuthorizationKey, "Bearer "+alice.GetToken())
+	_, err = client.RequestPasswordReset(ctx, &authservicev1.PasswordResetRequest{Email: "alice@example.org"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.ResetPassword(ctx, &authservicev1.ResetPasswordRequest{Token: "guess", NewPass: "new"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.RequestEmailVerification(aliceCtx, &authservicev1.UserRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.VerifyEmail(ctx, &authservicev1.VerifyEmailRequest{Token: "guess"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
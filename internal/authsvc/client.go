@@ -0,0 +1,90 @@
+package authsvc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+)
+
+// Client returns a client calling srv directly, for servers that run the auth service
+// in-process. Call options are ignored; outgoing metadata reaches srv as incoming metadata,
+// as it would over the network.
+func Client(srv authservicev1.AuthServiceServer) authservicev1.AuthServiceClient {
+	return localClient{srv: srv}
+}
+
+type localClient struct {
+	srv authservicev1.AuthServiceServer
+}
+
+// incoming hands the outgoing metadata of ctx to the service as the metadata of the call.
+func incoming(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func (c localClient) Register(ctx context.Context, in *authservicev1.User,
+	_ ...grpc.CallOption) (*authservicev1.AuthResponse, error) {
+	return c.srv.Register(incoming(ctx), in)
+}
+
+func (c localClient) Login(ctx context.Context, in *authservicev1.UserCreds,
+	_ ...grpc.CallOption) (*authservicev1.AuthResponse, error) {
+	return c.srv.Login(incoming(ctx), in)
+}
+
+func (c localClient) Refresh(ctx context.Context, in *authservicev1.RefreshRequest,
+	_ ...grpc.CallOption) (*authservicev1.AuthResponse, error) {
+	return c.srv.Refresh(incoming(ctx), in)
+}
+
+func (c localClient) Logout(ctx context.Context, in *authservicev1.LogoutRequest,
+	_ ...grpc.CallOption) (*authservicev1.LogoutResponse, error) {
+	return c.srv.Logout(incoming(ctx), in)
+}
+
+func (c localClient) GetProfile(ctx context.Context, in *authservicev1.UserRequest,
+	_ ...grpc.CallOption) (*authservicev1.Profile, error) {
+	return c.srv.GetProfile(incoming(ctx), in)
+}
+
+func (c localClient) UpdateProfile(ctx context.Context, in *authservicev1.UpdateProfileRequest,
+	_ ...grpc.CallOption) (*authservicev1.Profile, error) {
+	return c.srv.UpdateProfile(incoming(ctx), in)
+}
+
+func (c localClient) ChangePassword(ctx context.Context, in *authservicev1.ChangePasswordRequest,
+	_ ...grpc.CallOption) (*authservicev1.StatusResponse, error) {
+	return c.srv.ChangePassword(incoming(ctx), in)
+}
+
+func (c localClient) RequestPasswordReset(ctx context.Context, in *authservicev1.PasswordResetRequest,
+	_ ...grpc.CallOption) (*authservicev1.StatusResponse, error) {
+	return c.srv.RequestPasswordReset(incoming(ctx), in)
+}
+
+func (c localClient) ResetPassword(ctx context.Context, in *authservicev1.ResetPasswordRequest,
+	_ ...grpc.CallOption) (*authservicev1.StatusResponse, error) {
+	return c.srv.ResetPassword(incoming(ctx), in)
+}
+
+func (c localClient) RequestEmailVerification(ctx context.Context, in *authservicev1.UserRequest,
+	_ ...grpc.CallOption) (*authservicev1.StatusResponse, error) {
+	return c.srv.RequestEmailVerification(incoming(ctx), in)
+}
+
+func (c localClient) VerifyEmail(ctx context.Context, in *authservicev1.VerifyEmailRequest,
+	_ ...grpc.CallOption) (*authservicev1.StatusResponse, error) {
+	return c.srv.VerifyEmail(incoming(ctx), in)
+}
+
+func (c localClient) DeleteUser(ctx context.Context, in *authservicev1.UserRequest,
+	_ ...grpc.CallOption) (*authservicev1.StatusResponse, error) {
+	return c.srv.DeleteUser(incoming(ctx), in)
+}
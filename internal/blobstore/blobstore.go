@@ -0,0 +1,33 @@
+// Package blobstore keeps uploaded files such as e-books and cover images. Storage backends
+// implement Store; Local keeps the files in a directory.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store saves and serves blobs by key. Keys are slash separated paths such as
+// "files/<uid>/<fid>"; they never start with a slash or contain "." or ".." elements.
+type Store interface {
+	// Put stores the content of r under key, replacing an existing blob, and returns its size.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns the blob stored under key or ErrNotFound.
+	Open(ctx context.Context, key string) (*Blob, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is an open blob. It is seekable, so it can be served with range requests.
+type Blob struct {
+	io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+}
@@ -0,0 +1,46 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	size, err := store.Put(ctx, "files/uid/fid", strings.NewReader("book content"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 12, size)
+
+	blob, err := store.Open(ctx, "files/uid/fid")
+	require.NoError(t, err)
+	data, err := io.ReadAll(blob)
+	assert.NoError(t, err)
+	assert.NoError(t, blob.Close())
+	assert.Equal(t, "book content", string(data))
+	assert.EqualValues(t, 12, blob.Size)
+
+	_, err = store.Put(ctx, "files/uid/fid", strings.NewReader("new"))
+	assert.NoError(t, err)
+	blob, err = store.Open(ctx, "files/uid/fid")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, blob.Size)
+	assert.NoError(t, blob.Close())
+
+	assert.NoError(t, store.Delete(ctx, "files/uid/fid"))
+	assert.NoError(t, store.Delete(ctx, "files/uid/fid"))
+	_, err = store.Open(ctx, "files/uid/fid")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, key := range []string{"../outside", "/abs", "", ".", "a//b"} {
+		_, err = store.Put(ctx, key, strings.NewReader("x"))
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
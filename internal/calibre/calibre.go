@@ -0,0 +1,226 @@
+// Package calibre reads the books of a Calibre library directory, either from the library
+// database (metadata.db) or from the metadata.opf file Calibre keeps next to every book.
+package calibre
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+	"github.com/Dorrrke/g2-books/internal/formats/opf"
+)
+
+const (
+	SourceAuto = "auto"
+	SourceDB   = "db"
+	SourceOPF  = "opf"
+
+	dbFile  = "metadata.db"
+	opfFile = "metadata.opf"
+
+	// Calibre joins several authors with an ampersand in its own author sort field.
+	authorSep = " & "
+)
+
+var (
+	ErrUnknownSource = errors.New("unknown calibre source")
+	ErrNotLibrary    = errors.New("directory is not a calibre library")
+)
+
+// Read reads the library in dir. With SourceAuto the database is used when the library has one.
+func Read(ctx context.Context, dir, source string) ([]formats.Row, error) {
+	switch source {
+	case SourceAuto, "":
+		if _, err := os.Stat(filepath.Join(dir, dbFile)); err == nil {
+			return ReadDB(ctx, filepath.Join(dir, dbFile))
+		}
+		rows, err := ReadOPF(ctx, os.DirFS(dir))
+		if err == nil && len(rows) == 0 {
+			return nil, ErrNotLibrary
+		}
+		return rows, err
+	case SourceDB:
+		return ReadDB(ctx, filepath.Join(dir, dbFile))
+	case SourceOPF:
+		return ReadOPF(ctx, os.DirFS(dir))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, source)
+	}
+}
+
+// ReadOPF reads every metadata.opf below the root of fsys. A file that cannot be parsed
+// produces a row with an error instead of failing the whole library.
+func ReadOPF(ctx context.Context, fsys fs.FS) ([]formats.Row, error) {
+	var rows []formats.Row
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if entry.IsDir() || path.Base(name) != opfFile {
+			return nil
+		}
+		rows = append(rows, readOPFFile(fsys, name))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func readOPFFile(fsys fs.FS, name string) formats.Row {
+	row := formats.Row{Ref: name}
+	file, err := fsys.Open(name)
+	if err != nil {
+		row.Errors = []string{err.Error()}
+		return row
+	}
+	defer file.Close()
+	pkg, err := opf.Parse(file)
+	if err != nil {
+		row.Errors = []string{fmt.Sprintf("parse %s: %s", opfFile, err)}
+		return row
+	}
+	meta := pkg.Metadata
+	row.Book = models.Book{
+		Lable:  meta.Title(),
+		Author: strings.Join(meta.Authors(), authorSep),
+		ISBN:   formats.NormalizeISBN(meta.ISBN()),
+		Rating: meta.Rating(),
+		Tags:   meta.Tags(),
+	}
+	row.Book.Series, row.Book.SeriesIndex = meta.Series()
+	row.Errors = formats.Validate(row.Book)
+	return row
+}
+
+// ReadDB reads the books of a Calibre library database. The database is opened read-only.
+func ReadDB(ctx context.Context, dbPath string) ([]formats.Row, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotLibrary, err)
+	}
+	dsn := url.URL{Scheme: "file", Path: dbPath, RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	books := make(map[int64]*models.Book)
+	var ids []int64
+	err = query(ctx, db, "SELECT id, title, series_index FROM books", func(rows *sql.Rows) error {
+		var id int64
+		var book models.Book
+		if scanErr := rows.Scan(&id, &book.Lable, &book.SeriesIndex); scanErr != nil {
+			return scanErr
+		}
+		books[id] = &book
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	links := []struct {
+		query string
+		apply func(book *models.Book, value string)
+	}{
+		{
+			query: `SELECT l.book, a.name FROM books_authors_link l JOIN authors a ON a.id = l.author ORDER BY l.id`,
+			apply: func(book *models.Book, value string) {
+				if book.Author != "" {
+					book.Author += authorSep
+				}
+				book.Author += value
+			},
+		},
+		{
+			query: `SELECT l.book, s.name FROM books_series_link l JOIN series s ON s.id = l.series`,
+			apply: func(book *models.Book, value string) { book.Series = value },
+		},
+		{
+			query: `SELECT l.book, t.name FROM books_tags_link l JOIN tags t ON t.id = l.tag ORDER BY t.name`,
+			apply: func(book *models.Book, value string) { book.Tags = append(book.Tags, value) },
+		},
+		{
+			query: `SELECT book, val FROM identifiers WHERE type = 'isbn'`,
+			apply: func(book *models.Book, value string) { book.ISBN = formats.NormalizeISBN(value) },
+		},
+	}
+	for _, link := range links {
+		err = query(ctx, db, link.query, func(rows *sql.Rows) error {
+			var id int64
+			var value string
+			if scanErr := rows.Scan(&id, &value); scanErr != nil {
+				return scanErr
+			}
+			if book, ok := books[id]; ok {
+				link.apply(book, value)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = query(ctx, db, `SELECT l.book, r.rating FROM books_ratings_link l JOIN ratings r ON r.id = l.rating`,
+		func(rows *sql.Rows) error {
+			var id int64
+			var rating float64
+			if scanErr := rows.Scan(&id, &rating); scanErr != nil {
+				return scanErr
+			}
+			if book, ok := books[id]; ok {
+				book.Rating = rating / 2 //nolint: gomnd //calibre stores half stars
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	result := make([]formats.Row, 0, len(ids))
+	for _, id := range ids {
+		book := *books[id]
+		if book.Series == "" {
+			book.SeriesIndex = 0
+		}
+		result = append(result, formats.Row{
+			Ref:    fmt.Sprintf("%s#%d", dbFile, id),
+			Book:   book,
+			Errors: formats.Validate(book),
+		})
+	}
+	return result, nil
+}
+
+func query(ctx context.Context, db *sql.DB, q string, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
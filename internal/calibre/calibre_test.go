@@ -0,0 +1,104 @@
+package calibre
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+const testOPF = `<?xml version='1.0' encoding='utf-8'?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uuid_id" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+    <dc:identifier opf:scheme="calibre" id="calibre_id">1</dc:identifier>
+    <dc:title>Dune Messiah</dc:title>
+    <dc:creator opf:file-as="Herbert, Frank" opf:role="aut">Frank Herbert</dc:creator>
+    <dc:creator opf:role="ill">John Schoenherr</dc:creator>
+    <dc:identifier opf:scheme="ISBN">0-593-09823-4</dc:identifier>
+    <dc:subject>Science Fiction</dc:subject>
+    <dc:subject>Classics</dc:subject>
+    <meta name="calibre:series" content="Dune"/>
+    <meta name="calibre:series_index" content="2.0"/>
+    <meta name="calibre:rating" content="8"/>
+  </metadata>
+</package>`
+
+func TestReadOPF(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Frank Herbert/Dune Messiah (1)/metadata.opf": {Data: []byte(testOPF)},
+		"Frank Herbert/Dune Messiah (1)/cover.jpg":    {Data: []byte{0xff, 0xd8}},
+		"Broken/Book (2)/metadata.opf":                {Data: []byte("<package><metadata>")},
+	}
+	rows, err := ReadOPF(context.Background(), fsys)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, "Broken/Book (2)/metadata.opf", rows[0].Ref)
+	assert.NotEmpty(t, rows[0].Errors)
+
+	assert.Equal(t, "Frank Herbert/Dune Messiah (1)/metadata.opf", rows[1].Ref)
+	assert.Empty(t, rows[1].Errors)
+	assert.Equal(t, models.Book{
+		Lable:       "Dune Messiah",
+		Author:      "Frank Herbert",
+		ISBN:        "9780593098233",
+		Rating:      4,
+		Series:      "Dune",
+		SeriesIndex: 2,
+		Tags:        []string{"Science Fiction", "Classics"},
+	}, rows[1].Book)
+}
+
+func TestReadDB(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite", filepath.Join(dir, dbFile))
+	require.NoError(t, err)
+	for _, stmt := range []string{
+		`CREATE TABLE books (id INTEGER PRIMARY KEY, title TEXT, series_index REAL)`,
+		`CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE books_authors_link (id INTEGER PRIMARY KEY, book INTEGER, author INTEGER)`,
+		`CREATE TABLE series (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE books_series_link (id INTEGER PRIMARY KEY, book INTEGER, series INTEGER)`,
+		`CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE books_tags_link (id INTEGER PRIMARY KEY, book INTEGER, tag INTEGER)`,
+		`CREATE TABLE identifiers (id INTEGER PRIMARY KEY, book INTEGER, type TEXT, val TEXT)`,
+		`CREATE TABLE ratings (id INTEGER PRIMARY KEY, rating INTEGER)`,
+		`CREATE TABLE books_ratings_link (id INTEGER PRIMARY KEY, book INTEGER, rating INTEGER)`,
+		`INSERT INTO books VALUES (1, 'Good Omens', 1.0), (2, 'Untitled', 1.0)`,
+		`INSERT INTO authors VALUES (1, 'Terry Pratchett'), (2, 'Neil Gaiman')`,
+		`INSERT INTO books_authors_link VALUES (1, 1, 1), (2, 1, 2)`,
+		`INSERT INTO tags VALUES (1, 'humor'), (2, 'fantasy')`,
+		`INSERT INTO books_tags_link VALUES (1, 1, 1), (2, 1, 2)`,
+		`INSERT INTO identifiers VALUES (1, 1, 'isbn', '9780060853983'), (2, 1, 'amazon', 'B000')`,
+		`INSERT INTO ratings VALUES (1, 10)`,
+		`INSERT INTO books_ratings_link VALUES (1, 1, 1)`,
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.Close())
+
+	rows, err := Read(context.Background(), dir, SourceAuto)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "metadata.db#1", rows[0].Ref)
+	assert.Equal(t, models.Book{
+		Lable:  "Good Omens",
+		Author: "Terry Pratchett & Neil Gaiman",
+		ISBN:   "9780060853983",
+		Rating: 5,
+		Tags:   []string{"fantasy", "humor"},
+	}, rows[0].Book)
+	assert.Equal(t, []string{"author is required"}, rows[1].Errors)
+
+	_, err = Read(context.Background(), t.TempDir(), SourceAuto)
+	assert.ErrorIs(t, err, ErrNotLibrary)
+	_, err = Read(context.Background(), dir, "xml")
+	assert.ErrorIs(t, err, ErrUnknownSource)
+}
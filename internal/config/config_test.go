@@ -49,6 +49,20 @@ func TestReadConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "Test ReadConfig() func; Case 3:",
+			flags: []string{"test", "-mem"},
+			env:   nil,
+			want: want{
+				cfg: Config{
+					Host:        defaultHost,
+					DBDsn:       defaultDBDSN,
+					MigratePath: defaultMigratePath,
+					AuthAddr:    defaultAuthAddr,
+					InMemory:    true,
+				},
+			},
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -67,3 +81,37 @@ func TestReadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestReadCalibreImportConfig(t *testing.T) {
+	cfg, err := ReadCalibreImportConfig([]string{"-library", "/srv/calibre", "-user", "testUID", "-dry-run"})
+	assert.NoError(t, err)
+	assert.Equal(t, CalibreImportConfig{
+		DBDsn:       defaultDBDSN,
+		MigratePath: defaultMigratePath,
+		Library:     "/srv/calibre",
+		Source:      "auto",
+		UserID:      "testUID",
+		DryRun:      true,
+	}, cfg)
+
+	cfg, err = ReadCalibreImportConfig([]string{"-library", "/srv/calibre", "-user", "testUID", "-org", "testOID"})
+	assert.NoError(t, err)
+	assert.Equal(t, "testOID", cfg.TenantID)
+
+	_, err = ReadCalibreImportConfig([]string{"-library", "/srv/calibre"})
+	assert.Error(t, err)
+}
+
+func TestReadSetRoleConfig(t *testing.T) {
+	cfg, err := ReadSetRoleConfig([]string{"-email", "alice@example.org", "-role", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, SetRoleConfig{
+		DBDsn:       defaultDBDSN,
+		MigratePath: defaultMigratePath,
+		Email:       "alice@example.org",
+		Role:        "admin",
+	}, cfg)
+
+	_, err = ReadSetRoleConfig([]string{"-email", "alice@example.org"})
+	assert.Error(t, err)
+}
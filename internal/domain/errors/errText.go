@@ -1,9 +1,78 @@
 package errors
 
 const (
-	InvalidAuthDataError = "invalid password"
-	UserNotFoundError    = "user not found"
-	BookNotFoundError    = "book not found"
-	BooksListEmptyError  = "book database is empty"
-	BookWasDeletedError  = "the book has been deleted"
+	InvalidAuthDataError   = "invalid password"
+	UserNotFoundError      = "user not found"
+	UserExistsError        = "user with this e-mail address is already registered"
+	BookNotFoundError      = "book not found"
+	BooksListEmptyError    = "book database is empty"
+	BookWasDeletedError    = "the book has been deleted"
+	BatchAbortedError      = "batch was rolled back"
+	BatchEmptyError        = "batch has no operations"
+	BatchTooLargeError     = "batch has too many operations"
+	UnknownBookOpError     = "unknown batch operation"
+	BookIDRequiredError    = "book id is required"
+	BookFieldsError        = "lable and author are required"
+	UnsupportedFmtError    = "unsupported format"
+	ImportFileError        = "import file is missing"
+	CalibreDisabledError   = "calibre import is not enabled on this server"
+	LibraryPathError       = "library directory not found"
+	JobNotFoundError       = "job not found"
+	HighlightNotFoundError = "highlight not found"
+	HighlightKindError     = "unknown highlight kind"
+	HighlightColorError    = "unknown highlight color"
+	HighlightEmptyError    = "highlight needs text, a note or a location"
+	PercentageError        = "percentage must be between 0 and 100"
+	SearchQueryError       = "search needs a query or a tag"
+	InvalidPageError       = "invalid page"
+	FileNotFoundError      = "file not found"
+	FilesDisabledError     = "file storage is not enabled on this server"
+	FileTypeError          = "only epub and pdf files are supported"
+	FileTooLargeError      = "file is too large"
+	CoverNotFoundError     = "book has no cover"
+	CoverTypeError         = "cover must be a JPEG, PNG or WebP image"
+	CoverTooLargeError     = "cover image is too large"
+	CitationStyleError     = "unknown citation style; use apa, chicago, bibtex, ris or csl-json"
+	NotAcceptableError     = "not acceptable; use application/json, application/ld+json or application/xml"
+	VisibilityError        = "visibility must be private, unlisted or public"
+	ShelfNotFoundError     = "shelf not found"
+	ShareLinkNotFoundError = "share link not found"
+	ShareLinkExpiryError   = "share link expiry must be in the future and within a year"
+	ShareLinkInvalidError  = "share link is invalid, expired or revoked"
+	GrantNotFoundError     = "access grant not found"
+	RoleError              = "role must be viewer, editor or owner"
+	GrantSelfError         = "owners always have access to their own books"
+	ForbiddenError         = "your role does not allow this"
+	MemberNotFoundError    = "organization member not found"
+	NotMemberError         = "you are not a member of this organization"
+	OrgRoleError           = "role must be member or admin"
+	OrgNameError           = "organization name is required"
+	LastAdminError         = "an organization needs at least one admin"
+	OrgsDisabledError      = "organizations are not enabled on this server"
+	OrgTokensDisabledError = "the server has no secret to sign organization tokens with"
+	RefreshTokenError      = "refresh token is invalid or expired"
+	RefreshRequiredError   = "refresh_token is required"
+	AuthUnavailableError   = "the auth service is unavailable, try again later"
+	APIKeyNotFoundError    = "API key not found"
+	APIKeyNameError        = "API key name is required"
+	APIKeyScopeError       = "scopes must be one or more of books:read, books:write, sharing, orgs and admin"
+	APIKeyExpiryError      = "API key expiry must be in the future"
+	APIKeyAdminError       = "only librarians and admins can create admin keys"
+	APIKeyScopeDeniedError = "the API key does not have the scope this needs"
+	APIKeyManageError      = "API keys cannot manage API keys, log in instead"
+	IdentityNotFoundError  = "identity not found"
+	OIDCDisabledError      = "single sign-on is not enabled on this server"
+	OIDCStateError         = "the login expired or was started elsewhere, try again"
+	OIDCLoginError         = "login with the identity provider failed"
+	OIDCEmailError         = "the identity provider shared no e-mail address"
+	OIDCEmailTakenError    = "an account with this e-mail address exists; log in and link the identity provider to it"
+	OIDCLinkedError        = "this identity is linked to another account"
+	SessionTokensError     = "the server has no secret to sign session tokens with"
+	OldPasswordError       = "the current password is wrong"
+	ResetTokenError        = "reset token is invalid or expired"
+	VerifyTokenError       = "verification token is invalid or expired"
+	TokenRequiredError     = "token is required"
+	PasswordRequiredError  = "new_pass is required"
+	EmailRequiredError     = "email is required"
+	DeleteBooksError       = "books must be delete or anonymize"
 )
@@ -1,16 +1,392 @@
 package models
 
+import "time"
+
+const (
+	StatusToRead       = "to-read"
+	StatusReading      = "currently-reading"
+	StatusRead         = "read"
+	StatusDidNotFinish = "did-not-finish"
+)
+
+// Visibilities of books and shelves. Private ones are only seen by their owner, unlisted ones
+// by anyone who knows their URL, and public ones are listed in the shared catalog as well.
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
+// DeletedUser owns the books kept, anonymized, when their owner deletes their account.
+const DeletedUser = "deleted"
+
+// Roles granted on books and shelves of other users. Viewers may read, editors may change
+// book details, files and covers, and owners may also delete, publish and manage access.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// Account roles carried in tokens. Users manage their own library, librarians also moderate
+// the books of everyone, and admins run the service.
+const (
+	RoleUser      = "user"
+	RoleLibrarian = "librarian"
+	RoleAdmin     = "admin"
+)
+
+// Kinds of things access is granted on.
+const (
+	GrantBook  = "book"
+	GrantShelf = "shelf"
+)
+
+// Roles of organization members. Admins manage who belongs to their organization.
+const (
+	OrgMember = "member"
+	OrgAdmin  = "admin"
+)
+
+const (
+	HighlightHighlight = "highlight"
+	HighlightNote      = "note"
+	HighlightBookmark  = "bookmark"
+)
+
+// NoPassword is stored as the password hash of users who log in through an OpenID provider
+// only. It is no bcrypt hash, so no password matches it.
+const NoPassword = "!"
+
 type User struct {
 	UID   string `json:"uid"`
 	Name  string `json:"name"  validate:"required"`
 	Email string `json:"email" validate:"required, email"`
 	Pass  string `json:"pass"  validate:"required"`
+	// EmailVerified is kept by the auth service; clients cannot set it.
+	EmailVerified bool `json:"-"`
+	// Role is the account role tokens of the user carry. Operators grant it; clients cannot.
+	Role string `json:"-"`
+	// PasswordChangedAt is when the password was last set after registering. Tokens issued
+	// before are no longer valid.
+	PasswordChangedAt time.Time `json:"-"`
+}
+
+// UserSummary describes a user known to the service, either registered locally or owning books.
+type UserSummary struct {
+	UID   string `json:"uid"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Books int    `json:"books"`
+}
+
+// Stats are counts over the whole service, all tenants included.
+type Stats struct {
+	Users        int   `json:"users"`
+	Books        int   `json:"books"`
+	DeletedBooks int   `json:"deleted_books"`
+	PublicBooks  int   `json:"public_books"`
+	Highlights   int   `json:"highlights"`
+	Files        int   `json:"files"`
+	FilesSize    int64 `json:"files_size"`
+	Orgs         int   `json:"orgs"`
 }
 
 type Book struct {
-	BID    string `json:"b_id"`
-	Lable  string `json:"lable"  validate:"required"`
-	Author string `json:"author" validate:"required"`
-	Delete bool   `json:"delete"`
-	UID    string `json:"uid"    validate:"required"`
+	BID         string     `json:"b_id"`
+	Lable       string     `json:"lable"                  validate:"required"`
+	Author      string     `json:"author"                 validate:"required"`
+	Delete      bool       `json:"delete"`
+	UID         string     `json:"uid"                    validate:"required"`
+	ISBN        string     `json:"isbn,omitempty"`
+	Rating      float64    `json:"rating,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	Shelves     []string   `json:"shelves,omitempty"`
+	DateStarted *time.Time `json:"date_started,omitempty"`
+	DateRead    *time.Time `json:"date_read,omitempty"`
+	Series      string     `json:"series,omitempty"`
+	SeriesIndex float64    `json:"series_index,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Publisher   string     `json:"publisher,omitempty"`
+	Place       string     `json:"place,omitempty"`
+	Edition     string     `json:"edition,omitempty"`
+	Year        int        `json:"year,omitempty"`
+	Pages       int        `json:"pages,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	Visibility  string     `json:"visibility,omitempty"`
+	Cover       string     `json:"-"`
+	TenantID    string     `json:"-"`
+	Covers      *Covers    `json:"covers,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// Covers holds the URLs of a book cover and its thumbnails. The URLs change whenever the
+// cover does, so clients may cache the images forever.
+type Covers struct {
+	Original string `json:"original"`
+	Small    string `json:"small"`
+	Medium   string `json:"medium"`
+	Large    string `json:"large"`
+}
+
+const (
+	BookOpCreate = "create"
+	BookOpUpdate = "update"
+	BookOpDelete = "delete"
+)
+
+type BookOp struct {
+	Op   string `json:"op"   validate:"required"`
+	Book Book   `json:"book"`
+	// KeepShelves leaves the stored shelves of an updated book unchanged. Shelf grants share
+	// books by shelf, so editors of a shared book must not move it between shelves.
+	KeepShelves bool `json:"-"`
+}
+
+type BookBatch struct {
+	Atomic bool     `json:"atomic"`
+	Ops    []BookOp `json:"ops"    validate:"required"`
+}
+
+// HarvestQuery selects public books, deleted ones included, by the time they were last changed.
+// Results are ordered by update time and book id; After and AfterID resume after the last
+// book of a previous page.
+type HarvestQuery struct {
+	From    *time.Time
+	Until   *time.Time
+	After   *time.Time
+	AfterID string
+	Limit   int
+}
+
+// Shelf is one of a user's shelves, which exists as long as a book is on it.
+type Shelf struct {
+	UID        string `json:"uid"`
+	Name       string `json:"name"`
+	Visibility string `json:"visibility"`
+	Books      int    `json:"books"`
+}
+
+type VisibilityUpdate struct {
+	Visibility string `json:"visibility" validate:"required"`
+}
+
+// ShareLink grants read-only access to a shelf without login until it expires or is revoked.
+type ShareLink struct {
+	LID       string     `json:"l_id"`
+	UID       string     `json:"uid"`
+	Shelf     string     `json:"shelf"`
+	URL       string     `json:"url,omitempty"`
+	TenantID  string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type ShareLinkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Grant gives UID a role on a book or a shelf of Owner. Target is the book id or shelf name.
+type Grant struct {
+	Owner     string     `json:"owner"`
+	Kind      string     `json:"kind"`
+	Target    string     `json:"target"`
+	UID       string     `json:"uid"`
+	Role      string     `json:"role"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type GrantRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// Org is an organization. Its members share a library kept apart from every other library;
+// the organization id is the tenant id of that library. Role is the caller's role in it.
+type Org struct {
+	OID       string     `json:"o_id"`
+	Name      string     `json:"name"                 validate:"required"`
+	Role      string     `json:"role,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type Member struct {
+	OID       string     `json:"o_id"`
+	UID       string     `json:"uid"`
+	Role      string     `json:"role"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type MemberRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// RefreshRequest carries the refresh token handed out at login, to renew or end a session.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// APIKey is a personal API key scripts and integrations authenticate with instead of a login.
+// Only a hash of the key is kept; Key is set once, in the answer to its creation, and Prefix
+// tells keys apart afterwards. Keys carry no role of their own: admin keys act with the account
+// role their user has when they are used.
+type APIKey struct {
+	KID        string     `json:"k_id"`
+	UID        string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	TenantID   string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Profile is the account of a user as they see and change it.
+type Profile struct {
+	UID           string `json:"uid"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// ProfileRequest changes the fields of a profile that are not empty.
+type ProfileRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type PasswordChangeRequest struct {
+	OldPass string `json:"old_pass"`
+	NewPass string `json:"new_pass"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirm sets a new password with the token of a password reset.
+type PasswordResetConfirm struct {
+	Token   string `json:"token"`
+	NewPass string `json:"new_pass"`
+}
+
+type EmailVerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// Identity links a user of an OpenID provider, named by issuer and subject, to a local user.
+type Identity struct {
+	Issuer    string     `json:"issuer"`
+	Subject   string     `json:"subject"`
+	UID       string     `json:"uid"`
+	Email     string     `json:"email,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type SharedShelf struct {
+	Shelf     string     `json:"shelf"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Books     []Book     `json:"books"`
+}
+
+type BookOpResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	BID   string `json:"b_id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	ImportRowInvalid  = "invalid"
+	ImportRowValid    = "valid"
+	ImportRowImported = "imported"
+	ImportRowFailed   = "failed"
+	ImportRowDup      = "duplicate"
+)
+
+type ImportRow struct {
+	Line   int      `json:"line,omitempty"`
+	Ref    string   `json:"ref,omitempty"`
+	Status string   `json:"status"`
+	BID    string   `json:"b_id,omitempty"`
+	Lable  string   `json:"lable,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun     bool        `json:"dry_run"`
+	Total      int         `json:"total"`
+	Valid      int         `json:"valid"`
+	Duplicates int         `json:"duplicates"`
+	Imported   int         `json:"imported"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+type CalibreImport struct {
+	Path   string `json:"path"    validate:"required"`
+	Source string `json:"source"`
+	DryRun bool   `json:"dry_run"`
+}
+
+const (
+	ColorYellow = "yellow"
+	ColorBlue   = "blue"
+	ColorPink   = "pink"
+	ColorOrange = "orange"
+	ColorGreen  = "green"
+)
+
+type BookFile struct {
+	FID         string     `json:"f_id"`
+	BID         string     `json:"b_id"`
+	UID         string     `json:"uid"`
+	Name        string     `json:"name"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	Hash        string     `json:"sha256"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+type BookFileUpload struct {
+	File           BookFile `json:"file"`
+	Book           Book     `json:"book"`
+	Prefilled      []string `json:"prefilled,omitempty"`
+	CoverExtracted bool     `json:"cover_extracted"`
+}
+
+type Highlight struct {
+	HID        string     `json:"h_id"`
+	BID        string     `json:"b_id"`
+	UID        string     `json:"uid"`
+	Kind       string     `json:"kind"`
+	Text       string     `json:"text,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	Color      string     `json:"color,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Location   string     `json:"location,omitempty"`
+	Page       int        `json:"page,omitempty"`
+	Percentage float64    `json:"percentage,omitempty"`
+	CFI        string     `json:"cfi,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	Hash       string     `json:"-"`
+}
+
+type HighlightImportReport struct {
+	DryRun       bool        `json:"dry_run"`
+	Total        int         `json:"total"`
+	Imported     int         `json:"imported"`
+	Duplicates   int         `json:"duplicates"`
+	BooksMatched int         `json:"books_matched"`
+	BooksCreated int         `json:"books_created"`
+	Invalid      []ImportRow `json:"invalid,omitempty"`
 }
@@ -0,0 +1,316 @@
+// Package bookcsv reads and writes books as CSV.
+package bookcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+// Book fields that can be imported and exported. The names match the JSON tags of models.Book.
+const (
+	FieldBID         = "b_id"
+	FieldLable       = "lable"
+	FieldAuthor      = "author"
+	FieldISBN        = "isbn"
+	FieldRating      = "rating"
+	FieldStatus      = "status"
+	FieldShelves     = "shelves"
+	FieldDateStarted = "date_started"
+	FieldDateRead    = "date_read"
+	FieldSeries      = "series"
+	FieldSeriesIndex = "series_index"
+	FieldTags        = "tags"
+)
+
+const listSep = ";"
+
+var ErrInvalidMapping = errors.New("invalid column mapping")
+
+type field struct {
+	name    string
+	aliases []string
+	get     func(models.Book) string
+	set     func(*models.Book, string) error
+}
+
+var fields = []field{ //nolint: gochecknoglobals //static field table
+	{
+		name:    FieldBID,
+		aliases: []string{"id", "bid"},
+		get:     func(b models.Book) string { return b.BID },
+		set:     func(*models.Book, string) error { return nil },
+	},
+	{
+		name:    FieldLable,
+		aliases: []string{"title", "label", "name"},
+		get:     func(b models.Book) string { return b.Lable },
+		set:     func(b *models.Book, v string) error { b.Lable = v; return nil },
+	},
+	{
+		name:    FieldAuthor,
+		aliases: []string{"authors", "writer"},
+		get:     func(b models.Book) string { return b.Author },
+		set:     func(b *models.Book, v string) error { b.Author = v; return nil },
+	},
+	{
+		name:    FieldISBN,
+		aliases: []string{"isbn13", "isbn10"},
+		get:     func(b models.Book) string { return b.ISBN },
+		set:     func(b *models.Book, v string) error { b.ISBN = v; return nil },
+	},
+	{
+		name:    FieldRating,
+		aliases: []string{"my rating", "stars"},
+		get: func(b models.Book) string {
+			if b.Rating == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(b.Rating, 'f', -1, 64)
+		},
+		set: func(b *models.Book, v string) error {
+			if v == "" {
+				return nil
+			}
+			rating, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rating %q", v)
+			}
+			b.Rating = rating
+			return nil
+		},
+	},
+	{
+		name:    FieldStatus,
+		aliases: []string{"read status", "reading status"},
+		get:     func(b models.Book) string { return b.Status },
+		set:     func(b *models.Book, v string) error { b.Status = strings.ToLower(v); return nil },
+	},
+	{
+		name:    FieldShelves,
+		aliases: []string{"shelf", "tags"},
+		get:     func(b models.Book) string { return strings.Join(b.Shelves, listSep) },
+		set:     func(b *models.Book, v string) error { b.Shelves = formats.SplitList(v, listSep); return nil },
+	},
+	{
+		name:    FieldDateStarted,
+		aliases: []string{"started", "date started"},
+		get:     func(b models.Book) string { return formats.FormatDate(b.DateStarted) },
+		set: func(b *models.Book, v string) error {
+			var err error
+			b.DateStarted, err = formats.ParseDate(v)
+			return err
+		},
+	},
+	{
+		name:    FieldDateRead,
+		aliases: []string{"finished", "date read"},
+		get:     func(b models.Book) string { return formats.FormatDate(b.DateRead) },
+		set: func(b *models.Book, v string) error {
+			var err error
+			b.DateRead, err = formats.ParseDate(v)
+			return err
+		},
+	},
+	{
+		name:    FieldSeries,
+		aliases: []string{"series name"},
+		get:     func(b models.Book) string { return b.Series },
+		set:     func(b *models.Book, v string) error { b.Series = v; return nil },
+	},
+	{
+		name:    FieldSeriesIndex,
+		aliases: []string{"series number", "series #"},
+		get: func(b models.Book) string {
+			if b.SeriesIndex == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(b.SeriesIndex, 'f', -1, 64)
+		},
+		set: func(b *models.Book, v string) error {
+			if v == "" {
+				return nil
+			}
+			index, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("invalid series index %q", v)
+			}
+			b.SeriesIndex = index
+			return nil
+		},
+	},
+	{
+		name:    FieldTags,
+		aliases: []string{"tag", "subjects"},
+		get:     func(b models.Book) string { return strings.Join(b.Tags, listSep) },
+		set:     func(b *models.Book, v string) error { b.Tags = formats.SplitList(v, listSep); return nil },
+	},
+}
+
+// Mapping maps a book field name to the CSV column header it is read from.
+type Mapping map[string]string
+
+// ParseMapping parses a mapping of the form "lable:Title,author:Written By".
+func ParseMapping(s string) (Mapping, error) {
+	m := Mapping{}
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, column, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		column = strings.TrimSpace(column)
+		if !ok || name == "" || column == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMapping, pair)
+		}
+		if findField(name) == nil {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidMapping, name)
+		}
+		m[name] = column
+	}
+	return m, nil
+}
+
+// Read parses all records from r. The first record must be a header. Columns are matched
+// to fields through the mapping first, then by field name or alias, case-insensitively.
+func Read(r io.Reader, mapping Mapping) ([]formats.Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv file is empty")
+		}
+		return nil, err
+	}
+	columns := resolveColumns(header, mapping)
+	for name, column := range mapping {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: column %q not found in header", ErrInvalidMapping, column)
+		}
+	}
+
+	var rows []formats.Row
+	for {
+		record, readErr := reader.Read()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(readErr, &parseErr) {
+				return nil, readErr
+			}
+			rows = append(rows, formats.Row{Line: parseErr.StartLine, Errors: []string{parseErr.Err.Error()}})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		row := formats.Row{Line: line}
+		for _, f := range fields {
+			idx, ok := columns[f.name]
+			if !ok || idx >= len(record) {
+				continue
+			}
+			if setErr := f.set(&row.Book, strings.TrimSpace(record[idx])); setErr != nil {
+				row.Errors = append(row.Errors, setErr.Error())
+			}
+		}
+		row.Errors = append(row.Errors, formats.Validate(row.Book)...)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Writer streams books as CSV, writing the header before the first book.
+type Writer struct {
+	csv     *csv.Writer
+	started bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{csv: csv.NewWriter(w)}
+}
+
+func (w *Writer) Write(book models.Book) error {
+	if !w.started {
+		if err := w.WriteHeader(); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.get(book)
+	}
+	return w.csv.Write(record)
+}
+
+// WriteHeader writes the header row. Write calls it on its own, so it is only needed
+// when the export may have no books.
+func (w *Writer) WriteHeader() error {
+	w.started = true
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	return w.csv.Write(header)
+}
+
+// Flush writes buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func resolveColumns(header []string, mapping Mapping) map[string]int {
+	columns := make(map[string]int)
+	for idx, column := range header {
+		header[idx] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+	}
+	for idx, column := range header {
+		for name, mapped := range mapping {
+			if strings.EqualFold(column, mapped) {
+				columns[name] = idx
+			}
+		}
+	}
+	for _, f := range fields {
+		if _, mapped := mapping[f.name]; mapped {
+			continue
+		}
+		for idx, column := range header {
+			if matchesField(f, column) {
+				columns[f.name] = idx
+				break
+			}
+		}
+	}
+	return columns
+}
+
+func matchesField(f field, column string) bool {
+	if strings.EqualFold(column, f.name) {
+		return true
+	}
+	for _, alias := range f.aliases {
+		if strings.EqualFold(column, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+func findField(name string) *field {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package bookcsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+func TestRead(t *testing.T) {
+	type want struct {
+		err  bool
+		rows []formats.Row
+	}
+	type test struct {
+		name    string
+		data    string
+		mapping string
+		want    want
+	}
+	tests := []test{
+		{
+			name: "Test Read() func; Case 1:",
+			data: "Title,Author\nDune,Frank Herbert\n,Nobody\n",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Book: models.Book{Lable: "Dune", Author: "Frank Herbert"}},
+					{Line: 3, Book: models.Book{Author: "Nobody"}, Errors: []string{"lable is required"}},
+				},
+			},
+		},
+		{
+			name:    "Test Read() func; Case 2:",
+			data:    "Book Name;Written By\n",
+			mapping: "lable:Book Name,author:Written By",
+			want: want{
+				err: true,
+			},
+		},
+		{
+			name:    "Test Read() func; Case 3:",
+			data:    "\ufeffBook Name,Written By,Year\nSolaris,Stanislaw Lem,1961\n",
+			mapping: "lable:book name,author:Written By",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Book: models.Book{Lable: "Solaris", Author: "Stanislaw Lem"}},
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 4:",
+			data: "lable,author\n\"Broken,Quote\n",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Errors: []string{"extraneous or missing \" in quoted-field"}},
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 5:",
+			data: "title,author,rating,status,shelves,date read\nDune,Frank Herbert,6,lost,sci-fi; favorites,2024/13/01\n",
+			want: want{
+				rows: []formats.Row{
+					{
+						Line: 2,
+						Book: models.Book{
+							Lable: "Dune", Author: "Frank Herbert", Rating: 6, Status: "lost",
+							Shelves: []string{"sci-fi", "favorites"},
+						},
+						Errors: []string{
+							"invalid date \"2024/13/01\"",
+							"rating must be between 0 and 5",
+							"unknown reading status \"lost\"",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 6:",
+			data: "",
+			want: want{
+				err: true,
+			},
+		},
+		{
+			name: "Test Read() func; Case 7:",
+			data: "title,author,tags\nDune,Frank Herbert,sci-fi;classics\n",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Book: models.Book{
+						Lable: "Dune", Author: "Frank Herbert", Shelves: []string{"sci-fi", "classics"},
+						Tags: []string{"sci-fi", "classics"},
+					}},
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, err := ParseMapping(tc.mapping)
+			assert.NoError(t, err)
+			rows, err := Read(strings.NewReader(tc.data), mapping)
+			if tc.want.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.rows, rows)
+		})
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	_, err := ParseMapping("lable:Title,isbn")
+	assert.ErrorIs(t, err, ErrInvalidMapping)
+	_, err = ParseMapping("pages:Pages")
+	assert.ErrorIs(t, err, ErrInvalidMapping)
+	mapping, err := ParseMapping(" lable : Title ")
+	assert.NoError(t, err)
+	assert.Equal(t, Mapping{"lable": "Title"}, mapping)
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	read := time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, writer.Write(models.Book{BID: "bid1", Lable: "Dune, Messiah", Author: "Frank Herbert"}))
+	assert.NoError(t, writer.Write(models.Book{
+		BID: "bid2", Lable: "Solaris", Author: "Stanislaw Lem", ISBN: "9780156027601", Rating: 4.5,
+		Status: models.StatusRead, Shelves: []string{"sci-fi", "classics"}, DateRead: &read,
+	}))
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, "b_id,lable,author,isbn,rating,status,shelves,date_started,date_read,series,series_index,tags\n"+
+		"bid1,\"Dune, Messiah\",Frank Herbert,,,,,,,,,\n"+
+		"bid2,Solaris,Stanislaw Lem,9780156027601,4.5,read,sci-fi;classics,,2024-03-02,,,\n", buf.String())
+}
@@ -0,0 +1,134 @@
+package cite
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+var bibtexEscaper = strings.NewReplacer( //nolint: gochecknoglobals //read-only replacer
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`,
+	"_", `\_`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+)
+
+// WriteBibTeX writes books as BibTeX @book entries. Citation keys are built from the first
+// author's surname, the year and the first word of the title, and are unique within the output.
+func WriteBibTeX(w io.Writer, books []models.Book) error {
+	used := make(map[string]bool)
+	for i, book := range books {
+		base := bibtexKey(book)
+		key := base
+		for n := 2; used[key]; n++ {
+			key = base + keySuffix(n)
+		}
+		used[key] = true
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if err := writeBibTeXEntry(w, key, book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBibTeXEntry(w io.Writer, key string, book models.Book) error {
+	var authors []string
+	for _, name := range Authors(book) {
+		if name.Given == "" {
+			// Braces keep BibTeX from splitting a corporate name into given and family parts.
+			authors = append(authors, "{"+bibtexEscaper.Replace(name.Family)+"}")
+			continue
+		}
+		authors = append(authors, bibtexEscaper.Replace(name.Inverted()))
+	}
+	fields := [][2]string{
+		{"author", strings.Join(authors, " and ")},
+		{"title", bibtexEscaper.Replace(book.Lable)},
+		{"edition", bibtexEscaper.Replace(book.Edition)},
+		{"publisher", bibtexEscaper.Replace(book.Publisher)},
+		{"year", optionalInt(book.Year)},
+		{"isbn", bibtexEscaper.Replace(book.ISBN)},
+		{"series", bibtexEscaper.Replace(book.Series)},
+		{"number", optionalIndex(book.SeriesIndex)},
+		{"pagetotal", optionalInt(book.Pages)},
+		{"language", bibtexEscaper.Replace(book.Language)},
+		{"keywords", bibtexEscaper.Replace(strings.Join(book.Tags, ", "))},
+	}
+	if _, err := fmt.Fprintf(w, "@book{%s,\n", key); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "  %-9s = {%s},\n", f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// bibtexKey returns a key such as "herbert1965dune" made of ASCII letters and digits only.
+func bibtexKey(book models.Book) string {
+	var key string
+	if authors := Authors(book); len(authors) != 0 {
+		key = keyPart(authors[0].Family)
+	}
+	if book.Year != 0 {
+		key += strconv.Itoa(book.Year)
+	}
+	for _, word := range strings.Fields(book.Lable) {
+		switch strings.ToLower(word) {
+		case "a", "an", "the":
+			continue
+		}
+		if part := keyPart(word); part != "" {
+			key += part
+			break
+		}
+	}
+	if key == "" || !unicode.IsLetter(rune(key[0])) {
+		key = "book" + key
+	}
+	return key
+}
+
+// keySuffix tells apart the nth entry with the same key: herbert1965dune, herbert1965duneb, ...,
+// herbert1965dunez and then herbert1965dune27 and on.
+func keySuffix(n int) string {
+	if n <= 26 { //nolint: gomnd //letters of the alphabet
+		return string(rune('a' + n - 1))
+	}
+	return strconv.Itoa(n)
+}
+
+func keyPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func optionalIndex(idx float64) string {
+	if idx == 0 {
+		return ""
+	}
+	return seriesIndex(idx)
+}
@@ -0,0 +1,164 @@
+// Package cite renders books as bibliographic references: BibTeX, RIS and CSL-JSON for
+// reference managers, and formatted APA and Chicago citations for pasting into documents.
+package cite
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+// Style names accepted by Lookup.
+const (
+	StyleBibTeX  = "bibtex"
+	StyleRIS     = "ris"
+	StyleCSLJSON = "csl-json"
+	StyleAPA     = "apa"
+	StyleChicago = "chicago"
+)
+
+const textType = "text/plain; charset=utf-8"
+
+// Format renders a list of books in one citation style.
+type Format struct {
+	Name        string
+	ContentType string
+	Ext         string
+	Write       func(io.Writer, []models.Book) error
+}
+
+var styles = []Format{ //nolint: gochecknoglobals //read-only table
+	{Name: StyleBibTeX, ContentType: "application/x-bibtex; charset=utf-8", Ext: "bib", Write: WriteBibTeX},
+	{Name: StyleRIS, ContentType: "application/x-research-info-systems; charset=utf-8", Ext: "ris", Write: WriteRIS},
+	{Name: StyleCSLJSON, ContentType: "application/vnd.citationstyles.csl+json", Ext: "json", Write: WriteCSLJSON},
+	{Name: StyleAPA, ContentType: textType, Ext: "txt", Write: textWriter(APA)},
+	{Name: StyleChicago, ContentType: textType, Ext: "txt", Write: textWriter(Chicago)},
+}
+
+// Lookup returns the format of a style by name.
+func Lookup(style string) (Format, bool) {
+	for _, f := range styles {
+		if f.Name == style {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// textWriter writes one formatted citation per line.
+func textWriter(format func(models.Book) string) func(io.Writer, []models.Book) error {
+	return func(w io.Writer, books []models.Book) error {
+		for _, book := range books {
+			if _, err := io.WriteString(w, format(book)+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Name is a personal name split for citation styles. Names that cannot be split, such as
+// organizations, only have a Family part.
+type Name struct {
+	Given  string
+	Family string
+}
+
+// Authors splits the author field of book, which joins several authors with "&". Names may
+// be written "Frank Herbert" or "Herbert, Frank".
+func Authors(book models.Book) []Name {
+	var names []Name
+	for _, author := range formats.SplitList(book.Author, "&") {
+		if family, given, ok := strings.Cut(author, ","); ok {
+			names = append(names, Name{Given: strings.TrimSpace(given), Family: strings.TrimSpace(family)})
+			continue
+		}
+		idx := strings.LastIndex(author, " ")
+		if idx < 0 {
+			names = append(names, Name{Family: author})
+			continue
+		}
+		names = append(names, Name{Given: strings.TrimSpace(author[:idx]), Family: author[idx+1:]})
+	}
+	return names
+}
+
+// Inverted returns the name as "Herbert, Frank".
+func (n Name) Inverted() string {
+	if n.Given == "" {
+		return n.Family
+	}
+	return n.Family + ", " + n.Given
+}
+
+// String returns the name as "Frank Herbert".
+func (n Name) String() string {
+	return strings.TrimSpace(n.Given + " " + n.Family)
+}
+
+// Initials returns the given names as initials, as in "J. R. R." for "John Ronald Reuel".
+// Hyphenated names keep their hyphen: "Jean-Paul" becomes "J.-P.".
+func (n Name) Initials() string {
+	var parts []string
+	for _, given := range strings.Fields(n.Given) {
+		var hyphenated []string
+		for _, part := range strings.Split(given, "-") {
+			if runes := []rune(strings.Trim(part, ".")); len(runes) != 0 {
+				hyphenated = append(hyphenated, string(unicode.ToUpper(runes[0]))+".")
+			}
+		}
+		if len(hyphenated) != 0 {
+			parts = append(parts, strings.Join(hyphenated, "-"))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// edition renders the edition of a book for a formatted citation: "2" and "2nd" both become
+// "2nd ed.", other text is kept as written.
+func edition(value string) string {
+	value = strings.TrimSpace(value)
+	digits := strings.TrimRightFunc(value, unicode.IsLetter)
+	n, err := strconv.Atoi(digits)
+	if err != nil || n <= 0 {
+		return value
+	}
+	if n == 1 {
+		return ""
+	}
+	return ordinal(n) + " ed."
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2: //nolint: gomnd //ordinal suffixes
+		suffix = "nd"
+	case 3: //nolint: gomnd //ordinal suffixes
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// seriesIndex formats a series position without trailing zeros.
+func seriesIndex(idx float64) string {
+	return strconv.FormatFloat(idx, 'f', -1, 64)
+}
+
+// sentence ends s with a period unless it already ends with punctuation.
+func sentence(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
@@ -0,0 +1,182 @@
+package cite
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+var dune = models.Book{ //nolint: gochecknoglobals //test fixture
+	BID: "bid1", Lable: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", Publisher: "Chilton Books",
+	Edition: "2", Year: 1965, Pages: 412, Series: "Dune", SeriesIndex: 1, Language: "en",
+	Tags: []string{"sci-fi"},
+}
+
+func TestAuthors(t *testing.T) {
+	book := models.Book{Author: "J. R. R. Tolkien & Herbert, Frank & NASA & Jean-Paul Sartre"}
+	authors := Authors(book)
+	assert.Equal(t, []Name{
+		{Given: "J. R. R.", Family: "Tolkien"}, {Given: "Frank", Family: "Herbert"}, {Family: "NASA"},
+		{Given: "Jean-Paul", Family: "Sartre"},
+	}, authors)
+	assert.Equal(t, "J. R. R.", authors[0].Initials())
+	assert.Equal(t, "J.-P.", authors[3].Initials())
+}
+
+func TestAPA(t *testing.T) {
+	type test struct {
+		name string
+		book models.Book
+		want string
+	}
+	tests := []test{
+		{name: "Test APA() func; Case 1:", book: dune, want: "Herbert, F. (1965). Dune (2nd ed.). Chilton Books."},
+		{
+			name: "Test APA() func; Case 2:",
+			book: models.Book{Lable: "Good Omens", Author: "Terry Pratchett & Neil Gaiman"},
+			want: "Pratchett, T., & Gaiman, N. (n.d.). Good Omens.",
+		},
+		{
+			name: "Test APA() func; Case 3:",
+			book: models.Book{Lable: "Who Moved My Cheese?", Author: "A B & C D & E F", Year: 1998, Edition: "Revised"},
+			want: "B, A., D, C., & F, E. (1998). Who Moved My Cheese? (Revised).",
+		},
+		{
+			name: "Test APA() func; Case 4:",
+			book: models.Book{Lable: "Annual Report", Year: 2020, Publisher: "NASA"},
+			want: "Annual Report. (2020). NASA.",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, APA(tc.book))
+		})
+	}
+}
+
+func TestChicago(t *testing.T) {
+	type test struct {
+		name string
+		book models.Book
+		want string
+	}
+	tests := []test{
+		{name: "Test Chicago() func; Case 1:", book: dune, want: "Herbert, Frank. Dune. 2nd ed. Dune 1. Chilton Books, 1965."},
+		{
+			name: "Test Chicago() func; Case 2:",
+			book: models.Book{Lable: "Good Omens", Author: "Terry Pratchett & Neil Gaiman", Year: 1990},
+			want: "Pratchett, Terry, and Neil Gaiman. Good Omens. 1990.",
+		},
+		{
+			name: "Test Chicago() func; Case 3:",
+			book: models.Book{Lable: "Emma", Author: "Jane Austen & A B & C D", Edition: "1st"},
+			want: "Austen, Jane, A B, and C D. Emma.",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Chicago(tc.book))
+		})
+	}
+}
+
+func TestWriteBibTeX(t *testing.T) {
+	books := []models.Book{
+		dune,
+		{Lable: "The Dune Encyclopedia", Author: "NASA & Kevin J. Anderson", Year: 1965, Publisher: "R&D_Press"},
+		{Lable: "Dune", Author: "Frank Herbert", Year: 1965},
+	}
+	var out strings.Builder
+	require.NoError(t, WriteBibTeX(&out, books))
+	assert.Equal(t, `@book{herbert1965dune,
+  author    = {Herbert, Frank},
+  title     = {Dune},
+  edition   = {2},
+  publisher = {Chilton Books},
+  year      = {1965},
+  isbn      = {9780441172719},
+  series    = {Dune},
+  number    = {1},
+  pagetotal = {412},
+  language  = {en},
+  keywords  = {sci-fi},
+}
+
+@book{nasa1965dune,
+  author    = {{NASA} and Anderson, Kevin J.},
+  title     = {The Dune Encyclopedia},
+  publisher = {R\&D\_Press},
+  year      = {1965},
+}
+
+@book{herbert1965duneb,
+  author    = {Herbert, Frank},
+  title     = {Dune},
+  year      = {1965},
+}
+`, out.String())
+}
+
+func TestWriteBibTeXKeys(t *testing.T) {
+	books := make([]models.Book, 28)
+	for i := range books {
+		books[i] = models.Book{Lable: "Dune", Author: "Frank Herbert", Year: 1965}
+	}
+	// A key that looks like a suffixed one is not handed out twice.
+	books = append(books, models.Book{Lable: "Duneb", Author: "Frank Herbert", Year: 1965})
+	var out strings.Builder
+	require.NoError(t, WriteBibTeX(&out, books))
+	keys := regexp.MustCompile(`@book\{(\w+),`).FindAllStringSubmatch(out.String(), -1)
+	require.Len(t, keys, len(books))
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		assert.False(t, seen[key[1]], key[1])
+		seen[key[1]] = true
+	}
+	assert.Equal(t, "herbert1965duneb", keys[1][1])
+	assert.Equal(t, "herbert1965dunez", keys[25][1])
+	assert.Equal(t, "herbert1965dune27", keys[26][1])
+	assert.Equal(t, "herbert1965dune28", keys[27][1])
+	assert.Equal(t, "herbert1965dunebb", keys[28][1])
+}
+
+func TestWriteRIS(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, WriteRIS(&out, []models.Book{dune}))
+	assert.Equal(t, "TY  - BOOK\r\nAU  - Herbert, Frank\r\nTI  - Dune\r\nET  - 2\r\nPB  - Chilton Books\r\n"+
+		"PY  - 1965\r\nSN  - 9780441172719\r\nT3  - Dune\r\nVL  - 1\r\nSP  - 412\r\nLA  - en\r\nKW  - sci-fi\r\nER  - \r\n",
+		out.String())
+}
+
+func TestWriteCSLJSON(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, WriteCSLJSON(&out, []models.Book{dune, {Lable: "Report", Author: "NASA"}}))
+	var items []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &items))
+	require.Len(t, items, 2)
+	assert.Equal(t, "bid1", items[0]["id"])
+	assert.Equal(t, "book", items[0]["type"])
+	assert.Equal(t, []any{map[string]any{"family": "Herbert", "given": "Frank"}}, items[0]["author"])
+	assert.Equal(t, map[string]any{"date-parts": []any{[]any{1965.0}}}, items[0]["issued"])
+	assert.Equal(t, "Dune", items[0]["collection-title"])
+	assert.Equal(t, "9780441172719", items[0]["ISBN"])
+	assert.Equal(t, "nasareport", items[1]["id"])
+	assert.Equal(t, []any{map[string]any{"literal": "NASA"}}, items[1]["author"])
+	assert.NotContains(t, items[1], "issued")
+}
+
+func TestLookup(t *testing.T) {
+	for _, style := range []string{StyleBibTeX, StyleRIS, StyleCSLJSON, StyleAPA, StyleChicago} {
+		f, ok := Lookup(style)
+		assert.True(t, ok, style)
+		assert.Equal(t, style, f.Name)
+	}
+	_, ok := Lookup("mla")
+	assert.False(t, ok)
+}
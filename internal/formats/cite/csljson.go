@@ -0,0 +1,79 @@
+package cite
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+// Item is a CSL-JSON item, the input format of citeproc processors and of Zotero imports.
+type Item struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	Title            string    `json:"title"`
+	Author           []CSLName `json:"author,omitempty"`
+	Issued           *CSLDate  `json:"issued,omitempty"`
+	Publisher        string    `json:"publisher,omitempty"`
+	Edition          string    `json:"edition,omitempty"`
+	ISBN             string    `json:"ISBN,omitempty"`
+	CollectionTitle  string    `json:"collection-title,omitempty"`
+	CollectionNumber string    `json:"collection-number,omitempty"`
+	NumberOfPages    string    `json:"number-of-pages,omitempty"`
+	Language         string    `json:"language,omitempty"`
+	Keyword          string    `json:"keyword,omitempty"`
+}
+
+// CSLName is a name variable. Names that cannot be split are given as a literal.
+type CSLName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// CSLItem maps book onto a CSL-JSON item of type "book".
+func CSLItem(book models.Book) Item {
+	item := Item{
+		ID:               book.BID,
+		Type:             "book",
+		Title:            book.Lable,
+		Publisher:        book.Publisher,
+		Edition:          book.Edition,
+		ISBN:             book.ISBN,
+		CollectionTitle:  book.Series,
+		CollectionNumber: optionalIndex(book.SeriesIndex),
+		NumberOfPages:    optionalInt(book.Pages),
+		Language:         book.Language,
+		Keyword:          strings.Join(book.Tags, ", "),
+	}
+	if item.ID == "" {
+		item.ID = bibtexKey(book)
+	}
+	for _, name := range Authors(book) {
+		if name.Given == "" {
+			item.Author = append(item.Author, CSLName{Literal: name.Family})
+			continue
+		}
+		item.Author = append(item.Author, CSLName{Family: name.Family, Given: name.Given})
+	}
+	if book.Year != 0 {
+		item.Issued = &CSLDate{DateParts: [][]int{{book.Year}}}
+	}
+	return item
+}
+
+// WriteCSLJSON writes books as a CSL-JSON array.
+func WriteCSLJSON(w io.Writer, books []models.Book) error {
+	items := make([]Item, 0, len(books))
+	for _, book := range books {
+		items = append(items, CSLItem(book))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
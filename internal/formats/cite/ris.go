@@ -0,0 +1,46 @@
+package cite
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+// WriteRIS writes books as RIS records of type BOOK. Lines end with CRLF as the format
+// specifies.
+func WriteRIS(w io.Writer, books []models.Book) error {
+	for _, book := range books {
+		var tags [][2]string
+		tags = append(tags, [2]string{"TY", "BOOK"})
+		for _, name := range Authors(book) {
+			tags = append(tags, [2]string{"AU", name.Inverted()})
+		}
+		tags = append(tags,
+			[2]string{"TI", book.Lable},
+			[2]string{"ET", book.Edition},
+			[2]string{"PB", book.Publisher},
+			[2]string{"PY", optionalInt(book.Year)},
+			[2]string{"SN", book.ISBN},
+			[2]string{"T3", book.Series},
+			[2]string{"VL", optionalIndex(book.SeriesIndex)},
+			[2]string{"SP", optionalInt(book.Pages)},
+			[2]string{"LA", book.Language},
+		)
+		for _, tag := range book.Tags {
+			tags = append(tags, [2]string{"KW", tag})
+		}
+		for _, tag := range tags {
+			if tag[1] == "" {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s  - %s\r\n", tag[0], tag[1]); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "ER  - \r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
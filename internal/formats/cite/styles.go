@@ -0,0 +1,106 @@
+package cite
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+const (
+	// APA lists up to 20 authors; longer lists keep the first 19 and the last one.
+	apaMaxAuthors = 20
+	// Chicago lists up to 10 authors; longer lists keep the first 7 followed by "et al.".
+	chicagoMaxAuthors  = 10
+	chicagoKeptAuthors = 7
+)
+
+// APA formats book as a reference list entry in APA style (7th edition), as in
+// "Herbert, F. (1965). Dune (2nd ed.). Chilton Books.". Titles are not italicized since the
+// result is plain text.
+func APA(book models.Book) string {
+	var names []string
+	for _, name := range Authors(book) {
+		if initials := name.Initials(); initials != "" {
+			names = append(names, name.Family+", "+initials)
+		} else {
+			names = append(names, name.Family)
+		}
+	}
+	year := "n.d."
+	if book.Year != 0 {
+		year = strconv.Itoa(book.Year)
+	}
+	title := book.Lable
+	if ed := edition(book.Edition); ed != "" {
+		title += " (" + ed + ")"
+	}
+
+	var parts []string
+	if len(names) == 0 {
+		// Without an author the title takes its place.
+		parts = append(parts, sentence(title), "("+year+").")
+	} else {
+		parts = append(parts, sentence(apaAuthors(names)), "("+year+").", sentence(title))
+	}
+	if book.Publisher != "" {
+		parts = append(parts, sentence(book.Publisher))
+	}
+	return strings.Join(parts, " ")
+}
+
+func apaAuthors(names []string) string {
+	switch {
+	case len(names) == 1:
+		return names[0]
+	case len(names) > apaMaxAuthors:
+		return strings.Join(names[:apaMaxAuthors-1], ", ") + ", . . . " + names[len(names)-1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+}
+
+// Chicago formats book as a bibliography entry in Chicago notes and bibliography style
+// (17th edition), as in "Herbert, Frank. Dune. 2nd ed. Chilton Books, 1965.".
+func Chicago(book models.Book) string {
+	var parts []string
+	if authors := Authors(book); len(authors) != 0 {
+		parts = append(parts, sentence(chicagoAuthors(authors)))
+	}
+	parts = append(parts, sentence(book.Lable))
+	if ed := edition(book.Edition); ed != "" {
+		parts = append(parts, sentence(ed))
+	}
+	if book.Series != "" {
+		series := book.Series
+		if book.SeriesIndex != 0 {
+			series += " " + seriesIndex(book.SeriesIndex)
+		}
+		parts = append(parts, sentence(series))
+	}
+	var published []string
+	if book.Publisher != "" {
+		published = append(published, book.Publisher)
+	}
+	if book.Year != 0 {
+		published = append(published, strconv.Itoa(book.Year))
+	}
+	if len(published) != 0 {
+		parts = append(parts, sentence(strings.Join(published, ", ")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// chicagoAuthors inverts only the first name: "Herbert, Frank, and Kevin J. Anderson".
+func chicagoAuthors(authors []Name) string {
+	names := []string{authors[0].Inverted()}
+	for _, name := range authors[1:] {
+		names = append(names, name.String())
+	}
+	switch {
+	case len(names) == 1:
+		return names[0]
+	case len(names) > chicagoMaxAuthors:
+		return strings.Join(names[:chicagoKeptAuthors], ", ") + ", et al"
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
@@ -0,0 +1,139 @@
+package formats
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+const (
+	isbn10Len = 10
+	isbn13Len = 13
+)
+
+// Index finds books that are already in a library, either by ISBN or by normalized title and author.
+type Index struct {
+	isbns  map[string]string
+	titles map[string]string
+}
+
+func NewIndex(books []models.Book) *Index {
+	idx := &Index{
+		isbns:  make(map[string]string),
+		titles: make(map[string]string),
+	}
+	for _, book := range books {
+		idx.Add(book)
+	}
+	return idx
+}
+
+// Add records the book; its BID is what Find returns for later duplicates.
+func (idx *Index) Add(book models.Book) {
+	if isbn := NormalizeISBN(book.ISBN); isbn != "" {
+		idx.isbns[isbn] = book.BID
+	}
+	if key := titleKey(book); key != "" {
+		idx.titles[key] = book.BID
+	}
+}
+
+// Find reports whether a matching book was added and returns its BID.
+func (idx *Index) Find(book models.Book) (string, bool) {
+	if isbn := NormalizeISBN(book.ISBN); isbn != "" {
+		if bid, ok := idx.isbns[isbn]; ok {
+			return bid, true
+		}
+	}
+	bid, ok := idx.titles[titleKey(book)]
+	return bid, ok
+}
+
+func titleKey(book models.Book) string {
+	title := NormalizeTitle(book.Lable)
+	if title == "" {
+		return ""
+	}
+	return title + "|" + NormalizeAuthor(book.Author)
+}
+
+// NormalizeISBN returns the ISBN-13 form of an ISBN-10 or ISBN-13, ignoring hyphens, spaces
+// and spreadsheet quoting such as ="0345391802". It returns an empty string for invalid input.
+func NormalizeISBN(isbn string) string {
+	var digits []rune
+	for _, r := range strings.ToUpper(isbn) {
+		if unicode.IsDigit(r) || r == 'X' {
+			digits = append(digits, r)
+		}
+	}
+	switch len(digits) {
+	case isbn10Len:
+		if !validISBN10(digits) {
+			return ""
+		}
+		body := append([]rune("978"), digits[:9]...)
+		return string(append(body, isbn13Check(body)))
+	case isbn13Len:
+		if slices.Contains(digits, 'X') || isbn13Check(digits[:12]) != digits[12] {
+			return ""
+		}
+		return string(digits)
+	default:
+		return ""
+	}
+}
+
+// NormalizeTitle lowercases the title and drops punctuation, subtitles and the series
+// suffix Goodreads appends, e.g. "Dune (Dune Chronicles, #1)".
+func NormalizeTitle(title string) string {
+	if i := strings.LastIndex(title, " ("); i > 0 && strings.HasSuffix(title, ")") {
+		title = title[:i]
+	}
+	if i := strings.Index(title, ":"); i > 0 {
+		title = title[:i]
+	}
+	return strings.Join(words(title), " ")
+}
+
+// NormalizeAuthor lowercases the name and sorts its parts, so "Herbert, Frank" and
+// "Frank Herbert" compare equal.
+func NormalizeAuthor(author string) string {
+	parts := words(author)
+	slices.Sort(parts)
+	return strings.Join(parts, " ")
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func validISBN10(digits []rune) bool {
+	sum := 0
+	for i, r := range digits {
+		value := int(r - '0')
+		if r == 'X' {
+			if i != isbn10Len-1 {
+				return false
+			}
+			value = 10
+		}
+		sum += value * (isbn10Len - i)
+	}
+	return sum%11 == 0
+}
+
+func isbn13Check(body []rune) rune {
+	sum := 0
+	for i, r := range body {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(r-'0') * weight
+	}
+	return rune('0' + (10-sum%10)%10)
+}
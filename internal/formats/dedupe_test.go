@@ -0,0 +1,46 @@
+package formats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name string
+		isbn string
+		want string
+	}{
+		{name: "Test NormalizeISBN() func; Case 1:", isbn: "0-441-17271-7", want: "9780441172719"},
+		{name: "Test NormalizeISBN() func; Case 2:", isbn: `="9780441172719"`, want: "9780441172719"},
+		{name: "Test NormalizeISBN() func; Case 3:", isbn: "080442957X", want: "9780804429573"},
+		{name: "Test NormalizeISBN() func; Case 4:", isbn: "9780441172710", want: ""},
+		{name: "Test NormalizeISBN() func; Case 5:", isbn: "B00B7NPRY8", want: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NormalizeISBN(tc.isbn))
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	index := NewIndex([]models.Book{
+		{BID: "bid1", Lable: "The Left Hand of Darkness", Author: "Ursula K. Le Guin"},
+		{BID: "bid2", Lable: "Dune", Author: "Frank Herbert", ISBN: "0441172717"},
+	})
+
+	bid, ok := index.Find(models.Book{Lable: "The Left Hand of Darkness (Hainish Cycle, #4)", Author: "Le Guin, Ursula K."})
+	assert.True(t, ok)
+	assert.Equal(t, "bid1", bid)
+
+	bid, ok = index.Find(models.Book{Lable: "Dune: Deluxe Edition", Author: "Someone Else", ISBN: "978-0441172719"})
+	assert.True(t, ok)
+	assert.Equal(t, "bid2", bid)
+
+	_, ok = index.Find(models.Book{Lable: "Dune Messiah", Author: "Frank Herbert"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,91 @@
+// Package dublincore describes books as simple Dublin Core records in the oai_dc XML schema
+// that OAI-PMH harvesters and most catalog tools understand.
+package dublincore
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+const (
+	ContentType    = "application/xml"
+	Namespace      = "http://purl.org/dc/elements/1.1/"
+	OAINamespace   = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	SchemaLocation = OAINamespace + " http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+	xsiNamespace   = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// Record is an oai_dc:dc element. Every element may repeat, as simple Dublin Core allows.
+type Record struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	XmlnsOAI       string   `xml:"xmlns:oai_dc,attr"`
+	XmlnsDC        string   `xml:"xmlns:dc,attr"`
+	XmlnsXSI       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Titles         []string `xml:"dc:title"`
+	Creators       []string `xml:"dc:creator"`
+	Subjects       []string `xml:"dc:subject"`
+	Publishers     []string `xml:"dc:publisher"`
+	Dates          []string `xml:"dc:date"`
+	Types          []string `xml:"dc:type"`
+	Formats        []string `xml:"dc:format"`
+	Identifiers    []string `xml:"dc:identifier"`
+	Languages      []string `xml:"dc:language"`
+	Relations      []string `xml:"dc:relation"`
+}
+
+// FromBook maps book onto a Dublin Core record. The series goes into dc:relation and the page
+// count into dc:format, the element simple Dublin Core uses for extent.
+func FromBook(book models.Book) Record {
+	rec := Record{
+		XmlnsOAI:       OAINamespace,
+		XmlnsDC:        Namespace,
+		XmlnsXSI:       xsiNamespace,
+		SchemaLocation: SchemaLocation,
+		Titles:         []string{book.Lable},
+		Creators:       formats.SplitList(book.Author, "&"),
+		Subjects:       book.Tags,
+		Types:          []string{"Text"},
+		Identifiers:    []string{"urn:uuid:" + book.BID},
+	}
+	if book.Publisher != "" {
+		rec.Publishers = []string{book.Publisher}
+	}
+	if book.Year != 0 {
+		rec.Dates = []string{strconv.Itoa(book.Year)}
+	}
+	if book.Pages != 0 {
+		rec.Formats = []string{strconv.Itoa(book.Pages) + " pages"}
+	}
+	if book.ISBN != "" {
+		rec.Identifiers = append(rec.Identifiers, "urn:isbn:"+book.ISBN)
+	}
+	if book.Language != "" {
+		rec.Languages = []string{book.Language}
+	}
+	if book.Series != "" {
+		series := book.Series
+		if book.SeriesIndex != 0 {
+			series += " ; " + strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64)
+		}
+		rec.Relations = []string{series}
+	}
+	return rec
+}
+
+// Write encodes book as a standalone XML document.
+func Write(w io.Writer, book models.Book) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(FromBook(book)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,36 @@
+package dublincore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestWrite(t *testing.T) {
+	book := models.Book{
+		BID: "bid1", Lable: "Dune & Co", Author: "Frank Herbert", ISBN: "9780441172719", Publisher: "Chilton Books",
+		Year: 1965, Pages: 412, Language: "en", Series: "Dune", SeriesIndex: 1, Tags: []string{"sci-fi", "classic"},
+	}
+	var out strings.Builder
+	assert.NoError(t, Write(&out, book))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/" `+
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+		`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd">
+  <dc:title>Dune &amp; Co</dc:title>
+  <dc:creator>Frank Herbert</dc:creator>
+  <dc:subject>sci-fi</dc:subject>
+  <dc:subject>classic</dc:subject>
+  <dc:publisher>Chilton Books</dc:publisher>
+  <dc:date>1965</dc:date>
+  <dc:type>Text</dc:type>
+  <dc:format>412 pages</dc:format>
+  <dc:identifier>urn:uuid:bid1</dc:identifier>
+  <dc:identifier>urn:isbn:9780441172719</dc:identifier>
+  <dc:language>en</dc:language>
+  <dc:relation>Dune ; 1</dc:relation>
+</oai_dc:dc>`, out.String())
+}
@@ -0,0 +1,124 @@
+// Package epub reads the metadata and cover image of EPUB files.
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/opf"
+)
+
+const (
+	MediaType     = "application/epub+zip"
+	containerPath = "META-INF/container.xml"
+	maxXMLSize    = 4 << 20
+	maxCoverSize  = 10 << 20
+)
+
+var ErrNotEPUB = errors.New("not an epub file")
+
+// Metadata is what an EPUB tells about its book. Cover is empty if the book has none.
+type Metadata struct {
+	Title     string
+	Authors   []string
+	Language  string
+	ISBN      string
+	Cover     []byte
+	CoverType string
+}
+
+// Book maps the metadata onto a book; several authors are joined with " & ".
+func (m Metadata) Book() models.Book {
+	return models.Book{
+		Lable:    m.Title,
+		Author:   strings.Join(m.Authors, " & "),
+		Language: m.Language,
+		ISBN:     m.ISBN,
+	}
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// Read extracts the metadata of the EPUB in r, a file of the given size.
+func Read(r io.ReaderAt, size int64) (Metadata, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %w", ErrNotEPUB, err)
+	}
+	var c container
+	if err = decodeXML(archive, containerPath, &c); err != nil {
+		return Metadata{}, err
+	}
+	if len(c.Rootfiles) == 0 || c.Rootfiles[0].FullPath == "" {
+		return Metadata{}, fmt.Errorf("%w: no package document", ErrNotEPUB)
+	}
+	opfPath := c.Rootfiles[0].FullPath
+	file, err := archive.Open(opfPath)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %w", ErrNotEPUB, err)
+	}
+	defer file.Close()
+	pkg, err := opf.Parse(io.LimitReader(file, maxXMLSize))
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %w", ErrNotEPUB, err)
+	}
+
+	meta := Metadata{
+		Title:    pkg.Metadata.Title(),
+		Authors:  pkg.Metadata.Authors(),
+		Language: pkg.Metadata.Language(),
+		ISBN:     pkg.Metadata.ISBN(),
+	}
+	if item, ok := pkg.Cover(); ok && strings.HasPrefix(item.MediaType, "image/") {
+		// A missing or oversized cover is not worth failing the whole file for.
+		if cover, coverErr := readCover(archive, opfPath, item.Href); coverErr == nil {
+			meta.Cover, meta.CoverType = cover, item.MediaType
+		}
+	}
+	return meta, nil
+}
+
+func decodeXML(archive *zip.Reader, name string, v any) error {
+	file, err := archive.Open(name)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotEPUB, err)
+	}
+	defer file.Close()
+	if err = xml.NewDecoder(io.LimitReader(file, maxXMLSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotEPUB, err)
+	}
+	return nil
+}
+
+// readCover reads the manifest item href, which is relative to the package document.
+func readCover(archive *zip.Reader, opfPath, href string) ([]byte, error) {
+	href, err := url.PathUnescape(href)
+	if err != nil {
+		return nil, err
+	}
+	file, err := archive.Open(path.Join(path.Dir(opfPath), href))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxCoverSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCoverSize {
+		return nil, errors.New("cover image is too large")
+	}
+	return data, nil
+}
@@ -0,0 +1,108 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+const testPackage = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Dune</dc:title>
+    <dc:creator>Frank Herbert</dc:creator>
+    <dc:creator>Brian Herbert</dc:creator>
+    <dc:language>en</dc:language>
+    <dc:identifier id="id">urn:isbn:9780441172719</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="cover" href="images/cover%20art.jpg" media-type="image/jpeg" properties="cover-image"/>
+  </manifest>
+</package>`
+
+func buildEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	type want struct {
+		meta Metadata
+		err  error
+	}
+	type test struct {
+		name  string
+		files map[string]string
+		want  want
+	}
+	tests := []test{
+		{
+			name: "Test Read() func; Case 1:",
+			files: map[string]string{
+				"mimetype":                   MediaType,
+				containerPath:                testContainer,
+				"OEBPS/content.opf":          testPackage,
+				"OEBPS/images/cover art.jpg": "jpeg",
+			},
+			want: want{
+				meta: Metadata{
+					Title: "Dune", Authors: []string{"Frank Herbert", "Brian Herbert"}, Language: "en",
+					ISBN: "9780441172719", Cover: []byte("jpeg"), CoverType: "image/jpeg",
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 2:",
+			files: map[string]string{
+				containerPath:       testContainer,
+				"OEBPS/content.opf": testPackage,
+			},
+			want: want{
+				meta: Metadata{
+					Title: "Dune", Authors: []string{"Frank Herbert", "Brian Herbert"}, Language: "en",
+					ISBN: "9780441172719",
+				},
+			},
+		},
+		{
+			name:  "Test Read() func; Case 3:",
+			files: map[string]string{"mimetype": MediaType},
+			want:  want{err: ErrNotEPUB},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildEPUB(t, tc.files)
+			meta, err := Read(bytes.NewReader(data), int64(len(data)))
+			if tc.want.err != nil {
+				assert.ErrorIs(t, err, tc.want.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.meta, meta)
+			assert.Equal(t, "Frank Herbert & Brian Herbert", meta.Book().Author)
+		})
+	}
+
+	_, err := Read(bytes.NewReader([]byte("%PDF-1.7")), 8)
+	assert.ErrorIs(t, err, ErrNotEPUB)
+}
@@ -0,0 +1,150 @@
+// Package formats holds what the book import and export formats have in common.
+package formats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+const maxRating = 5
+
+// Row is a single parsed record. Line is the 1-based line number in the source file;
+// formats that are not line based set Ref to where the record came from instead.
+type Row struct {
+	Line   int
+	Ref    string
+	Book   models.Book
+	Errors []string
+}
+
+// Validate returns the problems that prevent a book from being imported.
+func Validate(book models.Book) []string {
+	var problems []string
+	if book.Lable == "" {
+		problems = append(problems, "lable is required")
+	}
+	if book.Author == "" {
+		problems = append(problems, "author is required")
+	}
+	if book.Rating < 0 || book.Rating > maxRating {
+		problems = append(problems, fmt.Sprintf("rating must be between 0 and %d", maxRating))
+	}
+	if book.Status != "" && !slices.Contains(Statuses(), book.Status) {
+		problems = append(problems, fmt.Sprintf("unknown reading status %q", book.Status))
+	}
+	if book.ISBN != "" && NormalizeISBN(book.ISBN) == "" {
+		problems = append(problems, fmt.Sprintf("invalid isbn %q", book.ISBN))
+	}
+	return problems
+}
+
+// Statuses lists the reading statuses a book may have.
+func Statuses() []string {
+	return []string{models.StatusToRead, models.StatusReading, models.StatusRead, models.StatusDidNotFinish}
+}
+
+// Record is a CSV record whose fields are looked up by header name.
+type Record struct {
+	Line    int
+	columns map[string]int
+	values  []string
+}
+
+// Get returns the trimmed value of the named column, or an empty string if there is none.
+func (r Record) Get(column string) string {
+	idx, ok := r.columns[strings.ToLower(column)]
+	if !ok || idx >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[idx])
+}
+
+// ReadTable reads a CSV file with a header row and turns every record into a book with parse.
+// The columns listed in required must be present in the header.
+func ReadTable(r io.Reader, required []string, parse func(Record) models.Book) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv file is empty")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for idx, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if _, ok := columns[column]; !ok {
+			columns[column] = idx
+		}
+	}
+	for _, column := range required {
+		if _, ok := columns[strings.ToLower(column)]; !ok {
+			return nil, fmt.Errorf("column %q not found in header", column)
+		}
+	}
+
+	var rows []Row
+	for {
+		values, readErr := reader.Read()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(readErr, &parseErr) {
+				return nil, readErr
+			}
+			rows = append(rows, Row{Line: parseErr.StartLine, Errors: []string{parseErr.Err.Error()}})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		book := parse(Record{Line: line, columns: columns, values: values})
+		rows = append(rows, Row{Line: line, Book: book, Errors: Validate(book)})
+	}
+	return rows, nil
+}
+
+// DateLayout is the layout dates are exported with.
+const DateLayout = time.DateOnly
+
+// ParseDate parses dates as written by this service and by the Goodreads and StoryGraph exports.
+func ParseDate(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil //nolint: nilnil //an empty cell means no date
+	}
+	for _, layout := range []string{DateLayout, "2006/01/02", "2006/1/2", "01/02/2006", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q", s)
+}
+
+// FormatDate is the inverse of ParseDate; nil dates are formatted as an empty string.
+func FormatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(DateLayout)
+}
+
+// SplitList splits a cell holding several values such as shelves, dropping empty items.
+func SplitList(s string, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,53 @@
+// Package goodreads reads the library export produced by Goodreads
+// (My Books -> Import and export -> Export library).
+package goodreads
+
+import (
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+const (
+	colTitle          = "Title"
+	colAuthor         = "Author"
+	colISBN           = "ISBN"
+	colISBN13         = "ISBN13"
+	colMyRating       = "My Rating"
+	colDateRead       = "Date Read"
+	colBookshelves    = "Bookshelves"
+	colExclusiveShelf = "Exclusive Shelf"
+)
+
+// Read parses a Goodreads export. The exclusive shelf becomes the reading status and the
+// remaining bookshelves become shelves.
+func Read(r io.Reader) ([]formats.Row, error) {
+	return formats.ReadTable(r, []string{colTitle, colAuthor, colExclusiveShelf}, parse)
+}
+
+func parse(rec formats.Record) models.Book {
+	book := models.Book{
+		Lable:  rec.Get(colTitle),
+		Author: rec.Get(colAuthor),
+		ISBN:   formats.NormalizeISBN(rec.Get(colISBN13)),
+		Status: rec.Get(colExclusiveShelf),
+	}
+	if book.ISBN == "" {
+		book.ISBN = formats.NormalizeISBN(rec.Get(colISBN))
+	}
+	if rating, err := strconv.ParseFloat(rec.Get(colMyRating), 64); err == nil {
+		book.Rating = rating
+	}
+	for _, shelf := range formats.SplitList(rec.Get(colBookshelves), ",") {
+		if shelf != book.Status && !slices.Contains(formats.Statuses(), shelf) {
+			book.Shelves = append(book.Shelves, shelf)
+		}
+	}
+	// Goodreads does not export a start date; an unparsable date is dropped rather than
+	// failing the row, as the export sometimes holds partial dates.
+	book.DateRead, _ = formats.ParseDate(rec.Get(colDateRead))
+	return book
+}
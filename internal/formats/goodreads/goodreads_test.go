@@ -0,0 +1,81 @@
+package goodreads
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+const header = "Book Id,Title,Author,ISBN,ISBN13,My Rating,Date Read,Bookshelves,Exclusive Shelf\n"
+
+func TestRead(t *testing.T) {
+	read := time.Date(2023, time.February, 10, 0, 0, 0, 0, time.UTC)
+	type want struct {
+		err  bool
+		rows []formats.Row
+	}
+	type test struct {
+		name string
+		data string
+		want want
+	}
+	tests := []test{
+		{
+			name: "Test Read() func; Case 1:",
+			data: header + `1,Dune (Dune Chronicles #1),Frank Herbert,"=""0441013597""","=""9780441013593""",5,` +
+				`2023/02/10,"sci-fi, read, favorites",read` + "\n",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Book: models.Book{
+						Lable: "Dune (Dune Chronicles #1)", Author: "Frank Herbert", ISBN: "9780441013593", Rating: 5,
+						Status: models.StatusRead, Shelves: []string{"sci-fi", "favorites"}, DateRead: &read,
+					}},
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 2:",
+			data: header + `2,Solaris,Stanislaw Lem,"=""0156027607""","=""""",0,,to-read,to-read` + "\n",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Book: models.Book{
+						Lable: "Solaris", Author: "Stanislaw Lem", ISBN: "9780156027601", Status: models.StatusToRead,
+					}},
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 3:",
+			data: header + `3,Emma,Jane Austen,,,7,2023/13/45,,owned` + "\n",
+			want: want{
+				rows: []formats.Row{
+					{Line: 2, Book: models.Book{Lable: "Emma", Author: "Jane Austen", Rating: 7, Status: "owned"},
+						Errors: []string{"rating must be between 0 and 5", "unknown reading status \"owned\""}},
+				},
+			},
+		},
+		{
+			name: "Test Read() func; Case 4:",
+			data: "Title,Author\nDune,Frank Herbert\n",
+			want: want{
+				err: true,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, err := Read(strings.NewReader(tc.data))
+			if tc.want.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.rows, rows)
+		})
+	}
+}
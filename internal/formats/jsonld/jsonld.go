@@ -0,0 +1,96 @@
+// Package jsonld describes books as JSON-LD using the schema.org Book vocabulary, the form
+// search engines and web catalogs read embedded in pages.
+package jsonld
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+const (
+	ContentType   = "application/ld+json"
+	schemaContext = "https://schema.org"
+)
+
+// Book is a schema.org Book.
+type Book struct {
+	Context       string   `json:"@context"`
+	Type          string   `json:"@type"`
+	ID            string   `json:"@id"`
+	Name          string   `json:"name"`
+	Author        []Thing  `json:"author,omitempty"`
+	ISBN          string   `json:"isbn,omitempty"`
+	BookEdition   string   `json:"bookEdition,omitempty"`
+	Publisher     *Thing   `json:"publisher,omitempty"`
+	DatePublished string   `json:"datePublished,omitempty"`
+	NumberOfPages int      `json:"numberOfPages,omitempty"`
+	InLanguage    string   `json:"inLanguage,omitempty"`
+	Keywords      []string `json:"keywords,omitempty"`
+	IsPartOf      *Thing   `json:"isPartOf,omitempty"`
+	Position      string   `json:"position,omitempty"`
+	Image         string   `json:"image,omitempty"`
+	DateCreated   string   `json:"dateCreated,omitempty"`
+	DateModified  string   `json:"dateModified,omitempty"`
+}
+
+// Thing is a named node such as a Person, an Organization or a BookSeries.
+type Thing struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// FromBook maps book onto a schema.org Book. Its node id is the book's UUID URN, the same id
+// the OPDS catalog uses.
+func FromBook(book models.Book) Book {
+	doc := Book{
+		Context:       schemaContext,
+		Type:          "Book",
+		ID:            "urn:uuid:" + book.BID,
+		Name:          book.Lable,
+		ISBN:          book.ISBN,
+		BookEdition:   book.Edition,
+		NumberOfPages: book.Pages,
+		InLanguage:    book.Language,
+		Keywords:      book.Tags,
+		DateCreated:   formatTime(book.CreatedAt),
+		DateModified:  formatTime(book.UpdatedAt),
+	}
+	for _, author := range formats.SplitList(book.Author, "&") {
+		doc.Author = append(doc.Author, Thing{Type: "Person", Name: author})
+	}
+	if book.Publisher != "" {
+		doc.Publisher = &Thing{Type: "Organization", Name: book.Publisher}
+	}
+	if book.Year != 0 {
+		doc.DatePublished = strconv.Itoa(book.Year)
+	}
+	if book.Series != "" {
+		doc.IsPartOf = &Thing{Type: "BookSeries", Name: book.Series}
+		if book.SeriesIndex != 0 {
+			doc.Position = strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64)
+		}
+	}
+	if book.Covers != nil {
+		doc.Image = book.Covers.Original
+	}
+	return doc
+}
+
+// Write encodes book as a JSON-LD document.
+func Write(w io.Writer, book models.Book) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(FromBook(book))
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
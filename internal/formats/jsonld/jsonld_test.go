@@ -0,0 +1,44 @@
+package jsonld
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestWrite(t *testing.T) {
+	created := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	book := models.Book{
+		BID: "bid1", Lable: "Good Omens", Author: "Terry Pratchett & Neil Gaiman", ISBN: "9780060853983",
+		Publisher: "Gollancz", Year: 1990, Pages: 288, Language: "en", Series: "Discworld", SeriesIndex: 2.5,
+		Tags: []string{"fantasy"}, CreatedAt: &created, Covers: &models.Covers{Original: "/books/bid1/cover/abc/original"},
+	}
+	var out strings.Builder
+	assert.NoError(t, Write(&out, book))
+	assert.JSONEq(t, `{
+  "@context": "https://schema.org",
+  "@type": "Book",
+  "@id": "urn:uuid:bid1",
+  "name": "Good Omens",
+  "author": [{"@type": "Person", "name": "Terry Pratchett"}, {"@type": "Person", "name": "Neil Gaiman"}],
+  "isbn": "9780060853983",
+  "publisher": {"@type": "Organization", "name": "Gollancz"},
+  "datePublished": "1990",
+  "numberOfPages": 288,
+  "inLanguage": "en",
+  "keywords": ["fantasy"],
+  "isPartOf": {"@type": "BookSeries", "name": "Discworld"},
+  "position": "2.5",
+  "image": "/books/bid1/cover/abc/original",
+  "dateCreated": "2024-05-01T07:00:00Z"
+}`, out.String())
+
+	out.Reset()
+	assert.NoError(t, Write(&out, models.Book{BID: "bid2", Lable: "Untitled"}))
+	assert.JSONEq(t, `{"@context": "https://schema.org", "@type": "Book", "@id": "urn:uuid:bid2", "name": "Untitled"}`,
+		out.String())
+}
@@ -0,0 +1,146 @@
+// Package kindle parses the "My Clippings.txt" file Kindle devices keep in their documents folder.
+package kindle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+const separator = "=========="
+
+var ( //nolint: gochecknoglobals //compiled once
+	metaRe     = regexp.MustCompile(`(?i)^-\s*(?:your\s+)?(highlight|note|bookmark)\b(.*)$`)
+	pageRe     = regexp.MustCompile(`(?i)\bpage\s+(\d+)`)
+	locationRe = regexp.MustCompile(`(?i)\b(?:location|loc\.)\s+([\d-]+)`)
+	addedRe    = regexp.MustCompile(`(?i)\badded on\s+(.+)$`)
+)
+
+var dateLayouts = []string{ //nolint: gochecknoglobals //date layouts of the US and international firmware
+	"Monday, January 2, 2006 3:04:05 PM",
+	"Monday, 2 January 2006 15:04:05",
+	"Monday, January 2, 2006, 3:04 PM",
+	"Monday, 2 January 06 15:04:05",
+}
+
+// Clipping is one entry of the clippings file. Line is the line its title is on.
+type Clipping struct {
+	Line     int
+	Title    string
+	Author   string
+	Kind     string
+	Location string
+	Page     int
+	AddedAt  *time.Time
+	Text     string
+	Errors   []string
+}
+
+// Parse reads all clippings from r. Entries that cannot be understood are returned with Errors set.
+func Parse(r io.Reader) ([]Clipping, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) //nolint: gomnd //long highlights
+	var clippings []Clipping
+	var entry []string
+	start, line := 1, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r")
+		if strings.TrimSpace(text) == separator {
+			if clipping, ok := parseEntry(start, entry); ok {
+				clippings = append(clippings, clipping)
+			}
+			entry = entry[:0]
+			start = line + 1
+			continue
+		}
+		entry = append(entry, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if clipping, ok := parseEntry(start, entry); ok {
+		clippings = append(clippings, clipping)
+	}
+	if len(clippings) == 0 {
+		return nil, errors.New("no clippings found")
+	}
+	return clippings, nil
+}
+
+func parseEntry(start int, lines []string) (Clipping, bool) {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+		start++
+	}
+	if len(lines) == 0 {
+		return Clipping{}, false
+	}
+	clipping := Clipping{Line: start}
+	clipping.Title, clipping.Author = splitTitle(strings.TrimSpace(lines[0]))
+	if len(lines) < 2 { //nolint: gomnd //title and metadata lines
+		clipping.Errors = []string{"missing clipping details"}
+		return clipping, true
+	}
+	meta := metaRe.FindStringSubmatch(strings.TrimSpace(lines[1]))
+	if meta == nil {
+		clipping.Errors = []string{fmt.Sprintf("unrecognized clipping details %q", strings.TrimSpace(lines[1]))}
+		return clipping, true
+	}
+	clipping.Kind = strings.ToLower(meta[1])
+	details := meta[2]
+	if m := pageRe.FindStringSubmatch(details); m != nil {
+		clipping.Page, _ = strconv.Atoi(m[1])
+	}
+	if m := locationRe.FindStringSubmatch(details); m != nil {
+		clipping.Location = m[1]
+	}
+	if m := addedRe.FindStringSubmatch(details); m != nil {
+		clipping.AddedAt = parseDate(strings.TrimSpace(m[1]))
+	}
+	clipping.Text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	if clipping.Title == "" {
+		clipping.Errors = append(clipping.Errors, "missing book title")
+	}
+	if clipping.Kind != models.HighlightBookmark && clipping.Text == "" {
+		clipping.Errors = append(clipping.Errors, "empty "+clipping.Kind)
+	}
+	return clipping, true
+}
+
+// splitTitle separates "Title (Author)"; the author is the last parenthesized group,
+// so titles carrying a series such as "Dune (Dune Chronicles, #1) (Frank Herbert)" work.
+func splitTitle(s string) (string, string) {
+	if !strings.HasSuffix(s, ")") {
+		return s, ""
+	}
+	depth := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1 : len(s)-1])
+			}
+		}
+	}
+	return s, ""
+}
+
+func parseDate(s string) *time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package kindle
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClippings = "\ufeff" +
+	"Dune (Dune Chronicles, #1) (Frank Herbert)\r\n" +
+	"- Your Highlight on page 12 | Location 170-172 | Added on Saturday, March 5, 2022 10:15:32 PM\r\n" +
+	"\r\n" +
+	"I must not fear. Fear is the mind-killer.\r\n" +
+	"==========\r\n" +
+	"Dune (Dune Chronicles, #1) (Frank Herbert)\r\n" +
+	"- Your Note on Location 172 | Added on Saturday, 5 March 2022 22:16:01\r\n" +
+	"\r\n" +
+	"Litany against fear\r\n" +
+	"==========\r\n" +
+	"Notes on a Nervous Planet\r\n" +
+	"- Your Bookmark on page 5 | Location 60 | Added on Sunday, March 6, 2022 9:00:00 AM\r\n" +
+	"\r\n" +
+	"\r\n" +
+	"==========\r\n" +
+	"Broken entry\r\n" +
+	"- Something else entirely\r\n" +
+	"==========\r\n"
+
+func TestParse(t *testing.T) {
+	clippings, err := Parse(strings.NewReader(testClippings))
+	require.NoError(t, err)
+	require.Len(t, clippings, 4)
+
+	added := time.Date(2022, time.March, 5, 22, 15, 32, 0, time.UTC)
+	assert.Equal(t, Clipping{
+		Line:     1,
+		Title:    "Dune (Dune Chronicles, #1)",
+		Author:   "Frank Herbert",
+		Kind:     "highlight",
+		Location: "170-172",
+		Page:     12,
+		AddedAt:  &added,
+		Text:     "I must not fear. Fear is the mind-killer.",
+	}, clippings[0])
+
+	assert.Equal(t, "note", clippings[1].Kind)
+	assert.Equal(t, "172", clippings[1].Location)
+	require.NotNil(t, clippings[1].AddedAt)
+	assert.Equal(t, 16, clippings[1].AddedAt.Minute())
+
+	assert.Equal(t, "bookmark", clippings[2].Kind)
+	assert.Equal(t, "Notes on a Nervous Planet", clippings[2].Title)
+	assert.Empty(t, clippings[2].Author)
+	assert.Empty(t, clippings[2].Errors)
+
+	assert.Equal(t, 16, clippings[3].Line)
+	assert.NotEmpty(t, clippings[3].Errors)
+
+	_, err = Parse(strings.NewReader(""))
+	assert.Error(t, err)
+}
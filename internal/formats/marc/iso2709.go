@@ -0,0 +1,173 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+// ISO 2709 structure.
+const (
+	ContentType = "application/marc"
+
+	fieldTerminator  = 0x1E
+	recordTerminator = 0x1D
+	subfieldDelim    = 0x1F
+	leaderLen        = 24
+	entryLen         = 12
+	maxFieldLen      = 9999
+	maxRecordLen     = 99999
+)
+
+var (
+	ErrRecordTooLong = errors.New("marc record is too long")
+	ErrBadRecord     = errors.New("malformed marc record")
+)
+
+// MarshalBinary encodes the record in the ISO 2709 exchange format.
+func (r Record) MarshalBinary() ([]byte, error) {
+	var directory, data bytes.Buffer
+	for _, f := range r.Fields {
+		start := data.Len()
+		if f.IsControl() {
+			data.WriteString(f.Value)
+		} else {
+			data.WriteByte(indicator(f.Ind1))
+			data.WriteByte(indicator(f.Ind2))
+			for _, sf := range f.Subfields {
+				data.WriteByte(subfieldDelim)
+				data.WriteByte(sf.Code)
+				data.WriteString(sf.Value)
+			}
+		}
+		data.WriteByte(fieldTerminator)
+		length := data.Len() - start
+		if len(f.Tag) != 3 || length > maxFieldLen { //nolint: gomnd //tags have three characters
+			return nil, fmt.Errorf("field %s: %w", f.Tag, ErrRecordTooLong)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", f.Tag, length, start)
+	}
+	directory.WriteByte(fieldTerminator)
+	base := leaderLen + directory.Len()
+	length := base + data.Len() + 1
+	if length > maxRecordLen {
+		return nil, ErrRecordTooLong
+	}
+
+	out := make([]byte, 0, length)
+	out = append(out, leader(length, base)...)
+	out = append(out, directory.Bytes()...)
+	out = append(out, data.Bytes()...)
+	return append(out, recordTerminator), nil
+}
+
+// UnmarshalBinary decodes one ISO 2709 record, including its record terminator.
+func (r *Record) UnmarshalBinary(data []byte) error {
+	data = bytes.TrimSuffix(data, []byte{recordTerminator})
+	if len(data) < leaderLen {
+		return ErrBadRecord
+	}
+	base, err := strconv.Atoi(string(data[12:17]))
+	if err != nil || base <= leaderLen || base > len(data) || (base-leaderLen-1)%entryLen != 0 {
+		return ErrBadRecord
+	}
+	rec := Record{Leader: string(data[:leaderLen])}
+	directory := data[leaderLen : base-1]
+	for i := 0; i < len(directory); i += entryLen {
+		entry := directory[i : i+entryLen]
+		length, lenErr := strconv.Atoi(string(entry[3:7]))
+		start, startErr := strconv.Atoi(string(entry[7:12]))
+		if lenErr != nil || startErr != nil || start < 0 || length <= 0 || base+start+length > len(data) {
+			return ErrBadRecord
+		}
+		rec.Fields = append(rec.Fields, parseField(string(entry[:3]),
+			bytes.TrimSuffix(data[base+start:base+start+length], []byte{fieldTerminator})))
+	}
+	*r = rec
+	return nil
+}
+
+func parseField(tag string, body []byte) Field {
+	f := Field{Tag: tag}
+	if f.IsControl() {
+		f.Value = string(body)
+		return f
+	}
+	f.Ind1, f.Ind2 = ' ', ' '
+	if len(body) >= 2 { //nolint: gomnd //two indicators
+		f.Ind1, f.Ind2 = body[0], body[1]
+		body = body[2:]
+	}
+	for i, part := range bytes.Split(body, []byte{subfieldDelim}) {
+		if i == 0 || len(part) == 0 {
+			continue
+		}
+		f.Subfields = append(f.Subfields, Subfield{Code: part[0], Value: string(part[1:])})
+	}
+	return f
+}
+
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
+
+// Writer writes books as a stream of ISO 2709 records.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(book models.Book) error {
+	data, err := FromBook(book).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(data)
+	return err
+}
+
+// Read parses a file of ISO 2709 records. Rows refer to records by their position in the file.
+func Read(r io.Reader) ([]formats.Row, error) {
+	reader := bufio.NewReader(r)
+	var rows []formats.Row
+	for n := 1; ; n++ {
+		data, err := reader.ReadBytes(recordTerminator)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if data = bytes.TrimLeft(data, " \r\n"); len(data) != 0 {
+			rows = append(rows, recordRow(n, data))
+		}
+		if err != nil {
+			break
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("marc file is empty")
+	}
+	return rows, nil
+}
+
+func recordRow(n int, data []byte) formats.Row {
+	row := formats.Row{Ref: "record " + strconv.Itoa(n)}
+	var rec Record
+	if err := rec.UnmarshalBinary(data); err != nil {
+		row.Errors = []string{err.Error()}
+		return row
+	}
+	row.Book = rec.Book()
+	row.Errors = formats.Validate(row.Book)
+	return row
+}
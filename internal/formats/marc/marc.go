@@ -0,0 +1,228 @@
+// Package marc reads and writes books as MARC 21 bibliographic records, both in the ISO 2709
+// exchange format and as MARCXML.
+//
+// Only the fields that map onto models.Book are used: 001 (book id), 020 (ISBN), 100/700
+// (authors), 245 (title), 250 (edition), 260/264 (place, publisher and year), 300 (pages),
+// 490 (series) and 650 (tags).
+package marc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+// authorSep joins several authors of a book, the same way the Calibre importer does.
+const authorSep = " & "
+
+var ( //nolint: gochecknoglobals //compiled once
+	yearRe   = regexp.MustCompile(`\d{4}`)
+	pagesRe  = regexp.MustCompile(`(?i)(\d+)\s*(?:pages?|pp?\b)`)
+	numberRe = regexp.MustCompile(`\d+(?:\.\d+)?`)
+)
+
+// Record is a MARC record. Control fields (tags 001 to 009) carry a Value, data fields carry
+// indicators and subfields.
+type Record struct {
+	Leader string
+	Fields []Field
+}
+
+type Field struct {
+	Tag       string
+	Ind1      byte
+	Ind2      byte
+	Value     string
+	Subfields []Subfield
+}
+
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// IsControl reports whether f is a control field.
+func (f Field) IsControl() bool {
+	return strings.HasPrefix(f.Tag, "00")
+}
+
+// Subfield returns the first subfield of f with the given code.
+func (f Field) Subfield(code byte) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+// Field returns the first field with the given tag.
+func (r Record) Field(tag string) (Field, bool) {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// FromBook builds the MARC record of book.
+func FromBook(book models.Book) Record {
+	rec := Record{Leader: leader(0, 0)}
+	if book.BID != "" {
+		rec.Fields = append(rec.Fields, Field{Tag: "001", Value: book.BID})
+	}
+	if book.ISBN != "" {
+		rec.Fields = append(rec.Fields, dataField("020", ' ', ' ', 'a', book.ISBN))
+	}
+	authors := formats.SplitList(book.Author, strings.TrimSpace(authorSep))
+	if len(authors) != 0 {
+		rec.Fields = append(rec.Fields, dataField("100", '1', ' ', 'a', invertName(authors[0])))
+	}
+	title := Field{Tag: "245", Ind1: '0', Ind2: '0', Subfields: []Subfield{{Code: 'a', Value: book.Lable}}}
+	if len(authors) != 0 {
+		title.Ind1 = '1'
+		title.Subfields = append(title.Subfields, Subfield{Code: 'c', Value: book.Author})
+	}
+	rec.Fields = append(rec.Fields, title)
+	if book.Edition != "" {
+		rec.Fields = append(rec.Fields, dataField("250", ' ', ' ', 'a', book.Edition))
+	}
+	if book.Place != "" || book.Publisher != "" || book.Year != 0 {
+		publication := Field{Tag: "260", Ind1: ' ', Ind2: ' '}
+		if book.Place != "" {
+			publication.Subfields = append(publication.Subfields, Subfield{Code: 'a', Value: book.Place})
+		}
+		if book.Publisher != "" {
+			publication.Subfields = append(publication.Subfields, Subfield{Code: 'b', Value: book.Publisher})
+		}
+		if book.Year != 0 {
+			publication.Subfields = append(publication.Subfields, Subfield{Code: 'c', Value: strconv.Itoa(book.Year)})
+		}
+		rec.Fields = append(rec.Fields, publication)
+	}
+	if book.Pages != 0 {
+		rec.Fields = append(rec.Fields, dataField("300", ' ', ' ', 'a', strconv.Itoa(book.Pages)+" pages"))
+	}
+	if book.Series != "" {
+		series := dataField("490", '0', ' ', 'a', book.Series)
+		if book.SeriesIndex != 0 {
+			series.Subfields = append(series.Subfields, Subfield{
+				Code: 'v', Value: strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64),
+			})
+		}
+		rec.Fields = append(rec.Fields, series)
+	}
+	for _, tag := range book.Tags {
+		rec.Fields = append(rec.Fields, dataField("650", ' ', '4', 'a', tag))
+	}
+	for _, author := range authors[min(1, len(authors)):] {
+		rec.Fields = append(rec.Fields, dataField("700", '1', ' ', 'a', invertName(author)))
+	}
+	return rec
+}
+
+// Book maps the record onto a book. ISBD punctuation at the end of subfields is dropped.
+func (r Record) Book() models.Book {
+	var book models.Book
+	var authors []string
+	for _, f := range r.Fields {
+		switch f.Tag {
+		case "001":
+			book.BID = strings.TrimSpace(f.Value)
+		case "020":
+			if isbn := strings.Fields(f.Subfield('a')); book.ISBN == "" && len(isbn) != 0 {
+				book.ISBN = isbn[0]
+			}
+		case "100", "110", "700", "710":
+			if name := clean(f.Subfield('a')); name != "" {
+				if f.Ind1 == '1' && (f.Tag == "100" || f.Tag == "700") {
+					name = uninvertName(name)
+				}
+				authors = append(authors, name)
+			}
+		case "245":
+			book.Lable = clean(f.Subfield('a'))
+			if subtitle := clean(f.Subfield('b')); subtitle != "" {
+				book.Lable += ": " + subtitle
+			}
+		case "250":
+			book.Edition = clean(f.Subfield('a'))
+		case "260", "264":
+			if f.Tag == "264" && f.Ind2 != '1' {
+				continue
+			}
+			if book.Place == "" {
+				book.Place = clean(f.Subfield('a'))
+			}
+			if book.Publisher == "" {
+				book.Publisher = clean(f.Subfield('b'))
+			}
+			if year := yearRe.FindString(f.Subfield('c')); book.Year == 0 && year != "" {
+				book.Year, _ = strconv.Atoi(year)
+			}
+		case "300":
+			if m := pagesRe.FindStringSubmatch(f.Subfield('a')); m != nil {
+				book.Pages, _ = strconv.Atoi(m[1])
+			}
+		case "490":
+			book.Series = clean(f.Subfield('a'))
+			if idx := numberRe.FindString(f.Subfield('v')); idx != "" {
+				book.SeriesIndex, _ = strconv.ParseFloat(idx, 64)
+			}
+		case "650":
+			if tag := clean(f.Subfield('a')); tag != "" {
+				book.Tags = append(book.Tags, tag)
+			}
+		}
+	}
+	book.Author = strings.Join(authors, authorSep)
+	return book
+}
+
+// leader returns a leader for a book ("nam") in Unicode with ISBD punctuation omitted.
+func leader(length, base int) string {
+	return fmt.Sprintf("%05dnam a22%05d7c 4500", length, base)
+}
+
+func dataField(tag string, ind1, ind2, code byte, value string) Field {
+	return Field{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: []Subfield{{Code: code, Value: value}}}
+}
+
+// invertName turns "Frank Herbert" into the "Herbert, Frank" form MARC uses for personal names.
+func invertName(name string) string {
+	if strings.Contains(name, ",") {
+		return name
+	}
+	idx := strings.LastIndex(name, " ")
+	if idx < 0 {
+		return name
+	}
+	return name[idx+1:] + ", " + name[:idx]
+}
+
+func uninvertName(name string) string {
+	surname, forename, ok := strings.Cut(name, ",")
+	if !ok {
+		return name
+	}
+	return strings.TrimSpace(strings.TrimSpace(forename) + " " + strings.TrimSpace(surname))
+}
+
+// clean strips the ISBD punctuation that separates subfields. A final period is kept after
+// an initial, as in "Herbert, Frank H.".
+func clean(s string) string {
+	s = strings.TrimRight(strings.TrimSpace(s), " /:;,=")
+	if strings.HasSuffix(s, ".") {
+		body := s[:len(s)-1]
+		if word := body[strings.LastIndex(body, " ")+1:]; utf8.RuneCountInString(word) != 1 {
+			s = body
+		}
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,150 @@
+package marc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func testBook() models.Book {
+	return models.Book{
+		BID:         "bid1",
+		Lable:       "Dune",
+		Author:      "Frank Herbert & Brian Herbert",
+		ISBN:        "9780441172719",
+		Edition:     "40th anniversary ed",
+		Publisher:   "Ace Books",
+		Place:       "New York",
+		Year:        2005,
+		Pages:       528,
+		Series:      "Dune Chronicles",
+		SeriesIndex: 1,
+		Tags:        []string{"Science fiction", "Deserts"},
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	require.NoError(t, writer.Write(testBook()))
+	require.NoError(t, writer.Write(models.Book{Lable: "Solaris", Author: "Stanislaw Lem"}))
+
+	data := buf.Bytes()
+	assert.Equal(t, "nam a22", string(data[5:12]))
+	assert.Equal(t, byte(recordTerminator), data[len(data)-1])
+
+	rows, err := Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "record 1", rows[0].Ref)
+	assert.Empty(t, rows[0].Errors)
+	assert.Equal(t, testBook(), rows[0].Book)
+	assert.Equal(t, models.Book{Lable: "Solaris", Author: "Stanislaw Lem"}, rows[1].Book)
+}
+
+func TestReadBadRecord(t *testing.T) {
+	rows, err := Read(strings.NewReader("00042nam a22\x1d"))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, []string{ErrBadRecord.Error()}, rows[0].Errors)
+
+	_, err = Read(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestReadMalformedDirectory(t *testing.T) {
+	record, err := FromBook(testBook()).MarshalBinary()
+	require.NoError(t, err)
+	type test struct {
+		name  string
+		pos   int
+		value string
+	}
+	tests := []test{
+		{name: "Test malformed directory; Case 1:", pos: leaderLen + 3, value: "-962"},
+		{name: "Test malformed directory; Case 2:", pos: leaderLen + 7, value: "-0001"},
+		{name: "Test malformed directory; Case 3:", pos: leaderLen + 3, value: "0000"},
+		{name: "Test malformed directory; Case 4:", pos: leaderLen + 7, value: "99999"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data := bytes.Clone(record)
+			copy(data[tc.pos:], tc.value)
+			rows, err := Read(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Equal(t, []string{ErrBadRecord.Error()}, rows[0].Errors)
+		})
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewXMLWriter(&buf)
+	require.NoError(t, writer.Write(testBook()))
+	require.NoError(t, writer.Close())
+
+	rows, err := ReadXML(&buf)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, testBook(), rows[0].Book)
+}
+
+func TestReadXMLCatalogRecord(t *testing.T) {
+	const record = `<?xml version="1.0" encoding="UTF-8"?>
+<marc:record xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:leader>01142cam  2200301 a 4500</marc:leader>
+  <marc:controlfield tag="001">   92005291 </marc:controlfield>
+  <marc:datafield tag="020" ind1=" " ind2=" ">
+    <marc:subfield code="a">0-441-17271-7 (pbk.) :</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="100" ind1="1" ind2=" ">
+    <marc:subfield code="a">Le Guin, Ursula K.,</marc:subfield>
+    <marc:subfield code="d">1929-2018.</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="245" ind1="1" ind2="4">
+    <marc:subfield code="a">The left hand of darkness :</marc:subfield>
+    <marc:subfield code="b">a novel /</marc:subfield>
+    <marc:subfield code="c">Ursula K. Le Guin.</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="250" ind1=" " ind2=" ">
+    <marc:subfield code="a">1st ed.</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="264" ind1=" " ind2="1">
+    <marc:subfield code="a">New York :</marc:subfield>
+    <marc:subfield code="b">Ace Books,</marc:subfield>
+    <marc:subfield code="c">[1969]</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="300" ind1=" " ind2=" ">
+    <marc:subfield code="a">xii, 286 p. ;</marc:subfield>
+    <marc:subfield code="c">18 cm.</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="490" ind1="1" ind2=" ">
+    <marc:subfield code="a">Hainish cycle ;</marc:subfield>
+    <marc:subfield code="v">v. 4</marc:subfield>
+  </marc:datafield>
+</marc:record>`
+
+	rows, err := ReadXML(strings.NewReader(record))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, models.Book{
+		BID:         "92005291",
+		Lable:       "The left hand of darkness: a novel",
+		Author:      "Ursula K. Le Guin",
+		ISBN:        "0-441-17271-7",
+		Edition:     "1st ed",
+		Publisher:   "Ace Books",
+		Place:       "New York",
+		Year:        1969,
+		Pages:       286,
+		Series:      "Hainish cycle",
+		SeriesIndex: 4,
+	}, rows[0].Book)
+	assert.Empty(t, rows[0].Errors)
+}
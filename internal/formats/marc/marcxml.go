@@ -0,0 +1,154 @@
+package marc
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+const (
+	XMLContentType = "application/marcxml+xml"
+	xmlNamespace   = "http://www.loc.gov/MARC21/slim"
+)
+
+type xmlRecord struct {
+	XMLName       xml.Name       `xml:"record"`
+	Leader        string         `xml:"leader"`
+	ControlFields []xmlControl   `xml:"controlfield"`
+	DataFields    []xmlDataField `xml:"datafield"`
+}
+
+type xmlControl struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+func toXML(rec Record) xmlRecord {
+	out := xmlRecord{Leader: rec.Leader}
+	for _, f := range rec.Fields {
+		if f.IsControl() {
+			out.ControlFields = append(out.ControlFields, xmlControl{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+		df := xmlDataField{Tag: f.Tag, Ind1: string(indicator(f.Ind1)), Ind2: string(indicator(f.Ind2))}
+		for _, sf := range f.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: string(sf.Code), Value: sf.Value})
+		}
+		out.DataFields = append(out.DataFields, df)
+	}
+	return out
+}
+
+func fromXML(rec xmlRecord) Record {
+	out := Record{Leader: rec.Leader}
+	for _, f := range rec.ControlFields {
+		out.Fields = append(out.Fields, Field{Tag: f.Tag, Value: f.Value})
+	}
+	for _, f := range rec.DataFields {
+		field := Field{Tag: f.Tag, Ind1: firstByte(f.Ind1), Ind2: firstByte(f.Ind2)}
+		for _, sf := range f.Subfields {
+			field.Subfields = append(field.Subfields, Subfield{Code: firstByte(sf.Code), Value: sf.Value})
+		}
+		out.Fields = append(out.Fields, field)
+	}
+	return out
+}
+
+func firstByte(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
+
+// XMLWriter writes books as a MARCXML collection. Close must be called to end the document.
+type XMLWriter struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	started bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &XMLWriter{w: w, enc: enc}
+}
+
+func (w *XMLWriter) Write(book models.Book) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	return w.enc.Encode(toXML(FromBook(book)))
+}
+
+func (w *XMLWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}}); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+func (w *XMLWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	if _, err := io.WriteString(w.w, xml.Header); err != nil {
+		return err
+	}
+	return w.enc.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: xmlNamespace}},
+	})
+}
+
+// ReadXML parses a MARCXML document, either a collection or a single record. Rows refer to
+// records by their position in the document.
+func ReadXML(r io.Reader) ([]formats.Row, error) {
+	dec := xml.NewDecoder(r)
+	var rows []formats.Row
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var rec xmlRecord
+		if err = dec.DecodeElement(&rec, &start); err != nil {
+			return nil, err
+		}
+		book := fromXML(rec).Book()
+		rows = append(rows, formats.Row{
+			Ref: "record " + strconv.Itoa(len(rows)+1), Book: book, Errors: formats.Validate(book),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("marcxml document has no records")
+	}
+	return rows, nil
+}
@@ -0,0 +1,130 @@
+// Package markdown renders a book's highlights as a Markdown note with YAML front matter,
+// the layout note-taking apps such as Obsidian and Logseq pick up.
+package markdown
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+// ContentType is the media type of the rendered notes.
+const ContentType = "text/markdown; charset=utf-8"
+
+// Write renders the book and its highlights, in the given order, to w.
+func Write(w io.Writer, book models.Book, highlights []models.Highlight) error {
+	var b strings.Builder
+	writeFrontMatter(&b, book, len(highlights))
+	b.WriteString("\n# " + book.Lable + "\n\n")
+	b.WriteString("*" + book.Author + "*\n")
+	for _, h := range highlights {
+		b.WriteString("\n")
+		writeHighlight(&b, h)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFrontMatter(b *strings.Builder, book models.Book, count int) {
+	b.WriteString("---\n")
+	writeField(b, "title", quote(book.Lable))
+	writeField(b, "author", quote(book.Author))
+	if book.ISBN != "" {
+		writeField(b, "isbn", quote(book.ISBN))
+	}
+	if book.Series != "" {
+		writeField(b, "series", quote(book.Series))
+		if book.SeriesIndex != 0 {
+			writeField(b, "series_index", strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64))
+		}
+	}
+	if len(book.Tags) != 0 {
+		writeField(b, "tags", quoteList(book.Tags))
+	}
+	writeField(b, "b_id", quote(book.BID))
+	writeField(b, "highlights", strconv.Itoa(count))
+	b.WriteString("---\n")
+}
+
+func writeField(b *strings.Builder, name, value string) {
+	b.WriteString(name + ": " + value + "\n")
+}
+
+func writeHighlight(b *strings.Builder, h models.Highlight) {
+	switch {
+	case h.Kind == models.HighlightBookmark:
+		b.WriteString("- Bookmark")
+		if where := position(h); where != "" {
+			b.WriteString(": " + where)
+		}
+		b.WriteString("\n")
+		return
+	case h.Text != "" && h.Kind == models.HighlightNote:
+		b.WriteString("**Note:** " + h.Text + "\n")
+	case h.Text != "":
+		for _, line := range strings.Split(strings.TrimRight(h.Text, "\n"), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+	}
+	if meta := details(h); meta != "" {
+		b.WriteString("\n— " + meta + "\n")
+	}
+	if h.Note != "" {
+		b.WriteString("\n" + h.Note + "\n")
+	}
+	if len(h.Tags) != 0 {
+		tags := make([]string, len(h.Tags))
+		for i, tag := range h.Tags {
+			tags[i] = "#" + strings.Join(strings.Fields(tag), "-")
+		}
+		b.WriteString("\n" + strings.Join(tags, " ") + "\n")
+	}
+}
+
+// position describes where in the book the highlight is, using every kind of location it has.
+func position(h models.Highlight) string {
+	var parts []string
+	if h.Page != 0 {
+		parts = append(parts, "page "+strconv.Itoa(h.Page))
+	}
+	if h.Location != "" {
+		parts = append(parts, "location "+h.Location)
+	}
+	if h.Percentage != 0 {
+		parts = append(parts, strconv.FormatFloat(h.Percentage, 'f', -1, 64)+"%")
+	}
+	if h.CFI != "" {
+		parts = append(parts, "`"+h.CFI+"`")
+	}
+	return strings.Join(parts, " · ")
+}
+
+func details(h models.Highlight) string {
+	var parts []string
+	if where := position(h); where != "" {
+		parts = append(parts, where)
+	}
+	if h.Color != "" {
+		parts = append(parts, h.Color)
+	}
+	if h.CreatedAt != nil {
+		parts = append(parts, formats.FormatDate(h.CreatedAt))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// quote returns s as a double-quoted YAML scalar. Go escapes are a subset of YAML's.
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+func quoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = quote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,59 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestWrite(t *testing.T) {
+	added := time.Date(2022, time.March, 5, 22, 15, 32, 0, time.UTC)
+	book := models.Book{
+		BID: "bid1", Lable: `Dune "Deluxe"`, Author: "Frank Herbert", ISBN: "9780441172719",
+		Series: "Dune", SeriesIndex: 1, Tags: []string{"sci-fi"},
+	}
+	highlights := []models.Highlight{
+		{Kind: models.HighlightHighlight, Text: "I must not fear.\nFear is the mind-killer.", Page: 12,
+			Location: "170-172", Color: models.ColorYellow, CreatedAt: &added, Note: "Litany",
+			Tags: []string{"fear", "big ideas"}},
+		{Kind: models.HighlightNote, Text: "Reread this part", Percentage: 42.5},
+		{Kind: models.HighlightBookmark, CFI: "epubcfi(/6/4!/4/10)"},
+	}
+
+	var out strings.Builder
+	assert.NoError(t, Write(&out, book, highlights))
+	assert.Equal(t, `---
+title: "Dune \"Deluxe\""
+author: "Frank Herbert"
+isbn: "9780441172719"
+series: "Dune"
+series_index: 1
+tags: ["sci-fi"]
+b_id: "bid1"
+highlights: 3
+---
+
+# Dune "Deluxe"
+
+*Frank Herbert*
+
+> I must not fear.
+> Fear is the mind-killer.
+
+— page 12 · location 170-172 · yellow · 2022-03-05
+
+Litany
+
+#fear #big-ideas
+
+**Note:** Reread this part
+
+— 42.5%
+
+- Bookmark: `+"`epubcfi(/6/4!/4/10)`"+`
+`, out.String())
+}
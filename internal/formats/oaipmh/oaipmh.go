@@ -0,0 +1,193 @@
+// Package oaipmh builds OAI-PMH 2.0 responses, the protocol aggregators use to harvest
+// catalog records incrementally.
+package oaipmh
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/Dorrrke/g2-books/internal/formats/dublincore"
+)
+
+const (
+	ContentType     = "text/xml; charset=utf-8"
+	ProtocolVersion = "2.0"
+	Namespace       = "http://www.openarchives.org/OAI/2.0/"
+	schemaLocation  = Namespace + " http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd"
+	xsiNamespace    = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// Verbs.
+const (
+	VerbIdentify            = "Identify"
+	VerbListMetadataFormats = "ListMetadataFormats"
+	VerbListSets            = "ListSets"
+	VerbListIdentifiers     = "ListIdentifiers"
+	VerbListRecords         = "ListRecords"
+	VerbGetRecord           = "GetRecord"
+)
+
+// Error codes.
+const (
+	ErrBadArgument             = "badArgument"
+	ErrBadResumptionToken      = "badResumptionToken"
+	ErrBadVerb                 = "badVerb"
+	ErrCannotDisseminateFormat = "cannotDisseminateFormat"
+	ErrIDDoesNotExist          = "idDoesNotExist"
+	ErrNoRecordsMatch          = "noRecordsMatch"
+	ErrNoSetHierarchy          = "noSetHierarchy"
+)
+
+// Granularities of datestamps.
+const (
+	SecondsLayout = "2006-01-02T15:04:05Z"
+	DayLayout     = "2006-01-02"
+)
+
+// PrefixOAIDC is the metadata format every repository has to support.
+const PrefixOAIDC = "oai_dc"
+
+var ErrDatestamp = errors.New("invalid datestamp")
+
+type Response struct {
+	XMLName             xml.Name             `xml:"OAI-PMH"`
+	Xmlns               string               `xml:"xmlns,attr"`
+	XmlnsXSI            string               `xml:"xmlns:xsi,attr"`
+	SchemaLocation      string               `xml:"xsi:schemaLocation,attr"`
+	ResponseDate        string               `xml:"responseDate"`
+	Request             Request              `xml:"request"`
+	Errors              []Error              `xml:"error"`
+	Identify            *Identify            `xml:"Identify"`
+	ListMetadataFormats *ListMetadataFormats `xml:"ListMetadataFormats"`
+	ListIdentifiers     *ListIdentifiers     `xml:"ListIdentifiers"`
+	ListRecords         *ListRecords         `xml:"ListRecords"`
+	GetRecord           *GetRecord           `xml:"GetRecord"`
+}
+
+// Request echoes the request. Its arguments are left out when the request was invalid.
+type Request struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	BaseURL         string `xml:",chardata"`
+}
+
+type Error struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+type Identify struct {
+	RepositoryName    string   `xml:"repositoryName"`
+	BaseURL           string   `xml:"baseURL"`
+	ProtocolVersion   string   `xml:"protocolVersion"`
+	AdminEmails       []string `xml:"adminEmail"`
+	EarliestDatestamp string   `xml:"earliestDatestamp"`
+	DeletedRecord     string   `xml:"deletedRecord"`
+	Granularity       string   `xml:"granularity"`
+}
+
+type ListMetadataFormats struct {
+	Formats []MetadataFormat `xml:"metadataFormat"`
+}
+
+type MetadataFormat struct {
+	Prefix    string `xml:"metadataPrefix"`
+	Schema    string `xml:"schema"`
+	Namespace string `xml:"metadataNamespace"`
+}
+
+// OAIDC describes the oai_dc metadata format.
+func OAIDC() MetadataFormat {
+	return MetadataFormat{
+		Prefix:    PrefixOAIDC,
+		Schema:    "http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+		Namespace: dublincore.OAINamespace,
+	}
+}
+
+type Header struct {
+	Status     string `xml:"status,attr,omitempty"`
+	Identifier string `xml:"identifier"`
+	Datestamp  string `xml:"datestamp"`
+}
+
+type Record struct {
+	Header   Header    `xml:"header"`
+	Metadata *Metadata `xml:"metadata"`
+}
+
+type Metadata struct {
+	DC dublincore.Record `xml:"oai_dc:dc"`
+}
+
+// ResumptionToken continues an incomplete list. The last part of a list carries an empty token.
+type ResumptionToken struct {
+	Cursor int    `xml:"cursor,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type ListIdentifiers struct {
+	Headers []Header         `xml:"header"`
+	Token   *ResumptionToken `xml:"resumptionToken"`
+}
+
+type ListRecords struct {
+	Records []Record         `xml:"record"`
+	Token   *ResumptionToken `xml:"resumptionToken"`
+}
+
+type GetRecord struct {
+	Record Record `xml:"record"`
+}
+
+// NewResponse returns an empty response to a request sent to baseURL.
+func NewResponse(baseURL string, now time.Time) *Response {
+	return &Response{
+		Xmlns:          Namespace,
+		XmlnsXSI:       xsiNamespace,
+		SchemaLocation: schemaLocation,
+		ResponseDate:   FormatDatestamp(now),
+		Request:        Request{BaseURL: baseURL},
+	}
+}
+
+// AddError records a protocol error; a response with errors carries no verb payload.
+func (r *Response) AddError(code, message string) {
+	r.Errors = append(r.Errors, Error{Code: code, Message: message})
+}
+
+// FormatDatestamp formats t with seconds granularity in UTC.
+func FormatDatestamp(t time.Time) string {
+	return t.UTC().Format(SecondsLayout)
+}
+
+// ParseDatestamp parses a from or until argument, which may have day or seconds granularity.
+func ParseDatestamp(s string) (t time.Time, day bool, err error) {
+	if t, err = time.Parse(DayLayout, s); err == nil {
+		return t, true, nil
+	}
+	if t, err = time.Parse(SecondsLayout, s); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, ErrDatestamp
+}
+
+// Write encodes resp as an XML document.
+func Write(w io.Writer, resp *Response) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,68 @@
+package oaipmh
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDatestamp(t *testing.T) {
+	type want struct {
+		t   time.Time
+		day bool
+		err error
+	}
+	type test struct {
+		name  string
+		value string
+		want  want
+	}
+	tests := []test{
+		{
+			name:  "Test ParseDatestamp() func; Case 1:",
+			value: "2024-05-01",
+			want:  want{t: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC), day: true},
+		},
+		{
+			name:  "Test ParseDatestamp() func; Case 2:",
+			value: "2024-05-01T10:20:30Z",
+			want:  want{t: time.Date(2024, time.May, 1, 10, 20, 30, 0, time.UTC)},
+		},
+		{name: "Test ParseDatestamp() func; Case 3:", value: "2024-05-01T10:20:30+03:00", want: want{err: ErrDatestamp}},
+		{name: "Test ParseDatestamp() func; Case 4:", value: "2024-05", want: want{err: ErrDatestamp}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, day, err := ParseDatestamp(tc.value)
+			assert.ErrorIs(t, err, tc.want.err)
+			assert.Equal(t, tc.want.t, got)
+			assert.Equal(t, tc.want.day, day)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	resp := NewResponse("http://localhost:8080/oai", time.Date(2024, time.May, 1, 13, 0, 0, 0, time.FixedZone("MSK", 3*60*60)))
+	resp.Request.Verb = VerbListIdentifiers
+	resp.ListIdentifiers = &ListIdentifiers{
+		Headers: []Header{{Identifier: "oai:g2-books:bid1", Datestamp: "2024-04-01T00:00:00Z", Status: "deleted"}},
+		Token:   &ResumptionToken{Cursor: 100},
+	}
+	var out strings.Builder
+	assert.NoError(t, Write(&out, resp))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+		`xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/ http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd">
+  <responseDate>2024-05-01T10:00:00Z</responseDate>
+  <request verb="ListIdentifiers">http://localhost:8080/oai</request>
+  <ListIdentifiers>
+    <header status="deleted">
+      <identifier>oai:g2-books:bid1</identifier>
+      <datestamp>2024-04-01T00:00:00Z</datestamp>
+    </header>
+    <resumptionToken cursor="100"></resumptionToken>
+  </ListIdentifiers>
+</OAI-PMH>`, out.String())
+}
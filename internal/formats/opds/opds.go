@@ -0,0 +1,191 @@
+// Package opds builds OPDS 1.2 catalog documents: Atom navigation and acquisition feeds and
+// the OpenSearch description e-reader apps use to search a catalog.
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+// Media types of OPDS documents.
+const (
+	NavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType  = "application/opensearchdescription+xml"
+)
+
+// Link relations used by OPDS catalogs besides the Atom ones (self, alternate, ...).
+const (
+	RelStart       = "start"
+	RelSubsection  = "subsection"
+	RelSearch      = "search"
+	RelFirst       = "first"
+	RelPrevious    = "previous"
+	RelNext        = "next"
+	RelLast        = "last"
+	RelSortNew     = "http://opds-spec.org/sort/new"
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+)
+
+const (
+	atomNamespace       = "http://www.w3.org/2005/Atom"
+	dcNamespace         = "http://purl.org/dc/terms/"
+	openSearchNamespace = "http://a9.com/-/spec/opensearch/1.1/"
+)
+
+type Feed struct {
+	XMLName      xml.Name `xml:"feed"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	XmlnsDC      string   `xml:"xmlns:dc,attr"`
+	XmlnsOS      string   `xml:"xmlns:opensearch,attr"`
+	ID           string   `xml:"id"`
+	Title        string   `xml:"title"`
+	Updated      string   `xml:"updated"`
+	Links        []Link   `xml:"link"`
+	TotalResults int      `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int      `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int      `xml:"opensearch:startIndex,omitempty"`
+	Entries      []Entry  `xml:"entry"`
+}
+
+type Entry struct {
+	ID          string     `xml:"id"`
+	Title       string     `xml:"title"`
+	Updated     string     `xml:"updated"`
+	Authors     []Author   `xml:"author"`
+	Identifiers []string   `xml:"dc:identifier"`
+	Publisher   string     `xml:"dc:publisher,omitempty"`
+	Issued      string     `xml:"dc:issued,omitempty"`
+	Categories  []Category `xml:"category"`
+	Content     *Content   `xml:"content"`
+	Links       []Link     `xml:"link"`
+}
+
+type Author struct {
+	Name string `xml:"name"`
+}
+
+type Category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type Content struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type Link struct {
+	Rel   string `xml:"rel,attr"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// NewFeed returns an empty feed with the namespaces OPDS documents use.
+func NewFeed(id, title string, updated time.Time) *Feed {
+	return &Feed{
+		Xmlns:   atomNamespace,
+		XmlnsDC: dcNamespace,
+		XmlnsOS: openSearchNamespace,
+		ID:      id,
+		Title:   title,
+		Updated: formatTime(updated),
+	}
+}
+
+// NavigationEntry is an entry of a navigation feed pointing at another feed.
+func NavigationEntry(id, title, summary, href, kind string, updated time.Time) Entry {
+	return Entry{
+		ID:      id,
+		Title:   title,
+		Updated: formatTime(updated),
+		Content: &Content{Type: "text", Text: summary},
+		Links:   []Link{{Rel: RelSubsection, Href: href, Type: kind}},
+	}
+}
+
+// BookEntry describes a book in an acquisition feed. Links to the book itself are left to
+// the caller.
+func BookEntry(book models.Book, updated time.Time) Entry {
+	entry := Entry{
+		ID:        "urn:uuid:" + book.BID,
+		Title:     book.Lable,
+		Updated:   formatTime(updated),
+		Publisher: book.Publisher,
+	}
+	for _, author := range strings.Split(book.Author, "&") {
+		if author = strings.TrimSpace(author); author != "" {
+			entry.Authors = append(entry.Authors, Author{Name: author})
+		}
+	}
+	if book.ISBN != "" {
+		entry.Identifiers = append(entry.Identifiers, "urn:isbn:"+book.ISBN)
+	}
+	if book.Year != 0 {
+		entry.Issued = strconv.Itoa(book.Year)
+	}
+	for _, tag := range book.Tags {
+		entry.Categories = append(entry.Categories, Category{Term: tag, Label: tag})
+	}
+	if book.Series != "" {
+		summary := book.Series
+		if book.SeriesIndex != 0 {
+			summary += " #" + strconv.FormatFloat(book.SeriesIndex, 'f', -1, 64)
+		}
+		entry.Content = &Content{Type: "text", Text: summary}
+	}
+	return entry
+}
+
+type OpenSearchDescription struct {
+	XMLName        xml.Name        `xml:"OpenSearchDescription"`
+	Xmlns          string          `xml:"xmlns,attr"`
+	ShortName      string          `xml:"ShortName"`
+	Description    string          `xml:"Description"`
+	InputEncoding  string          `xml:"InputEncoding"`
+	OutputEncoding string          `xml:"OutputEncoding"`
+	URLs           []OpenSearchURL `xml:"Url"`
+}
+
+type OpenSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// NewOpenSearchDescription describes a search returning acquisition feeds. template contains
+// the {searchTerms} placeholder.
+func NewOpenSearchDescription(shortName, description, template string) *OpenSearchDescription {
+	return &OpenSearchDescription{
+		Xmlns:          openSearchNamespace,
+		ShortName:      shortName,
+		Description:    description,
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URLs:           []OpenSearchURL{{Type: AcquisitionType, Template: template}},
+	}
+}
+
+// Write encodes doc, a feed or an OpenSearch description, as an XML document.
+func Write(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
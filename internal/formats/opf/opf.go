@@ -0,0 +1,174 @@
+// Package opf reads the package metadata of EPUB books and Calibre libraries (metadata.opf).
+package opf
+
+import (
+	"encoding/xml"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const roleAuthor = "aut"
+
+// Package is the subset of an OPF package document this service uses.
+type Package struct {
+	Metadata Metadata `xml:"metadata"`
+	Manifest []Item   `xml:"manifest>item"`
+}
+
+// Item is a resource listed in the manifest. Href is relative to the package document.
+type Item struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+type Metadata struct {
+	Titles      []string     `xml:"http://purl.org/dc/elements/1.1/ title"`
+	Creators    []Creator    `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Identifiers []Identifier `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+	Subjects    []string     `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Languages   []string     `xml:"http://purl.org/dc/elements/1.1/ language"`
+	Meta        []Meta       `xml:"meta"`
+}
+
+type Creator struct {
+	Name string `xml:",chardata"`
+	Role string `xml:"http://www.idpf.org/2007/opf role,attr"`
+}
+
+type Identifier struct {
+	Value  string `xml:",chardata"`
+	Scheme string `xml:"http://www.idpf.org/2007/opf scheme,attr"`
+}
+
+// Meta covers both the OPF 2 form (name/content) and the OPF 3 form (property/text).
+type Meta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Parse decodes an OPF package document.
+func Parse(r io.Reader) (Package, error) {
+	var pkg Package
+	if err := xml.NewDecoder(r).Decode(&pkg); err != nil {
+		return Package{}, err
+	}
+	return pkg, nil
+}
+
+// Title returns the first title.
+func (m Metadata) Title() string {
+	for _, title := range m.Titles {
+		if title = strings.TrimSpace(title); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// Authors returns the creators with the author role. Creators without a role are treated as authors.
+func (m Metadata) Authors() []string {
+	var authors []string
+	for _, creator := range m.Creators {
+		name := strings.TrimSpace(creator.Name)
+		if name != "" && (creator.Role == "" || creator.Role == roleAuthor) {
+			authors = append(authors, name)
+		}
+	}
+	return authors
+}
+
+// ISBN returns the identifier with the ISBN scheme or an "isbn:" URN, if any.
+func (m Metadata) ISBN() string {
+	for _, id := range m.Identifiers {
+		value := strings.TrimSpace(id.Value)
+		if strings.EqualFold(id.Scheme, "isbn") {
+			return value
+		}
+		if rest, ok := cutPrefixFold(value, "urn:isbn:"); ok {
+			return rest
+		}
+		if rest, ok := cutPrefixFold(value, "isbn:"); ok {
+			return rest
+		}
+	}
+	return ""
+}
+
+// Tags returns the dc:subject entries.
+func (m Metadata) Tags() []string {
+	var tags []string
+	for _, subject := range m.Subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			tags = append(tags, subject)
+		}
+	}
+	return tags
+}
+
+// Language returns the first dc:language, a BCP 47 tag such as "en" or "pt-BR".
+func (m Metadata) Language() string {
+	for _, lang := range m.Languages {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// Cover returns the manifest item of the cover image: the EPUB 3 item with the cover-image
+// property, or the item named by the EPUB 2 cover meta.
+func (p Package) Cover() (Item, bool) {
+	coverID := p.Metadata.meta("cover")
+	for _, item := range p.Manifest {
+		if slices.Contains(strings.Fields(item.Properties), "cover-image") {
+			return item, true
+		}
+	}
+	for _, item := range p.Manifest {
+		if coverID != "" && item.ID == coverID {
+			return item, true
+		}
+	}
+	return Item{}, false
+}
+
+// Series returns the Calibre series and position within it.
+func (m Metadata) Series() (string, float64) {
+	series := m.meta("calibre:series")
+	index, _ := strconv.ParseFloat(m.meta("calibre:series_index"), 64)
+	return series, index
+}
+
+// Rating returns the Calibre rating, converted from Calibre's 0-10 scale to stars.
+func (m Metadata) Rating() float64 {
+	rating, err := strconv.ParseFloat(m.meta("calibre:rating"), 64)
+	if err != nil {
+		return 0
+	}
+	return rating / 2 //nolint: gomnd //calibre stores half stars
+}
+
+func (m Metadata) meta(name string) string {
+	for _, meta := range m.Meta {
+		if meta.Name == name {
+			return strings.TrimSpace(meta.Content)
+		}
+		if meta.Property == name {
+			return strings.TrimSpace(meta.Value)
+		}
+	}
+	return ""
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return "", false
+}
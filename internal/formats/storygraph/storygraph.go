@@ -0,0 +1,51 @@
+// Package storygraph reads the library export produced by The StoryGraph
+// (Manage Account -> Export StoryGraph Library).
+package storygraph
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+)
+
+const (
+	colTitle        = "Title"
+	colAuthors      = "Authors"
+	colISBN         = "ISBN/UID"
+	colReadStatus   = "Read Status"
+	colLastDateRead = "Last Date Read"
+	colDatesRead    = "Dates Read"
+	colStarRating   = "Star Rating"
+	colTags         = "Tags"
+)
+
+// Read parses a StoryGraph export. Tags become shelves, and the start of the latest
+// "Dates Read" range becomes the start date.
+func Read(r io.Reader) ([]formats.Row, error) {
+	return formats.ReadTable(r, []string{colTitle, colAuthors, colReadStatus}, parse)
+}
+
+func parse(rec formats.Record) models.Book {
+	book := models.Book{
+		Lable:   rec.Get(colTitle),
+		Author:  rec.Get(colAuthors),
+		ISBN:    formats.NormalizeISBN(rec.Get(colISBN)),
+		Status:  rec.Get(colReadStatus),
+		Shelves: formats.SplitList(rec.Get(colTags), ","),
+	}
+	if book.Status == "paused" {
+		book.Status = models.StatusReading
+	}
+	if rating, err := strconv.ParseFloat(rec.Get(colStarRating), 64); err == nil {
+		book.Rating = rating
+	}
+	book.DateRead, _ = formats.ParseDate(rec.Get(colLastDateRead))
+	if ranges := formats.SplitList(rec.Get(colDatesRead), ","); len(ranges) != 0 {
+		start, _, _ := strings.Cut(ranges[len(ranges)-1], "-")
+		book.DateStarted, _ = formats.ParseDate(start)
+	}
+	return book
+}
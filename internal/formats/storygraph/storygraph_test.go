@@ -0,0 +1,36 @@
+package storygraph
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestRead(t *testing.T) {
+	data := "Title,Authors,Contributors,ISBN/UID,Format,Read Status,Date Added,Last Date Read,Dates Read," +
+		"Read Count,Star Rating,Review,Tags,Owned?\n" +
+		`Piranesi,Susanna Clarke,,9781635575637,hardcover,read,2023/01/02,2023/02/10,` +
+		`"2021/05/01-2021/05/09, 2023/02/01-2023/02/10",2,4.75,,"fantasy, favorites",Yes` + "\n" +
+		`Middlemarch,George Eliot,,b1c2d3,paperback,paused,2023/01/02,,,0,,,,No` + "\n"
+
+	rows, err := Read(strings.NewReader(data))
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		started := time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+		read := time.Date(2023, time.February, 10, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, models.Book{
+			Lable: "Piranesi", Author: "Susanna Clarke", ISBN: "9781635575637", Rating: 4.75,
+			Status: models.StatusRead, Shelves: []string{"fantasy", "favorites"}, DateStarted: &started, DateRead: &read,
+		}, rows[0].Book)
+		assert.Empty(t, rows[0].Errors)
+		assert.Equal(t, models.StatusReading, rows[1].Book.Status)
+		assert.Empty(t, rows[1].Book.ISBN)
+	}
+
+	_, err = Read(strings.NewReader("Title,Author\nDune,Frank Herbert\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+	"github.com/Dorrrke/g2-books/internal/formats/kindle"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+// unknownAuthor is used for books created from clippings of documents without an author.
+const unknownAuthor = "Unknown"
+
+type HighlightStorage interface {
+	Storage
+	SaveHighlights([]models.Highlight) (int, error)
+}
+
+// ImportClippings attaches Kindle clippings to the user's books, matching books by title and
+// author and creating the ones that are missing. Clippings that were imported before are
+// recognized by their hash and skipped.
+func ImportClippings(store HighlightStorage, uid string, clippings []kindle.Clipping,
+	dryRun bool) (models.HighlightImportReport, error) {
+	existing, err := store.GetBookByUID(uid)
+	if err != nil && !errors.Is(err, storage.ErrBooksListEmpty) {
+		return models.HighlightImportReport{}, err
+	}
+	index := formats.NewIndex(existing)
+	report := models.HighlightImportReport{DryRun: dryRun, Total: len(clippings)}
+
+	bookIDs := make(map[string]string)
+	var newBooks []models.BookOp
+	var newKeys []string
+	valid := make([]kindle.Clipping, 0, len(clippings))
+	for _, c := range clippings {
+		if len(c.Errors) != 0 {
+			report.Invalid = append(report.Invalid, models.ImportRow{
+				Line: c.Line, Status: models.ImportRowInvalid, Lable: c.Title, Errors: c.Errors,
+			})
+			continue
+		}
+		valid = append(valid, c)
+		key := bookKey(c)
+		if _, seen := bookIDs[key]; seen {
+			continue
+		}
+		book := clippingBook(c)
+		if bid, ok := index.Find(book); ok {
+			bookIDs[key] = bid
+			report.BooksMatched++
+			continue
+		}
+		bookIDs[key] = ""
+		book.UID = uid
+		newBooks = append(newBooks, models.BookOp{Op: models.BookOpCreate, Book: book})
+		newKeys = append(newKeys, key)
+	}
+	report.BooksCreated = len(newBooks)
+	if dryRun {
+		return report, nil
+	}
+
+	if len(newBooks) != 0 {
+		results, applyErr := store.ApplyBookOps(newBooks, true)
+		if applyErr != nil {
+			return models.HighlightImportReport{}, applyErr
+		}
+		for i, res := range results {
+			bookIDs[newKeys[i]] = res.BID
+		}
+	}
+	highlights := make([]models.Highlight, 0, len(valid))
+	for _, c := range valid {
+		h := models.Highlight{
+			BID:       bookIDs[bookKey(c)],
+			UID:       uid,
+			Kind:      c.Kind,
+			Text:      c.Text,
+			Location:  c.Location,
+			Page:      c.Page,
+			CreatedAt: c.AddedAt,
+		}
+		h.Hash = HighlightHash(h)
+		highlights = append(highlights, h)
+	}
+	if len(highlights) == 0 {
+		return report, nil
+	}
+	inserted, err := store.SaveHighlights(highlights)
+	if err != nil {
+		return models.HighlightImportReport{}, err
+	}
+	report.Imported = inserted
+	report.Duplicates = len(highlights) - inserted
+	return report, nil
+}
+
+// HighlightHash identifies a highlight by its book, position and text, so the same clipping
+// imported twice gets the same hash.
+func HighlightHash(h models.Highlight) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		h.BID, h.Kind, h.Location, strconv.Itoa(h.Page), h.Text,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func clippingBook(c kindle.Clipping) models.Book {
+	author := c.Author
+	if author == "" {
+		author = unknownAuthor
+	}
+	return models.Book{Lable: c.Title, Author: author}
+}
+
+func bookKey(c kindle.Clipping) string {
+	return c.Title + "\x00" + c.Author
+}
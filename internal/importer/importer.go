@@ -0,0 +1,69 @@
+// Package importer stores parsed import rows as books, skipping invalid rows and books
+// the user already has. It is shared by the HTTP API and the command line.
+package importer
+
+import (
+	"errors"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+type Storage interface {
+	GetBookByUID(string) ([]models.Book, error)
+	ApplyBookOps([]models.BookOp, bool) ([]models.BookOpResult, error)
+}
+
+// Import creates a book for every valid row that does not match one of the user's books by
+// ISBN or by title and author. With dryRun set nothing is written.
+func Import(store Storage, uid string, rows []formats.Row, dryRun bool) (models.ImportReport, error) {
+	existing, err := store.GetBookByUID(uid)
+	if err != nil && !errors.Is(err, storage.ErrBooksListEmpty) {
+		return models.ImportReport{}, err
+	}
+	index := formats.NewIndex(existing)
+
+	report := models.ImportReport{DryRun: dryRun, Total: len(rows), Rows: make([]models.ImportRow, len(rows))}
+	ops := make([]models.BookOp, 0, len(rows))
+	positions := make([]int, 0, len(rows))
+	for i, row := range rows {
+		report.Rows[i] = models.ImportRow{Line: row.Line, Ref: row.Ref, Lable: row.Book.Lable, Errors: row.Errors}
+		if len(row.Errors) != 0 {
+			report.Rows[i].Status = models.ImportRowInvalid
+			continue
+		}
+		report.Valid++
+		if bid, ok := index.Find(row.Book); ok {
+			report.Rows[i].Status = models.ImportRowDup
+			report.Rows[i].BID = bid
+			report.Duplicates++
+			continue
+		}
+		index.Add(row.Book)
+		report.Rows[i].Status = models.ImportRowValid
+		book := row.Book
+		book.UID = uid
+		ops = append(ops, models.BookOp{Op: models.BookOpCreate, Book: book})
+		positions = append(positions, i)
+	}
+	if dryRun || len(ops) == 0 {
+		return report, nil
+	}
+	results, err := store.ApplyBookOps(ops, false)
+	if err != nil {
+		return models.ImportReport{}, err
+	}
+	for j, res := range results {
+		row := &report.Rows[positions[j]]
+		if res.Error != "" {
+			row.Status = models.ImportRowFailed
+			row.Errors = append(row.Errors, res.Error)
+			continue
+		}
+		row.Status = models.ImportRowImported
+		row.BID = res.BID
+		report.Imported++
+	}
+	return report, nil
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/kindle"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const testClippings = `Dune (Frank Herbert)
+- Your Highlight on page 12 | Location 170-172 | Added on Saturday, March 5, 2022 10:15:32 PM
+
+I must not fear.
+==========
+Solaris (Lem, Stanislaw)
+- Your Highlight on Location 50-51 | Added on Saturday, March 5, 2022 11:00:00 PM
+
+The ocean.
+==========
+`
+
+func TestImportClippings(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Solaris", Author: "Stanislaw Lem", UID: "testUID"}))
+
+	clippings, err := kindle.Parse(strings.NewReader(testClippings))
+	require.NoError(t, err)
+
+	report, err := ImportClippings(store, "testUID", clippings, true)
+	require.NoError(t, err)
+	assert.Equal(t, models.HighlightImportReport{DryRun: true, Total: 2, BooksMatched: 1, BooksCreated: 1}, report)
+
+	report, err = ImportClippings(store, "testUID", clippings, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.HighlightImportReport{Total: 2, Imported: 2, BooksMatched: 1, BooksCreated: 1}, report)
+
+	report, err = ImportClippings(store, "testUID", clippings, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.HighlightImportReport{Total: 2, Duplicates: 2, BooksMatched: 2}, report)
+
+	books, err := store.GetBookByUID("testUID")
+	require.NoError(t, err)
+	assert.Len(t, books, 2)
+	for _, book := range books {
+		highlights, getErr := store.GetHighlightsByBook("testUID", book.BID)
+		require.NoError(t, getErr)
+		assert.Len(t, highlights, 1)
+	}
+}
@@ -0,0 +1,117 @@
+// Package jobs runs long operations such as library imports in the background and keeps
+// their outcome so clients can poll for it.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Dorrrke/g2-books/internal/logger"
+)
+
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+
+	// Finished jobs are forgotten after this long.
+	retention = 24 * time.Hour
+)
+
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	UID        string     `json:"uid"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Result     any        `json:"result,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type Manager struct {
+	mu     sync.RWMutex
+	jobs   map[string]*Job
+	ctx    context.Context //nolint: containedctx //cancels running jobs on Close
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		jobs:   make(map[string]*Job),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start runs fn in a new goroutine and returns the job tracking it. The context passed to fn
+// is cancelled by Close.
+func (m *Manager) Start(uid, kind string, fn func(context.Context) (any, error)) Job {
+	job := &Job{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		UID:       uid,
+		Status:    StatusRunning,
+		CreatedAt: time.Now().UTC(),
+	}
+	m.mu.Lock()
+	m.prune(job.CreatedAt)
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		result, err := fn(m.ctx)
+		m.finish(job.ID, result, err)
+	}()
+	return snapshot
+}
+
+// Get returns a copy of the job.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Close cancels running jobs and waits for them to return.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *Manager) finish(id string, result any, err error) {
+	log := logger.Get()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	job.Result = result
+	if err != nil {
+		log.Error().Err(err).Str("job", id).Str("kind", job.Kind).Msg("job failed")
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = StatusDone
+}
+
+func (m *Manager) prune(now time.Time) {
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > retention {
+			delete(m.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/logger"
+)
+
+// wait polls the job until it is no longer running.
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	var job Job
+	require.Eventually(t, func() bool {
+		var ok bool
+		job, ok = m.Get(id)
+		return ok && job.Status != StatusRunning
+	}, time.Second, time.Millisecond)
+	return job
+}
+
+func TestManager(t *testing.T) {
+	logger.Get(true)
+	m := New()
+	defer m.Close()
+
+	type want struct {
+		status string
+		err    string
+		result any
+	}
+	type test struct {
+		name string
+		fn   func(context.Context) (any, error)
+		want want
+	}
+	tests := []test{
+		{
+			name: "Test Manager; Case 1:",
+			fn:   func(context.Context) (any, error) { return 3, nil },
+			want: want{status: StatusDone, result: 3},
+		},
+		{
+			name: "Test Manager; Case 2:",
+			fn:   func(context.Context) (any, error) { return nil, errors.New("library not found") },
+			want: want{status: StatusFailed, err: "library not found"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			started := m.Start("testUID", "test", tc.fn)
+			assert.Equal(t, "testUID", started.UID)
+			job := wait(t, m, started.ID)
+			assert.Equal(t, tc.want.status, job.Status)
+			assert.Equal(t, tc.want.err, job.Error)
+			assert.Equal(t, tc.want.result, job.Result)
+			assert.NotNil(t, job.FinishedAt)
+		})
+	}
+
+	_, ok := m.Get("unknown")
+	assert.False(t, ok)
+}
+
+func TestManagerClose(t *testing.T) {
+	logger.Get(true)
+	m := New()
+	running := make(chan struct{})
+	job := m.Start("testUID", "test", func(ctx context.Context) (any, error) {
+		close(running)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-running
+	m.Close()
+	// Close waits for running jobs, which see their context cancelled.
+	got, ok := m.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, StatusFailed, got.Status)
+	assert.Equal(t, context.Canceled.Error(), got.Error)
+}
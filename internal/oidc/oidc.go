@@ -0,0 +1,290 @@
+// Package oidc logs users in with an OpenID Connect provider, using the authorization code flow
+// with PKCE. The endpoints of the provider come from its discovery document, and ID tokens are
+// checked with package tokens against the keys the provider publishes in its JWKS.
+//
+// Logins in progress are kept in memory, so the callback must reach the server instance the
+// login started on.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+const (
+	discoveryPath  = "/.well-known/openid-configuration"
+	httpTimeout    = 10 * time.Second
+	loginTTL       = 10 * time.Minute
+	maxPending     = 10000
+	maxBody        = 1 << 20
+	randomSize     = 32
+	idTokenLeeway  = time.Minute
+	defaultScopes  = "openid email profile"
+	challengeS256  = "S256"
+	grantAuthzCode = "authorization_code"
+)
+
+var (
+	// ErrState means the callback names no login in progress, or one that took too long.
+	ErrState = errors.New("unknown or expired login")
+	// ErrBusy means too many logins are in progress at once.
+	ErrBusy = errors.New("too many logins in progress")
+	// ErrRejected means the provider refused the authorization code.
+	ErrRejected = errors.New("authorization code rejected")
+	// ErrIDToken means the ID token of the provider did not check out.
+	ErrIDToken = errors.New("invalid id token")
+)
+
+// Config names the provider and how the service is registered with it.
+type Config struct {
+	// Issuer is the issuer URL of the provider; its discovery document is read below it.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to, registered with the provider.
+	RedirectURL string
+	// Scopes default to openid, email and profile.
+	Scopes []string
+}
+
+// Identity is a user as the provider knows them. Issuer and Subject identify them for good;
+// the rest is what the provider tells about them at the time of the login.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Client talks to one provider.
+type Client struct {
+	cfg      Config
+	issuer   string
+	authURL  string
+	tokenURL string
+	verifier *tokens.Verifier
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending map[string]login
+	now     func() time.Time
+}
+
+// login is a login in progress, waiting for its callback.
+type login struct {
+	codeVerifier string
+	nonce        string
+	expiry       time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// idClaims are the claims of ID tokens the client reads.
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// New reads the discovery document and the keys of the provider, so misconfigurations show
+// at startup.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: issuer, client id and redirect url are required")
+	}
+	c := &Client{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: httpTimeout},
+		pending: make(map[string]login),
+		now:     time.Now,
+	}
+	var doc discovery
+	if err := c.getJSON(ctx, strings.TrimSuffix(cfg.Issuer, "/")+discoveryPath, &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", doc.Issuer, cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: endpoints missing")
+	}
+	verifier, err := tokens.New(ctx, tokens.Config{
+		JWKS:          doc.JWKSURI,
+		Issuer:        doc.Issuer,
+		Audience:      cfg.ClientID,
+		RequireExpiry: true,
+		Leeway:        idTokenLeeway,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("oidc keys: %w", err)
+	}
+	c.issuer, c.authURL, c.tokenURL, c.verifier = doc.Issuer, doc.AuthorizationEndpoint, doc.TokenEndpoint, verifier
+	return c, nil
+}
+
+// AuthCodeURL starts a login. It returns the URL of the login page of the provider to send the
+// user to, and the state the provider hands back to the callback along with the code.
+func (c *Client) AuthCodeURL() (string, string, error) {
+	state, err := random()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := random()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := random()
+	if err != nil {
+		return "", "", err
+	}
+	now := c.now()
+	c.mu.Lock()
+	for key, l := range c.pending {
+		if l.expiry.Before(now) {
+			delete(c.pending, key)
+		}
+	}
+	if len(c.pending) >= maxPending {
+		c.mu.Unlock()
+		return "", "", ErrBusy
+	}
+	c.pending[state] = login{codeVerifier: codeVerifier, nonce: nonce, expiry: now.Add(loginTTL)}
+	c.mu.Unlock()
+
+	scopes := defaultScopes
+	if len(c.cfg.Scopes) > 0 {
+		scopes = strings.Join(c.cfg.Scopes, " ")
+	}
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.cfg.ClientID},
+		"redirect_uri":          {c.cfg.RedirectURL},
+		"scope":                 {scopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {challengeS256},
+	}
+	sep := "?"
+	if strings.Contains(c.authURL, "?") {
+		sep = "&"
+	}
+	return c.authURL + sep + query.Encode(), state, nil
+}
+
+// Exchange finishes the login with state, trading the code for an ID token and returning
+// who logged in. Each state works once.
+func (c *Client) Exchange(ctx context.Context, state, code string) (Identity, error) {
+	c.mu.Lock()
+	l, ok := c.pending[state]
+	delete(c.pending, state)
+	c.mu.Unlock()
+	if !ok || l.expiry.Before(c.now()) {
+		return Identity{}, ErrState
+	}
+	form := url.Values{
+		"grant_type":    {grantAuthzCode},
+		"code":          {code},
+		"redirect_uri":  {c.cfg.RedirectURL},
+		"client_id":     {c.cfg.ClientID},
+		"code_verifier": {l.codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var tr tokenResponse
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&tr); err != nil {
+		return Identity{}, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return Identity{}, fmt.Errorf("%w: %s %s", ErrRejected, tr.Error, tr.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("oidc token request: %s", resp.Status)
+	}
+	var claims idClaims
+	if err = c.verifier.Parse(ctx, tr.IDToken, &claims); err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrIDToken, err)
+	}
+	if claims.Subject == "" || claims.Nonce != l.nonce {
+		return Identity{}, fmt.Errorf("%w: subject or nonce do not match", ErrIDToken)
+	}
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	return Identity{
+		Issuer:        c.issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:          name,
+	}, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(v)
+}
+
+// random returns an unguessable URL-safe string, as states, nonces and code verifiers need.
+func random() (string, error) {
+	raw := make([]byte, randomSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://books.example/user/oidc/callback"
+
+// authorize follows authURL to the provider and returns the code and state it sends back.
+func authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	back, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return back.Query().Get("code"), back.Query().Get("state")
+}
+
+func TestClient(t *testing.T) {
+	provider, err := oidctest.NewProvider("books", "secret")
+	require.NoError(t, err)
+	defer provider.Close()
+	provider.SetUser(oidctest.User{Subject: "alice", Email: "alice@example.org", EmailVerified: true, Name: "Alice"})
+	ctx := context.Background()
+
+	_, err = New(ctx, Config{Issuer: provider.URL + "/other", ClientID: "books", RedirectURL: redirectURL})
+	assert.Error(t, err)
+
+	c, err := New(ctx, Config{Issuer: provider.URL, ClientID: "books", ClientSecret: "secret",
+		RedirectURL: redirectURL})
+	require.NoError(t, err)
+
+	authURL, state, err := c.AuthCodeURL()
+	require.NoError(t, err)
+	code, backState := authorize(t, authURL)
+	assert.Equal(t, state, backState)
+	id, err := c.Exchange(ctx, state, code)
+	require.NoError(t, err)
+	assert.Equal(t, Identity{Issuer: provider.URL, Subject: "alice", Email: "alice@example.org",
+		EmailVerified: true, Name: "Alice"}, id)
+	// A login finishes once.
+	_, err = c.Exchange(ctx, state, code)
+	assert.ErrorIs(t, err, ErrState)
+
+	// Codes only work with the code verifier of their own login.
+	authURL, _, err = c.AuthCodeURL()
+	require.NoError(t, err)
+	codeA, _ := authorize(t, authURL)
+	_, stateB, err := c.AuthCodeURL()
+	require.NoError(t, err)
+	_, err = c.Exchange(ctx, stateB, codeA)
+	assert.ErrorIs(t, err, ErrRejected)
+
+	wrong, err := New(ctx, Config{Issuer: provider.URL, ClientID: "books", ClientSecret: "guess",
+		RedirectURL: redirectURL})
+	require.NoError(t, err)
+	authURL, state, err = wrong.AuthCodeURL()
+	require.NoError(t, err)
+	code, _ = authorize(t, authURL)
+	_, err = wrong.Exchange(ctx, state, code)
+	assert.ErrorIs(t, err, ErrRejected)
+}
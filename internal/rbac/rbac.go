@@ -0,0 +1,97 @@
+// Package rbac decides what the account roles carried in tokens permit. Unlike the roles of
+// package access, which users grant each other on their books, account roles are assigned by
+// whoever issues the tokens and apply to the whole service.
+package rbac
+
+import (
+	"net/http"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+// Permissions checked by the routes that need more than a logged-in user.
+const (
+	ListUsers     = "users:list"
+	ModerateBooks = "books:moderate"
+	ViewStats     = "stats:view"
+)
+
+// permissions lists what each role may do on top of managing its own library.
+var permissions = map[string][]string{ //nolint: gochecknoglobals //read-only table
+	models.RoleUser:      nil,
+	models.RoleLibrarian: {ModerateBooks, ViewStats},
+	models.RoleAdmin:     {ListUsers, ModerateBooks, ViewStats},
+}
+
+// Valid reports whether role is a known account role.
+func Valid(role string) bool {
+	_, ok := permissions[role]
+	return ok
+}
+
+// Role returns the role a token carries. Tokens without a role belong to plain users.
+func Role(role string) string {
+	if role == "" {
+		return models.RoleUser
+	}
+	return role
+}
+
+// Allows reports whether role has permission perm. Unknown roles have no permissions.
+func Allows(role, perm string) bool {
+	for _, p := range permissions[Role(role)] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes an API key may carry. Keys act for their user, but only as far as their scopes go;
+// tokens from a login carry no scopes and are not limited by them. The book scopes cover the
+// library of the user: books, shelves, highlights and import jobs. Sharing covers visibility,
+// grants and share links, orgs the organizations of the user.
+const (
+	ScopeReadBooks  = "books:read"
+	ScopeWriteBooks = "books:write"
+	ScopeSharing    = "sharing"
+	ScopeOrgs       = "orgs"
+	ScopeAdmin      = "admin"
+)
+
+// ValidScope reports whether scope is a known API key scope.
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeReadBooks, ScopeWriteBooks, ScopeSharing, ScopeOrgs, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// ScopeAllows reports whether an API key with scopes may make a request with method to the
+// library of its user. Reading needs books:read or books:write; changing anything needs
+// books:write.
+func ScopeAllows(scopes []string, method string) bool {
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeWriteBooks:
+			return true
+		case ScopeReadBooks:
+			if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScope reports whether scopes contain scope.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
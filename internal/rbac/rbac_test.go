@@ -0,0 +1,64 @@
+package rbac
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+)
+
+func TestAllows(t *testing.T) {
+	type test struct {
+		name string
+		role string
+		perm string
+		want bool
+	}
+	tests := []test{
+		{name: "Test Allows() func; Case 1:", role: "", perm: ViewStats, want: false},
+		{name: "Test Allows() func; Case 2:", role: models.RoleUser, perm: ModerateBooks, want: false},
+		{name: "Test Allows() func; Case 3:", role: models.RoleLibrarian, perm: ModerateBooks, want: true},
+		{name: "Test Allows() func; Case 4:", role: models.RoleLibrarian, perm: ListUsers, want: false},
+		{name: "Test Allows() func; Case 5:", role: models.RoleAdmin, perm: ListUsers, want: true},
+		{name: "Test Allows() func; Case 6:", role: "root", perm: ViewStats, want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Allows(tc.role, tc.perm))
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(models.RoleLibrarian))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid(models.RoleOwner))
+	assert.Equal(t, models.RoleUser, Role(""))
+	assert.True(t, ValidScope(ScopeSharing))
+	assert.True(t, ValidScope(ScopeOrgs))
+	assert.False(t, ValidScope("orgs:write"))
+}
+
+func TestScopeAllows(t *testing.T) {
+	type test struct {
+		name   string
+		scopes []string
+		method string
+		want   bool
+	}
+	tests := []test{
+		{name: "Test ScopeAllows() func; Case 1:", scopes: []string{ScopeReadBooks}, method: http.MethodGet, want: true},
+		{name: "Test ScopeAllows() func; Case 2:", scopes: []string{ScopeReadBooks}, method: http.MethodPost, want: false},
+		{name: "Test ScopeAllows() func; Case 3:", scopes: []string{ScopeWriteBooks}, method: http.MethodDelete, want: true},
+		{name: "Test ScopeAllows() func; Case 4:", scopes: []string{ScopeWriteBooks}, method: http.MethodGet, want: true},
+		{name: "Test ScopeAllows() func; Case 5:", scopes: []string{ScopeAdmin}, method: http.MethodGet, want: false},
+		{name: "Test ScopeAllows() func; Case 6:", scopes: nil, method: http.MethodGet, want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ScopeAllows(tc.scopes, tc.method))
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+// How DeleteAccountHandler deals with the books of the account.
+const (
+	deleteBooks    = "delete"
+	anonymizeBooks = "anonymize"
+)
+
+// authMetadataKey is the metadata key account calls to the auth service carry the token of
+// the caller under; the service acts on the user of that token only.
+const authMetadataKey = "authorization"
+
+var (
+	errOldPassword      = errors.New(errText.OldPasswordError)
+	errResetToken       = errors.New(errText.ResetTokenError)
+	errVerifyToken      = errors.New(errText.VerifyTokenError)
+	errTokenRequired    = errors.New(errText.TokenRequiredError)
+	errPasswordRequired = errors.New(errText.PasswordRequiredError)
+	errEmailRequired    = errors.New(errText.EmailRequiredError)
+	errDeleteBooks      = errors.New(errText.DeleteBooksError)
+)
+
+// ProfileHandler returns the profile of the caller.
+func (s *Server) ProfileHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	resp, err := s.authClient.GetProfile(userContext(ctx), &authservicev1.UserRequest{Uid: uid})
+	if err != nil {
+		accountError(ctx, err, "get profile failed")
+		return
+	}
+	ctx.JSON(http.StatusOK, toProfile(resp))
+}
+
+// UpdateProfileHandler changes the name or e-mail address of the caller. A new address is
+// unverified until the token mailed to it is confirmed.
+func (s *Server) UpdateProfileHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	var req models.ProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := s.authClient.UpdateProfile(userContext(ctx), &authservicev1.UpdateProfileRequest{
+		Uid:   uid,
+		Name:  req.Name,
+		Email: req.Email,
+	})
+	if err != nil {
+		accountError(ctx, err, "update profile failed")
+		return
+	}
+	ctx.JSON(http.StatusOK, toProfile(resp))
+}
+
+// ChangePasswordHandler sets a new password for the caller, who must know the current one. The
+// tokens issued to the caller before end.
+func (s *Server) ChangePasswordHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	var req models.PasswordChangeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.NewPass == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errPasswordRequired.Error()})
+		return
+	}
+	_, err := s.authClient.ChangePassword(userContext(ctx), &authservicev1.ChangePasswordRequest{
+		Uid:     uid,
+		OldPass: req.OldPass,
+		NewPass: req.NewPass,
+	})
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": errOldPassword.Error()})
+			return
+		}
+		accountError(ctx, err, "change password failed")
+		return
+	}
+	s.verifier.RevokeUser(uid)
+	ctx.Status(http.StatusNoContent)
+}
+
+// RequestVerificationHandler mails a new verification token to the address of the caller.
+func (s *Server) RequestVerificationHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	resp, err := s.authClient.RequestEmailVerification(userContext(ctx), &authservicev1.UserRequest{Uid: uid})
+	if err != nil {
+		accountError(ctx, err, "request e-mail verification failed")
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"message": resp.GetMessage()})
+}
+
+// VerifyEmailHandler confirms an e-mail address with the token mailed to it. The token is
+// proof enough, so no login is needed.
+func (s *Server) VerifyEmailHandler(ctx *gin.Context) {
+	var req models.EmailVerifyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Token == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errTokenRequired.Error()})
+		return
+	}
+	_, err := s.authClient.VerifyEmail(ctx.Request.Context(), &authservicev1.VerifyEmailRequest{Token: req.Token})
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated || status.Code(err) == codes.NotFound {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": errVerifyToken.Error()})
+			return
+		}
+		accountError(ctx, err, "verify e-mail failed")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// PasswordResetHandler mails a reset token to an address. The answer is the same whether or
+// not the address has an account.
+func (s *Server) PasswordResetHandler(ctx *gin.Context) {
+	var req models.PasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errEmailRequired.Error()})
+		return
+	}
+	resp, err := s.authClient.RequestPasswordReset(ctx.Request.Context(), &authservicev1.PasswordResetRequest{
+		Email: req.Email,
+	})
+	if err != nil {
+		accountError(ctx, err, "request password reset failed")
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"message": resp.GetMessage()})
+}
+
+// ConfirmPasswordResetHandler sets a new password with a reset token. The sessions of the user
+// end, so a stolen session does not outlive the reset. The answer names no user, so the tokens
+// handed out before are rejected only where the users are local, see WithLocalUsers.
+func (s *Server) ConfirmPasswordResetHandler(ctx *gin.Context) {
+	var req models.PasswordResetConfirm
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case req.Token == "":
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errTokenRequired.Error()})
+		return
+	case req.NewPass == "":
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errPasswordRequired.Error()})
+		return
+	}
+	_, err := s.authClient.ResetPassword(ctx.Request.Context(), &authservicev1.ResetPasswordRequest{
+		Token:   req.Token,
+		NewPass: req.NewPass,
+	})
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": errResetToken.Error()})
+			return
+		}
+		accountError(ctx, err, "reset password failed")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// DeleteAccountHandler deletes the account of the caller and their books. With ?books=anonymize
+// their public books stay in the catalog instead, owned by models.DeletedUser and without their
+// personal reading data. The data goes first, with the blobs of the deleted books, and is kept
+// while the caller is the last admin of an organization others belong to. The user is then
+// deleted with the auth service; the token stays valid until the end, so a retry after a
+// failure goes on where it stopped. Every token of the user is revoked at last.
+func (s *Server) DeleteAccountHandler(ctx *gin.Context) {
+	log := logger.Get()
+	claims, ok := principal(ctx)
+	if !ok {
+		unauthorized(ctx)
+		return
+	}
+	books := ctx.DefaultQuery("books", deleteBooks)
+	if books != deleteBooks && books != anonymizeBooks {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errDeleteBooks.Error()})
+		return
+	}
+	anonymize := books == anonymizeBooks
+	blobs, err := s.accountBlobs(claims.UserID, anonymize)
+	if err != nil {
+		log.Error().Err(err).Str("uid", claims.UserID).Msg("list account blobs failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err = s.storage.DeleteAccount(claims.UserID, anonymize); err != nil {
+		if errors.Is(err, storage.ErrLastAdmin) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": errLastAdmin.Error()})
+			return
+		}
+		log.Error().Err(err).Str("uid", claims.UserID).Msg("delete account data failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, key := range blobs {
+		if err = s.blobs.Delete(ctx.Request.Context(), key); err != nil {
+			log.Error().Err(err).Str("key", key).Msg("delete account blob failed")
+		}
+	}
+	_, err = s.authClient.DeleteUser(userContext(ctx), &authservicev1.UserRequest{Uid: claims.UserID})
+	if err != nil && status.Code(err) != codes.NotFound {
+		accountError(ctx, err, "delete user failed")
+		return
+	}
+	token, _ := bearerToken(ctx.GetHeader("Authorization"))
+	var expiry time.Time
+	if claims.ExpiresAt != nil {
+		expiry = claims.ExpiresAt.Time
+	}
+	s.verifier.Revoke(token, expiry)
+	s.verifier.RevokeUser(claims.UserID)
+	log.Info().Str("uid", claims.UserID).Str("books", books).Msg("account deleted")
+	s.deleteChan <- 1
+	ctx.Status(http.StatusNoContent)
+}
+
+// accountBlobs lists the blobs of the books DeleteAccount deletes for uid: their files and
+// covers, in the library of the user and in the organizations they belong to. Public books
+// kept anonymized keep theirs.
+func (s *Server) accountBlobs(uid string, anonymize bool) ([]string, error) {
+	if s.blobs == nil {
+		return nil, nil
+	}
+	stores := []Storage{s.storage}
+	if s.tenants != nil {
+		orgs, err := s.storage.GetUserOrgs(uid)
+		if err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			stores = append(stores, s.tenants(org.OID))
+		}
+	}
+	var keys []string
+	for _, store := range stores {
+		books, err := store.GetBookByUID(uid)
+		if errors.Is(err, storage.ErrBooksListEmpty) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		bIDs := []string{}
+		for _, book := range books {
+			if anonymize && book.Visibility == models.VisibilityPublic {
+				continue
+			}
+			bIDs = append(bIDs, book.BID)
+			if book.Cover != "" {
+				keys = append(keys, coverKeys(book.Cover)...)
+			}
+		}
+		if len(bIDs) == 0 {
+			continue
+		}
+		files, err := store.GetBookFilesOf(uid, bIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, bookFiles := range files {
+			for _, file := range bookFiles {
+				keys = append(keys, bookFileKey(file))
+			}
+		}
+	}
+	return keys, nil
+}
+
+// userContext returns the context of the request with the token of the caller attached for
+// account calls to the auth service.
+func userContext(ctx *gin.Context) context.Context {
+	token, _ := bearerToken(ctx.GetHeader("Authorization"))
+	return metadata.AppendToOutgoingContext(ctx.Request.Context(), authMetadataKey, "Bearer "+token)
+}
+
+// accountError answers a failed account call to the auth service.
+func accountError(ctx *gin.Context, err error, msg string) {
+	log := logger.Get()
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": status.Convert(err).Message()})
+	case codes.NotFound:
+		ctx.JSON(http.StatusNotFound, gin.H{"error": status.Convert(err).Message()})
+	case codes.Aborted, codes.AlreadyExists:
+		ctx.JSON(http.StatusConflict, gin.H{"error": "user with this e-mail address is already registered"})
+	case codes.Unimplemented:
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case codes.FailedPrecondition:
+		// The auth service cannot send e-mail, so resets and verifications are off.
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": status.Convert(err).Message()})
+	default:
+		if authUnavailable(ctx, err) {
+			return
+		}
+		log.Error().Err(err).Msg(msg)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func toProfile(p *authservicev1.Profile) models.Profile {
+	return models.Profile{
+		UID:           p.GetUid(),
+		Name:          p.GetName(),
+		Email:         p.GetEmail(),
+		EmailVerified: p.GetEmailVerified(),
+	}
+}
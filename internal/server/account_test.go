@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/authsvc"
+	"github.com/Dorrrke/g2-books/internal/blobstore"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+// outbox keeps the last token mailed to each address.
+type outbox map[string]string
+
+func (o outbox) SendPasswordReset(email, token string) error {
+	o["reset "+email] = token
+	return nil
+}
+
+func (o outbox) SendEmailVerification(email, token string) error {
+	o["verify "+email] = token
+	return nil
+}
+
+func TestAccount(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	mail := outbox{}
+	verifier := tokens.NewHMAC(testSecret)
+	svc, err := authsvc.New(store, verifier, authsvc.Config{Mailer: mail})
+	require.NoError(t, err)
+	auth := authsvc.Client(svc)
+	blobs, err := blobstore.NewLocal(t.TempDir())
+	require.NoError(t, err)
+	srv := New("0.0.0.0:8080", store, auth, WithVerifier(verifier), WithBlobStore(blobs), WithLocalUsers())
+	httpSrv := testServer(t, srv)
+	client := resty.New().SetBaseURL(httpSrv.URL)
+
+	// login hands out a fresh token; staleToken one issued an hour ago, before any password change.
+	login := func(email, pass string) string {
+		resp, err := auth.Login(context.Background(), &authservicev1.UserCreds{Email: email, Pass: pass})
+		require.NoError(t, err)
+		return "Bearer " + resp.GetToken()
+	}
+	staleToken := func(uid string) string {
+		issued := jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		token, err := verifier.Sign(Claims{RegisteredClaims: jwt.RegisteredClaims{IssuedAt: issued}, UserID: uid})
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+	register := func(name, email string) (string, string) {
+		resp, err := auth.Register(context.Background(), &authservicev1.User{Name: name, Email: email, Pass: "pass"})
+		require.NoError(t, err)
+		uid, _, err := store.ValidateUser(models.User{Email: email})
+		require.NoError(t, err)
+		return uid, "Bearer " + resp.GetToken()
+	}
+	alice, aliceToken := register("Alice", "alice@example.org")
+	bob, _ := register("Bob", "bob@example.org")
+
+	var profile models.Profile
+	resp, err := client.R().SetHeader("Authorization", aliceToken).SetResult(&profile).Get("/user/me")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, models.Profile{UID: alice, Name: "Alice", Email: "alice@example.org"}, profile)
+
+	type test struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		body   string
+		status int
+	}
+	tests := []test{
+		{name: "Test account; Case 1:", method: http.MethodGet, path: "/user/me", status: http.StatusUnauthorized},
+		{name: "Test account; Case 2:", method: http.MethodPatch, path: "/user/me", auth: aliceToken,
+			body: `{"email":"bob@example.org"}`, status: http.StatusConflict},
+		{name: "Test account; Case 3:", method: http.MethodPatch, path: "/user/me", auth: aliceToken,
+			body: `{"name":"Alicia"}`, status: http.StatusOK},
+		{name: "Test account; Case 4:", method: http.MethodPut, path: "/user/me/password", auth: aliceToken,
+			body: `{"old_pass":"wrong","new_pass":"new"}`, status: http.StatusForbidden},
+		{name: "Test account; Case 5:", method: http.MethodPut, path: "/user/me/password", auth: aliceToken,
+			body: `{"old_pass":"pass"}`, status: http.StatusBadRequest},
+		{name: "Test account; Case 6:", method: http.MethodDelete, path: "/user/me?books=keep", auth: aliceToken,
+			status: http.StatusBadRequest},
+		{name: "Test account; Case 7:", method: http.MethodPost, path: "/user/verify-email",
+			body: `{"token":"guess"}`, status: http.StatusBadRequest},
+		{name: "Test account; Case 8:", method: http.MethodPost, path: "/user/password-reset",
+			body: `{"email":"eve@example.org"}`, status: http.StatusAccepted},
+		{name: "Test account; Case 9:", method: http.MethodPost, path: "/user/password-reset/confirm",
+			body: `{"token":"guess","new_pass":"new"}`, status: http.StatusBadRequest},
+		{name: "Test account; Case 10:", method: http.MethodPut, path: "/user/me/password", auth: aliceToken,
+			body: `{"old_pass":"pass","new_pass":"new"}`, status: http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := client.R().SetHeader("Content-Type", "application/json").SetBody(tc.body)
+			if tc.auth != "" {
+				req.SetHeader("Authorization", tc.auth)
+			}
+			resp, err := req.Execute(tc.method, tc.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode())
+		})
+	}
+
+	// Tokens issued before the new password are rejected, on this server and after a restart.
+	stale := staleToken(alice)
+	aliceToken = login("alice@example.org", "new")
+	for _, restarted := range []bool{false, true} {
+		if restarted {
+			// A restart forgets the revocations kept in memory.
+			srv.verifier = tokens.NewHMAC(testSecret)
+		}
+		resp, err = client.R().SetHeader("Authorization", stale).Get("/user/me")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		resp, err = client.R().SetHeader("Authorization", aliceToken).Get("/user/me")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	}
+
+	// A changed address is confirmed with the token mailed to it.
+	resp, err = client.R().SetHeader("Authorization", aliceToken).SetBody(`{"email":"al@example.org"}`).
+		SetResult(&profile).Patch("/user/me")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, models.Profile{UID: alice, Name: "Alicia", Email: "al@example.org"}, profile)
+	resp, err = client.R().SetBody(models.EmailVerifyRequest{Token: mail["verify al@example.org"]}).
+		Post("/user/verify-email")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", aliceToken).SetResult(&profile).Get("/user/me")
+	require.NoError(t, err)
+	assert.True(t, profile.EmailVerified)
+
+	resp, err = client.R().SetBody(`{"email":"al@example.org"}`).Post("/user/password-reset")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode())
+	resp, err = client.R().SetBody(models.PasswordResetConfirm{Token: mail["reset al@example.org"], NewPass: "reset"}).
+		Post("/user/password-reset/confirm")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	stale = staleToken(alice)
+	aliceToken = login("al@example.org", "reset")
+	resp, err = client.R().SetHeader("Authorization", stale).Get("/user/me")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+
+	// Deleting the account keeps public books when asked to, without the reading data of the user.
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: alice,
+		Status: "read", Rating: 5}))
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Diary", Author: "Alice", UID: alice}))
+	books, err := store.GetBookByUID(alice)
+	require.NoError(t, err)
+	covers := map[string]string{}
+	for _, book := range books {
+		if book.Lable == "Dune" {
+			require.NoError(t, store.SetBookVisibility(alice, book.BID, models.VisibilityPublic))
+		}
+		covers[book.Lable] = "covers/" + book.BID + "/hash"
+		_, err = blobs.Put(context.Background(), covers[book.Lable], strings.NewReader("cover"))
+		require.NoError(t, err)
+		require.NoError(t, store.SetBookCover(alice, book.BID, covers[book.Lable]))
+	}
+	// The last admin of an organization others belong to keeps the account.
+	oid, err := store.SaveOrg(models.Org{Name: "Book club"}, alice)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveMember(models.Member{OID: oid, UID: bob, Role: models.OrgMember}))
+	resp, err = client.R().SetHeader("Authorization", aliceToken).Delete("/user/me?books=anonymize")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode())
+	_, err = store.GetUser(alice)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveMember(models.Member{OID: oid, UID: bob, Role: models.OrgAdmin}))
+	otherToken := "Bearer " + testToken(t, alice)
+	resp, err = client.R().SetHeader("Authorization", aliceToken).Delete("/user/me?books=anonymize")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	_, err = store.GetBookByUID(alice)
+	assert.ErrorIs(t, err, storage.ErrBooksListEmpty)
+	kept, err := store.GetBookByUID(models.DeletedUser)
+	require.NoError(t, err)
+	require.Len(t, kept, 1)
+	assert.Equal(t, "Dune", kept[0].Lable)
+	assert.Empty(t, kept[0].Status)
+	assert.Zero(t, kept[0].Rating)
+	_, err = store.GetUser(alice)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	// Covers of deleted books go with them.
+	_, err = blobs.Open(context.Background(), covers["Diary"])
+	assert.ErrorIs(t, err, blobstore.ErrNotFound)
+	cover, err := blobs.Open(context.Background(), covers["Dune"])
+	require.NoError(t, err)
+	cover.Close()
+
+	// Every token of the deleted account stops working.
+	for _, token := range []string{aliceToken, otherToken} {
+		resp, err = client.R().SetHeader("Authorization", token).Get("/user/me")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	}
+}
+
+func TestAccountWithoutMail(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	verifier := tokens.NewHMAC(testSecret)
+	svc, err := authsvc.New(store, verifier, authsvc.Config{})
+	require.NoError(t, err)
+	auth := authsvc.Client(svc)
+	srv := New("0.0.0.0:8080", store, auth, WithVerifier(verifier))
+	httpSrv := testServer(t, srv)
+	client := resty.New().SetBaseURL(httpSrv.URL)
+	resp, err := auth.Register(context.Background(), &authservicev1.User{Name: "Alice", Email: "alice@example.org",
+		Pass: "pass"})
+	require.NoError(t, err)
+	aliceToken := "Bearer " + resp.GetToken()
+
+	type test struct {
+		name   string
+		path   string
+		auth   string
+		body   string
+		status int
+	}
+	tests := []test{
+		{name: "Test account without mail; Case 1:", path: "/user/password-reset",
+			body: `{"email":"alice@example.org"}`, status: http.StatusServiceUnavailable},
+		{name: "Test account without mail; Case 2:", path: "/user/password-reset/confirm",
+			body: `{"token":"guess","new_pass":"new"}`, status: http.StatusServiceUnavailable},
+		{name: "Test account without mail; Case 3:", path: "/user/verify-email",
+			body: `{"token":"guess"}`, status: http.StatusServiceUnavailable},
+		{name: "Test account without mail; Case 4:", path: "/user/me/verify-email", auth: aliceToken,
+			status: http.StatusServiceUnavailable},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := client.R().SetHeader("Content-Type", "application/json").SetBody(tc.body)
+			if tc.auth != "" {
+				req.SetHeader("Authorization", tc.auth)
+			}
+			resp, err := req.Post(tc.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode())
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dorrrke/g2-books/internal/access"
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+var (
+	errRole      = errors.New(errText.RoleError)
+	errGrantSelf = errors.New(errText.GrantSelfError)
+)
+
+// BookAccessHandler lists who has been granted access to a book. Access granted through the
+// book's shelves is listed at the shelves.
+func (s *Server) BookAccessHandler(ctx *gin.Context) {
+	book, ok := s.aclBook(ctx)
+	if !ok {
+		return
+	}
+	s.writeGrants(ctx, book.UID, models.GrantBook, book.BID)
+}
+
+// GrantBookAccessHandler gives the user in the path a role on a book, replacing any role they
+// had on it. Only owners may grant access.
+func (s *Server) GrantBookAccessHandler(ctx *gin.Context) {
+	book, ok := s.aclBook(ctx)
+	if !ok {
+		return
+	}
+	s.grant(ctx, models.Grant{Owner: book.UID, Kind: models.GrantBook, Target: book.BID, UID: ctx.Param("uid")})
+}
+
+// RevokeBookAccessHandler removes the role the user in the path has on a book.
+func (s *Server) RevokeBookAccessHandler(ctx *gin.Context) {
+	book, ok := s.aclBook(ctx)
+	if !ok {
+		return
+	}
+	s.revoke(ctx, models.Grant{Owner: book.UID, Kind: models.GrantBook, Target: book.BID, UID: ctx.Param("uid")})
+}
+
+// ShelfAccessHandler lists who has been granted access to one of the caller's shelves.
+// Grants outlive the shelf, so they are listed for empty shelves as well.
+func (s *Server) ShelfAccessHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	s.writeGrants(ctx, uid, models.GrantShelf, ctx.Param("name"))
+}
+
+// GrantShelfAccessHandler gives the user in the path a role on every book on one of the
+// caller's shelves, including books added to it later.
+func (s *Server) GrantShelfAccessHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	shelf, ok := s.userShelf(ctx, uid, ctx.Param("name"))
+	if !ok {
+		return
+	}
+	s.grant(ctx, models.Grant{Owner: shelf.UID, Kind: models.GrantShelf, Target: shelf.Name, UID: ctx.Param("uid")})
+}
+
+// RevokeShelfAccessHandler removes the role the user in the path has on one of the caller's
+// shelves, also after the shelf was emptied.
+func (s *Server) RevokeShelfAccessHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	s.revoke(ctx, models.Grant{Owner: uid, Kind: models.GrantShelf, Target: ctx.Param("name"), UID: ctx.Param("uid")})
+}
+
+// aclBook authenticates the caller and loads the book in the path if they may manage its access.
+func (s *Server) aclBook(ctx *gin.Context) (models.Book, bool) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return models.Book{}, false
+	}
+	return s.authorizeBook(ctx, uid, models.RoleOwner)
+}
+
+func (s *Server) writeGrants(ctx *gin.Context, owner, kind, target string) {
+	log := logger.Get()
+	grants, err := s.store(ctx).GetGrants(owner, kind, target)
+	if err != nil {
+		log.Error().Err(err).Msg("get grants failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, grants)
+}
+
+func (s *Server) grant(ctx *gin.Context, g models.Grant) {
+	log := logger.Get()
+	var req models.GrantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !access.Valid(req.Role) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errRole.Error()})
+		return
+	}
+	if g.UID == g.Owner {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errGrantSelf.Error()})
+		return
+	}
+	g.Role = req.Role
+	if err := s.store(ctx).SaveGrant(g); err != nil {
+		log.Error().Err(err).Msg("save grant failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, g)
+}
+
+func (s *Server) revoke(ctx *gin.Context, g models.Grant) {
+	log := logger.Get()
+	if err := s.store(ctx).DeleteGrant(g.Owner, g.Kind, g.Target, g.UID); err != nil {
+		if errors.Is(err, storage.ErrGrantNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("delete grant failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+func TestAccessFlow(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: "ownerUID",
+		Shelves: []string{"family"}}))
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Emma", Author: "Jane Austen", UID: "ownerUID"}))
+	books, err := store.GetBookByUID("ownerUID")
+	require.NoError(t, err)
+	byLable := map[string]string{}
+	for _, book := range books {
+		byLable[book.Lable] = book.BID
+	}
+	dune, emma := byLable["Dune"], byLable["Emma"]
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	owner := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "ownerUID"))
+	editor := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "editorUID"))
+	viewer := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "viewerUID"))
+
+	// Without grants other users cannot tell the books exist.
+	resp, err := editor.R().Get("/books/" + dune + "/cite")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = editor.R().Delete("/books/delete/" + dune)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	resp, err = owner.R().SetBody(`{"role":"admin"}`).Put("/books/" + dune + "/access/editorUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, err = owner.R().SetBody(`{"role":"viewer"}`).Put("/books/" + dune + "/access/ownerUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, err = owner.R().SetBody(`{"role":"editor"}`).Put("/books/" + dune + "/access/editorUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = owner.R().SetBody(`{"role":"viewer"}`).Put("/shelves/family/access/viewerUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	var grants []models.Grant
+	resp, err = owner.R().SetResult(&grants).Get("/books/" + dune + "/access")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, grants, 1)
+	assert.Equal(t, models.Grant{Owner: "ownerUID", Kind: models.GrantBook, Target: dune, UID: "editorUID",
+		Role: models.RoleEditor, CreatedAt: grants[0].CreatedAt}, grants[0])
+	resp, err = owner.R().SetResult(&grants).Get("/shelves/family/access")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, grants, 1)
+	assert.Equal(t, "viewerUID", grants[0].UID)
+
+	// Viewers read, through the shelf grant, but cannot change anything.
+	resp, err = viewer.R().Get("/books/" + dune)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = viewer.R().Get("/books/" + dune + "/cite")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = viewer.R().Get("/books/" + emma + "/cite")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = viewer.R().Get("/users/ownerUID/shelves/family")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = viewer.R().SetBody(`{"ops":[{"op":"update","book":{"b_id":"` + dune + `","lable":"Dune","author":"F"}}]}`).
+		Post("/books/batch")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Contains(t, string(resp.Body()), `"error":"your role does not allow this"`)
+
+	// Editors change books on behalf of the owner but cannot delete them or manage access.
+	resp, err = editor.R().SetBody(`{"ops":[{"op":"update","book":{"b_id":"` + dune +
+		`","lable":"Dune","author":"Frank Herbert","year":1965,"shelves":["family"]}}]}`).Post("/books/batch")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, `{"committed":true,"results":[{"index":0,"op":"update","b_id":"`+dune+`"}]}`, string(resp.Body()))
+	book, err := store.GetBookByID(dune)
+	assert.NoError(t, err)
+	assert.Equal(t, 1965, book.Year)
+	assert.Equal(t, "ownerUID", book.UID)
+	// Moving the book off the shared shelf is left to the owner; the rest of the update applies.
+	resp, err = editor.R().SetBody(`{"ops":[{"op":"update","book":{"b_id":"` + dune +
+		`","lable":"Dune","author":"Frank Herbert","year":1966,"shelves":["friends"]}}]}`).Post("/books/batch")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	book, err = store.GetBookByID(dune)
+	assert.NoError(t, err)
+	assert.Equal(t, 1966, book.Year)
+	assert.Equal(t, []string{"family"}, book.Shelves)
+	resp, err = owner.R().SetBody(`{"ops":[{"op":"update","book":{"b_id":"` + dune +
+		`","lable":"Dune","author":"Frank Herbert","year":1965,"shelves":["family","classics"]}}]}`).
+		Post("/books/batch")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	book, err = store.GetBookByID(dune)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"family", "classics"}, book.Shelves)
+	resp, err = editor.R().Delete("/books/delete/" + dune)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	resp, err = editor.R().SetBody(`{"role":"owner"}`).Put("/books/" + dune + "/access/editorUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	// Revoked users lose access right away.
+	resp, err = owner.R().Delete("/books/" + dune + "/access/editorUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = owner.R().Delete("/books/" + dune + "/access/editorUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = editor.R().Get("/books/" + dune)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = viewer.R().Get("/users/ownerUID/shelves/family")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = owner.R().Delete("/shelves/family/access/viewerUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = viewer.R().Get("/users/ownerUID/shelves/family")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	resp, err = owner.R().Delete("/books/delete/" + dune)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/rbac"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+// RequirePermission only lets callers through whose account role has permission perm. API keys
+// need the admin scope on top.
+func (s *Server) RequirePermission(perm string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		log := logger.Get()
+		claims, ok := principal(ctx)
+		if !ok {
+			unauthorized(ctx)
+			return
+		}
+		if key, isKey := apiKey(ctx); isKey && !rbac.HasScope(key.Scopes, rbac.ScopeAdmin) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errAPIKeyScopeDenied.Error()})
+			return
+		}
+		if !rbac.Allows(claims.Role, perm) {
+			log.Warn().Str("uid", claims.UserID).Str("role", rbac.Role(claims.Role)).Str("permission", perm).
+				Msg("permission denied")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errForbidden.Error()})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// AdminUsersHandler lists the users known to the service.
+func (s *Server) AdminUsersHandler(ctx *gin.Context) {
+	log := logger.Get()
+	users, err := s.storage.GetUsers()
+	if err != nil {
+		log.Error().Err(err).Msg("get users failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, users)
+}
+
+// AdminDeleteBookHandler deletes any book, whoever owns it and whatever library it is in.
+func (s *Server) AdminDeleteBookHandler(ctx *gin.Context) {
+	log := logger.Get()
+	if err := s.storage.ForceDeleteBook(ctx.Param("id")); err != nil {
+		if errors.Is(err, storage.ErrBookNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("force delete book failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Info().Str("bid", ctx.Param("id")).Msg("book force deleted")
+	s.deleteChan <- 1
+	ctx.String(http.StatusOK, "book was deleted")
+}
+
+// AdminStatsHandler reports counts over the whole service.
+func (s *Server) AdminStatsHandler(ctx *gin.Context) {
+	log := logger.Get()
+	stats, err := s.storage.GetStats()
+	if err != nil {
+		log.Error().Err(err).Msg("get stats failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/authsvc"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+// adminServer serves the routes of the API over a store with a book in the default library and
+// one in the library of an organization, whose id it returns.
+func adminServer(t *testing.T, opts ...Option) (*storage.MemStorage, string, *resty.Client) {
+	t.Helper()
+	store := storage.New()
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: "ownerUID"}))
+	require.NoError(t, store.WithTenant("orgID").SaveBook(models.Book{Lable: "Emma", Author: "Jane Austen",
+		UID: "ownerUID"}))
+	books, err := store.WithTenant("orgID").GetBookByUID("ownerUID")
+	require.NoError(t, err)
+
+	srv := New("0.0.0.0:8080", store, nil, append(opts, WithVerifier(testVerifier()))...)
+	t.Cleanup(srv.jobs.Close)
+	return store, books[0].BID, resty.New().SetBaseURL(testServer(t, srv).URL)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	logger.Get(true)
+	type want struct {
+		users  int
+		delete int
+		stats  int
+	}
+	type test struct {
+		name  string
+		token string
+		want  want
+	}
+	tests := []test{
+		{
+			name: "Test admin routes; Case 1:",
+			want: want{users: http.StatusUnauthorized, delete: http.StatusUnauthorized, stats: http.StatusUnauthorized},
+		},
+		{
+			name:  "Test admin routes; Case 2:",
+			token: testToken(t, "ownerUID"),
+			want:  want{users: http.StatusForbidden, delete: http.StatusForbidden, stats: http.StatusForbidden},
+		},
+		{
+			name:  "Test admin routes; Case 3:",
+			token: roleToken(t, "librarianUID", models.RoleLibrarian),
+			want:  want{users: http.StatusForbidden, delete: http.StatusOK, stats: http.StatusOK},
+		},
+		{
+			name:  "Test admin routes; Case 4:",
+			token: roleToken(t, "adminUID", models.RoleAdmin),
+			want:  want{users: http.StatusOK, delete: http.StatusOK, stats: http.StatusOK},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, emma, client := adminServer(t)
+			req := func() *resty.Request { return client.R().SetHeader("Authorization", tc.token) }
+			resp, err := req().Get("/admin/users")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.users, resp.StatusCode())
+			resp, err = req().Delete("/admin/books/" + emma)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.delete, resp.StatusCode())
+			resp, err = req().Get("/admin/stats")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.stats, resp.StatusCode())
+		})
+	}
+}
+
+func TestAdminModeration(t *testing.T) {
+	logger.Get(true)
+	store, emma, client := adminServer(t)
+	admin := func() *resty.Request {
+		return client.R().SetHeader("Authorization", roleToken(t, "adminUID", models.RoleAdmin))
+	}
+
+	// Moderation reaches into the organization's library.
+	resp, err := admin().Delete("/admin/books/" + emma)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	_, err = store.WithTenant("orgID").GetBookByID(emma)
+	assert.ErrorIs(t, err, storage.ErrBookDeleted)
+	resp, err = admin().Delete("/admin/books/" + emma)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	var users []models.UserSummary
+	resp, err = admin().SetResult(&users).Get("/admin/users")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, []models.UserSummary{{UID: "ownerUID", Books: 1}}, users)
+	var stats models.Stats
+	resp, err = admin().SetResult(&stats).Get("/admin/stats")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, models.Stats{Users: 1, Books: 1, DeletedBooks: 1}, stats)
+}
+
+// TestStoredRole follows an account role from the users table through the auth service's
+// tokens to the admin routes.
+func TestStoredRole(t *testing.T) {
+	logger.Get(true)
+	ctx := context.Background()
+	store, _, client := adminServer(t)
+	svc, err := authsvc.New(store, tokens.NewHMAC(testSecret), authsvc.Config{})
+	require.NoError(t, err)
+	auth := authsvc.Client(svc)
+	registered, err := auth.Register(ctx, &authservicev1.User{Name: "Ada", Email: "ada@example.org", Pass: "pass"})
+	require.NoError(t, err)
+	uid, _, err := store.ValidateUser(models.User{Email: "ada@example.org"})
+	require.NoError(t, err)
+
+	resp, err := client.R().SetHeader("Authorization", "Bearer "+registered.GetToken()).Get("/admin/stats")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	// A granted role reaches the user with their next token.
+	require.NoError(t, store.SetUserRole(uid, models.RoleLibrarian))
+	refreshed, err := auth.Refresh(ctx, &authservicev1.RefreshRequest{RefreshToken: registered.GetRefreshToken()})
+	require.NoError(t, err)
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+refreshed.GetToken()).Get("/admin/stats")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+refreshed.GetToken()).Get("/admin/users")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	require.NoError(t, store.SetUserRole(uid, models.RoleAdmin))
+	loggedIn, err := auth.Login(ctx, &authservicev1.UserCreds{Email: "ada@example.org", Pass: "pass"})
+	require.NoError(t, err)
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+loggedIn.GetToken()).Get("/admin/users")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/rbac"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+const (
+	// apiKeyPrefix starts every API key, which tells them apart from JWTs.
+	apiKeyPrefix = "g2b_"
+	apiKeySize   = 32
+	// apiKeyShownSize is how much of a key is kept in the clear to tell keys apart.
+	apiKeyShownSize = len(apiKeyPrefix) + 8
+	// apiKeyKey is the context key of the API key a request is made with.
+	apiKeyKey = "apikey"
+	// apiKeyTouchInterval keeps busy keys from writing their last use on every request.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	errAPIKeyNotFound    = errors.New(errText.APIKeyNotFoundError)
+	errAPIKeyName        = errors.New(errText.APIKeyNameError)
+	errAPIKeyScope       = errors.New(errText.APIKeyScopeError)
+	errAPIKeyExpiry      = errors.New(errText.APIKeyExpiryError)
+	errAPIKeyAdmin       = errors.New(errText.APIKeyAdminError)
+	errAPIKeyScopeDenied = errors.New(errText.APIKeyScopeDeniedError)
+	errAPIKeyManage      = errors.New(errText.APIKeyManageError)
+)
+
+// RequireLogin rejects requests not made with a token from a login. Routes that hand out
+// credentials or end sessions use it, so API keys cannot extend their own reach.
+func (s *Server) RequireLogin(ctx *gin.Context) {
+	if _, ok := principal(ctx); !ok {
+		unauthorized(ctx)
+		return
+	}
+	if _, ok := apiKey(ctx); ok {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errAPIKeyManage.Error()})
+		return
+	}
+	ctx.Next()
+}
+
+// APIKeysHandler lists the API keys of the caller.
+func (s *Server) APIKeysHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	keys, err := s.storage.GetAPIKeys(uid)
+	if err != nil {
+		log.Error().Err(err).Msg("get api keys failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKeyHandler creates an API key for the caller. The key is in the answer and cannot
+// be read again later. Only users with an account role may create admin keys.
+func (s *Server) CreateAPIKeyHandler(ctx *gin.Context) {
+	log := logger.Get()
+	claims, ok := principal(ctx)
+	if !ok {
+		unauthorized(ctx)
+		return
+	}
+	var req models.APIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errAPIKeyName.Error()})
+		return
+	}
+	scopes, ok := apiKeyScopes(req.Scopes)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errAPIKeyScope.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errAPIKeyExpiry.Error()})
+		return
+	}
+	if rbac.HasScope(scopes, rbac.ScopeAdmin) && rbac.Role(claims.Role) == models.RoleUser {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errAPIKeyAdmin.Error()})
+		return
+	}
+	secret, err := newAPIKey()
+	if err != nil {
+		log.Error().Err(err).Msg("generate api key failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	key := models.APIKey{
+		KID:       uuid.New().String(),
+		UID:       claims.UserID,
+		Name:      name,
+		Prefix:    secret[:apiKeyShownSize],
+		Hash:      hashAPIKey(secret),
+		Scopes:    scopes,
+		TenantID:  claims.TenantID,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: &now,
+	}
+	if err = s.storage.SaveAPIKey(key); err != nil {
+		log.Error().Err(err).Msg("save api key failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Info().Str("uid", key.UID).Str("kid", key.KID).Strs("scopes", scopes).Msg("api key created")
+	key.Key = secret
+	ctx.JSON(http.StatusCreated, key)
+}
+
+// DeleteAPIKeyHandler revokes an API key of the caller for good.
+func (s *Server) DeleteAPIKeyHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if err := s.storage.DeleteAPIKey(uid, ctx.Param("kid")); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": errAPIKeyNotFound.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("delete api key failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// identify returns the claims of token, which is either a JWT or an API key. The API key is
+// stored in the request context, where RequireAuth and RequirePermission check its scopes.
+// Admin keys act with the account role their user has now, so demoted users lose what their
+// keys could do; users kept only by a separate auth service have no stored role and act as
+// plain users. Authenticate checks the organization membership of keys as it does for tokens.
+func (s *Server) identify(ctx *gin.Context, token string) (*Claims, error) {
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return s.claims(ctx.Request.Context(), token)
+	}
+	key, err := s.storage.GetAPIKeyByHash(hashAPIKey(token))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, tokens.ErrExpired
+	}
+	role := ""
+	if rbac.HasScope(key.Scopes, rbac.ScopeAdmin) {
+		user, userErr := s.storage.GetUser(key.UID)
+		switch {
+		case userErr == nil:
+			role = user.Role
+		case !errors.Is(userErr, storage.ErrUserNotFound):
+			return nil, userErr
+		}
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err = s.storage.TouchAPIKey(key.KID, now); err != nil {
+			log := logger.Get()
+			log.Warn().Err(err).Str("kid", key.KID).Msg("record api key use failed")
+		}
+	}
+	ctx.Set(apiKeyKey, key)
+	return &Claims{UserID: key.UID, TenantID: key.TenantID, Role: role}, nil
+}
+
+// apiKey returns the API key the request is made with, if any.
+func apiKey(ctx *gin.Context) (models.APIKey, bool) {
+	value, ok := ctx.Get(apiKeyKey)
+	if !ok {
+		return models.APIKey{}, false
+	}
+	key, ok := value.(models.APIKey)
+	return key, ok
+}
+
+// apiKeyScopes checks and deduplicates the scopes asked for a new key.
+func apiKeyScopes(requested []string) ([]string, bool) {
+	scopes := []string{}
+	for _, scope := range requested {
+		if !rbac.ValidScope(scope) {
+			return nil, false
+		}
+		if !rbac.HasScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, len(scopes) > 0
+}
+
+func newAPIKey() (string, error) {
+	raw := make([]byte, apiKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashAPIKey is what keys are stored and looked up by. Keys are random enough that a plain
+// hash keeps them safe.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/rbac"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+func TestAPIKeys(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	uid, err := store.SaveUser(models.User{Name: "Ann", Email: "ann@example.org", Pass: "hash"})
+	require.NoError(t, err)
+	adminUID, err := store.SaveUser(models.User{Name: "Bob", Email: "bob@example.org", Pass: "hash"})
+	require.NoError(t, err)
+	require.NoError(t, store.SetUserRole(adminUID, models.RoleAdmin))
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: uid}))
+	books, err := store.GetBookByUID(uid)
+	require.NoError(t, err)
+	share := "/books/" + books[0].BID + "/visibility"
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()))
+	client := resty.New().SetBaseURL(testServer(t, srv).URL)
+	token := "Bearer " + testToken(t, uid)
+
+	create := func(auth, body string) (*resty.Response, models.APIKey) {
+		var key models.APIKey
+		resp, err := client.R().SetHeader("Authorization", auth).SetBody(body).SetResult(&key).
+			Post("/user/api-keys")
+		assert.NoError(t, err)
+		return resp, key
+	}
+
+	type test struct {
+		name   string
+		auth   string
+		body   string
+		status int
+	}
+	tests := []test{
+		{name: "Test API keys; Case 1:", body: `{"name":"script","scopes":["books:read"]}`, status: http.StatusUnauthorized},
+		{name: "Test API keys; Case 2:", auth: token, body: `{"scopes":["books:read"]}`, status: http.StatusBadRequest},
+		{name: "Test API keys; Case 3:", auth: token, body: `{"name":"script"}`, status: http.StatusBadRequest},
+		{name: "Test API keys; Case 4:", auth: token, body: `{"name":"script","scopes":["books:delete"]}`,
+			status: http.StatusBadRequest},
+		{name: "Test API keys; Case 5:", auth: token, body: `{"name":"script","scopes":["books:read"],
+			"expires_at":"2001-01-01T00:00:00Z"}`, status: http.StatusBadRequest},
+		{name: "Test API keys; Case 6:", auth: token, body: `{"name":"script","scopes":["admin"]}`,
+			status: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := create(tc.auth, tc.body)
+			assert.Equal(t, tc.status, resp.StatusCode())
+		})
+	}
+
+	resp, readKey := create(token, `{"name":"backup","scopes":["books:read","books:read"]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	assert.True(t, strings.HasPrefix(readKey.Key, apiKeyPrefix))
+	assert.True(t, strings.HasPrefix(readKey.Key, readKey.Prefix))
+	assert.Equal(t, []string{rbac.ScopeReadBooks}, readKey.Scopes)
+	readAuth := "Bearer " + readKey.Key
+
+	resp, err = client.R().SetHeader("Authorization", readAuth).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Contains(t, resp.String(), `"uid":"`+uid+`"`)
+	resp, err = client.R().SetHeader("Authorization", readAuth).Post("/books/add-book")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", readAuth).Get("/admin/stats")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	// Keys cannot manage keys.
+	resp, err = client.R().SetHeader("Authorization", readAuth).Get("/user/api-keys")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	// Listed keys show their last use, but never the key.
+	var listed []models.APIKey
+	resp, err = client.R().SetHeader("Authorization", token).SetResult(&listed).Get("/user/api-keys")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.NotContains(t, resp.String(), readKey.Key)
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, readKey.KID, listed[0].KID)
+		assert.NotNil(t, listed[0].LastUsedAt)
+	}
+
+	// Book scopes do not reach sharing, which needs its own scope.
+	resp, writeKey := create(token, `{"name":"sync","scopes":["books:write"]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+writeKey.Key).SetBody(`{"visibility":"public"}`).Put(share)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	resp, shareKey := create(token, `{"name":"share","scopes":["sharing"]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+shareKey.Key).SetBody(`{"visibility":"public"}`).Put(share)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+shareKey.Key).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	resp, adminKey := create("Bearer "+roleToken(t, adminUID, models.RoleAdmin),
+		`{"name":"stats","scopes":["admin"]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+adminKey.Key).Get("/admin/stats")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+adminKey.Key).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	// Keys act with the role their user has now.
+	require.NoError(t, store.SetUserRole(adminUID, models.RoleUser))
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+adminKey.Key).Get("/admin/stats")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+roleToken(t, adminUID, models.RoleAdmin)).
+		Delete("/user/api-keys/" + readKey.KID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", token).Delete("/user/api-keys/" + readKey.KID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", readAuth).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+}
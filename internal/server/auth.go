@@ -0,0 +1,256 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/rbac"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const (
+	// principalKey is the context key of the claims of the authenticated caller.
+	principalKey = "principal"
+	bearerRealm  = `Bearer realm="g2-books"`
+	// refreshHeader carries the refresh token next to the token in the Authorization header.
+	refreshHeader = "X-Refresh-Token"
+)
+
+var (
+	errRefreshToken    = errors.New(errText.RefreshTokenError)
+	errRefreshRequired = errors.New(errText.RefreshRequiredError)
+	errAuthUnavailable = errors.New(errText.AuthUnavailableError)
+)
+
+// Authenticate identifies the caller from the Bearer token in the Authorization header and
+// stores their claims in the request context. Requests without a valid token go on
+// anonymously; RequireAuth turns them away where a login is needed. Tokens sent without the
+// Bearer scheme, as earlier clients do, are accepted as well, and so are API keys in place of
+// a token.
+//
+// Requests made with an organization token are put into the library of that organization.
+// Membership is checked on every request, so removed members lose access at once.
+func (s *Server) Authenticate(ctx *gin.Context) {
+	log := logger.Get()
+	token, ok := bearerToken(ctx.GetHeader("Authorization"))
+	if !ok {
+		ctx.Next()
+		return
+	}
+	claims, err := s.identify(ctx, token)
+	if err != nil {
+		log.Debug().Err(err).Msg("invalid token")
+		ctx.Next()
+		return
+	}
+	if claims.TenantID != "" {
+		if s.tenants == nil {
+			ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": errOrgsDisabled.Error()})
+			return
+		}
+		if _, err = s.storage.GetMember(claims.TenantID, claims.UserID); err != nil {
+			if errors.Is(err, storage.ErrMemberNotFound) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errNotMember.Error()})
+				return
+			}
+			log.Error().Err(err).Msg("get member failed")
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.Set(tenantKey, claims.TenantID)
+	}
+	ctx.Set(principalKey, claims)
+	ctx.Next()
+}
+
+// RequireAuth rejects requests Authenticate could not identify, and requests made with an API
+// key whose scopes do not cover them.
+func (s *Server) RequireAuth(ctx *gin.Context) {
+	if _, ok := principal(ctx); !ok {
+		unauthorized(ctx)
+		return
+	}
+	if key, ok := apiKey(ctx); ok && !rbac.ScopeAllows(key.Scopes, ctx.Request.Method) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errAPIKeyScopeDenied.Error()})
+		return
+	}
+	ctx.Next()
+}
+
+// RequireScope rejects requests Authenticate could not identify, and requests made with an API
+// key without scope. Routes outside the library of the user, which the book scopes of
+// RequireAuth do not reach, use it in place of RequireAuth.
+func (s *Server) RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := principal(ctx); !ok {
+			unauthorized(ctx)
+			return
+		}
+		if key, ok := apiKey(ctx); ok && !rbac.HasScope(key.Scopes, scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errAPIKeyScopeDenied.Error()})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// unauthorized answers a request that needs a login it does not have.
+func unauthorized(ctx *gin.Context) {
+	challenge := bearerRealm
+	if ctx.GetHeader("Authorization") != "" {
+		challenge += `, error="invalid_token"`
+	}
+	ctx.Header("WWW-Authenticate", challenge)
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+}
+
+// authUnavailable answers with a 503 when err says the auth service could not be reached in
+// time, or its circuit breaker gave up on it for now.
+func authUnavailable(ctx *gin.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		log := logger.Get()
+		log.Warn().Err(err).Msg("auth service unavailable")
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": errAuthUnavailable.Error()})
+		return true
+	default:
+		return false
+	}
+}
+
+// bearerToken extracts the token from an Authorization header. Other schemes, such as the
+// Basic credentials of e-reader apps, yield no token.
+func bearerToken(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found {
+		return header, true
+	}
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// principal returns the claims of the authenticated caller.
+func principal(ctx *gin.Context) (*Claims, bool) {
+	value, ok := ctx.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+// authUID returns the uid of the authenticated caller. Handlers needing a login run behind
+// RequireAuth; should one be mounted without it, anonymous requests get the same 401.
+func authUID(ctx *gin.Context) (string, bool) {
+	claims, ok := principal(ctx)
+	if !ok {
+		unauthorized(ctx)
+		return "", false
+	}
+	return claims.UserID, true
+}
+
+// viewerUID returns the uid of the caller, or an empty string for anonymous requests. API keys
+// that may not read books view as anonymous.
+func viewerUID(ctx *gin.Context) string {
+	claims, ok := principal(ctx)
+	if !ok {
+		return ""
+	}
+	if key, isKey := apiKey(ctx); isKey && !rbac.ScopeAllows(key.Scopes, http.MethodGet) {
+		return ""
+	}
+	return claims.UserID
+}
+
+// RefreshHandler trades a refresh token for a new token. The auth service rotates the refresh
+// token as well, so the old one cannot be used again.
+func (s *Server) RefreshHandler(ctx *gin.Context) {
+	log := logger.Get()
+	var req models.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errRefreshRequired.Error()})
+		return
+	}
+	resp, err := s.authClient.Refresh(ctx.Request.Context(), &authservicev1.RefreshRequest{
+		RefreshToken: req.RefreshToken,
+	})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.NotFound, codes.InvalidArgument:
+			log.Debug().Err(err).Msg("refresh token rejected")
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": errRefreshToken.Error()})
+		case codes.Unimplemented:
+			ctx.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		default:
+			if authUnavailable(ctx, err) {
+				return
+			}
+			log.Error().Err(err).Msg("refresh request failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.Header("Authorization", resp.GetToken())
+	ctx.Header(refreshHeader, resp.GetRefreshToken())
+	ctx.JSON(http.StatusOK, gin.H{"token": resp.GetToken(), "refresh_token": resp.GetRefreshToken()})
+}
+
+// LogoutHandler ends the session of the caller. The token the request is made with is revoked
+// until it expires, and the refresh token, when given, is revoked with the auth service.
+func (s *Server) LogoutHandler(ctx *gin.Context) {
+	log := logger.Get()
+	claims, ok := principal(ctx)
+	if !ok {
+		unauthorized(ctx)
+		return
+	}
+	var req models.RefreshRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.RefreshToken != "" {
+		_, err := s.authClient.Logout(ctx.Request.Context(), &authservicev1.LogoutRequest{
+			RefreshToken: req.RefreshToken,
+		})
+		// Auth services without Logout keep the refresh token; the token is still revoked here.
+		if err != nil && status.Code(err) != codes.Unimplemented {
+			if authUnavailable(ctx, err) {
+				return
+			}
+			log.Error().Err(err).Msg("logout request failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	token, _ := bearerToken(ctx.GetHeader("Authorization"))
+	var expiry time.Time
+	if claims.ExpiresAt != nil {
+		expiry = claims.ExpiresAt.Time
+	}
+	s.verifier.Revoke(token, expiry)
+	ctx.Status(http.StatusNoContent)
+}
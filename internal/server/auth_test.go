@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	logger.Get(true)
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	r := gin.Default()
+	r.Use(srv.Authenticate)
+	r.GET("/public", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, viewerUID(ctx))
+	})
+	r.GET("/private", srv.RequireAuth, func(ctx *gin.Context) {
+		uid, _ := authUID(ctx)
+		ctx.String(http.StatusOK, uid)
+	})
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+
+	type want struct {
+		public    string
+		status    int
+		body      string
+		challenge string
+	}
+	type test struct {
+		name   string
+		header string
+		want   want
+	}
+	tests := []test{
+		{
+			name:   "Test auth middleware; Case 1:",
+			header: "Bearer " + testToken(t, "testUID"),
+			want:   want{public: "testUID", status: http.StatusOK, body: "testUID"},
+		},
+		{
+			name:   "Test auth middleware; Case 2:",
+			header: testToken(t, "testUID"),
+			want:   want{public: "testUID", status: http.StatusOK, body: "testUID"},
+		},
+		{
+			name: "Test auth middleware; Case 3:",
+			want: want{
+				status:    http.StatusUnauthorized,
+				body:      `{"error":"Invalid token"}`,
+				challenge: `Bearer realm="g2-books"`,
+			},
+		},
+		{
+			name:   "Test auth middleware; Case 4:",
+			header: "Bearer not-a-token",
+			want: want{
+				status:    http.StatusUnauthorized,
+				body:      `{"error":"Invalid token"}`,
+				challenge: `Bearer realm="g2-books", error="invalid_token"`,
+			},
+		},
+		{
+			name:   "Test auth middleware; Case 5:",
+			header: "Basic dXNlcjpwYXNz",
+			want: want{
+				status:    http.StatusUnauthorized,
+				body:      `{"error":"Invalid token"}`,
+				challenge: `Bearer realm="g2-books", error="invalid_token"`,
+			},
+		},
+	}
+	client := resty.New().SetBaseURL(httpSrv.URL)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := func() *resty.Request {
+				r := client.R()
+				if tc.header != "" {
+					r.SetHeader("Authorization", tc.header)
+				}
+				return r
+			}
+			resp, err := req().Get("/public")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode())
+			assert.Equal(t, tc.want.public, resp.String())
+
+			resp, err = req().Get("/private")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.status, resp.StatusCode())
+			assert.Equal(t, tc.want.body, resp.String())
+			assert.Equal(t, tc.want.challenge, resp.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+// fakeAuthClient answers Refresh and Logout like the auth service does, or fails every call
+// with err.
+type fakeAuthClient struct {
+	authservicev1.AuthServiceClient
+	refresh string
+	tokens  map[string]string
+	err     error
+}
+
+func (c *fakeAuthClient) Login(_ context.Context, _ *authservicev1.UserCreds,
+	_ ...grpc.CallOption) (*authservicev1.AuthResponse, error) {
+	return nil, c.err
+}
+
+func (c *fakeAuthClient) Refresh(_ context.Context, in *authservicev1.RefreshRequest,
+	_ ...grpc.CallOption) (*authservicev1.AuthResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	token, ok := c.tokens[in.GetRefreshToken()]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unknown refresh token")
+	}
+	delete(c.tokens, in.GetRefreshToken())
+	c.tokens[c.refresh] = token
+	return &authservicev1.AuthResponse{Token: token, RefreshToken: c.refresh}, nil
+}
+
+func (c *fakeAuthClient) Logout(_ context.Context, in *authservicev1.LogoutRequest,
+	_ ...grpc.CallOption) (*authservicev1.LogoutResponse, error) {
+	delete(c.tokens, in.GetRefreshToken())
+	return &authservicev1.LogoutResponse{}, nil
+}
+
+func TestRefreshLogout(t *testing.T) {
+	logger.Get(true)
+	token := testToken(t, "testUID")
+	auth := &fakeAuthClient{refresh: "refresh2", tokens: map[string]string{"refresh1": token}}
+	srv := New("0.0.0.0:8080", nil, auth, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+	client := resty.New().SetBaseURL(httpSrv.URL)
+
+	resp, err := client.R().SetBody(`{}`).Post("/user/refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, err = client.R().SetBody(`{"refresh_token":"refresh1"}`).Post("/user/refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, token, resp.Header().Get("Authorization"))
+	assert.Equal(t, "refresh2", resp.Header().Get(refreshHeader))
+	// Refresh tokens are used up.
+	resp, err = client.R().SetBody(`{"refresh_token":"refresh1"}`).Post("/user/refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+
+	resp, err = client.R().SetBody(`{"refresh_token":"refresh2"}`).Post("/user/logout")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+token).SetBody(`{"refresh_token":"refresh2"}`).
+		Post("/user/logout")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	assert.Empty(t, auth.tokens)
+
+	// The token is revoked, with or without the Bearer scheme.
+	resp, err = client.R().SetHeader("Authorization", token).Post("/user/logout")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	assert.Equal(t, `Bearer realm="g2-books", error="invalid_token"`, resp.Header().Get("WWW-Authenticate"))
+}
+
+func TestAuthUnavailable(t *testing.T) {
+	logger.Get(true)
+	auth := &fakeAuthClient{err: status.Error(codes.Unavailable, "circuit breaker is open")}
+	srv := New("0.0.0.0:8080", nil, auth, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+	client := resty.New().SetBaseURL(httpSrv.URL)
+
+	resp, err := client.R().SetBody(`{"email":"alice@example.org","pass":"pass"}`).Post("/user/auth")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode())
+	resp, err = client.R().SetBody(`{"refresh_token":"refresh1"}`).Post("/user/refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode())
+	resp, err = client.R().SetBasicAuth("alice@example.org", "pass").Get("/opds")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode())
+
+	auth.err = status.Error(codes.Unauthenticated, "incorrect password")
+	resp, err = client.R().SetBody(`{"email":"alice@example.org","pass":"pass"}`).Post("/user/auth")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+}
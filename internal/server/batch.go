@@ -0,0 +1,135 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dorrrke/g2-books/internal/access"
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const maxBatchSize = 1000
+
+// batchRoles are the roles needed on a book to update or delete it in a batch.
+var batchRoles = map[string]string{ //nolint: gochecknoglobals //read-only table
+	models.BookOpUpdate: models.RoleEditor,
+	models.BookOpDelete: models.RoleOwner,
+}
+
+var (
+	errBatchEmpty    = errors.New(errText.BatchEmptyError)
+	errBatchTooLarge = errors.New(errText.BatchTooLargeError)
+	errBookIDMissing = errors.New(errText.BookIDRequiredError)
+	errBookFields    = errors.New(errText.BookFieldsError)
+)
+
+// BatchBooksHandler applies a list of create/update/delete operations to the caller's books,
+// and to books shared with them as far as their role allows, in a single transaction. With
+// "atomic" set nothing is written unless every operation succeeds.
+func (s *Server) BatchBooksHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	var batch models.BookBatch
+	if err := ctx.ShouldBindBodyWithJSON(&batch); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(batch.Ops) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errBatchEmpty.Error()})
+		return
+	}
+	if len(batch.Ops) > maxBatchSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errBatchTooLarge.Error()})
+		return
+	}
+
+	results := make([]models.BookOpResult, len(batch.Ops))
+	valid := make([]models.BookOp, 0, len(batch.Ops))
+	positions := make([]int, 0, len(batch.Ops))
+	for i, op := range batch.Ops {
+		results[i] = models.BookOpResult{Index: i, Op: op.Op, BID: op.Book.BID}
+		if err := validateBookOp(op); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		op.Book.UID = uid
+		if op.Op != models.BookOpCreate {
+			// Shared books are changed on behalf of their owner.
+			book, role, checkErr := s.checkBookRole(s.store(ctx), op.Book.BID, uid, batchRoles[op.Op])
+			if checkErr != nil {
+				if !errors.Is(checkErr, storage.ErrBookNotFound) && !errors.Is(checkErr, errForbidden) {
+					log.Error().Err(checkErr).Msg("authorize book batch failed")
+					ctx.JSON(http.StatusInternalServerError, gin.H{"error": checkErr.Error()})
+					return
+				}
+				results[i].Error = checkErr.Error()
+				continue
+			}
+			op.Book.UID = book.UID
+			// Shelves decide who else may see a book, so only owners move it between them.
+			op.KeepShelves = !access.Allows(role, models.RoleOwner)
+		}
+		valid = append(valid, op)
+		positions = append(positions, i)
+	}
+	if len(valid) != len(batch.Ops) && batch.Atomic {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"committed": false, "results": results})
+		return
+	}
+	if len(valid) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"committed": false, "results": results})
+		return
+	}
+
+	applied, err := s.store(ctx).ApplyBookOps(valid, batch.Atomic)
+	for j, res := range applied {
+		res.Index = positions[j]
+		results[positions[j]] = res
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrBatchAborted) {
+			ctx.JSON(http.StatusConflict, gin.H{"committed": false, "results": results})
+			return
+		}
+		log.Error().Err(err).Msg("apply book batch failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, res := range applied {
+		if res.Op == models.BookOpDelete && res.Error == "" {
+			s.deleteChan <- 1
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"committed": true, "results": results})
+}
+
+func validateBookOp(op models.BookOp) error {
+	switch op.Op {
+	case models.BookOpCreate:
+		if op.Book.Lable == "" || op.Book.Author == "" {
+			return errBookFields
+		}
+	case models.BookOpUpdate:
+		if op.Book.BID == "" {
+			return errBookIDMissing
+		}
+		if op.Book.Lable == "" || op.Book.Author == "" {
+			return errBookFields
+		}
+	case models.BookOpDelete:
+		if op.Book.BID == "" {
+			return errBookIDMissing
+		}
+	default:
+		return storage.ErrUnknownBookOp
+	}
+	return nil
+}
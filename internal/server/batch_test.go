@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	mocks "github.com/Dorrrke/g2-books/moks"
+)
+
+func TestBatchBooksHandler(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	type want struct {
+		mockFlag   bool
+		statusCode int
+		body       string
+	}
+	type test struct {
+		name    string
+		token   string
+		body    string
+		books   []models.Book
+		ops     []models.BookOp
+		atomic  bool
+		results []models.BookOpResult
+		err     error
+		want    want
+	}
+
+	tests := []test{
+		{
+			name:  "Test BatchBooksHandler; Case 1:",
+			token: testToken(t, "testUID"),
+			body:  `{"ops":[{"op":"create","book":{"lable":"b_lable","author":"b_author"}},{"op":"update","book":{}}]}`,
+			ops: []models.BookOp{
+				{Op: models.BookOpCreate, Book: models.Book{Lable: "b_lable", Author: "b_author", UID: "testUID"}},
+			},
+			results: []models.BookOpResult{{Index: 0, Op: models.BookOpCreate, BID: "bid1"}},
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				body: `{"committed":true,"results":[{"index":0,"op":"create","b_id":"bid1"},` +
+					`{"index":1,"op":"update","error":"book id is required"}]}`,
+			},
+		},
+		{
+			name:  "Test BatchBooksHandler; Case 2:",
+			token: "bad token",
+			body:  `{"ops":[{"op":"delete","book":{"b_id":"bid1"}}]}`,
+			want: want{
+				statusCode: http.StatusUnauthorized,
+				body:       `{"error":"Invalid token"}`,
+			},
+		},
+		{
+			name:  "Test BatchBooksHandler; Case 3:",
+			token: testToken(t, "testUID"),
+			body:  `{"atomic":true,"ops":[{"op":"delete","book":{"b_id":"bid1"}},{"op":"move","book":{}}]}`,
+			books: []models.Book{{BID: "bid1", UID: "testUID"}},
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+				body: `{"committed":false,"results":[{"index":0,"op":"delete","b_id":"bid1"},` +
+					`{"index":1,"op":"move","error":"unknown batch operation"}]}`,
+			},
+		},
+		{
+			name:  "Test BatchBooksHandler; Case 4:",
+			token: testToken(t, "testUID"),
+			body:  `{"atomic":true,"ops":[{"op":"delete","book":{"b_id":"bid1"}}]}`,
+			books: []models.Book{{BID: "bid1", UID: "testUID"}},
+			ops: []models.BookOp{
+				{Op: models.BookOpDelete, Book: models.Book{BID: "bid1", UID: "testUID"}},
+			},
+			atomic:  true,
+			results: []models.BookOpResult{{Index: 0, Op: models.BookOpDelete, BID: "bid1", Error: "book not found"}},
+			err:     storage.ErrBatchAborted,
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusConflict,
+				body:       `{"committed":false,"results":[{"index":0,"op":"delete","b_id":"bid1","error":"book not found"}]}`,
+			},
+		},
+		{
+			name:  "Test BatchBooksHandler; Case 5:",
+			token: testToken(t, "testUID"),
+			body:  `{"ops":[]}`,
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"batch has no operations"}`,
+			},
+		},
+		{
+			name:  "Test BatchBooksHandler; Case 6:",
+			token: testToken(t, "testUID"),
+			body:  `{"ops":[{"op":"update","book":{"b_id":"bid1","lable":"l","author":"a"}}]}`,
+			books: []models.Book{{BID: "bid1", UID: "testUID"}},
+			ops: []models.BookOp{
+				{Op: models.BookOpUpdate, Book: models.Book{BID: "bid1", Lable: "l", Author: "a", UID: "testUID"}},
+			},
+			err: fmt.Errorf("test error"),
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusInternalServerError,
+				body:       `{"error":"test error"}`,
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			for _, book := range tc.books {
+				m.EXPECT().GetBookByID(book.BID).Return(book, nil)
+			}
+			if tc.want.mockFlag {
+				m.EXPECT().ApplyBookOps(tc.ops, tc.atomic).Return(tc.results, tc.err)
+			}
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/books/batch"
+			req.Body = tc.body
+			req.SetHeader("Authorization", tc.token)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dorrrke/g2-books/internal/calibre"
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/importer"
+)
+
+const jobKindCalibreImport = "calibre-import"
+
+var (
+	errCalibreDisabled = errors.New(errText.CalibreDisabledError)
+	errLibraryPath     = errors.New(errText.LibraryPathError)
+	errJobNotFound     = errors.New(errText.JobNotFoundError)
+)
+
+// CalibreImportHandler starts a background import of a Calibre library stored on the server.
+// The path is resolved below the configured Calibre root; the response points at the job.
+func (s *Server) CalibreImportHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if s.calibreRoot == "" {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errCalibreDisabled.Error()})
+		return
+	}
+	var req models.CalibreImport
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dir, ok := s.calibreLibrary(req.Path)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errLibraryPath.Error()})
+		return
+	}
+
+	// The job outlives the request, so the library it imports into is resolved now.
+	store := s.store(ctx)
+	job := s.jobs.Start(uid, jobKindCalibreImport, func(jobCtx context.Context) (any, error) {
+		rows, readErr := calibre.Read(jobCtx, dir, req.Source)
+		if readErr != nil {
+			return nil, readErr
+		}
+		report, importErr := importer.Import(store, uid, rows, req.DryRun)
+		if importErr != nil {
+			return nil, importErr
+		}
+		return report, nil
+	})
+	ctx.Header("Location", "/jobs/"+job.ID)
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// calibreLibrary resolves path to a library directory below the Calibre root. Symlinks are
+// followed before the check, so a link inside the root cannot lead out of it.
+func (s *Server) calibreLibrary(path string) (string, bool) {
+	root, err := filepath.EvalSymlinks(s.calibreRoot)
+	if err != nil {
+		return "", false
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join(root, filepath.Clean("/"+path)))
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if info, statErr := os.Stat(dir); statErr != nil || !info.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
+
+// JobHandler returns the state of one of the caller's background jobs.
+func (s *Server) JobHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	job, ok := s.jobs.Get(ctx.Param("id"))
+	if !ok || job.UID != uid {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": errJobNotFound.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/jobs"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const calibreOPF = `<?xml version='1.0' encoding='utf-8'?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Dune Messiah</dc:title>
+    <dc:creator>Frank Herbert</dc:creator>
+  </metadata>
+</package>`
+
+// writeLibrary creates a Calibre library of one book in dir.
+func writeLibrary(t *testing.T, dir string) {
+	t.Helper()
+	book := filepath.Join(dir, "Frank Herbert", "Dune Messiah (1)")
+	require.NoError(t, os.MkdirAll(book, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(book, "metadata.opf"), []byte(calibreOPF), 0o600))
+}
+
+func TestCalibreImportHandler(t *testing.T) {
+	logger.Get(true)
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeLibrary(t, filepath.Join(root, "library"))
+	writeLibrary(t, outside)
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "library"), filepath.Join(root, "alias")))
+
+	store := storage.New()
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithCalibreRoot(root))
+	defer srv.jobs.Close()
+	disabled := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()))
+	token := "Bearer " + testToken(t, "testUID")
+	client := resty.New().SetBaseURL(testServer(t, srv).URL).SetHeader("Authorization", token)
+	disabledClient := resty.New().SetBaseURL(testServer(t, disabled).URL).SetHeader("Authorization", token)
+
+	type test struct {
+		name   string
+		client *resty.Client
+		body   string
+		status int
+	}
+	tests := []test{
+		{name: "Test CalibreImportHandler; Case 1:", client: disabledClient, body: `{"path":"library"}`,
+			status: http.StatusForbidden},
+		{name: "Test CalibreImportHandler; Case 2:", client: client, body: `{"path":"missing"}`,
+			status: http.StatusBadRequest},
+		{name: "Test CalibreImportHandler; Case 3:", client: client, body: `{"path":"../../etc"}`,
+			status: http.StatusBadRequest},
+		{name: "Test CalibreImportHandler; Case 4:", client: client, body: `{"path":"escape"}`,
+			status: http.StatusBadRequest},
+		{name: "Test CalibreImportHandler; Case 5:", client: client, body: `{"path":"alias"}`,
+			status: http.StatusAccepted},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := tc.client.R().SetBody(tc.body).Post("/books/import/calibre")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode())
+		})
+	}
+
+	var job jobs.Job
+	resp, err := client.R().SetBody(`{"path":"library","dry_run":true}`).SetResult(&job).
+		Post("/books/import/calibre")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode())
+	assert.Equal(t, "/jobs/"+job.ID, resp.Header().Get("Location"))
+	require.Eventually(t, func() bool {
+		resp, err = client.R().SetResult(&job).Get("/jobs/" + job.ID)
+		return err == nil && resp.StatusCode() == http.StatusOK && job.Status != jobs.StatusRunning
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, jobs.StatusDone, job.Status)
+
+	// Jobs of other users look missing.
+	resp, err = client.R().SetHeader("Authorization", "Bearer "+testToken(t, "otherUID")).Get("/jobs/" + job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+}
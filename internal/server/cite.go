@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/cite"
+	"github.com/Dorrrke/g2-books/internal/logger"
+)
+
+var errCitationStyle = errors.New(errText.CitationStyleError)
+
+// CiteBookHandler renders a book the caller can read in a citation style: apa (the default),
+// chicago, bibtex, ris or csl-json.
+func (s *Server) CiteBookHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	style, ok := cite.Lookup(ctx.DefaultQuery("style", cite.StyleAPA))
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errCitationStyle.Error()})
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleViewer)
+	if !ok {
+		return
+	}
+	ctx.Header("Content-Type", style.ContentType)
+	ctx.Status(http.StatusOK)
+	if err := style.Write(ctx.Writer, []models.Book{book}); err != nil {
+		log.Error().Err(err).Msg("cite book failed")
+	}
+}
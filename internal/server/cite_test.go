@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	mocks "github.com/Dorrrke/g2-books/moks"
+)
+
+func TestCiteBookHandler(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	book := models.Book{
+		BID: "bid1", Lable: "Dune", Author: "Frank Herbert", UID: "testUID", Year: 1965, Publisher: "Chilton Books",
+	}
+	type want struct {
+		statusCode  int
+		contentType string
+		body        string
+	}
+	type test struct {
+		name     string
+		query    string
+		uid      string
+		mockFlag bool
+		want     want
+	}
+	tests := []test{
+		{
+			name:     "Test CiteBookHandler; Case 1:",
+			uid:      "testUID",
+			mockFlag: true,
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "text/plain; charset=utf-8",
+				body:        "Herbert, F. (1965). Dune. Chilton Books.\n",
+			},
+		},
+		{
+			name:     "Test CiteBookHandler; Case 2:",
+			query:    "?style=chicago",
+			uid:      "testUID",
+			mockFlag: true,
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "text/plain; charset=utf-8",
+				body:        "Herbert, Frank. Dune. Chilton Books, 1965.\n",
+			},
+		},
+		{
+			name:     "Test CiteBookHandler; Case 3:",
+			query:    "?style=bibtex",
+			uid:      "testUID",
+			mockFlag: true,
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "application/x-bibtex; charset=utf-8",
+				body: "@book{herbert1965dune,\n  author    = {Herbert, Frank},\n  title     = {Dune},\n" +
+					"  publisher = {Chilton Books},\n  year      = {1965},\n}\n",
+			},
+		},
+		{
+			name:  "Test CiteBookHandler; Case 4:",
+			query: "?style=mla",
+			uid:   "testUID",
+			want: want{
+				statusCode:  http.StatusBadRequest,
+				contentType: "application/json; charset=utf-8",
+				body:        `{"error":"unknown citation style; use apa, chicago, bibtex, ris or csl-json"}`,
+			},
+		},
+		{
+			name:     "Test CiteBookHandler; Case 5:",
+			uid:      "otherUID",
+			mockFlag: true,
+			want: want{
+				statusCode:  http.StatusNotFound,
+				contentType: "application/json; charset=utf-8",
+				body:        `{"error":"book not found"}`,
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			if tc.mockFlag {
+				m.EXPECT().GetBookByID("bid1").Return(book, nil)
+			}
+			if tc.mockFlag && tc.uid != book.UID {
+				m.EXPECT().GetUserGrants(book.UID, tc.uid).Return([]models.Grant{}, nil)
+			}
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodGet
+			req.URL = httpSrv.URL + "/books/bid1/cite" + tc.query
+			req.SetHeader("Authorization", testToken(t, tc.uid))
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.contentType, resp.Header().Get("Content-Type"))
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
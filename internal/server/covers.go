@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dorrrke/g2-books/internal/blobstore"
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/thumbnail"
+)
+
+const (
+	maxCoverSize = 10 << 20
+	coverOrigin  = "original"
+	// Hashed cover URLs never change their content, so they may be cached for a year.
+	immutableCache = "private, max-age=31536000, immutable"
+)
+
+var (
+	errCoverNotFound = errors.New(errText.CoverNotFoundError)
+	errCoverType     = errors.New(errText.CoverTypeError)
+	errCoverTooLarge = errors.New(errText.CoverTooLargeError)
+)
+
+// PutCoverHandler replaces the cover of a book the caller may edit. The image is sent as the
+// request body or as the "file" field of a multipart form; thumbnails are rendered right away.
+func (s *Server) PutCoverHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if s.blobs == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errFilesDisabled.Error()})
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleEditor)
+	if !ok {
+		return
+	}
+	body, err := importBody(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxCoverSize+1))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errCoverTooLarge.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(data) > maxCoverSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errCoverTooLarge.Error()})
+		return
+	}
+	// A declared type has to match the content; multipart uploads are checked by content only.
+	if declared := ctx.ContentType(); !strings.HasPrefix(declared, "multipart/") && declared != "" &&
+		declared != http.DetectContentType(data) {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": errCoverType.Error()})
+		return
+	}
+	book, err = s.storeCover(ctx.Request.Context(), s.store(ctx), book, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, thumbnail.ErrUnsupported):
+			ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": errCoverType.Error()})
+		case errors.Is(err, thumbnail.ErrDimensions):
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			log.Error().Err(err).Msg("store cover failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, s.withCovers(book))
+}
+
+// CoverHandler serves the current cover of a book the caller can read. Clients that want to
+// cache covers should use the hashed URLs from the book JSON instead.
+func (s *Server) CoverHandler(ctx *gin.Context) {
+	uid, ok := s.readerUID(ctx)
+	if !ok {
+		return
+	}
+	book, ok := s.coverBook(ctx, uid)
+	if !ok {
+		return
+	}
+	ctx.Header("Cache-Control", "private, no-cache")
+	s.serveCover(ctx, book.Cover, `"`+coverHash(book.Cover)+`"`, "")
+}
+
+// CoverImageHandler serves a cover or one of its thumbnails by content hash. A hash of a
+// replaced cover is not found, so a cached image is never served for the wrong cover.
+func (s *Server) CoverImageHandler(ctx *gin.Context) {
+	uid, ok := s.readerUID(ctx)
+	if !ok {
+		return
+	}
+	book, ok := s.coverBook(ctx, uid)
+	if !ok {
+		return
+	}
+	hash, size := ctx.Param("hash"), ctx.Param("size")
+	if hash != coverHash(book.Cover) || size != coverOrigin && !knownThumbnail(size) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": errCoverNotFound.Error()})
+		return
+	}
+	ctx.Header("Cache-Control", immutableCache)
+	if size == coverOrigin {
+		s.serveCover(ctx, book.Cover, `"`+hash+`"`, "")
+		return
+	}
+	s.serveCover(ctx, thumbnailKey(book.Cover, size), `"`+hash+"-"+size+`"`, thumbnail.ContentType)
+}
+
+// coverBook loads the book named in the path, writing an error unless files are enabled and
+// the book has a cover.
+func (s *Server) coverBook(ctx *gin.Context, uid string) (models.Book, bool) {
+	if s.blobs == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errFilesDisabled.Error()})
+		return models.Book{}, false
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleViewer)
+	if !ok {
+		return models.Book{}, false
+	}
+	if book.Cover == "" {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": errCoverNotFound.Error()})
+		return models.Book{}, false
+	}
+	return book, true
+}
+
+func (s *Server) serveCover(ctx *gin.Context, key, etag, contentType string) {
+	log := logger.Get()
+	blob, err := s.blobs.Open(ctx.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": errCoverNotFound.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("open cover failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer blob.Close()
+	ctx.Header("ETag", etag)
+	if contentType != "" {
+		ctx.Header("Content-Type", contentType)
+	}
+	http.ServeContent(ctx.Writer, ctx.Request, "", blob.ModTime, blob)
+}
+
+// storeCover validates a cover image, stores it with its thumbnails under its content hash
+// and makes it the cover of book in store. The blobs of the replaced cover are removed.
+func (s *Server) storeCover(ctx context.Context, store Storage, book models.Book, data []byte) (models.Book, error) {
+	log := logger.Get()
+	img, _, err := thumbnail.Decode(data)
+	if err != nil {
+		return book, err
+	}
+	sum := sha256.Sum256(data)
+	key := "covers/" + book.BID + "/" + hex.EncodeToString(sum[:])
+	if key == book.Cover {
+		return book, nil
+	}
+	for _, size := range thumbnail.Sizes {
+		thumb, renderErr := thumbnail.Render(img, size)
+		if renderErr != nil {
+			return book, renderErr
+		}
+		if _, err = s.blobs.Put(ctx, thumbnailKey(key, size.Name), bytes.NewReader(thumb)); err != nil {
+			return book, err
+		}
+	}
+	// The original goes last: a cover key only appears once all its thumbnails exist.
+	if _, err = s.blobs.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return book, err
+	}
+	if err = store.SetBookCover(book.UID, book.BID, key); err != nil {
+		return book, err
+	}
+	if book.Cover != "" {
+		for _, old := range coverKeys(book.Cover) {
+			if err = s.blobs.Delete(ctx, old); err != nil {
+				log.Error().Err(err).Str("key", old).Msg("delete replaced cover failed")
+			}
+		}
+	}
+	book.Cover = key
+	return book, nil
+}
+
+// withCovers sets the cover URLs of book if it has a cover.
+func (s *Server) withCovers(book models.Book) models.Book {
+	if s.blobs == nil || book.Cover == "" {
+		return book
+	}
+	base := "/books/" + book.BID + "/cover/" + coverHash(book.Cover) + "/"
+	book.Covers = &models.Covers{
+		Original: base + coverOrigin,
+		Small:    base + "small",
+		Medium:   base + "medium",
+		Large:    base + "large",
+	}
+	return book
+}
+
+// withBooksCovers sets the cover URLs of every book in place.
+func (s *Server) withBooksCovers(books []models.Book) []models.Book {
+	for i := range books {
+		books[i] = s.withCovers(books[i])
+	}
+	return books
+}
+
+// coverHash returns the content hash a cover key ends with.
+func coverHash(key string) string {
+	return path.Base(key)
+}
+
+func thumbnailKey(key, size string) string {
+	return key + "_" + size
+}
+
+// coverKeys lists the blobs of a cover: the original and its thumbnails.
+func coverKeys(key string) []string {
+	keys := []string{key}
+	for _, size := range thumbnail.Sizes {
+		keys = append(keys, thumbnailKey(key, size.Name))
+	}
+	return keys
+}
+
+func knownThumbnail(name string) bool {
+	for _, size := range thumbnail.Sizes {
+		if size.Name == name {
+			return true
+		}
+	}
+	return false
+}
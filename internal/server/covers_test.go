@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/blobstore"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+func TestCoversFlow(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	assert.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: "testUID"}))
+	books, err := store.GetBookByUID("testUID")
+	require.NoError(t, err)
+	bid := books[0].BID
+	blobs, err := blobstore.NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithBlobStore(blobs))
+	httpSrv := testServer(t, srv)
+
+	client := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "testUID"))
+	resp, err := client.R().Get("/books/" + bid + "/cover")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	cover := testPNG(t, 800, 1200)
+	resp, err = client.R().SetHeader("Content-Type", "image/jpeg").SetBody(cover).Put("/books/" + bid + "/cover")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode())
+	resp, err = client.R().SetHeader("Content-Type", "text/plain").SetBody("not an image").Put("/books/" + bid + "/cover")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode())
+
+	var book models.Book
+	resp, err = client.R().SetHeader("Content-Type", "image/png").SetBody(cover).SetResult(&book).
+		Put("/books/" + bid + "/cover")
+	assert.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.NotNil(t, book.Covers)
+	assert.Regexp(t, `^/books/`+bid+`/cover/[0-9a-f]{64}/small$`, book.Covers.Small)
+
+	resp, err = client.R().Get(book.Covers.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "image/jpeg", resp.Header().Get("Content-Type"))
+	assert.Equal(t, "private, max-age=31536000, immutable", resp.Header().Get("Cache-Control"))
+	resp, err = client.R().SetHeader("If-None-Match", resp.Header().Get("ETag")).Get(book.Covers.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode())
+	resp, err = client.R().Get(book.Covers.Original)
+	assert.NoError(t, err)
+	assert.Equal(t, cover, resp.Body())
+	resp, err = client.R().Get("/books/" + bid + "/cover/" + "0123/small")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	var replaced models.Book
+	resp, err = client.R().SetFileReader("file", "cover.png", bytes.NewReader(testPNG(t, 300, 200))).
+		SetResult(&replaced).Put("/books/" + bid + "/cover")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.NotNil(t, replaced.Covers)
+	assert.NotEqual(t, book.Covers.Large, replaced.Covers.Large)
+	resp, err = client.R().Get(book.Covers.Large)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	var listed []models.Book
+	resp, err = client.R().SetResult(&listed).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, listed, 1)
+	assert.Equal(t, replaced.Covers, listed[0].Covers)
+
+	resp, err = client.R().SetHeader("Authorization", testToken(t, "otherUID")).Get(replaced.Covers.Medium)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+}
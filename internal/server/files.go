@@ -0,0 +1,288 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Dorrrke/g2-books/internal/blobstore"
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/epub"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/thumbnail"
+)
+
+const (
+	maxFileSize = 200 << 20
+	pdfType     = "application/pdf"
+)
+
+var (
+	errFilesDisabled = errors.New(errText.FilesDisabledError)
+	errFileType      = errors.New(errText.FileTypeError)
+	errFileTooLarge  = errors.New(errText.FileTooLargeError)
+)
+
+// UploadBookFileHandler attaches an EPUB or PDF file, sent as the "file" field of a multipart
+// form, to a book the caller may edit. Metadata found in an EPUB fills the book's empty fields
+// and its cover image becomes the book cover unless the book already has one.
+func (s *Server) UploadBookFileHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if s.blobs == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errFilesDisabled.Error()})
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleEditor)
+	if !ok {
+		return
+	}
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxFileSize)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errFileTooLarge.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errImportFile.Error()})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	contentType, meta, err := detectBookFile(file, header.Size)
+	if err != nil {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+
+	record := models.BookFile{
+		FID:         uuid.New().String(),
+		BID:         book.BID,
+		UID:         book.UID,
+		Name:        fileName(header.Filename, contentType),
+		ContentType: contentType,
+	}
+	hash := sha256.New()
+	key := bookFileKey(record)
+	if record.Size, err = s.blobs.Put(ctx.Request.Context(), key, io.TeeReader(file, hash)); err != nil {
+		log.Error().Err(err).Msg("store book file failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	record.Hash = hex.EncodeToString(hash.Sum(nil))
+	if err = s.store(ctx).SaveBookFile(record); err != nil {
+		log.Error().Err(err).Msg("save book file failed")
+		if delErr := s.blobs.Delete(ctx.Request.Context(), key); delErr != nil {
+			log.Error().Err(delErr).Msg("delete orphaned blob failed")
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := models.BookFileUpload{File: record, Book: book}
+	if meta != nil {
+		if result, err = s.prefillBook(ctx.Request.Context(), s.store(ctx), result, *meta); err != nil {
+			log.Error().Err(err).Msg("prefill book failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	result.Book = s.withCovers(result.Book)
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// BookFilesHandler lists the files attached to a book the caller can read.
+func (s *Server) BookFilesHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleViewer)
+	if !ok {
+		return
+	}
+	files, err := s.store(ctx).GetBookFiles(book.UID, book.BID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, files)
+}
+
+// DownloadBookFileHandler serves an attached file. Range and conditional requests are
+// supported, so readers can resume downloads. E-reader apps may use Basic credentials.
+func (s *Server) DownloadBookFileHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := s.readerUID(ctx)
+	if !ok {
+		return
+	}
+	if s.blobs == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errFilesDisabled.Error()})
+		return
+	}
+	record, ok := s.bookFile(ctx, uid, models.RoleViewer)
+	if !ok {
+		return
+	}
+	blob, err := s.blobs.Open(ctx.Request.Context(), bookFileKey(record))
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": storage.ErrFileNotFound.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("open book file failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer blob.Close()
+	ctx.Header("Content-Type", record.ContentType)
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": record.Name}))
+	ctx.Header("ETag", `"`+record.Hash+`"`)
+	http.ServeContent(ctx.Writer, ctx.Request, record.Name, blob.ModTime, blob)
+}
+
+func (s *Server) DeleteBookFileHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if s.blobs == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errFilesDisabled.Error()})
+		return
+	}
+	record, ok := s.bookFile(ctx, uid, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if err := s.store(ctx).DeleteBookFile(record.UID, record.FID); err != nil {
+		if errors.Is(err, storage.ErrFileNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.blobs.Delete(ctx.Request.Context(), bookFileKey(record)); err != nil {
+		log.Error().Err(err).Msg("delete book file blob failed")
+	}
+	ctx.String(http.StatusOK, "file was deleted")
+}
+
+// prefillBook copies EPUB metadata into the empty fields of the uploaded book and stores the
+// EPUB cover if the book has none yet.
+func (s *Server) prefillBook(ctx context.Context, store Storage, result models.BookFileUpload,
+	meta epub.Metadata) (models.BookFileUpload, error) {
+	book := result.Book
+	found := meta.Book()
+	for _, f := range []struct {
+		name      string
+		dst       *string
+		extracted string
+	}{
+		{"lable", &book.Lable, found.Lable},
+		{"author", &book.Author, found.Author},
+		{"language", &book.Language, found.Language},
+		{"isbn", &book.ISBN, found.ISBN},
+	} {
+		if *f.dst == "" && f.extracted != "" {
+			*f.dst = f.extracted
+			result.Prefilled = append(result.Prefilled, f.name)
+		}
+	}
+	if len(result.Prefilled) != 0 {
+		_, err := store.ApplyBookOps([]models.BookOp{{Op: models.BookOpUpdate, Book: book}}, true)
+		if err != nil {
+			return result, err
+		}
+	}
+	if book.Cover == "" && len(meta.Cover) != 0 {
+		// An EPUB cover the thumbnailer cannot read is skipped rather than failing the upload.
+		covered, err := s.storeCover(ctx, store, book, meta.Cover)
+		switch {
+		case err == nil:
+			book, result.CoverExtracted = covered, true
+		case !errors.Is(err, thumbnail.ErrUnsupported) && !errors.Is(err, thumbnail.ErrDimensions):
+			return result, err
+		}
+	}
+	result.Book = book
+	return result, nil
+}
+
+// bookFile loads the file named in the path if uid holds at least role need on the book in
+// the path, writing a 404 unless the file belongs to that book.
+func (s *Server) bookFile(ctx *gin.Context, uid, need string) (models.BookFile, bool) {
+	book, ok := s.authorizeBook(ctx, uid, need)
+	if !ok {
+		return models.BookFile{}, false
+	}
+	record, err := s.store(ctx).GetBookFile(book.UID, ctx.Param("fid"))
+	if err == nil && record.BID == ctx.Param("id") {
+		return record, true
+	}
+	if err == nil || errors.Is(err, storage.ErrFileNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": storage.ErrFileNotFound.Error()})
+		return models.BookFile{}, false
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	return models.BookFile{}, false
+}
+
+// detectBookFile recognizes EPUB and PDF files by their content, returning the EPUB metadata
+// for EPUB files.
+func detectBookFile(r io.ReaderAt, size int64) (string, *epub.Metadata, error) {
+	head := make([]byte, 5) //nolint: gomnd //length of the PDF magic
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return "", nil, errFileType
+	}
+	switch {
+	case bytes.Equal(head, []byte("%PDF-")):
+		return pdfType, nil, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		meta, err := epub.Read(r, size)
+		if err != nil {
+			return "", nil, errFileType
+		}
+		return epub.MediaType, &meta, nil
+	}
+	return "", nil, errFileType
+}
+
+func bookFileKey(file models.BookFile) string {
+	return "files/" + file.UID + "/" + file.FID
+}
+
+// fileName keeps the base name of an uploaded file, falling back to a name made from its type.
+func fileName(name, contentType string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "book"
+		if contentType == pdfType {
+			return name + ".pdf"
+		}
+		return name + ".epub"
+	}
+	return name
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/blobstore"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+// testPNG encodes a width by height PNG filled with one color.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 120, B: 40, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func testEPUB(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"mimetype": "application/epub+zip",
+		"META-INF/container.xml": `<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"content.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Dune Messiah</dc:title>
+    <dc:creator>Frank Herbert</dc:creator>
+    <dc:language>en</dc:language>
+    <meta name="cover" content="cover-img"/>
+  </metadata>
+  <manifest><item id="cover-img" href="cover.png" media-type="image/png"/></manifest>
+</package>`,
+		"cover.png": string(testPNG(t, 60, 90)),
+	} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestBookFilesFlow(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	assert.NoError(t, store.SaveBook(models.Book{Lable: "Dune Messiah", UID: "testUID"}))
+	books, err := store.GetBookByUID("testUID")
+	require.NoError(t, err)
+	bid := books[0].BID
+	blobs, err := blobstore.NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithBlobStore(blobs))
+	httpSrv := testServer(t, srv)
+
+	client := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "testUID"))
+	data := testEPUB(t)
+	var upload models.BookFileUpload
+	resp, err := client.R().SetFileReader("file", "dune messiah.epub", bytes.NewReader(data)).
+		SetResult(&upload).Post("/books/" + bid + "/files")
+	assert.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode())
+	assert.Equal(t, "application/epub+zip", upload.File.ContentType)
+	assert.Equal(t, "dune messiah.epub", upload.File.Name)
+	assert.EqualValues(t, len(data), upload.File.Size)
+	assert.Len(t, upload.File.Hash, 64)
+	assert.Equal(t, []string{"author", "language"}, upload.Prefilled)
+	assert.True(t, upload.CoverExtracted)
+	book, err := store.GetBookByID(bid)
+	assert.NoError(t, err)
+	assert.Equal(t, "Frank Herbert", book.Author)
+	assert.Equal(t, "en", book.Language)
+	assert.NotEmpty(t, book.Cover)
+
+	resp, err = client.R().SetFileReader("file", "notes.txt", bytes.NewReader([]byte("plain text"))).
+		Post("/books/" + bid + "/files")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode())
+	resp, err = client.R().SetFileReader("file", "scan.pdf", bytes.NewReader([]byte("%PDF-1.7\n"))).
+		Post("/books/" + bid + "/files")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+
+	var files []models.BookFile
+	resp, err = client.R().SetResult(&files).Get("/books/" + bid + "/files")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Len(t, files, 2)
+
+	resp, err = client.R().SetHeader("Range", "bytes=0-3").Get("/books/" + bid + "/files/" + upload.File.FID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode())
+	assert.Equal(t, data[:4], resp.Body())
+	assert.Equal(t, "application/epub+zip", resp.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="dune messiah.epub"`, resp.Header().Get("Content-Disposition"))
+	assert.Equal(t, `"`+upload.File.Hash+`"`, resp.Header().Get("ETag"))
+
+	resp, err = client.R().Get("/books/" + bid + "/cover")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "image/png", resp.Header().Get("Content-Type"))
+
+	resp, err = client.R().SetHeader("Authorization", testToken(t, "otherUID")).
+		Get("/books/" + bid + "/files/" + upload.File.FID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = client.R().Delete("/books/" + bid + "/files/" + upload.File.FID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = client.R().Get("/books/" + bid + "/files/" + upload.File.FID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	// Purging the deleted book removes the blobs of its remaining files.
+	pdf := files[0]
+	if pdf.FID == upload.File.FID {
+		pdf = files[1]
+	}
+	require.NoError(t, store.DeleteBook(bid))
+	require.NoError(t, srv.purgeBooks(context.Background()))
+	_, err = blobs.Open(context.Background(), bookFileKey(pdf))
+	assert.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	srv.blobs = nil
+	resp, err = client.R().SetFileReader("file", "book.epub", bytes.NewReader(data)).Post("/books/" + bid + "/files")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode())
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/kindle"
+	"github.com/Dorrrke/g2-books/internal/formats/markdown"
+	"github.com/Dorrrke/g2-books/internal/importer"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const (
+	formatKindle   = "kindle"
+	formatMarkdown = "markdown"
+)
+
+var (
+	errHighlightKind  = errors.New(errText.HighlightKindError)
+	errHighlightColor = errors.New(errText.HighlightColorError)
+	errHighlightEmpty = errors.New(errText.HighlightEmptyError)
+	errPercentage     = errors.New(errText.PercentageError)
+	errSearchQuery    = errors.New(errText.SearchQueryError)
+)
+
+// BookHighlightsHandler lists the caller's highlights, notes and bookmarks for a book.
+func (s *Server) BookHighlightsHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	highlights, err := s.store(ctx).GetHighlightsByBook(uid, ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, highlights)
+}
+
+// CreateHighlightHandler adds a highlight, note or bookmark to a book the caller can read.
+// Highlights stay private to the caller, also on books shared with them.
+func (s *Server) CreateHighlightHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if _, ok := s.authorizeBook(ctx, uid, models.RoleViewer); !ok {
+		return
+	}
+	var h models.Highlight
+	if err := ctx.ShouldBindBodyWithJSON(&h); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateHighlight(&h); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	h.BID, h.UID, h.CreatedAt, h.Hash = ctx.Param("id"), uid, &now, ""
+	hid, err := s.store(ctx).SaveHighlight(h)
+	if err != nil {
+		log.Error().Err(err).Msg("save highlight failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.HID = hid
+	ctx.JSON(http.StatusCreated, h)
+}
+
+func (s *Server) GetHighlightHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	h, ok := s.userHighlight(ctx, uid)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, h)
+}
+
+// UpdateHighlightHandler replaces the editable fields of a highlight. The book it belongs to
+// and its creation time stay unchanged.
+func (s *Server) UpdateHighlightHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	old, ok := s.userHighlight(ctx, uid)
+	if !ok {
+		return
+	}
+	var h models.Highlight
+	if err := ctx.ShouldBindBodyWithJSON(&h); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateHighlight(&h); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.HID, h.BID, h.UID, h.CreatedAt = old.HID, old.BID, old.UID, old.CreatedAt
+	if err := s.store(ctx).UpdateHighlight(h); err != nil {
+		if errors.Is(err, storage.ErrHighlightNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("update highlight failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, h)
+}
+
+func (s *Server) DeleteHighlightHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	h, ok := s.userHighlight(ctx, uid)
+	if !ok {
+		return
+	}
+	if err := s.store(ctx).DeleteHighlight(uid, h.HID); err != nil {
+		if errors.Is(err, storage.ErrHighlightNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("delete highlight failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.String(http.StatusOK, "highlight was deleted")
+}
+
+// SearchHighlightsHandler runs a full-text search over the text and notes of the caller's
+// highlights. Either q or tag is required; both together narrow the result.
+func (s *Server) SearchHighlightsHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	query, tag := ctx.Query("q"), ctx.Query("tag")
+	if query == "" && tag == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errSearchQuery.Error()})
+		return
+	}
+	highlights, err := s.store(ctx).SearchHighlights(uid, query, tag)
+	if err != nil {
+		log.Error().Err(err).Msg("search highlights failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, highlights)
+}
+
+// ExportHighlightsHandler renders all highlights of a book as a Markdown note.
+func (s *Server) ExportHighlightsHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if ctx.DefaultQuery("format", formatMarkdown) != formatMarkdown {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errUnsupportedFormat.Error()})
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleViewer)
+	if !ok {
+		return
+	}
+	highlights, err := s.store(ctx).GetHighlightsByBook(uid, book.BID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", `attachment; filename="`+book.BID+`.md"`)
+	ctx.Header("Content-Type", markdown.ContentType)
+	ctx.Status(http.StatusOK)
+	if err = markdown.Write(ctx.Writer, book, highlights); err != nil {
+		log.Error().Err(err).Msg("markdown export failed")
+	}
+}
+
+// ImportHighlightsHandler imports a Kindle "My Clippings.txt" file for the caller.
+func (s *Server) ImportHighlightsHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	if ctx.DefaultQuery("format", formatKindle) != formatKindle {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errUnsupportedFormat.Error()})
+		return
+	}
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := importBody(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+	clippings, err := kindle.Parse(body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := importer.ImportClippings(s.store(ctx), uid, clippings, dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("import clippings failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// userHighlight loads the highlight named in the path, writing a 404 unless it belongs to uid
+// and to the book in the path.
+func (s *Server) userHighlight(ctx *gin.Context, uid string) (models.Highlight, bool) {
+	h, err := s.store(ctx).GetHighlightByID(uid, ctx.Param("hid"))
+	if err == nil && h.BID == ctx.Param("id") {
+		return h, true
+	}
+	if err == nil || errors.Is(err, storage.ErrHighlightNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": storage.ErrHighlightNotFound.Error()})
+		return models.Highlight{}, false
+	}
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	return models.Highlight{}, false
+}
+
+// validateHighlight defaults the kind and checks the fields a client may set.
+func validateHighlight(h *models.Highlight) error {
+	if h.Kind == "" {
+		h.Kind = models.HighlightHighlight
+	}
+	switch h.Kind {
+	case models.HighlightHighlight, models.HighlightNote, models.HighlightBookmark:
+	default:
+		return errHighlightKind
+	}
+	switch h.Color {
+	case "", models.ColorYellow, models.ColorBlue, models.ColorPink, models.ColorOrange, models.ColorGreen:
+	default:
+		return errHighlightColor
+	}
+	if h.Percentage < 0 || h.Percentage > 100 {
+		return errPercentage
+	}
+	located := h.Location != "" || h.Page != 0 || h.Percentage != 0 || h.CFI != ""
+	if !located && (h.Kind == models.HighlightBookmark || h.Text == "" && h.Note == "") {
+		return errHighlightEmpty
+	}
+	return nil
+}
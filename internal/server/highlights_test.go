@@ -0,0 +1,188 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	mocks "github.com/Dorrrke/g2-books/moks"
+)
+
+func TestCreateHighlightHandler(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	type want struct {
+		bookFlag   bool
+		grantsFlag bool
+		mockFlag   bool
+		statusCode int
+		body       string
+	}
+	type test struct {
+		name    string
+		token   string
+		body    string
+		book    models.Book
+		bookErr error
+		want    want
+	}
+
+	tests := []test{
+		{
+			name:  "Test CreateHighlightHandler; Case 1:",
+			token: testToken(t, "testUID"),
+			body:  `{"text":"I must not fear.","color":"yellow","percentage":12.5,"tags":["fear"]}`,
+			book:  models.Book{BID: "bid1", UID: "testUID"},
+			want: want{
+				bookFlag:   true,
+				mockFlag:   true,
+				statusCode: http.StatusCreated,
+				body: `"h_id":"hid1","b_id":"bid1","uid":"testUID","kind":"highlight","text":"I must not fear.",` +
+					`"color":"yellow","tags":["fear"],"percentage":12.5`,
+			},
+		},
+		{
+			name:  "Test CreateHighlightHandler; Case 2:",
+			token: testToken(t, "testUID"),
+			body:  `{"text":"I must not fear."}`,
+			book:  models.Book{BID: "bid1", UID: "otherUID"},
+			want: want{
+				bookFlag:   true,
+				grantsFlag: true,
+				statusCode: http.StatusNotFound,
+				body:       `{"error":"book not found"}`,
+			},
+		},
+		{
+			name:    "Test CreateHighlightHandler; Case 3:",
+			token:   testToken(t, "testUID"),
+			body:    `{"text":"I must not fear."}`,
+			bookErr: storage.ErrBookDeleted,
+			want: want{
+				bookFlag:   true,
+				statusCode: http.StatusNotFound,
+				body:       `{"error":"book not found"}`,
+			},
+		},
+		{
+			name:  "Test CreateHighlightHandler; Case 4:",
+			token: testToken(t, "testUID"),
+			body:  `{"kind":"bookmark","color":"red"}`,
+			book:  models.Book{BID: "bid1", UID: "testUID"},
+			want: want{
+				bookFlag:   true,
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"unknown highlight color"}`,
+			},
+		},
+		{
+			name:  "Test CreateHighlightHandler; Case 5:",
+			token: testToken(t, "testUID"),
+			body:  `{"kind":"bookmark","note":"come back later"}`,
+			book:  models.Book{BID: "bid1", UID: "testUID"},
+			want: want{
+				bookFlag:   true,
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"highlight needs text, a note or a location"}`,
+			},
+		},
+		{
+			name:  "Test CreateHighlightHandler; Case 6:",
+			token: "bad token",
+			body:  `{"text":"I must not fear."}`,
+			want: want{
+				statusCode: http.StatusUnauthorized,
+				body:       `{"error":"Invalid token"}`,
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			if tc.want.bookFlag {
+				m.EXPECT().GetBookByID("bid1").Return(tc.book, tc.bookErr)
+			}
+			if tc.want.grantsFlag {
+				m.EXPECT().GetUserGrants(tc.book.UID, "testUID").Return([]models.Grant{}, nil)
+			}
+			if tc.want.mockFlag {
+				m.EXPECT().SaveHighlight(gomock.Any()).DoAndReturn(func(h models.Highlight) (string, error) {
+					assert.NotNil(t, h.CreatedAt)
+					assert.Empty(t, h.Hash)
+					return "hid1", nil
+				})
+			}
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/books/bid1/highlights"
+			req.Body = tc.body
+			req.SetHeader("Authorization", tc.token)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Contains(t, string(resp.Body()), tc.want.body)
+		})
+	}
+}
+
+func TestHighlightsFlow(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	assert.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: "testUID"}))
+	books, err := store.GetBookByUID("testUID")
+	assert.NoError(t, err)
+	bid := books[0].BID
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	client := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "testUID"))
+	var created models.Highlight
+	resp, err := client.R().SetBody(`{"text":"Fear is the mind-killer.","page":12,"tags":["fear"]}`).
+		SetResult(&created).Post("/books/" + bid + "/highlights")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+
+	resp, err = client.R().SetBody(`{"text":"Fear is the mind-killer.","page":12,"note":"Litany","color":"blue"}`).
+		Put("/books/" + bid + "/highlights/" + created.HID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	var found []models.Highlight
+	resp, err = client.R().SetResult(&found).Get("/highlights/search?q=litany+FEAR")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Len(t, found, 1)
+	resp, err = client.R().Get("/highlights/search")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+
+	resp, err = client.R().Get("/books/" + bid + "/highlights/export")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "text/markdown; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Contains(t, string(resp.Body()), "> Fear is the mind-killer.\n\n— page 12 · blue")
+
+	resp, err = client.R().SetHeader("Authorization", testToken(t, "otherUID")).
+		Delete("/books/" + bid + "/highlights/" + created.HID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = client.R().Delete("/books/" + bid + "/highlights/" + created.HID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = client.R().SetResult(&found).Get("/highlights/search?tag=fear")
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+}
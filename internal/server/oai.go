@@ -0,0 +1,376 @@
+package server
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/dublincore"
+	"github.com/Dorrrke/g2-books/internal/formats/oaipmh"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const (
+	oaiPath        = "/oai"
+	oaiPageSize    = 100
+	oaiIDPrefix    = "oai:g2-books:"
+	oaiRepository  = "g2-books"
+	oaiDefaultMail = "admin@localhost"
+)
+
+var errOAIToken = errors.New("invalid resumption token")
+
+// oaiArgs lists the arguments each verb accepts; required ones are marked true. A resumption
+// token is exclusive: no other argument may come with it.
+var oaiArgs = map[string]map[string]bool{ //nolint: gochecknoglobals //static protocol table
+	oaipmh.VerbIdentify:            {},
+	oaipmh.VerbListMetadataFormats: {"identifier": false},
+	oaipmh.VerbListSets:            {"resumptionToken": false},
+	oaipmh.VerbListIdentifiers: {
+		"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false,
+	},
+	oaipmh.VerbListRecords: {
+		"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false,
+	},
+	oaipmh.VerbGetRecord: {"identifier": true, "metadataPrefix": true},
+}
+
+// oaiToken is the state kept in a resumption token. Tokens are self-contained, so they never
+// expire and survive restarts.
+type oaiToken struct {
+	Prefix  string     `json:"p"`
+	From    *time.Time `json:"f,omitempty"`
+	Until   *time.Time `json:"u,omitempty"`
+	After   time.Time  `json:"a"`
+	AfterID string     `json:"i"`
+	Cursor  int        `json:"c"`
+}
+
+// OAIHandler is the OAI-PMH 2.0 endpoint. Books are harvested in oai_dc by update time;
+// deleted books show up as deleted records until they are purged.
+func (s *Server) OAIHandler(ctx *gin.Context) {
+	resp := oaipmh.NewResponse(oaiBaseURL(ctx), time.Now())
+	if err := ctx.Request.ParseForm(); err != nil {
+		resp.AddError(oaipmh.ErrBadArgument, err.Error())
+		writeOAI(ctx, resp)
+		return
+	}
+	args := ctx.Request.Form
+	verb := args.Get("verb")
+	allowed, ok := oaiArgs[verb]
+	if !ok || len(args["verb"]) != 1 {
+		resp.AddError(oaipmh.ErrBadVerb, "illegal or missing verb")
+		writeOAI(ctx, resp)
+		return
+	}
+	if msg := checkOAIArgs(args, allowed); msg != "" {
+		resp.AddError(oaipmh.ErrBadArgument, msg)
+		writeOAI(ctx, resp)
+		return
+	}
+	resp.Request = oaipmh.Request{
+		Verb:            verb,
+		Identifier:      args.Get("identifier"),
+		MetadataPrefix:  args.Get("metadataPrefix"),
+		From:            args.Get("from"),
+		Until:           args.Get("until"),
+		Set:             args.Get("set"),
+		ResumptionToken: args.Get("resumptionToken"),
+		BaseURL:         resp.Request.BaseURL,
+	}
+
+	var err error
+	switch verb {
+	case oaipmh.VerbIdentify:
+		err = s.oaiIdentify(resp)
+	case oaipmh.VerbListMetadataFormats:
+		err = s.oaiListMetadataFormats(resp, args.Get("identifier"))
+	case oaipmh.VerbListSets:
+		if args.Has("resumptionToken") {
+			resp.AddError(oaipmh.ErrBadResumptionToken, "this repository issues no set tokens")
+		} else {
+			resp.AddError(oaipmh.ErrNoSetHierarchy, "this repository does not support sets")
+		}
+	case oaipmh.VerbGetRecord:
+		err = s.oaiGetRecord(resp, args.Get("identifier"), args.Get("metadataPrefix"))
+	case oaipmh.VerbListIdentifiers, oaipmh.VerbListRecords:
+		err = s.oaiList(resp, verb, args)
+	}
+	if err != nil {
+		log := logger.Get()
+		log.Error().Err(err).Str("verb", verb).Msg("oai request failed")
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeOAI(ctx, resp)
+}
+
+func (s *Server) oaiIdentify(resp *oaipmh.Response) error {
+	earliest := time.Unix(0, 0)
+	books, err := s.storage.HarvestBooks(models.HarvestQuery{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(books) != 0 && books[0].UpdatedAt != nil {
+		earliest = *books[0].UpdatedAt
+	}
+	resp.Identify = &oaipmh.Identify{
+		RepositoryName:    oaiRepository,
+		BaseURL:           resp.Request.BaseURL,
+		ProtocolVersion:   oaipmh.ProtocolVersion,
+		AdminEmails:       []string{cmp.Or(s.oaiAdmin, oaiDefaultMail)},
+		EarliestDatestamp: oaipmh.FormatDatestamp(earliest),
+		DeletedRecord:     "transient",
+		Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+	}
+	return nil
+}
+
+func (s *Server) oaiListMetadataFormats(resp *oaipmh.Response, identifier string) error {
+	if identifier != "" {
+		_, ok, err := s.oaiBook(identifier)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			resp.AddError(oaipmh.ErrIDDoesNotExist, "unknown identifier "+identifier)
+			return nil
+		}
+	}
+	resp.ListMetadataFormats = &oaipmh.ListMetadataFormats{Formats: []oaipmh.MetadataFormat{oaipmh.OAIDC()}}
+	return nil
+}
+
+func (s *Server) oaiGetRecord(resp *oaipmh.Response, identifier, prefix string) error {
+	book, ok, err := s.oaiBook(identifier)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		resp.AddError(oaipmh.ErrIDDoesNotExist, "unknown identifier "+identifier)
+	}
+	if prefix != oaipmh.PrefixOAIDC {
+		resp.AddError(oaipmh.ErrCannotDisseminateFormat, "only oai_dc is supported")
+	}
+	if len(resp.Errors) == 0 {
+		resp.GetRecord = &oaipmh.GetRecord{Record: oaiRecord(book)}
+	}
+	return nil
+}
+
+// oaiList answers ListIdentifiers and ListRecords, which only differ in the payload.
+func (s *Server) oaiList(resp *oaipmh.Response, verb string, args url.Values) error {
+	state, ok := parseOAIList(resp, args)
+	if !ok {
+		return nil
+	}
+	q := models.HarvestQuery{From: state.From, Until: state.Until, AfterID: state.AfterID, Limit: oaiPageSize + 1}
+	if !state.After.IsZero() {
+		q.After = &state.After
+	}
+	books, err := s.storage.HarvestBooks(q)
+	if err != nil {
+		return err
+	}
+	if len(books) == 0 {
+		if args.Has("resumptionToken") {
+			// The rest of the list vanished, e.g. books were purged since the last page.
+			resp.AddError(oaipmh.ErrBadResumptionToken, "the list has changed; restart the harvest")
+		} else {
+			resp.AddError(oaipmh.ErrNoRecordsMatch, "no books match the arguments")
+		}
+		return nil
+	}
+
+	var token *oaipmh.ResumptionToken
+	if len(books) > oaiPageSize {
+		books = books[:oaiPageSize]
+		last := books[len(books)-1]
+		next := state
+		next.After, next.AfterID, next.Cursor = bookTime(last.UpdatedAt), last.BID, state.Cursor+len(books)
+		token = &oaipmh.ResumptionToken{Cursor: state.Cursor, Value: encodeOAIToken(next)}
+	} else if args.Has("resumptionToken") {
+		token = &oaipmh.ResumptionToken{Cursor: state.Cursor}
+	}
+	if verb == oaipmh.VerbListIdentifiers {
+		list := &oaipmh.ListIdentifiers{Token: token}
+		for _, book := range books {
+			list.Headers = append(list.Headers, oaiHeader(book))
+		}
+		resp.ListIdentifiers = list
+		return nil
+	}
+	list := &oaipmh.ListRecords{Token: token}
+	for _, book := range books {
+		list.Records = append(list.Records, oaiRecord(book))
+	}
+	resp.ListRecords = list
+	return nil
+}
+
+// parseOAIList reads the selection of a list request, from a resumption token or from the
+// arguments, recording protocol errors in resp.
+func parseOAIList(resp *oaipmh.Response, args url.Values) (oaiToken, bool) {
+	if args.Has("resumptionToken") {
+		state, err := decodeOAIToken(args.Get("resumptionToken"))
+		if err != nil {
+			resp.AddError(oaipmh.ErrBadResumptionToken, "invalid resumption token")
+			return oaiToken{}, false
+		}
+		return state, true
+	}
+	state := oaiToken{Prefix: args.Get("metadataPrefix")}
+	if state.Prefix != oaipmh.PrefixOAIDC {
+		resp.AddError(oaipmh.ErrCannotDisseminateFormat, "only oai_dc is supported")
+	}
+	if args.Has("set") {
+		resp.AddError(oaipmh.ErrNoSetHierarchy, "this repository does not support sets")
+	}
+	var fromDay, untilDay bool
+	if from := args.Get("from"); from != "" {
+		t, day, err := oaipmh.ParseDatestamp(from)
+		if err != nil {
+			resp.AddError(oaipmh.ErrBadArgument, "invalid from "+from)
+			return oaiToken{}, false
+		}
+		state.From, fromDay = &t, day
+	}
+	if until := args.Get("until"); until != "" {
+		t, day, err := oaipmh.ParseDatestamp(until)
+		if err != nil {
+			resp.AddError(oaipmh.ErrBadArgument, "invalid until "+until)
+			return oaiToken{}, false
+		}
+		// until is inclusive at its granularity; the storage bound is exclusive.
+		if day {
+			t = t.AddDate(0, 0, 1)
+		} else {
+			t = t.Add(time.Second)
+		}
+		state.Until, untilDay = &t, day
+	}
+	if state.From != nil && state.Until != nil {
+		if fromDay != untilDay {
+			resp.AddError(oaipmh.ErrBadArgument, "from and until must have the same granularity")
+			return oaiToken{}, false
+		}
+		if !state.From.Before(*state.Until) {
+			resp.AddError(oaipmh.ErrBadArgument, "from is later than until")
+			return oaiToken{}, false
+		}
+	}
+	return state, len(resp.Errors) == 0
+}
+
+// oaiBook loads the public book an OAI identifier names. Deleted books that are not purged
+// yet are found too, with Delete set.
+func (s *Server) oaiBook(identifier string) (models.Book, bool, error) {
+	bid, ok := strings.CutPrefix(identifier, oaiIDPrefix)
+	if !ok || bid == "" {
+		return models.Book{}, false, nil
+	}
+	book, err := s.storage.GetBookByID(bid)
+	switch {
+	case err == nil, errors.Is(err, storage.ErrBookDeleted):
+		return book, book.Visibility == models.VisibilityPublic, nil
+	case errors.Is(err, storage.ErrBookNotFound):
+		return models.Book{}, false, nil
+	}
+	return models.Book{}, false, err
+}
+
+// checkOAIArgs returns why the arguments are illegal for a verb, or an empty string.
+func checkOAIArgs(args url.Values, allowed map[string]bool) string {
+	for name, values := range args {
+		if name == "verb" {
+			continue
+		}
+		if _, ok := allowed[name]; !ok {
+			return "illegal argument " + name
+		}
+		if len(values) != 1 {
+			return "repeated argument " + name
+		}
+	}
+	if args.Has("resumptionToken") {
+		if len(args) != 2 { //nolint: gomnd //verb and the token
+			return "resumptionToken is an exclusive argument"
+		}
+		return ""
+	}
+	for name, required := range allowed {
+		if required && !args.Has(name) {
+			return "missing argument " + name
+		}
+	}
+	return ""
+}
+
+func oaiHeader(book models.Book) oaipmh.Header {
+	header := oaipmh.Header{
+		Identifier: oaiIDPrefix + book.BID,
+		Datestamp:  oaipmh.FormatDatestamp(bookTime(book.UpdatedAt)),
+	}
+	if book.Delete {
+		header.Status = "deleted"
+	}
+	return header
+}
+
+func oaiRecord(book models.Book) oaipmh.Record {
+	rec := oaipmh.Record{Header: oaiHeader(book)}
+	if !book.Delete {
+		rec.Metadata = &oaipmh.Metadata{DC: dublincore.FromBook(book)}
+	}
+	return rec
+}
+
+func encodeOAIToken(state oaiToken) string {
+	data, _ := json.Marshal(state) //nolint: errchkjson //plain struct always encodes
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeOAIToken(token string) (oaiToken, error) {
+	var state oaiToken
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return state, err
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	if state.Prefix != oaipmh.PrefixOAIDC || state.AfterID == "" || state.Cursor <= 0 {
+		return state, errOAIToken
+	}
+	return state, nil
+}
+
+// oaiBaseURL is the absolute URL of the endpoint as the client reached it.
+func oaiBaseURL(ctx *gin.Context) string {
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := ctx.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + ctx.Request.Host + oaiPath
+}
+
+func writeOAI(ctx *gin.Context, resp *oaipmh.Response) {
+	log := logger.Get()
+	ctx.Header("Content-Type", oaipmh.ContentType)
+	ctx.Status(http.StatusOK)
+	if err := oaipmh.Write(ctx.Writer, resp); err != nil {
+		log.Error().Err(err).Msg("write oai response failed")
+	}
+}
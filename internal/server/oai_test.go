@@ -0,0 +1,175 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+var (
+	oaiTokenRe = regexp.MustCompile(`<resumptionToken cursor="(\d+)">([^<]*)</resumptionToken>`)
+	oaiIDRe    = regexp.MustCompile(`<identifier>oai:g2-books:([^<]+)</identifier>`)
+)
+
+func TestOAIHandler(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	for i := 0; i < oaiPageSize+6; i++ {
+		require.NoError(t, store.SaveBook(models.Book{
+			Lable: "Book " + strconv.Itoa(i), Author: "Frank Herbert", UID: "testUID", Year: 1965,
+		}))
+	}
+	books, err := store.GetBookByUID("testUID")
+	require.NoError(t, err)
+	for _, book := range books[1:] {
+		require.NoError(t, store.SetBookVisibility("testUID", book.BID, models.VisibilityPublic))
+	}
+	// Private books are never harvested.
+	private := books[0].BID
+	books = books[1:]
+	deleted := books[0].BID
+	require.NoError(t, store.DeleteBook(deleted))
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithOAIAdminEmail("books@example.org"))
+	httpSrv := testServer(t, srv)
+
+	type want struct {
+		contains    []string
+		notContains []string
+	}
+	type test struct {
+		name  string
+		query string
+		want  want
+	}
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+	tests := []test{
+		{
+			name:  "Test OAIHandler; Case 1:",
+			query: "verb=Identify",
+			want: want{contains: []string{
+				"<baseURL>" + httpSrv.URL + "/oai</baseURL>",
+				"<adminEmail>books@example.org</adminEmail>",
+				"<deletedRecord>transient</deletedRecord>",
+			}},
+		},
+		{
+			name:  "Test OAIHandler; Case 2:",
+			query: "verb=Harvest",
+			want:  want{contains: []string{`<error code="badVerb">`}, notContains: []string{`verb="Harvest"`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 3:",
+			query: "verb=ListRecords",
+			want:  want{contains: []string{`<error code="badArgument">missing argument metadataPrefix</error>`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 4:",
+			query: "verb=ListRecords&metadataPrefix=marc21",
+			want:  want{contains: []string{`<error code="cannotDisseminateFormat">`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 5:",
+			query: "verb=ListIdentifiers&metadataPrefix=oai_dc&from=2024-01-01&until=2024-01-01T00:00:00Z",
+			want:  want{contains: []string{"same granularity"}},
+		},
+		{
+			name:  "Test OAIHandler; Case 6:",
+			query: "verb=ListIdentifiers&metadataPrefix=oai_dc&from=" + tomorrow,
+			want:  want{contains: []string{`<error code="noRecordsMatch">`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 7:",
+			query: "verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:g2-books:" + books[1].BID,
+			want: want{contains: []string{
+				"<identifier>oai:g2-books:" + books[1].BID + "</identifier>",
+				"<dc:creator>Frank Herbert</dc:creator>",
+			}},
+		},
+		{
+			name:  "Test OAIHandler; Case 8:",
+			query: "verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:g2-books:" + deleted,
+			want:  want{contains: []string{`<header status="deleted">`}, notContains: []string{"<metadata>"}},
+		},
+		{
+			name:  "Test OAIHandler; Case 9:",
+			query: "verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:g2-books:unknown",
+			want:  want{contains: []string{`<error code="idDoesNotExist">`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 10:",
+			query: "verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:g2-books:" + private,
+			want:  want{contains: []string{`<error code="idDoesNotExist">`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 11:",
+			query: "verb=ListSets",
+			want:  want{contains: []string{`<error code="noSetHierarchy">`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 12:",
+			query: "verb=ListRecords&resumptionToken=bogus",
+			want:  want{contains: []string{`<error code="badResumptionToken">`}},
+		},
+		{
+			name:  "Test OAIHandler; Case 13:",
+			query: "verb=ListRecords&resumptionToken=bogus&metadataPrefix=oai_dc",
+			want:  want{contains: []string{"resumptionToken is an exclusive argument"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := resty.New().R().Get(httpSrv.URL + oaiPath + "?" + tc.query)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode())
+			assert.Equal(t, "text/xml; charset=utf-8", resp.Header().Get("Content-Type"))
+			for _, s := range tc.want.contains {
+				assert.Contains(t, string(resp.Body()), s)
+			}
+			for _, s := range tc.want.notContains {
+				assert.NotContains(t, string(resp.Body()), s)
+			}
+		})
+	}
+
+	t.Run("Test OAIHandler; Case 14:", func(t *testing.T) {
+		// A full harvest pages through every book, including the deleted one, exactly once.
+		seen := map[string]bool{}
+		resp, err := resty.New().R().SetFormData(map[string]string{
+			"verb": "ListIdentifiers", "metadataPrefix": "oai_dc",
+		}).Post(httpSrv.URL + oaiPath)
+		require.NoError(t, err)
+		for page := 0; ; page++ {
+			body := string(resp.Body())
+			for _, id := range oaiIDRe.FindAllStringSubmatch(body, -1) {
+				assert.False(t, seen[id[1]], "identifier %s listed twice", id[1])
+				seen[id[1]] = true
+			}
+			m := oaiTokenRe.FindStringSubmatch(body)
+			require.NotNil(t, m, body)
+			assert.Equal(t, strconv.Itoa(page*oaiPageSize), m[1])
+			if m[2] == "" {
+				break
+			}
+			resp, err = resty.New().R().SetQueryParams(map[string]string{
+				"verb": "ListIdentifiers", "resumptionToken": m[2],
+			}).Get(httpSrv.URL + oaiPath)
+			require.NoError(t, err)
+		}
+		assert.Len(t, seen, oaiPageSize+5)
+		assert.True(t, seen[deleted])
+		assert.False(t, seen[private])
+		assert.Equal(t, 1, strings.Count(string(resp.Body()), `status="deleted"`))
+	})
+}
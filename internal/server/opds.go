@@ -0,0 +1,449 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/opds"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/thumbnail"
+)
+
+const (
+	opdsRoot     = "/opds"
+	opdsPageSize = 50
+	opdsRealm    = `Basic realm="g2-books", charset="UTF-8"`
+	// readerLoginTTL is how long Basic credentials that checked out are trusted before they are
+	// checked with the auth service again.
+	readerLoginTTL = 5 * time.Minute
+)
+
+var errInvalidPage = errors.New(errText.InvalidPageError)
+
+// opdsFacet is a way of grouping books into navigation feeds.
+type opdsFacet struct {
+	path   string
+	title  string
+	values func(models.Book) []string
+}
+
+var opdsFacets = []opdsFacet{ //nolint: gochecknoglobals //static facet table
+	{path: "authors", title: "By author", values: func(b models.Book) []string {
+		var authors []string
+		for _, author := range strings.Split(b.Author, "&") {
+			if author = strings.TrimSpace(author); author != "" {
+				authors = append(authors, author)
+			}
+		}
+		return authors
+	}},
+	{path: "tags", title: "By tag", values: func(b models.Book) []string { return b.Tags }},
+	{path: "shelves", title: "By shelf", values: func(b models.Book) []string { return b.Shelves }},
+}
+
+// OPDSRootHandler serves the start feed of the caller's OPDS catalog.
+func (s *Server) OPDSRootHandler(ctx *gin.Context) {
+	uid, books, ok := s.opdsBooks(ctx)
+	if !ok {
+		return
+	}
+	updated := latestUpdate(books)
+	feed := opds.NewFeed(opdsID(uid, ""), "My books", updated)
+	feed.Links = opdsLinks(opdsRoot, opds.NavigationType)
+	recent := opds.NavigationEntry(opdsID(uid, "recent"), "Recently added", "Books added most recently",
+		opdsRoot+"/recent", opds.AcquisitionType, updated)
+	recent.Links[0].Rel = opds.RelSortNew
+	feed.Entries = append(feed.Entries, recent,
+		opds.NavigationEntry(opdsID(uid, "books"), "All books", strconv.Itoa(len(books))+" books",
+			opdsRoot+"/books", opds.AcquisitionType, updated),
+	)
+	for _, facet := range opdsFacets {
+		feed.Entries = append(feed.Entries, opds.NavigationEntry(opdsID(uid, facet.path), facet.title,
+			"Books grouped "+strings.ToLower(facet.title), opdsRoot+"/"+facet.path, opds.NavigationType, updated))
+	}
+	writeOPDS(ctx, opds.NavigationType, feed)
+}
+
+// OPDSBooksHandler serves all of the caller's books ordered by title.
+func (s *Server) OPDSBooksHandler(ctx *gin.Context) {
+	uid, books, ok := s.opdsBooks(ctx)
+	if !ok {
+		return
+	}
+	sort.SliceStable(books, func(i, j int) bool {
+		return strings.ToLower(books[i].Lable) < strings.ToLower(books[j].Lable)
+	})
+	s.writeAcquisitionFeed(ctx, uid, "books", "All books", opdsRoot+"/books", books)
+}
+
+// OPDSRecentHandler serves the caller's books, most recently added first.
+func (s *Server) OPDSRecentHandler(ctx *gin.Context) {
+	uid, books, ok := s.opdsBooks(ctx)
+	if !ok {
+		return
+	}
+	sort.SliceStable(books, func(i, j int) bool {
+		return bookTime(books[i].CreatedAt).After(bookTime(books[j].CreatedAt))
+	})
+	s.writeAcquisitionFeed(ctx, uid, "recent", "Recently added", opdsRoot+"/recent", books)
+}
+
+// OPDSFacetHandler serves a navigation feed with one entry per author, tag or shelf.
+func (s *Server) OPDSFacetHandler(facet opdsFacet) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uid, books, ok := s.opdsBooks(ctx)
+		if !ok {
+			return
+		}
+		counts := make(map[string]int)
+		for _, book := range books {
+			for _, value := range facet.values(book) {
+				counts[value]++
+			}
+		}
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool { return strings.ToLower(values[i]) < strings.ToLower(values[j]) })
+
+		updated := latestUpdate(books)
+		self := opdsRoot + "/" + facet.path
+		feed := opds.NewFeed(opdsID(uid, facet.path), facet.title, updated)
+		feed.Links = opdsLinks(self, opds.NavigationType)
+		for _, value := range values {
+			feed.Entries = append(feed.Entries, opds.NavigationEntry(
+				opdsID(uid, facet.path+"/"+url.PathEscape(value)), value, strconv.Itoa(counts[value])+" books",
+				self+"/"+url.PathEscape(value), opds.AcquisitionType, updated))
+		}
+		writeOPDS(ctx, opds.NavigationType, feed)
+	}
+}
+
+// OPDSFacetBooksHandler serves the books of one author, tag or shelf.
+func (s *Server) OPDSFacetBooksHandler(facet opdsFacet) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uid, books, ok := s.opdsBooks(ctx)
+		if !ok {
+			return
+		}
+		value := ctx.Param("value")
+		var matched []models.Book
+		for _, book := range books {
+			if slices.Contains(facet.values(book), value) {
+				matched = append(matched, book)
+			}
+		}
+		if len(matched) == 0 {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": storage.ErrBooksListEmpty.Error()})
+			return
+		}
+		path := facet.path + "/" + url.PathEscape(value)
+		s.writeAcquisitionFeed(ctx, uid, path, value, opdsRoot+"/"+path, matched)
+	}
+}
+
+// OPDSSearchHandler serves the books whose title, author, ISBN, series or tags contain every
+// word of q. It is the target of the OpenSearch description.
+func (s *Server) OPDSSearchHandler(ctx *gin.Context) {
+	uid, books, ok := s.opdsBooks(ctx)
+	if !ok {
+		return
+	}
+	query := ctx.Query("q")
+	words := strings.Fields(strings.ToLower(query))
+	var matched []models.Book
+	for _, book := range books {
+		text := strings.ToLower(strings.Join(append([]string{book.Lable, book.Author, book.ISBN, book.Series},
+			book.Tags...), " "))
+		if !slices.ContainsFunc(words, func(word string) bool { return !strings.Contains(text, word) }) {
+			matched = append(matched, book)
+		}
+	}
+	s.writeAcquisitionFeed(ctx, uid, "search?q="+url.QueryEscape(query), "Search: "+query,
+		opdsRoot+"/search?q="+url.QueryEscape(query), matched)
+}
+
+// OPDSOpenSearchHandler serves the OpenSearch description of the catalog. It is public so
+// that apps can discover search before asking for credentials.
+func (s *Server) OPDSOpenSearchHandler(ctx *gin.Context) {
+	doc := opds.NewOpenSearchDescription("g2-books", "Search your books by title, author, ISBN or tag",
+		opdsRoot+"/search?q={searchTerms}")
+	writeOPDS(ctx, opds.OpenSearchType, doc)
+}
+
+// writeAcquisitionFeed writes one page of books, selected by the page query parameter.
+func (s *Server) writeAcquisitionFeed(ctx *gin.Context, uid, id, title, self string, books []models.Book) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPage.Error()})
+		return
+	}
+	pages := max(1, (len(books)+opdsPageSize-1)/opdsPageSize)
+	start := min((page-1)*opdsPageSize, len(books))
+	pageBooks := books[start:min(start+opdsPageSize, len(books))]
+
+	feed := opds.NewFeed(opdsID(uid, id), title, latestUpdate(pageBooks))
+	feed.Links = opdsLinks(pageURL(self, page), opds.AcquisitionType)
+	feed.Links = append(feed.Links, opds.Link{
+		Rel: opds.RelFirst, Href: pageURL(self, 1), Type: opds.AcquisitionType,
+	}, opds.Link{
+		Rel: opds.RelLast, Href: pageURL(self, pages), Type: opds.AcquisitionType,
+	})
+	if page > 1 {
+		feed.Links = append(feed.Links, opds.Link{
+			Rel: opds.RelPrevious, Href: pageURL(self, min(page-1, pages)), Type: opds.AcquisitionType,
+		})
+	}
+	if page < pages {
+		feed.Links = append(feed.Links, opds.Link{
+			Rel: opds.RelNext, Href: pageURL(self, page+1), Type: opds.AcquisitionType,
+		})
+	}
+	feed.TotalResults, feed.ItemsPerPage, feed.StartIndex = len(books), opdsPageSize, start+1
+	var files map[string][]models.BookFile
+	if s.blobs != nil {
+		bIDs := make([]string, 0, len(pageBooks))
+		for _, book := range pageBooks {
+			bIDs = append(bIDs, book.BID)
+		}
+		var err error
+		if files, err = s.store(ctx).GetBookFilesOf(uid, bIDs); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for _, book := range pageBooks {
+		entry := opds.BookEntry(book, bookUpdated(book))
+		entry.Links = append(entry.Links, opds.Link{Rel: "alternate", Href: "/books/" + book.BID,
+			Type: "application/json"})
+		if s.blobs != nil {
+			entry.Links = append(entry.Links, s.opdsFileLinks(book, files[book.BID])...)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	writeOPDS(ctx, opds.AcquisitionType, feed)
+}
+
+// opdsFileLinks links the cover and the attached files of a book.
+func (s *Server) opdsFileLinks(book models.Book, files []models.BookFile) []opds.Link {
+	var links []opds.Link
+	if covers := s.withCovers(book).Covers; covers != nil {
+		links = append(links,
+			opds.Link{Rel: opds.RelImage, Href: covers.Original},
+			opds.Link{Rel: opds.RelThumbnail, Href: covers.Medium, Type: thumbnail.ContentType})
+	}
+	for _, file := range files {
+		links = append(links, opds.Link{
+			Rel: opds.RelAcquisition, Href: "/books/" + book.BID + "/files/" + file.FID, Type: file.ContentType,
+		})
+	}
+	return links
+}
+
+// opdsBooks authenticates the caller and loads their books.
+func (s *Server) opdsBooks(ctx *gin.Context) (string, []models.Book, bool) {
+	uid, ok := s.readerUID(ctx)
+	if !ok {
+		return "", nil, false
+	}
+	books, err := s.store(ctx).GetBookByUID(uid)
+	if err != nil && !errors.Is(err, storage.ErrBooksListEmpty) {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", nil, false
+	}
+	return uid, books, true
+}
+
+// readerUID authenticates requests from e-reader apps. They only speak HTTP Basic, so besides
+// a token the Authorization header may carry the e-mail and password, which are checked with
+// the auth service. On failure it writes a 401 asking for Basic credentials.
+func (s *Server) readerUID(ctx *gin.Context) (string, bool) {
+	log := logger.Get()
+	if uid := viewerUID(ctx); uid != "" {
+		return uid, true
+	}
+	if email, pass, ok := ctx.Request.BasicAuth(); ok {
+		uid, err := s.readerLogin(ctx.Request.Context(), email, pass)
+		if authUnavailable(ctx, err) {
+			return "", false
+		}
+		if err == nil {
+			return uid, true
+		}
+		// Wrong passwords typed into a reader app are no fault of the server.
+		log.Warn().Err(err).Msg("basic auth login failed")
+	}
+	ctx.Header("WWW-Authenticate", opdsRealm)
+	ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	return "", false
+}
+
+// readerLogin checks the Basic credentials of an e-reader app with the auth service. Apps send
+// them with every request, so credentials that checked out are trusted for readerLoginTTL, and
+// the session the login opens at the auth service is closed again right away.
+func (s *Server) readerLogin(ctx context.Context, email, pass string) (string, error) {
+	log := logger.Get()
+	key := s.readers.key(email, pass)
+	if uid, issued := s.readers.get(key); uid != "" {
+		revoked, err := s.userRevoked(uid, issued)
+		if err != nil {
+			return "", err
+		}
+		if !revoked {
+			return uid, nil
+		}
+	}
+	resp, err := s.authClient.Login(ctx, &authservicev1.UserCreds{Email: email, Pass: pass})
+	if err != nil {
+		return "", err
+	}
+	if refresh := resp.GetRefreshToken(); refresh != "" {
+		_, err = s.authClient.Logout(ctx, &authservicev1.LogoutRequest{RefreshToken: refresh})
+		if err != nil && status.Code(err) != codes.Unimplemented {
+			log.Warn().Err(err).Msg("closing the session of a basic auth login failed")
+		}
+	}
+	claims, err := s.claims(ctx, resp.GetToken())
+	if err != nil {
+		return "", err
+	}
+	s.readers.add(key, claims.UserID, claims.issued())
+	return claims.UserID, nil
+}
+
+// readerLogins remembers which user the Basic credentials of e-reader apps belong to. The
+// credentials are kept only as an HMAC under a key made for the process.
+type readerLogins struct {
+	secret []byte
+	mu     sync.Mutex
+	logins map[string]readerLogin
+}
+
+// readerLogin is a checked login; issued is when the token it yielded was issued, so logins
+// end with the tokens of their user.
+type readerLogin struct {
+	uid    string
+	issued time.Time
+	expiry time.Time
+}
+
+func newReaderLogins() *readerLogins {
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return &readerLogins{secret: secret, logins: make(map[string]readerLogin)}
+}
+
+func (l *readerLogins) key(email, pass string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(email))
+	mac.Write([]byte{0})
+	mac.Write([]byte(pass))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// get returns the user the credentials with key belong to and when their token was issued, or
+// an empty string when they were not checked within readerLoginTTL.
+func (l *readerLogins) get(key string) (string, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	login, ok := l.logins[key]
+	if !ok || login.expiry.Before(time.Now()) {
+		return "", time.Time{}
+	}
+	return login.uid, login.issued
+}
+
+func (l *readerLogins) add(key, uid string, issued time.Time) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, login := range l.logins {
+		if login.expiry.Before(now) {
+			delete(l.logins, k)
+		}
+	}
+	l.logins[key] = readerLogin{uid: uid, issued: issued, expiry: now.Add(readerLoginTTL)}
+}
+
+func writeOPDS(ctx *gin.Context, contentType string, doc any) {
+	ctx.Header("Content-Type", contentType+";charset=utf-8")
+	ctx.Status(http.StatusOK)
+	log := logger.Get()
+	if err := opds.Write(ctx.Writer, doc); err != nil {
+		log.Error().Err(err).Msg("write opds feed failed")
+	}
+}
+
+// opdsLinks returns the links every feed carries.
+func opdsLinks(self, kind string) []opds.Link {
+	return []opds.Link{
+		{Rel: "self", Href: self, Type: kind},
+		{Rel: opds.RelStart, Href: opdsRoot, Type: opds.NavigationType},
+		{Rel: opds.RelSearch, Href: opdsRoot + "/opensearch.xml", Type: opds.OpenSearchType},
+	}
+}
+
+func opdsID(uid, path string) string {
+	return "urn:g2-books:opds:" + uid + ":" + path
+}
+
+func pageURL(self string, page int) string {
+	if page == 1 {
+		return self
+	}
+	sep := "?"
+	if strings.Contains(self, "?") {
+		sep = "&"
+	}
+	return self + sep + "page=" + strconv.Itoa(page)
+}
+
+func latestUpdate(books []models.Book) time.Time {
+	var latest time.Time
+	for _, book := range books {
+		if updated := bookUpdated(book); updated.After(latest) {
+			latest = updated
+		}
+	}
+	if latest.IsZero() {
+		return time.Now()
+	}
+	return latest
+}
+
+func bookUpdated(book models.Book) time.Time {
+	if book.UpdatedAt != nil {
+		return *book.UpdatedAt
+	}
+	return bookTime(book.CreatedAt)
+}
+
+func bookTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	mocks "github.com/Dorrrke/g2-books/moks"
+)
+
+func TestOPDSHandlers(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	updated := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	books := make([]models.Book, 0, 60)
+	for i := 0; i < 60; i++ {
+		added := updated.Add(time.Duration(i) * time.Hour)
+		books = append(books, models.Book{
+			BID: fmt.Sprintf("bid%02d", i), Lable: fmt.Sprintf("Book %02d", i), Author: "Frank Herbert",
+			UID: "testUID", CreatedAt: &added, UpdatedAt: &added,
+		})
+	}
+	books[7].Tags = []string{"sci fi"}
+	books[7].ISBN = "9780441172719"
+
+	type want struct {
+		mockFlag   bool
+		statusCode int
+		contains   []string
+		excludes   []string
+	}
+	type test struct {
+		name  string
+		token string
+		path  string
+		want  want
+	}
+
+	tests := []test{
+		{
+			name:  "Test OPDSHandlers; Case 1:",
+			token: testToken(t, "testUID"),
+			path:  "/opds",
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				contains: []string{
+					`<feed xmlns="http://www.w3.org/2005/Atom"`,
+					`<link rel="http://opds-spec.org/sort/new" href="/opds/recent" ` +
+						`type="application/atom+xml;profile=opds-catalog;kind=acquisition">`,
+					`<link rel="search" href="/opds/opensearch.xml" type="application/opensearchdescription+xml">`,
+					`<content type="text">60 books</content>`,
+					`<updated>2024-05-03T23:00:00Z</updated>`,
+				},
+			},
+		},
+		{
+			name:  "Test OPDSHandlers; Case 2:",
+			token: testToken(t, "testUID"),
+			path:  "/opds/books?page=2",
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				contains: []string{
+					`<link rel="self" href="/opds/books?page=2"`,
+					`<link rel="previous" href="/opds/books"`,
+					`<opensearch:totalResults>60</opensearch:totalResults>`,
+					`<opensearch:startIndex>51</opensearch:startIndex>`,
+					`<id>urn:uuid:bid59</id>`,
+				},
+				excludes: []string{`rel="next"`, `<id>urn:uuid:bid49</id>`},
+			},
+		},
+		{
+			name:  "Test OPDSHandlers; Case 3:",
+			token: testToken(t, "testUID"),
+			path:  "/opds/recent",
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				contains:   []string{`<link rel="next" href="/opds/recent?page=2"`, `<id>urn:uuid:bid59</id>`},
+				excludes:   []string{`<id>urn:uuid:bid00</id>`},
+			},
+		},
+		{
+			name:  "Test OPDSHandlers; Case 4:",
+			token: testToken(t, "testUID"),
+			path:  "/opds/tags",
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				contains:   []string{`<title>sci fi</title>`, `href="/opds/tags/sci%20fi"`},
+			},
+		},
+		{
+			name:  "Test OPDSHandlers; Case 5:",
+			token: testToken(t, "testUID"),
+			path:  "/opds/tags/sci%20fi",
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				contains:   []string{`<dc:identifier>urn:isbn:9780441172719</dc:identifier>`},
+				excludes:   []string{`<id>urn:uuid:bid08</id>`},
+			},
+		},
+		{
+			name:  "Test OPDSHandlers; Case 6:",
+			token: testToken(t, "testUID"),
+			path:  "/opds/search?q=BOOK+07",
+			want: want{
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				contains:   []string{`<opensearch:totalResults>1</opensearch:totalResults>`, `<id>urn:uuid:bid07</id>`},
+			},
+		},
+		{
+			name:  "Test OPDSHandlers; Case 7:",
+			token: "bad token",
+			path:  "/opds",
+			want: want{
+				statusCode: http.StatusUnauthorized,
+				contains:   []string{`{"error":"Invalid token"}`},
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			if tc.want.mockFlag {
+				m.EXPECT().GetBookByUID("testUID").Return(append([]models.Book(nil), books...), nil)
+			}
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodGet
+			req.URL = httpSrv.URL + tc.path
+			req.SetHeader("Authorization", tc.token)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			for _, s := range tc.want.contains {
+				assert.Contains(t, string(resp.Body()), s)
+			}
+			for _, s := range tc.want.excludes {
+				assert.NotContains(t, string(resp.Body()), s)
+			}
+			if tc.want.statusCode == http.StatusUnauthorized {
+				assert.Equal(t, opdsRealm, resp.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+// readerAuthClient logs in the one reader it knows and counts the logins and open sessions.
+type readerAuthClient struct {
+	authservicev1.AuthServiceClient
+	token    string
+	logins   int
+	sessions map[string]bool
+}
+
+func (c *readerAuthClient) Login(_ context.Context, in *authservicev1.UserCreds,
+	_ ...grpc.CallOption) (*authservicev1.AuthResponse, error) {
+	c.logins++
+	if in.GetEmail() != "alice@example.org" || in.GetPass() != "pass" {
+		return nil, status.Error(codes.Unauthenticated, "incorrect password")
+	}
+	refresh := fmt.Sprintf("refresh%d", c.logins)
+	c.sessions[refresh] = true
+	return &authservicev1.AuthResponse{Token: c.token, RefreshToken: refresh}, nil
+}
+
+func (c *readerAuthClient) Logout(_ context.Context, in *authservicev1.LogoutRequest,
+	_ ...grpc.CallOption) (*authservicev1.LogoutResponse, error) {
+	delete(c.sessions, in.GetRefreshToken())
+	return &authservicev1.LogoutResponse{}, nil
+}
+
+func TestOPDSBasicAuth(t *testing.T) {
+	logger.Get(true)
+	auth := &readerAuthClient{token: testToken(t, "testUID"), sessions: map[string]bool{}}
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStorage(ctrl)
+	m.EXPECT().GetBookByUID("testUID").Return(nil, nil).Times(3)
+	srv := New("0.0.0.0:8080", m, auth, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+	client := resty.New().SetBaseURL(httpSrv.URL)
+
+	// Polling the catalog checks the password once and leaves no session behind.
+	for i := 0; i < 3; i++ {
+		resp, err := client.R().SetBasicAuth("alice@example.org", "pass").Get("/opds")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	}
+	assert.Equal(t, 1, auth.logins)
+	assert.Empty(t, auth.sessions)
+
+	// Other credentials are checked on their own.
+	resp, err := client.R().SetBasicAuth("alice@example.org", "wrong").Get("/opds")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	assert.Equal(t, 2, auth.logins)
+
+	// Credentials of users whose tokens were revoked are checked again.
+	srv.verifier.RevokeUser("testUID")
+	resp, err = client.R().SetBasicAuth("alice@example.org", "pass").Get("/opds")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	assert.Equal(t, 3, auth.logins)
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+// tenantKey is the context key of the tenant a request works in.
+const tenantKey = "tenant"
+
+var (
+	errNotMember    = errors.New(errText.NotMemberError)
+	errOrgRole      = errors.New(errText.OrgRoleError)
+	errOrgName      = errors.New(errText.OrgNameError)
+	errLastAdmin    = errors.New(errText.LastAdminError)
+	errOrgsDisabled = errors.New(errText.OrgsDisabledError)
+	errOrgTokens    = errors.New(errText.OrgTokensDisabledError)
+)
+
+// store returns the storage of the library the request works in: the organization named by the
+// caller's token, or the default library.
+func (s *Server) store(ctx *gin.Context) Storage {
+	return s.tenantStore(ctx.GetString(tenantKey))
+}
+
+// tenantStore returns the storage of the library of tenant.
+func (s *Server) tenantStore(tenant string) Storage {
+	if tenant == "" {
+		return s.storage
+	}
+	return s.tenants(tenant)
+}
+
+// CreateOrgHandler creates an organization with the caller as its first admin.
+func (s *Server) CreateOrgHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	var org models.Org
+	if err := ctx.ShouldBindJSON(&org); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errOrgName.Error()})
+		return
+	}
+	oid, err := s.storage.SaveOrg(org, uid)
+	if err != nil {
+		log.Error().Err(err).Msg("save organization failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, models.Org{OID: oid, Name: org.Name, Role: models.OrgAdmin})
+}
+
+// OrgsHandler lists the organizations the caller belongs to.
+func (s *Server) OrgsHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	orgs, err := s.storage.GetUserOrgs(uid)
+	if err != nil {
+		log.Error().Err(err).Msg("get organizations failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, orgs)
+}
+
+// OrgMembersHandler lists the members of an organization to its members.
+func (s *Server) OrgMembersHandler(ctx *gin.Context) {
+	log := logger.Get()
+	if _, ok := s.orgMember(ctx, models.OrgMember); !ok {
+		return
+	}
+	members, err := s.storage.GetMembers(ctx.Param("oid"))
+	if err != nil {
+		log.Error().Err(err).Msg("get members failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, members)
+}
+
+// SetMemberHandler adds the user in the path to an organization or changes their role.
+// Only admins may do so, and the last admin cannot step down.
+func (s *Server) SetMemberHandler(ctx *gin.Context) {
+	log := logger.Get()
+	if _, ok := s.orgMember(ctx, models.OrgAdmin); !ok {
+		return
+	}
+	var req models.MemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role != models.OrgMember && req.Role != models.OrgAdmin {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errOrgRole.Error()})
+		return
+	}
+	m := models.Member{OID: ctx.Param("oid"), UID: ctx.Param("uid"), Role: req.Role}
+	if req.Role != models.OrgAdmin && !s.keepsAdmin(ctx, m.OID, m.UID) {
+		return
+	}
+	if err := s.storage.SaveMember(m); err != nil {
+		log.Error().Err(err).Msg("save member failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
+
+// RemoveMemberHandler removes the user in the path from an organization. Admins may remove
+// anyone, members only themselves.
+func (s *Server) RemoveMemberHandler(ctx *gin.Context) {
+	log := logger.Get()
+	caller, ok := s.orgMember(ctx, models.OrgMember)
+	if !ok {
+		return
+	}
+	oid, uid := ctx.Param("oid"), ctx.Param("uid")
+	if caller.UID != uid && caller.Role != models.OrgAdmin {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errForbidden.Error()})
+		return
+	}
+	if !s.keepsAdmin(ctx, oid, uid) {
+		return
+	}
+	if err := s.storage.DeleteMember(oid, uid); err != nil {
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("delete member failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// OrgTokenHandler issues the caller a token for the library of an organization they belong to.
+// The token expires with the one it was requested with; the caller's own library stays
+// reachable with that one.
+func (s *Server) OrgTokenHandler(ctx *gin.Context) {
+	log := logger.Get()
+	if s.tenants == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errOrgsDisabled.Error()})
+		return
+	}
+	m, ok := s.orgMember(ctx, models.OrgMember)
+	if !ok {
+		return
+	}
+	p, _ := principal(ctx)
+	claims := *p
+	claims.TenantID = m.OID
+	token, err := s.verifier.Sign(claims)
+	if errors.Is(err, tokens.ErrNoSecret) {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errOrgTokens.Error()})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("sign tenant token failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Authorization", token)
+	ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// orgMember authenticates the caller and checks that they hold at least role need in the
+// organization in the path. Non-members get a 404, so organizations look missing to them.
+func (s *Server) orgMember(ctx *gin.Context, need string) (models.Member, bool) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return models.Member{}, false
+	}
+	m, err := s.storage.GetMember(ctx.Param("oid"), uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": errNotMember.Error()})
+			return models.Member{}, false
+		}
+		log.Error().Err(err).Msg("get member failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.Member{}, false
+	}
+	if need == models.OrgAdmin && m.Role != models.OrgAdmin {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errForbidden.Error()})
+		return models.Member{}, false
+	}
+	return m, true
+}
+
+// keepsAdmin checks that the organization still has an admin once uid is no longer one.
+func (s *Server) keepsAdmin(ctx *gin.Context, oid, uid string) bool {
+	log := logger.Get()
+	members, err := s.storage.GetMembers(oid)
+	if err != nil {
+		log.Error().Err(err).Msg("get members failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	for _, m := range members {
+		if m.Role == models.OrgAdmin && m.UID != uid {
+			return true
+		}
+	}
+	ctx.JSON(http.StatusConflict, gin.H{"error": errLastAdmin.Error()})
+	return false
+}
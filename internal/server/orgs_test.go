@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+func TestOrgsFlow(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Emma", Author: "Jane Austen", UID: "aliceUID"}))
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithTenants(func(tenant string) Storage {
+		return store.WithTenant(tenant)
+	}))
+	httpSrv := testServer(t, srv)
+
+	alice := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "aliceUID"))
+	bob := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "bobUID"))
+
+	resp, err := alice.R().SetBody(`{"name":"  "}`).Post("/orgs")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	var org models.Org
+	resp, err = alice.R().SetBody(`{"name":"Physics"}`).SetResult(&org).Post("/orgs")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	assert.Equal(t, models.OrgAdmin, org.Role)
+	var orgs []models.Org
+	resp, err = alice.R().SetResult(&orgs).Get("/orgs")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "Physics", orgs[0].Name)
+
+	// Organizations look missing to outsiders.
+	resp, err = bob.R().Post("/orgs/" + org.OID + "/token")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = bob.R().Get("/orgs/" + org.OID + "/members")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	resp, err = alice.R().SetResult(&token).Post("/orgs/" + org.OID + "/token")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	aliceOrg := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", token.Token)
+
+	// Books added with the organization token stay in the organization's library.
+	resp, err = aliceOrg.R().SetBody(`{"lable":"Feynman Lectures","author":"Richard Feynman"}`).
+		Post("/books/add-book")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	var books []models.Book
+	resp, err = aliceOrg.R().SetResult(&books).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, books, 1)
+	assert.Equal(t, "Feynman Lectures", books[0].Lable)
+	feynman := books[0].BID
+	resp, err = alice.R().SetResult(&books).Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, books, 1)
+	assert.Equal(t, "Emma", books[0].Lable)
+	resp, err = alice.R().Get("/books/" + feynman)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = aliceOrg.R().Get("/books/" + feynman)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	orgBooks, err := store.WithTenant(org.OID).GetBookByUID("aliceUID")
+	assert.NoError(t, err)
+	assert.Len(t, orgBooks, 1)
+
+	// Tokens naming an organization are only honoured for its members.
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: "bobUID", TenantID: org.OID}).
+		SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	resp, err = resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", forged).R().Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+
+	resp, err = alice.R().SetBody(`{"role":"owner"}`).Put("/orgs/" + org.OID + "/members/bobUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, err = alice.R().SetBody(`{"role":"member"}`).Put("/orgs/" + org.OID + "/members/bobUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", forged).R().Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	var members []models.Member
+	resp, err = bob.R().SetResult(&members).Get("/orgs/" + org.OID + "/members")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, members, 2)
+	assert.Equal(t, []string{"aliceUID", models.OrgAdmin}, []string{members[0].UID, members[0].Role})
+
+	// Members cannot manage the organization, and its last admin cannot leave.
+	resp, err = bob.R().SetBody(`{"role":"admin"}`).Put("/orgs/" + org.OID + "/members/bobUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	resp, err = bob.R().Delete("/orgs/" + org.OID + "/members/aliceUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+	resp, err = alice.R().SetBody(`{"role":"member"}`).Put("/orgs/" + org.OID + "/members/aliceUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode())
+	resp, err = alice.R().Delete("/orgs/" + org.OID + "/members/aliceUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode())
+
+	// Removed members lose access at once, even with a token issued before.
+	resp, err = alice.R().Delete("/orgs/" + org.OID + "/members/bobUID")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", forged).R().Get("/books/my-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode())
+}
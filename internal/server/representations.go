@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats/dublincore"
+	"github.com/Dorrrke/g2-books/internal/formats/jsonld"
+	"github.com/Dorrrke/g2-books/internal/logger"
+)
+
+var errNotAcceptable = errors.New(errText.NotAcceptableError)
+
+// writeBook writes book in the representation the Accept header asks for: the plain JSON
+// model (the default), schema.org JSON-LD, or a Dublin Core XML record.
+func (s *Server) writeBook(ctx *gin.Context, book models.Book) {
+	log := logger.Get()
+	ctx.Header("Vary", "Accept")
+	var err error
+	switch ctx.NegotiateFormat(gin.MIMEJSON, jsonld.ContentType, dublincore.ContentType, gin.MIMEXML2) {
+	case gin.MIMEJSON:
+		ctx.JSON(http.StatusOK, book)
+	case jsonld.ContentType:
+		ctx.Header("Content-Type", jsonld.ContentType)
+		ctx.Status(http.StatusOK)
+		err = jsonld.Write(ctx.Writer, book)
+	case dublincore.ContentType, gin.MIMEXML2:
+		ctx.Header("Content-Type", dublincore.ContentType+"; charset=utf-8")
+		ctx.Status(http.StatusOK)
+		err = dublincore.Write(ctx.Writer, book)
+	default:
+		ctx.JSON(http.StatusNotAcceptable, gin.H{"error": errNotAcceptable.Error()})
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("write book failed")
+	}
+}
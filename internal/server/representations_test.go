@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	mocks "github.com/Dorrrke/g2-books/moks"
+)
+
+func TestGetBookByIDHandler(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	book := models.Book{
+		BID: "bid1", Lable: "Dune", Author: "Frank Herbert", UID: "testUID", Year: 1965,
+		Visibility: models.VisibilityPublic,
+	}
+	type want struct {
+		statusCode  int
+		contentType string
+		contains    string
+	}
+	type test struct {
+		name   string
+		accept string
+		want   want
+	}
+	tests := []test{
+		{
+			name: "Test GetBookByIDHandler; Case 1:",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "application/json; charset=utf-8",
+				contains:    `"lable":"Dune"`,
+			},
+		},
+		{
+			name:   "Test GetBookByIDHandler; Case 2:",
+			accept: "application/ld+json",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "application/ld+json",
+				contains:    `"@type":"Book","@id":"urn:uuid:bid1","name":"Dune"`,
+			},
+		},
+		{
+			name:   "Test GetBookByIDHandler; Case 3:",
+			accept: "text/xml;q=0.9, application/pdf",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "application/xml; charset=utf-8",
+				contains:    "<dc:title>Dune</dc:title>",
+			},
+		},
+		{
+			name:   "Test GetBookByIDHandler; Case 4:",
+			accept: "text/html,application/xhtml+xml,*/*;q=0.8",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "application/json; charset=utf-8",
+				contains:    `"b_id":"bid1"`,
+			},
+		},
+		{
+			name:   "Test GetBookByIDHandler; Case 5:",
+			accept: "text/html",
+			want: want{
+				statusCode:  http.StatusNotAcceptable,
+				contentType: "application/json; charset=utf-8",
+				contains:    `"error":"not acceptable`,
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			m.EXPECT().GetBookByID("bid1").Return(book, nil)
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodGet
+			req.URL = httpSrv.URL + "/books/bid1"
+			if tc.accept != "" {
+				req.SetHeader("Accept", tc.accept)
+			}
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.contentType, resp.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", resp.Header().Get("Vary"))
+			assert.Contains(t, string(resp.Body()), tc.want.contains)
+		})
+	}
+}
@@ -5,80 +5,359 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/Dorrrke/g2-books/internal/blobstore"
 	"github.com/Dorrrke/g2-books/internal/domain/models"
 	authservicev1 "github.com/Dorrrke/g2-books/internal/go"
+	"github.com/Dorrrke/g2-books/internal/jobs"
 	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/oidc"
+	"github.com/Dorrrke/g2-books/internal/rbac"
 	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
 )
 
-const SecretKey = "VerySecretKey2000"
+var errNoVerifier = errors.New("no token verifier configured")
 
+// Claims are the claims of the tokens the server accepts. TenantID names the organization whose
+// library the token works in; tokens without it work in the default library. Role is the
+// account role of the user; tokens without it belong to plain users.
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID string
+	UserID   string
+	TenantID string `json:",omitempty"`
+	Role     string `json:",omitempty"`
 }
 
 type Storage interface {
 	SaveUser(models.User) (string, error)
 	ValidateUser(models.User) (string, string, error)
+	GetUser(string) (models.User, error)
+	UpdateUser(models.User) error
+	SetPassword(string, string) error
+	DeleteUser(string) error
+	DeleteAccount(string, bool) error
 	GetBooks() ([]models.Book, error)
 	GetBookByID(string) (models.Book, error)
 	GetBookByUID(string) ([]models.Book, error)
+	HarvestBooks(models.HarvestQuery) ([]models.Book, error)
 	SaveBook(models.Book) error
 	DeleteBook(string) error
-	DeleteBooks() error
+	DeleteBooks() ([]models.BookFile, error)
+	ApplyBookOps([]models.BookOp, bool) ([]models.BookOpResult, error)
+	SaveHighlights([]models.Highlight) (int, error)
+	GetHighlightsByBook(string, string) ([]models.Highlight, error)
+	SaveHighlight(models.Highlight) (string, error)
+	GetHighlightByID(string, string) (models.Highlight, error)
+	SearchHighlights(string, string, string) ([]models.Highlight, error)
+	UpdateHighlight(models.Highlight) error
+	DeleteHighlight(string, string) error
+	SetBookCover(string, string, string) error
+	SaveBookFile(models.BookFile) error
+	GetBookFiles(string, string) ([]models.BookFile, error)
+	GetBookFilesOf(string, []string) (map[string][]models.BookFile, error)
+	GetBookFile(string, string) (models.BookFile, error)
+	DeleteBookFile(string, string) error
+	SetBookVisibility(string, string, string) error
+	GetShelves(string) ([]models.Shelf, error)
+	GetShelf(string, string) (models.Shelf, error)
+	SetShelfVisibility(string, string, string) error
+	SaveShareLink(models.ShareLink) error
+	GetShareLink(string) (models.ShareLink, error)
+	GetShareLinks(string, string) ([]models.ShareLink, error)
+	RevokeShareLink(string, string) error
+	SaveGrant(models.Grant) error
+	GetGrants(string, string, string) ([]models.Grant, error)
+	GetUserGrants(string, string) ([]models.Grant, error)
+	DeleteGrant(string, string, string, string) error
+	SaveOrg(models.Org, string) (string, error)
+	GetUserOrgs(string) ([]models.Org, error)
+	GetMember(string, string) (models.Member, error)
+	GetMembers(string) ([]models.Member, error)
+	SaveMember(models.Member) error
+	DeleteMember(string, string) error
+	SaveAPIKey(models.APIKey) error
+	GetAPIKeys(string) ([]models.APIKey, error)
+	GetAPIKeyByHash(string) (models.APIKey, error)
+	TouchAPIKey(string, time.Time) error
+	DeleteAPIKey(string, string) error
+	GetIdentity(string, string) (models.Identity, error)
+	SaveIdentity(models.Identity) error
+	ForceDeleteBook(string) error
+	GetUsers() ([]models.UserSummary, error)
+	GetStats() (models.Stats, error)
 }
 
 type Server struct {
-	serve      *http.Server
-	storage    Storage
-	deleteChan chan int
-	authClient authservicev1.AuthServiceClient
-	ErrChan    chan error
+	serve       *http.Server
+	storage     Storage
+	deleteChan  chan int
+	authClient  authservicev1.AuthServiceClient
+	jobs        *jobs.Manager
+	calibreRoot string
+	blobs       blobstore.Store
+	oaiAdmin    string
+	shareSecret []byte
+	tenants     func(string) Storage
+	verifier    *tokens.Verifier
+	sso         *oidc.Client
+	links       *oidcLinks
+	readers     *readerLogins
+	session     SessionConfig
+	localUsers  bool
+	ErrChan     chan error
 }
 
-func New(host string, storage Storage, authClien authservicev1.AuthServiceClient) *Server {
+// Option configures optional parts of the server.
+type Option func(*Server)
+
+// WithCalibreRoot allows importing Calibre libraries located below dir through the API.
+func WithCalibreRoot(dir string) Option {
+	return func(s *Server) {
+		s.calibreRoot = dir
+	}
+}
+
+// WithBlobStore enables e-book file uploads and cover images, kept in store.
+func WithBlobStore(store blobstore.Store) Option {
+	return func(s *Server) {
+		s.blobs = store
+	}
+}
+
+// WithOAIAdminEmail sets the contact address the OAI-PMH endpoint reports to harvesters.
+func WithOAIAdminEmail(email string) Option {
+	return func(s *Server) {
+		s.oaiAdmin = email
+	}
+}
+
+// WithShareSecret sets the key share links are signed with instead of a random key made for
+// the process. Changing it invalidates every link handed out before.
+func WithShareSecret(secret string) Option {
+	return func(s *Server) {
+		if secret != "" {
+			s.shareSecret = []byte(secret)
+		}
+	}
+}
+
+// WithTenants enables organizations. tenant returns the storage of the library of one
+// organization; the storage passed to New holds the default library and the organizations.
+func WithTenants(tenant func(string) Storage) Option {
+	return func(s *Server) {
+		s.tenants = tenant
+	}
+}
+
+// WithVerifier sets how tokens are verified. It is required: Run fails without a verifier.
+func WithVerifier(verifier *tokens.Verifier) Option {
+	return func(s *Server) {
+		s.verifier = verifier
+	}
+}
+
+// WithLocalUsers says the users are kept in the storage of the server, as the embedded auth
+// service keeps them. Tokens of users who are gone, or who set a new password after the token
+// was issued, are then rejected, also after a restart, which revocations in memory do not
+// survive.
+func WithLocalUsers() Option {
+	return func(s *Server) {
+		s.localUsers = true
+	}
+}
+
+// SessionConfig shapes the session tokens the server issues itself after a login through an
+// OpenID provider. Issuer and Audience are the iss and aud claims tokens are verified against.
+type SessionConfig struct {
+	TTL      time.Duration
+	Issuer   string
+	Audience string
+}
+
+// WithOIDC enables logins through the OpenID provider rp talks to. The session tokens handed
+// out are signed with the HMAC secret of the verifier. Identities are mapped to the users in
+// storage, so the users must be kept there, as the embedded auth service does.
+func WithOIDC(rp *oidc.Client, session SessionConfig) Option {
+	return func(s *Server) {
+		s.sso = rp
+		s.links = newOIDCLinks()
+		if session.TTL == 0 {
+			session.TTL = defaultSessionTTL
+		}
+		s.session = session
+	}
+}
+
+func New(host string, storage Storage, authClien authservicev1.AuthServiceClient, opts ...Option) *Server {
 	serve := http.Server{ //nolint: gosec //todo: another time fix
 		Addr: host,
 	}
 	dChan := make(chan int, 5) //nolint: gomnd //its size
 	errChan := make(chan error)
-	return &Server{
-		serve:      &serve,
-		storage:    storage,
-		deleteChan: dChan,
-		ErrChan:    errChan,
-		authClient: authClien,
+	srv := &Server{
+		serve:       &serve,
+		storage:     storage,
+		deleteChan:  dChan,
+		ErrChan:     errChan,
+		authClient:  authClien,
+		jobs:        jobs.New(),
+		shareSecret: randomShareSecret(),
+		readers:     newReaderLogins(),
+	}
+	for _, opt := range opts {
+		opt(srv)
 	}
+	return srv
 }
 
 func (s *Server) Run(ctx context.Context) error {
+	if s.verifier == nil {
+		return errNoVerifier
+	}
 	go s.deleter(ctx)
+	s.serve.Handler = s.routes()
+	if err := s.serve.ListenAndServe(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// routes wires the handlers of the API up with the middleware guarding them.
+func (s *Server) routes() *gin.Engine {
 	router := gin.Default()
+	router.Use(s.Authenticate)
 	userGroup := router.Group("/user")
 	{
 		userGroup.POST("/register", s.RegisterHandler)
 		userGroup.POST("/auth", s.AuthHandler)
+		userGroup.POST("/refresh", s.RefreshHandler)
+		userGroup.POST("/logout", s.RequireLogin, s.LogoutHandler)
+		userGroup.GET("/oidc/login", s.OIDCLoginHandler)
+		userGroup.GET("/oidc/callback", s.OIDCCallbackHandler)
+		userGroup.POST("/password-reset", s.PasswordResetHandler)
+		userGroup.POST("/password-reset/confirm", s.ConfirmPasswordResetHandler)
+		userGroup.POST("/verify-email", s.VerifyEmailHandler)
+	}
+	meGroup := userGroup.Group("/me", s.RequireLogin)
+	{
+		meGroup.GET("", s.ProfileHandler)
+		meGroup.PATCH("", s.UpdateProfileHandler)
+		meGroup.DELETE("", s.DeleteAccountHandler)
+		meGroup.PUT("/password", s.ChangePasswordHandler)
+		meGroup.POST("/verify-email", s.RequestVerificationHandler)
+		meGroup.POST("/oidc/link", s.OIDCLinkHandler)
+	}
+	apiKeyGroup := userGroup.Group("/api-keys", s.RequireLogin)
+	{
+		apiKeyGroup.GET("", s.APIKeysHandler)
+		apiKeyGroup.POST("", s.CreateAPIKeyHandler)
+		apiKeyGroup.DELETE("/:kid", s.DeleteAPIKeyHandler)
 	}
 	bookGroup := router.Group("/books")
 	{
-		bookGroup.GET("/my-books", s.BooksByUser)
 		bookGroup.GET("/all-books", s.AllBookHandler)
 		bookGroup.GET("/:id", s.GetBookByIDHandler)
-		bookGroup.POST("/add-book", s.SaveBookHandler)
-		bookGroup.DELETE("/delete/:id", s.DeleteBookHandler)
+		// E-reader apps fetch covers and files with Basic credentials, which these handlers check.
+		bookGroup.GET("/:id/files/:fid", s.DownloadBookFileHandler)
+		bookGroup.GET("/:id/cover", s.CoverHandler)
+		bookGroup.GET("/:id/cover/:hash/:size", s.CoverImageHandler)
 	}
-	s.serve.Handler = router
-	if err := s.serve.ListenAndServe(); err != nil {
-		return err
+	myBookGroup := bookGroup.Group("", s.RequireAuth)
+	{
+		myBookGroup.GET("/my-books", s.BooksByUser)
+		myBookGroup.POST("/add-book", s.SaveBookHandler)
+		myBookGroup.DELETE("/delete/:id", s.DeleteBookHandler)
+		myBookGroup.POST("/batch", s.BatchBooksHandler)
+		myBookGroup.POST("/import", s.ImportBooksHandler)
+		myBookGroup.GET("/export", s.ExportBooksHandler)
+		myBookGroup.POST("/import/calibre", s.CalibreImportHandler)
+		myBookGroup.GET("/:id/highlights", s.BookHighlightsHandler)
+		myBookGroup.POST("/:id/highlights", s.CreateHighlightHandler)
+		myBookGroup.GET("/:id/highlights/export", s.ExportHighlightsHandler)
+		myBookGroup.GET("/:id/highlights/:hid", s.GetHighlightHandler)
+		myBookGroup.PUT("/:id/highlights/:hid", s.UpdateHighlightHandler)
+		myBookGroup.DELETE("/:id/highlights/:hid", s.DeleteHighlightHandler)
+		myBookGroup.POST("/:id/files", s.UploadBookFileHandler)
+		myBookGroup.GET("/:id/files", s.BookFilesHandler)
+		myBookGroup.DELETE("/:id/files/:fid", s.DeleteBookFileHandler)
+		myBookGroup.GET("/:id/cite", s.CiteBookHandler)
+		myBookGroup.PUT("/:id/cover", s.PutCoverHandler)
 	}
-	return nil
+	bookShareGroup := bookGroup.Group("/:id", s.RequireScope(rbac.ScopeSharing))
+	{
+		bookShareGroup.PUT("/visibility", s.SetBookVisibilityHandler)
+		bookShareGroup.GET("/access", s.BookAccessHandler)
+		bookShareGroup.PUT("/access/:uid", s.GrantBookAccessHandler)
+		bookShareGroup.DELETE("/access/:uid", s.RevokeBookAccessHandler)
+	}
+	shelfGroup := router.Group("/shelves")
+	{
+		shelfGroup.GET("", s.RequireAuth, s.ShelvesHandler)
+	}
+	shelfShareGroup := shelfGroup.Group("/:name", s.RequireScope(rbac.ScopeSharing))
+	{
+		shelfShareGroup.PUT("/visibility", s.SetShelfVisibilityHandler)
+		shelfShareGroup.GET("/links", s.ShareLinksHandler)
+		shelfShareGroup.POST("/links", s.CreateShareLinkHandler)
+		shelfShareGroup.DELETE("/links/:lid", s.RevokeShareLinkHandler)
+		shelfShareGroup.GET("/access", s.ShelfAccessHandler)
+		shelfShareGroup.PUT("/access/:uid", s.GrantShelfAccessHandler)
+		shelfShareGroup.DELETE("/access/:uid", s.RevokeShelfAccessHandler)
+	}
+	usersGroup := router.Group("/users")
+	{
+		usersGroup.GET("/:uid/shelves", s.UserShelvesHandler)
+		usersGroup.GET("/:uid/shelves/:name", s.UserShelfHandler)
+	}
+	router.GET(sharedPath+":token", s.SharedShelfHandler)
+	// RequirePermission checks the login itself: admin routes need the admin scope of API keys
+	// rather than their book scopes.
+	adminGroup := router.Group("/admin")
+	{
+		adminGroup.GET("/users", s.RequirePermission(rbac.ListUsers), s.AdminUsersHandler)
+		adminGroup.DELETE("/books/:id", s.RequirePermission(rbac.ModerateBooks), s.AdminDeleteBookHandler)
+		adminGroup.GET("/stats", s.RequirePermission(rbac.ViewStats), s.AdminStatsHandler)
+	}
+	orgGroup := router.Group("/orgs", s.RequireScope(rbac.ScopeOrgs))
+	{
+		orgGroup.GET("", s.OrgsHandler)
+		orgGroup.POST("", s.CreateOrgHandler)
+		orgGroup.GET("/:oid/members", s.OrgMembersHandler)
+		orgGroup.PUT("/:oid/members/:uid", s.SetMemberHandler)
+		orgGroup.DELETE("/:oid/members/:uid", s.RemoveMemberHandler)
+		orgGroup.POST("/:oid/token", s.RequireLogin, s.OrgTokenHandler)
+	}
+	highlightGroup := router.Group("/highlights", s.RequireAuth)
+	{
+		highlightGroup.POST("/import", s.ImportHighlightsHandler)
+		highlightGroup.GET("/search", s.SearchHighlightsHandler)
+	}
+	opdsGroup := router.Group(opdsRoot)
+	{
+		opdsGroup.GET("", s.OPDSRootHandler)
+		opdsGroup.GET("/opensearch.xml", s.OPDSOpenSearchHandler)
+		opdsGroup.GET("/search", s.OPDSSearchHandler)
+		opdsGroup.GET("/books", s.OPDSBooksHandler)
+		opdsGroup.GET("/recent", s.OPDSRecentHandler)
+		for _, facet := range opdsFacets {
+			opdsGroup.GET("/"+facet.path, s.OPDSFacetHandler(facet))
+			opdsGroup.GET("/"+facet.path+"/:value", s.OPDSFacetBooksHandler(facet))
+		}
+	}
+	router.GET(oaiPath, s.OAIHandler)
+	router.POST(oaiPath, s.OAIHandler)
+	jobGroup := router.Group("/jobs", s.RequireAuth)
+	{
+		jobGroup.GET("/:id", s.JobHandler)
+	}
+	return router
 }
 
 func (s *Server) RegisterHandler(ctx *gin.Context) {
@@ -88,7 +367,7 @@ func (s *Server) RegisterHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	req, err := s.authClient.Register(context.TODO(), &authservicev1.User{
+	req, err := s.authClient.Register(ctx.Request.Context(), &authservicev1.User{
 		Name:  user.Name,
 		Email: user.Email,
 		Pass:  user.Pass,
@@ -99,12 +378,22 @@ func (s *Server) RegisterHandler(ctx *gin.Context) {
 			ctx.JSON(http.StatusConflict, gin.H{"error": "user with this e-mail address is already registered"})
 			return
 		}
+		if status.Code(err) == codes.InvalidArgument {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": status.Convert(err).Message()})
+			return
+		}
+		if authUnavailable(ctx, err) {
+			return
+		}
 		log.Error().Err(err).Msg("user register request failed")
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	log.Debug().Str("token", req.GetToken()).Str("msg", req.GetMessage()).Msg("grpc register request")
 	ctx.Header("Authorization", req.GetToken())
+	if req.GetRefreshToken() != "" {
+		ctx.Header(refreshHeader, req.GetRefreshToken())
+	}
 	ctx.String(http.StatusOK, req.GetMessage())
 }
 
@@ -115,29 +404,38 @@ func (s *Server) AuthHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	req, err := s.authClient.Login(context.TODO(), &authservicev1.UserCreds{
+	req, err := s.authClient.Login(ctx.Request.Context(), &authservicev1.UserCreds{
 		Email: user.Email,
 		Pass:  user.Pass,
 	})
 	if err != nil {
-		if status.Code(err) == codes.NotFound {
+		switch status.Code(err) {
+		case codes.NotFound:
 			log.Error().Err(err).Msg("request failed; user not found")
 			ctx.String(http.StatusUnauthorized, "user not found")
-		} else if status.Code(err) == codes.Unauthenticated {
+		case codes.Unauthenticated:
 			log.Error().Err(err).Msg("request failed; incorrect password")
 			ctx.String(http.StatusUnauthorized, "incorrect password")
+		default:
+			if authUnavailable(ctx, err) {
+				return
+			}
+			log.Error().Err(err).Msg("user login request failed")
+			ctx.String(http.StatusInternalServerError, err.Error())
 		}
-		log.Error().Err(err).Msg("user login request failed")
-		ctx.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 	log.Debug().Str("token", req.GetToken()).Str("msg", req.GetMessage()).Msg("grpc login request")
 	ctx.Header("Authorization", req.GetToken())
+	if req.GetRefreshToken() != "" {
+		ctx.Header(refreshHeader, req.GetRefreshToken())
+	}
 	ctx.String(http.StatusOK, req.GetMessage())
 }
 
+// AllBookHandler lists the public books of all users.
 func (s *Server) AllBookHandler(ctx *gin.Context) {
-	books, err := s.storage.GetBooks()
+	books, err := s.store(ctx).GetBooks()
 	if err != nil {
 		if errors.Is(err, storage.ErrBooksListEmpty) {
 			ctx.String(http.StatusNoContent, err.Error())
@@ -146,34 +444,43 @@ func (s *Server) AllBookHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, books)
+	ctx.JSON(http.StatusOK, s.withBooksCovers(books))
 }
 
+// GetBookByIDHandler serves a book. Private books are only served to their owner and to users
+// granted access, and look missing to everyone else.
 func (s *Server) GetBookByIDHandler(ctx *gin.Context) {
 	bid := ctx.Param("id")
 	log.Println(bid)
-	book, err := s.storage.GetBookByID(bid)
+	book, err := s.store(ctx).GetBookByID(bid)
 	if err != nil {
-		if errors.Is(err, storage.ErrBookNotFound) {
-			ctx.String(http.StatusNoContent, err.Error())
+		if errors.Is(err, storage.ErrBookNotFound) || errors.Is(err, storage.ErrBookDeleted) {
+			ctx.String(http.StatusNoContent, storage.ErrBookNotFound.Error())
 			return
 		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, book)
+	if !canView(book.Visibility, book.UID, "") {
+		role, roleErr := s.bookRole(s.store(ctx), book, viewerUID(ctx))
+		if roleErr != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": roleErr.Error()})
+			return
+		}
+		if role == "" {
+			ctx.String(http.StatusNoContent, storage.ErrBookNotFound.Error())
+			return
+		}
+	}
+	s.writeBook(ctx, s.withCovers(book))
 }
 
 func (s *Server) BooksByUser(ctx *gin.Context) {
-	log := logger.Get()
-	token := ctx.GetHeader("Authorization")
-	uid, err := getUID(token)
-	if err != nil {
-		log.Error().Err(err).Msg("get UID failed")
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	uid, ok := authUID(ctx)
+	if !ok {
 		return
 	}
-	books, err := s.storage.GetBookByUID(uid)
+	books, err := s.store(ctx).GetBookByUID(uid)
 	if err != nil {
 		if errors.Is(err, storage.ErrBooksListEmpty) {
 			ctx.String(http.StatusNoContent, err.Error())
@@ -182,7 +489,7 @@ func (s *Server) BooksByUser(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, books)
+	ctx.JSON(http.StatusOK, s.withBooksCovers(books))
 }
 
 func (s *Server) SaveBookHandler(ctx *gin.Context) {
@@ -191,23 +498,29 @@ func (s *Server) SaveBookHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	token := ctx.GetHeader("Authorization")
-	uid, err := getUID(token)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+	uid, ok := authUID(ctx)
+	if !ok {
 		return
 	}
 	book.UID = uid
-	if err = s.storage.SaveBook(book); err != nil {
+	if err := s.store(ctx).SaveBook(book); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	ctx.String(http.StatusCreated, "book was saved")
 }
 
+// DeleteBookHandler deletes a book. Only owners may do so.
 func (s *Server) DeleteBookHandler(ctx *gin.Context) {
-	bid := ctx.Param("id")
-	if err := s.storage.DeleteBook(bid); err != nil {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleOwner)
+	if !ok {
+		return
+	}
+	if err := s.store(ctx).DeleteBook(book.BID); err != nil {
 		if errors.Is(err, storage.ErrBookNotFound) {
 			ctx.String(http.StatusNoContent, err.Error())
 			return
@@ -227,6 +540,7 @@ func (s *Server) ShutdownServer(ctx context.Context) error {
 		log.Error().Err(err).Msg("server shutdown failed")
 		return err
 	}
+	s.jobs.Close()
 	return nil
 }
 
@@ -244,7 +558,7 @@ func (s *Server) deleter(ctx context.Context) {
 				for i := 0; i < 5; i++ {
 					<-s.deleteChan
 				}
-				if err := s.storage.DeleteBooks(); err != nil {
+				if err := s.purgeBooks(ctx); err != nil {
 					log.Error().Err(err).Msg("deleting books failed")
 					s.ErrChan <- err
 					return
@@ -254,18 +568,65 @@ func (s *Server) deleter(ctx context.Context) {
 	}
 }
 
-func getUID(tokenStr string) (string, error) {
-	claims := &Claims{}
+// purgeBooks purges deleted books and then removes the blobs of their files. A blob that
+// cannot be removed is only logged, as its record is gone already.
+func (s *Server) purgeBooks(ctx context.Context) error {
+	log := logger.Get()
+	files, err := s.storage.DeleteBooks()
+	if err != nil {
+		return err
+	}
+	if s.blobs == nil {
+		return nil
+	}
+	for _, file := range files {
+		if err = s.blobs.Delete(ctx, bookFileKey(file)); err != nil {
+			log.Error().Err(err).Str("fid", file.FID).Msg("delete book file blob failed")
+		}
+	}
+	return nil
+}
 
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
-	})
+// claims verifies tokenStr and returns its claims. Tokens of deleted accounts and tokens
+// issued before their user set a new password are rejected.
+func (s *Server) claims(ctx context.Context, tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	if err := s.verifier.Parse(ctx, tokenStr, claims); err != nil {
+		return nil, err
+	}
+	revoked, err := s.userRevoked(claims.UserID, claims.issued())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	if revoked {
+		return nil, tokens.ErrRevoked
+	}
+	return claims, nil
+}
 
-	if !token.Valid {
-		return "", errors.New("invalid token")
+// issued returns the iat claim, or the zero time for tokens without one.
+func (c *Claims) issued() time.Time {
+	if c.IssuedAt == nil {
+		return time.Time{}
+	}
+	return c.IssuedAt.Time
+}
+
+// userRevoked reports whether a token of uid issued at issued was revoked: in memory with
+// RevokeUser, or, with local users, by deleting the user or setting a new password.
+func (s *Server) userRevoked(uid string, issued time.Time) (bool, error) {
+	if s.verifier.UserRevoked(uid, issued) {
+		return true, nil
+	}
+	if !s.localUsers {
+		return false, nil
+	}
+	user, err := s.storage.GetUser(uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return true, nil
+		}
+		return false, err
 	}
-	return claims.UserID, nil
+	return tokens.IssuedBefore(issued, user.PasswordChangedAt), nil
 }
@@ -10,16 +10,52 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/Dorrrke/g2-books/internal/domain/models"
 	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/rbac"
 	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
 	mocks "github.com/Dorrrke/g2-books/moks"
 )
 
+// testSecret signs the tokens of the tests.
+const testSecret = "VerySecretKey2000"
+
+// testVerifier accepts the tokens testToken signs.
+func testVerifier() *tokens.Verifier {
+	return tokens.NewHMAC(testSecret)
+}
+
+func testToken(t *testing.T, uid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: uid})
+	tokenStr, err := token.SignedString([]byte(testSecret))
+	assert.NoError(t, err)
+	return tokenStr
+}
+
+func roleToken(t *testing.T, uid, role string) string {
+	t.Helper()
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: uid, Role: role}).
+		SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return tokenStr
+}
+
+// testServer serves the routes of srv, guarded as Run guards them, until the test ends.
+func testServer(t *testing.T, srv *Server) *httptest.Server {
+	t.Helper()
+	httpSrv := httptest.NewServer(srv.routes())
+	t.Cleanup(httpSrv.Close)
+	return httpSrv
+}
+
 func TestRegisterHandler(t *testing.T) {
 	var srv Server
 	r := gin.Default()
@@ -343,8 +379,8 @@ func TestDeleter(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			m := mocks.NewMockStorage(ctrl)
 			defer ctrl.Finish()
-			m.EXPECT().DeleteBooks().Return(tc.want.err)
-			srv := New("0.0.0.0:8080", m, nil)
+			m.EXPECT().DeleteBooks().Return(nil, tc.want.err)
+			srv := New("0.0.0.0:8080", m, nil, WithVerifier(testVerifier()))
 			for i := 0; i < 5; i++ {
 				srv.deleteChan <- i
 			}
@@ -365,3 +401,95 @@ func TestDeleter(t *testing.T) {
 		})
 	}
 }
+
+func TestRoutes(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	uid, err := store.SaveUser(models.User{Name: "Ann", Email: "ann@example.org", Pass: "hash"})
+	require.NoError(t, err)
+	adminUID, err := store.SaveUser(models.User{Name: "Bob", Email: "bob@example.org", Pass: "hash"})
+	require.NoError(t, err)
+	require.NoError(t, store.SetUserRole(adminUID, models.RoleAdmin))
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()),
+		WithTenants(func(tenant string) Storage { return store.WithTenant(tenant) }))
+	client := resty.New().SetBaseURL(testServer(t, srv).URL)
+
+	user := "Bearer " + testToken(t, uid)
+	admin := "Bearer " + roleToken(t, adminUID, models.RoleAdmin)
+	key := func(auth, scope string) string {
+		var created models.APIKey
+		resp, err := client.R().SetHeader("Authorization", auth).SetResult(&created).
+			SetBody(`{"name":"` + scope + `","scopes":["` + scope + `"]}`).Post("/user/api-keys")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode())
+		return "Bearer " + created.Key
+	}
+	readKey := key(user, rbac.ScopeReadBooks)
+	orgsKey := key(user, rbac.ScopeOrgs)
+	adminReadKey := key(admin, rbac.ScopeReadBooks)
+
+	type test struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		status int
+	}
+	tests := []test{
+		{name: "Test routes; Case 1:", method: http.MethodPost, path: "/user/logout", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 2:", method: http.MethodGet, path: "/user/me", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 3:", method: http.MethodGet, path: "/user/me", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 4:", method: http.MethodGet, path: "/user/api-keys", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 5:", method: http.MethodGet, path: "/user/api-keys", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 6:", method: http.MethodGet, path: "/books/my-books", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 7:", method: http.MethodGet, path: "/books/my-books", auth: readKey,
+			status: http.StatusNoContent},
+		{name: "Test routes; Case 8:", method: http.MethodPost, path: "/books/add-book", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 9:", method: http.MethodPut, path: "/books/bid/visibility",
+			status: http.StatusUnauthorized},
+		{name: "Test routes; Case 10:", method: http.MethodPut, path: "/books/bid/visibility", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 11:", method: http.MethodGet, path: "/shelves", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 12:", method: http.MethodGet, path: "/shelves/reading/links",
+			status: http.StatusUnauthorized},
+		{name: "Test routes; Case 13:", method: http.MethodGet, path: "/shelves/reading/links", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 14:", method: http.MethodGet, path: "/admin/users", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 15:", method: http.MethodGet, path: "/admin/users", auth: user,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 16:", method: http.MethodGet, path: "/admin/users",
+			auth: "Bearer " + roleToken(t, adminUID, models.RoleLibrarian), status: http.StatusForbidden},
+		{name: "Test routes; Case 17:", method: http.MethodDelete, path: "/admin/books/bid", auth: user,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 18:", method: http.MethodGet, path: "/admin/stats", auth: adminReadKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 19:", method: http.MethodGet, path: "/admin/stats", auth: admin,
+			status: http.StatusOK},
+		{name: "Test routes; Case 20:", method: http.MethodGet, path: "/orgs", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 21:", method: http.MethodGet, path: "/orgs", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 22:", method: http.MethodGet, path: "/orgs", auth: orgsKey, status: http.StatusOK},
+		{name: "Test routes; Case 23:", method: http.MethodPost, path: "/orgs/oid/token", auth: orgsKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 24:", method: http.MethodGet, path: "/highlights/search",
+			status: http.StatusUnauthorized},
+		{name: "Test routes; Case 25:", method: http.MethodPost, path: "/highlights/import", auth: readKey,
+			status: http.StatusForbidden},
+		{name: "Test routes; Case 26:", method: http.MethodGet, path: "/jobs/id", status: http.StatusUnauthorized},
+		{name: "Test routes; Case 27:", method: http.MethodGet, path: "/opds", status: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := client.R()
+			if tc.auth != "" {
+				req.SetHeader("Authorization", tc.auth)
+			}
+			resp, err := req.Execute(tc.method, tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode())
+		})
+	}
+}
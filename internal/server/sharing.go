@@ -0,0 +1,343 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const (
+	shareSecretSize     = 32
+	sharedPath          = "/shared/"
+	defaultShareLinkTTL = 30 * 24 * time.Hour
+	maxShareLinkTTL     = 365 * 24 * time.Hour
+)
+
+var (
+	errVisibility        = errors.New(errText.VisibilityError)
+	errShareLinkExpiry   = errors.New(errText.ShareLinkExpiryError)
+	errShareLinkInvalid  = errors.New(errText.ShareLinkInvalidError)
+	errShareLinkNotFound = errors.New(errText.ShareLinkNotFoundError)
+)
+
+// SetBookVisibilityHandler makes a book private, unlisted or public. Only owners may do so.
+func (s *Server) SetBookVisibilityHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	visibility, ok := bindVisibility(ctx)
+	if !ok {
+		return
+	}
+	book, ok := s.authorizeBook(ctx, uid, models.RoleOwner)
+	if !ok {
+		return
+	}
+	if err := s.store(ctx).SetBookVisibility(book.UID, book.BID, visibility); err != nil {
+		if errors.Is(err, storage.ErrBookNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("set book visibility failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	book.Visibility = visibility
+	ctx.JSON(http.StatusOK, s.withCovers(book))
+}
+
+// ShelvesHandler lists the caller's shelves with their visibility.
+func (s *Server) ShelvesHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	shelves, err := s.store(ctx).GetShelves(uid)
+	if err != nil {
+		log.Error().Err(err).Msg("get shelves failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, shelves)
+}
+
+// SetShelfVisibilityHandler makes one of the caller's shelves private, unlisted or public. The
+// books on a shared shelf are shown with it, whatever their own visibility.
+func (s *Server) SetShelfVisibilityHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	visibility, ok := bindVisibility(ctx)
+	if !ok {
+		return
+	}
+	shelf, ok := s.userShelf(ctx, uid, ctx.Param("name"))
+	if !ok {
+		return
+	}
+	if err := s.store(ctx).SetShelfVisibility(uid, shelf.Name, visibility); err != nil {
+		log.Error().Err(err).Msg("set shelf visibility failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	shelf.Visibility = visibility
+	ctx.JSON(http.StatusOK, shelf)
+}
+
+// UserShelvesHandler lists the public shelves of a user. No login is needed.
+func (s *Server) UserShelvesHandler(ctx *gin.Context) {
+	log := logger.Get()
+	shelves, err := s.store(ctx).GetShelves(ctx.Param("uid"))
+	if err != nil {
+		log.Error().Err(err).Msg("get shelves failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	public := []models.Shelf{}
+	for _, shelf := range shelves {
+		if shelf.Visibility == models.VisibilityPublic {
+			public = append(public, shelf)
+		}
+	}
+	ctx.JSON(http.StatusOK, public)
+}
+
+// UserShelfHandler serves the books on a shelf of a user. Unlisted and public shelves need no
+// login; private ones are only shown to their owner and to users granted access.
+func (s *Server) UserShelfHandler(ctx *gin.Context) {
+	owner, name := ctx.Param("uid"), ctx.Param("name")
+	shelf, ok := s.userShelf(ctx, owner, name)
+	if !ok {
+		return
+	}
+	if !canView(shelf.Visibility, owner, "") {
+		role, err := s.shelfRole(s.store(ctx), owner, name, viewerUID(ctx))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if role == "" {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": storage.ErrShelfNotFound.Error()})
+			return
+		}
+	}
+	s.writeShelf(ctx, s.store(ctx), models.SharedShelf{Shelf: name}, owner)
+}
+
+// ShareLinksHandler lists the share links of one of the caller's shelves, revoked and expired
+// ones included.
+func (s *Server) ShareLinksHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	links, err := s.store(ctx).GetShareLinks(uid, ctx.Param("name"))
+	if err != nil {
+		log.Error().Err(err).Msg("get share links failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range links {
+		links[i].URL = s.shareURL(links[i])
+	}
+	ctx.JSON(http.StatusOK, links)
+}
+
+// CreateShareLinkHandler creates a signed link that grants read-only access to one of the
+// caller's shelves without login. Links expire after 30 days unless expires_at says otherwise.
+func (s *Server) CreateShareLinkHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	var req models.ShareLinkRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	now := time.Now().UTC()
+	expires := now.Add(defaultShareLinkTTL)
+	if req.ExpiresAt != nil {
+		expires = req.ExpiresAt.UTC()
+		if !expires.After(now) || expires.After(now.Add(maxShareLinkTTL)) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": errShareLinkExpiry.Error()})
+			return
+		}
+	}
+	shelf, ok := s.userShelf(ctx, uid, ctx.Param("name"))
+	if !ok {
+		return
+	}
+	// Expiry is signed with second precision, which is what the database keeps as well.
+	link := models.ShareLink{
+		LID:       uuid.New().String(),
+		UID:       uid,
+		Shelf:     shelf.Name,
+		ExpiresAt: expires.Truncate(time.Second),
+		CreatedAt: &now,
+	}
+	if err := s.store(ctx).SaveShareLink(link); err != nil {
+		log.Error().Err(err).Msg("save share link failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	link.URL = s.shareURL(link)
+	ctx.JSON(http.StatusCreated, link)
+}
+
+// RevokeShareLinkHandler revokes a share link of one of the caller's shelves for good.
+func (s *Server) RevokeShareLinkHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	link, err := s.store(ctx).GetShareLink(ctx.Param("lid"))
+	if err == nil && (link.UID != uid || link.Shelf != ctx.Param("name")) {
+		err = storage.ErrShareLinkNotFound
+	}
+	if err == nil {
+		err = s.store(ctx).RevokeShareLink(uid, link.LID)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrShareLinkNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": errShareLinkNotFound.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("revoke share link failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// SharedShelfHandler serves the shelf a share link grants access to. Unknown, forged, expired
+// and revoked links all get the same 404.
+func (s *Server) SharedShelfHandler(ctx *gin.Context) {
+	log := logger.Get()
+	lid, sig, ok := strings.Cut(ctx.Param("token"), ".")
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": errShareLinkInvalid.Error()})
+		return
+	}
+	link, err := s.storage.GetShareLink(lid)
+	if err != nil {
+		if errors.Is(err, storage.ErrShareLinkNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": errShareLinkInvalid.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("get share link failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.shareSignature(link))) || link.RevokedAt != nil ||
+		!time.Now().Before(link.ExpiresAt) || (link.TenantID != "" && s.tenants == nil) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": errShareLinkInvalid.Error()})
+		return
+	}
+	ctx.Header("Cache-Control", "private, no-store")
+	// The link is opened without a token, so it names the library the shelf is in itself.
+	shared := models.SharedShelf{Shelf: link.Shelf, ExpiresAt: &link.ExpiresAt}
+	s.writeShelf(ctx, s.tenantStore(link.TenantID), shared, link.UID)
+}
+
+// writeShelf writes the books of a shelf of owner kept in store.
+func (s *Server) writeShelf(ctx *gin.Context, store Storage, shelf models.SharedShelf, owner string) {
+	log := logger.Get()
+	books, err := store.GetBookByUID(owner)
+	if err != nil && !errors.Is(err, storage.ErrBooksListEmpty) {
+		log.Error().Err(err).Msg("get shelf books failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	shelf.Books = []models.Book{}
+	for _, book := range books {
+		if slices.Contains(book.Shelves, shelf.Shelf) {
+			shelf.Books = append(shelf.Books, book)
+		}
+	}
+	shelf.Books = s.withBooksCovers(shelf.Books)
+	ctx.JSON(http.StatusOK, shelf)
+}
+
+// userShelf loads a shelf of uid, writing a 404 if no book is on it.
+func (s *Server) userShelf(ctx *gin.Context, uid, name string) (models.Shelf, bool) {
+	shelf, err := s.store(ctx).GetShelf(uid, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrShelfNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return models.Shelf{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.Shelf{}, false
+	}
+	return shelf, true
+}
+
+// shareURL returns the path a share link is opened at: the link id and its signature.
+func (s *Server) shareURL(link models.ShareLink) string {
+	return sharedPath + link.LID + "." + s.shareSignature(link)
+}
+
+// shareSignature signs everything a link grants, so a link id alone opens nothing.
+func (s *Server) shareSignature(link models.ShareLink) string {
+	mac := hmac.New(sha256.New, s.shareSecret)
+	mac.Write([]byte(link.LID + "\n" + link.UID + "\n" + link.Shelf + "\n" +
+		strconv.FormatInt(link.ExpiresAt.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomShareSecret is the key share links are signed with unless one is configured. It lives
+// as long as the process, so links stop working on restart.
+func randomShareSecret() []byte {
+	secret := make([]byte, shareSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		// Without randomness no key or token the server hands out would be safe.
+		panic(err)
+	}
+	return secret
+}
+
+func bindVisibility(ctx *gin.Context) (string, bool) {
+	var req models.VisibilityUpdate
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	switch req.Visibility {
+	case models.VisibilityPrivate, models.VisibilityUnlisted, models.VisibilityPublic:
+		return req.Visibility, true
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"error": errVisibility.Error()})
+	return "", false
+}
+
+// canView reports whether viewer, empty when anonymous, may see something of owner.
+func canView(visibility, owner, viewer string) bool {
+	return visibility == models.VisibilityPublic || visibility == models.VisibilityUnlisted ||
+		viewer != "" && viewer == owner
+}
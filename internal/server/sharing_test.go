@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+func TestSharingFlow(t *testing.T) {
+	logger.Get(true)
+	store := storage.New()
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", UID: "testUID",
+		Shelves: []string{"sci-fi"}, Visibility: models.VisibilityPublic}))
+	require.NoError(t, store.SaveBook(models.Book{Lable: "Emma", Author: "Jane Austen", UID: "testUID"}))
+	books, err := store.GetBookByUID("testUID")
+	require.NoError(t, err)
+	byLable := map[string]string{}
+	for _, book := range books {
+		// New books are private whatever the client asked for.
+		assert.Equal(t, models.VisibilityPrivate, book.Visibility)
+		byLable[book.Lable] = book.BID
+	}
+
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithShareSecret("test secret"))
+	httpSrv := testServer(t, srv)
+
+	owner := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "testUID"))
+	other := resty.New().SetBaseURL(httpSrv.URL).SetHeader("Authorization", testToken(t, "otherUID"))
+	anonymous := resty.New().SetBaseURL(httpSrv.URL)
+
+	// Private books are hidden from everyone but their owner.
+	resp, err := anonymous.R().Get("/books/all-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = other.R().Get("/books/" + byLable["Dune"])
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = owner.R().Get("/books/" + byLable["Dune"])
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	resp, err = owner.R().SetBody(`{"visibility":"everyone"}`).Put("/books/" + byLable["Dune"] + "/visibility")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, err = other.R().SetBody(`{"visibility":"public"}`).Put("/books/" + byLable["Dune"] + "/visibility")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	var book models.Book
+	resp, err = owner.R().SetBody(`{"visibility":"public"}`).SetResult(&book).
+		Put("/books/" + byLable["Dune"] + "/visibility")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, models.VisibilityPublic, book.Visibility)
+	resp, err = owner.R().SetBody(`{"visibility":"unlisted"}`).Put("/books/" + byLable["Emma"] + "/visibility")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	// Public books are listed, unlisted ones are only found by id.
+	var all []models.Book
+	resp, err = anonymous.R().SetResult(&all).Get("/books/all-books")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, all, 1)
+	assert.Equal(t, "Dune", all[0].Lable)
+	resp, err = anonymous.R().Get("/books/" + byLable["Emma"])
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	// Shelves are private until shared.
+	var shelves []models.Shelf
+	resp, err = owner.R().SetResult(&shelves).Get("/shelves")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, []models.Shelf{{UID: "testUID", Name: "sci-fi", Visibility: models.VisibilityPrivate, Books: 1}},
+		shelves)
+	resp, err = anonymous.R().Get("/users/testUID/shelves/sci-fi")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = owner.R().Get("/users/testUID/shelves/sci-fi")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = owner.R().SetBody(`{"visibility":"public"}`).Put("/shelves/fantasy/visibility")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = owner.R().SetBody(`{"visibility":"unlisted"}`).Put("/shelves/sci-fi/visibility")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	var shared models.SharedShelf
+	resp, err = anonymous.R().SetResult(&shared).Get("/users/testUID/shelves/sci-fi")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, shared.Books, 1)
+	assert.Equal(t, "Dune", shared.Books[0].Lable)
+	resp, err = anonymous.R().SetResult(&shelves).Get("/users/testUID/shelves")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Empty(t, shelves)
+
+	// Share links open a shelf without login until they expire or are revoked.
+	resp, err = owner.R().SetBody(map[string]any{"expires_at": time.Now().Add(-time.Hour)}).
+		Post("/shelves/sci-fi/links")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, err = owner.R().Post("/shelves/fantasy/links")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	var link models.ShareLink
+	resp, err = owner.R().SetResult(&link).Post("/shelves/sci-fi/links")
+	assert.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode())
+	assert.Equal(t, "sci-fi", link.Shelf)
+	assert.WithinDuration(t, time.Now().Add(defaultShareLinkTTL), link.ExpiresAt, time.Minute)
+
+	resp, err = anonymous.R().SetResult(&shared).Get(link.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "sci-fi", shared.Shelf)
+	assert.Len(t, shared.Books, 1)
+	resp, err = anonymous.R().Get(sharedPath + link.LID + ".forged")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	resp, err = other.R().Delete("/shelves/sci-fi/links/" + link.LID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = owner.R().Delete("/shelves/sci-fi/links/" + link.LID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	resp, err = anonymous.R().Get(link.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	var links []models.ShareLink
+	resp, err = owner.R().SetResult(&links).Get("/shelves/sci-fi/links")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.Len(t, links, 1)
+	assert.NotNil(t, links[0].RevokedAt)
+	assert.Equal(t, link.URL, links[0].URL)
+
+	// Expired links do not open either.
+	expired := models.ShareLink{LID: "expired", UID: "testUID", Shelf: "sci-fi", ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, store.SaveShareLink(expired))
+	resp, err = anonymous.R().Get(srv.shareURL(expired))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+}
+
+func TestShareSecret(t *testing.T) {
+	first, second := New("0.0.0.0:8080", nil, nil), New("0.0.0.0:8080", nil, nil)
+	defer first.jobs.Close()
+	defer second.jobs.Close()
+	// Without a configured secret each process signs with its own random key.
+	assert.Len(t, first.shareSecret, shareSecretSize)
+	assert.NotEqual(t, first.shareSecret, second.shareSecret)
+	assert.NotEqual(t, []byte(testSecret), first.shareSecret)
+	configured := New("0.0.0.0:8080", nil, nil, WithShareSecret("test secret"))
+	defer configured.jobs.Close()
+	assert.Equal(t, []byte("test secret"), configured.shareSecret)
+}
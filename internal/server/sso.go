@@ -0,0 +1,306 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/oidc"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	"github.com/Dorrrke/g2-books/internal/tokens"
+)
+
+const (
+	// oidcStateCookie ties the callback of a login to the browser that started it, so nobody
+	// can finish a login they started for someone else.
+	oidcStateCookie = "g2b_oidc_state"
+	oidcCookiePath  = "/user/oidc"
+	oidcCookieAge   = 600
+	// defaultSessionTTL is long, as sessions from the OpenID provider come without a refresh
+	// token.
+	defaultSessionTTL = 8 * time.Hour
+)
+
+var (
+	errOIDCDisabled   = errors.New(errText.OIDCDisabledError)
+	errOIDCState      = errors.New(errText.OIDCStateError)
+	errOIDCLogin      = errors.New(errText.OIDCLoginError)
+	errOIDCEmail      = errors.New(errText.OIDCEmailError)
+	errOIDCEmailTaken = errors.New(errText.OIDCEmailTakenError)
+	errOIDCLinked     = errors.New(errText.OIDCLinkedError)
+	errSessionTokens  = errors.New(errText.SessionTokensError)
+)
+
+// oidcLinks remembers, by the state of the login, which logged-in users asked to link the
+// identity they log in with at the provider.
+type oidcLinks struct {
+	mu      sync.Mutex
+	pending map[string]oidcLink
+}
+
+type oidcLink struct {
+	uid    string
+	expiry time.Time
+}
+
+func newOIDCLinks() *oidcLinks {
+	return &oidcLinks{pending: make(map[string]oidcLink)}
+}
+
+// add remembers that uid started the login with state. Links never finished are dropped once
+// their login has expired.
+func (l *oidcLinks) add(state, uid string) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, link := range l.pending {
+		if link.expiry.Before(now) {
+			delete(l.pending, key)
+		}
+	}
+	l.pending[state] = oidcLink{uid: uid, expiry: now.Add(oidcCookieAge * time.Second)}
+}
+
+// take returns the user who started the login with state as a link, or an empty string for
+// plain logins. Each state is taken once.
+func (l *oidcLinks) take(state string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	link, ok := l.pending[state]
+	delete(l.pending, state)
+	if !ok || link.expiry.Before(time.Now()) {
+		return ""
+	}
+	return link.uid
+}
+
+// OIDCLoginHandler sends the user to the OpenID provider to log in.
+func (s *Server) OIDCLoginHandler(ctx *gin.Context) {
+	authURL, _, ok := s.startOIDC(ctx)
+	if !ok {
+		return
+	}
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCLinkHandler starts linking an identity at the OpenID provider to the logged-in user, which
+// is how users whose e-mail address is not verified here sign in through the provider. It
+// answers with the URL of the login page of the provider; the callback then links the identity
+// instead of looking up its user by e-mail address.
+func (s *Server) OIDCLinkHandler(ctx *gin.Context) {
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	authURL, state, ok := s.startOIDC(ctx)
+	if !ok {
+		return
+	}
+	s.links.add(state, uid)
+	ctx.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// startOIDC starts a login at the provider and ties it to the browser with the state cookie.
+// It answers the request itself when the login cannot be started.
+func (s *Server) startOIDC(ctx *gin.Context) (string, string, bool) {
+	log := logger.Get()
+	if s.sso == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errOIDCDisabled.Error()})
+		return "", "", false
+	}
+	authURL, state, err := s.sso.AuthCodeURL()
+	if err != nil {
+		if errors.Is(err, oidc.ErrBusy) {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return "", "", false
+		}
+		log.Error().Err(err).Msg("start oidc login failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", "", false
+	}
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, state, oidcCookieAge, oidcCookiePath, "", ctx.Request.TLS != nil, true)
+	return authURL, state, true
+}
+
+// OIDCCallbackHandler finishes a login through the OpenID provider. The identity the provider
+// vouches for is mapped to a local user, or linked to the user who started the login with
+// OIDCLinkHandler, and that user gets a session token like a login with a password hands out.
+func (s *Server) OIDCCallbackHandler(ctx *gin.Context) {
+	log := logger.Get()
+	if s.sso == nil {
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": errOIDCDisabled.Error()})
+		return
+	}
+	state := ctx.Query("state")
+	cookie, err := ctx.Cookie(oidcStateCookie)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", ctx.Request.TLS != nil, true)
+	if err != nil || state == "" || cookie != state {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errOIDCState.Error()})
+		return
+	}
+	if reason := ctx.Query("error"); reason != "" {
+		log.Info().Str("error", reason).Str("description", ctx.Query("error_description")).
+			Msg("oidc login refused by provider")
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": errOIDCLogin.Error()})
+		return
+	}
+	identity, err := s.sso.Exchange(ctx.Request.Context(), state, ctx.Query("code"))
+	switch {
+	case err == nil:
+	case errors.Is(err, oidc.ErrState):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errOIDCState.Error()})
+		return
+	case errors.Is(err, oidc.ErrRejected), errors.Is(err, oidc.ErrIDToken):
+		log.Warn().Err(err).Msg("oidc login failed")
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": errOIDCLogin.Error()})
+		return
+	default:
+		log.Error().Err(err).Msg("oidc provider unavailable")
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": errOIDCLogin.Error()})
+		return
+	}
+	var uid string
+	if linkUID := s.links.take(state); linkUID != "" {
+		uid, err = s.linkIdentity(identity, linkUID)
+	} else {
+		uid, err = s.oidcUser(identity)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errOIDCEmail):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errOIDCEmailTaken), errors.Is(err, errOIDCLinked):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Error().Err(err).Msg("map oidc identity failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	token, err := s.sessionToken(uid)
+	if err != nil {
+		if errors.Is(err, tokens.ErrNoSecret) {
+			ctx.JSON(http.StatusNotImplemented, gin.H{"error": errSessionTokens.Error()})
+			return
+		}
+		log.Error().Err(err).Msg("sign session token failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Info().Str("uid", uid).Str("issuer", identity.Issuer).Msg("oidc login")
+	ctx.Header("Authorization", token)
+	ctx.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// oidcUser returns the local user of identity. Identities seen before keep their user. New
+// ones are linked to the user with the same e-mail address only when both the provider and
+// this service have verified it; otherwise the user has to link the identity while logged in.
+// Identities without a user get one of their own.
+func (s *Server) oidcUser(identity oidc.Identity) (string, error) {
+	linked, err := s.storage.GetIdentity(identity.Issuer, identity.Subject)
+	if err == nil {
+		return linked.UID, nil
+	}
+	if !errors.Is(err, storage.ErrIdentityNotFound) {
+		return "", err
+	}
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email == "" {
+		return "", errOIDCEmail
+	}
+	uid, _, err := s.storage.ValidateUser(models.User{Email: email})
+	switch {
+	case err == nil:
+		if !identity.EmailVerified {
+			return "", errOIDCEmailTaken
+		}
+		var user models.User
+		if user, err = s.storage.GetUser(uid); err != nil {
+			return "", err
+		}
+		if !user.EmailVerified {
+			return "", errOIDCEmailTaken
+		}
+	case errors.Is(err, storage.ErrUserNotFound):
+		// Users from the provider have no password and log in through it only.
+		user := models.User{Name: identity.Name, Email: email, Pass: models.NoPassword}
+		if uid, err = s.storage.SaveUser(user); err != nil {
+			if errors.Is(err, storage.ErrUserExists) {
+				return "", errOIDCEmailTaken
+			}
+			return "", err
+		}
+	default:
+		return "", err
+	}
+	err = s.storage.SaveIdentity(models.Identity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		UID:     uid,
+		Email:   email,
+	})
+	if err != nil {
+		return "", err
+	}
+	// Two first logins at once link the identity once; both get the user linked first.
+	linked, err = s.storage.GetIdentity(identity.Issuer, identity.Subject)
+	if err != nil {
+		return "", err
+	}
+	return linked.UID, nil
+}
+
+// linkIdentity links identity to the user uid, who asked for it while logged in. Identities
+// already linked to another user stay with that user.
+func (s *Server) linkIdentity(identity oidc.Identity, uid string) (string, error) {
+	err := s.storage.SaveIdentity(models.Identity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		UID:     uid,
+		Email:   strings.ToLower(strings.TrimSpace(identity.Email)),
+	})
+	if err != nil {
+		return "", err
+	}
+	linked, err := s.storage.GetIdentity(identity.Issuer, identity.Subject)
+	if err != nil {
+		return "", err
+	}
+	if linked.UID != uid {
+		return "", errOIDCLinked
+	}
+	return uid, nil
+}
+
+// sessionToken signs a token for uid like those of the auth service.
+func (s *Server) sessionToken(uid string) (string, error) {
+	user, err := s.storage.GetUser(uid)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uid,
+			Issuer:    s.session.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.session.TTL)),
+		},
+		UserID: uid,
+		Role:   user.Role,
+	}
+	if s.session.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.session.Audience}
+	}
+	return s.verifier.Sign(claims)
+}
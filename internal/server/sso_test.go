@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/oidc"
+	"github.com/Dorrrke/g2-books/internal/oidc/oidctest"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	logger.Get(true)
+	provider, err := oidctest.NewProvider("g2-books", "secret")
+	require.NoError(t, err)
+	defer provider.Close()
+
+	httpSrv := httptest.NewUnstartedServer(nil)
+	rp, err := oidc.New(context.Background(), oidc.Config{
+		Issuer:       provider.URL,
+		ClientID:     "g2-books",
+		ClientSecret: "secret",
+		RedirectURL:  "http://" + httpSrv.Listener.Addr().String() + "/user/oidc/callback",
+	})
+	require.NoError(t, err)
+	store := storage.New()
+	existing, err := store.SaveUser(models.User{Name: "Bob", Email: "bob@example.org", Pass: "hash"})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateUser(models.User{UID: existing, Name: "Bob", Email: "bob@example.org",
+		EmailVerified: true}))
+	unverified, err := store.SaveUser(models.User{Name: "Dave", Email: "dave@example.org", Pass: "hash"})
+	require.NoError(t, err)
+	srv := New("0.0.0.0:8080", store, nil, WithVerifier(testVerifier()), WithOIDC(rp, SessionConfig{}))
+	httpSrv.Config.Handler = srv.routes()
+	httpSrv.Start()
+	defer httpSrv.Close()
+
+	// login follows the redirects through the provider and returns who the token is for.
+	login := func(user oidctest.User) (int, string) {
+		provider.SetUser(user)
+		var body struct {
+			Token string `json:"token"`
+		}
+		client := resty.New().SetBaseURL(httpSrv.URL)
+		resp, err := client.R().SetResult(&body).Get("/user/oidc/login")
+		require.NoError(t, err)
+		if resp.StatusCode() != http.StatusOK {
+			return resp.StatusCode(), ""
+		}
+		resp, err = client.R().SetHeader("Authorization", "Bearer "+body.Token).Get("/shelves")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+		claims, err := srv.claims(context.Background(), body.Token)
+		require.NoError(t, err)
+		return http.StatusOK, claims.UserID
+	}
+
+	type want struct {
+		status int
+		uid    string
+	}
+	type test struct {
+		name string
+		user oidctest.User
+		want want
+	}
+	tests := []test{
+		{
+			name: "Test OIDC login; Case 1:",
+			user: oidctest.User{Subject: "bob", Email: "Bob@example.org", EmailVerified: true},
+			want: want{status: http.StatusOK, uid: existing},
+		},
+		{
+			name: "Test OIDC login; Case 2:",
+			user: oidctest.User{Subject: "bob", Email: "changed@example.org"},
+			want: want{status: http.StatusOK, uid: existing},
+		},
+		{
+			name: "Test OIDC login; Case 3:",
+			user: oidctest.User{Subject: "mallory", Email: "bob@example.org"},
+			want: want{status: http.StatusConflict},
+		},
+		{
+			name: "Test OIDC login; Case 4:",
+			user: oidctest.User{Subject: "nobody"},
+			want: want{status: http.StatusBadRequest},
+		},
+		{
+			name: "Test OIDC login; Case 5:",
+			user: oidctest.User{Subject: "dave", Email: "dave@example.org", EmailVerified: true},
+			want: want{status: http.StatusConflict},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status, uid := login(tc.user)
+			assert.Equal(t, tc.want.status, status)
+			assert.Equal(t, tc.want.uid, uid)
+		})
+	}
+
+	// New identities get a user of their own, and keep it.
+	status, carol := login(oidctest.User{Subject: "carol", Email: "carol@example.org", Name: "Carol"})
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, carol)
+	assert.NotEqual(t, existing, carol)
+	_, again := login(oidctest.User{Subject: "carol", Email: "carol@example.org"})
+	assert.Equal(t, carol, again)
+	user, err := store.GetUser(carol)
+	require.NoError(t, err)
+	assert.Equal(t, models.NoPassword, user.Pass)
+
+	// link starts a link as uid and follows it through the provider.
+	link := func(uid string, user oidctest.User) int {
+		provider.SetUser(user)
+		var started struct {
+			URL string `json:"url"`
+		}
+		client := resty.New().SetBaseURL(httpSrv.URL)
+		resp, err := client.R().SetHeader("Authorization", "Bearer "+testToken(t, uid)).SetResult(&started).
+			Post("/user/me/oidc/link")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+		resp, err = client.R().Get(started.URL)
+		require.NoError(t, err)
+		return resp.StatusCode()
+	}
+	// Users link identities while logged in; identities of other users stay with them.
+	dave := oidctest.User{Subject: "dave", Email: "dave@example.org", EmailVerified: true}
+	assert.Equal(t, http.StatusOK, link(unverified, dave))
+	status, uid := login(dave)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, unverified, uid)
+	assert.Equal(t, http.StatusConflict, link(unverified, oidctest.User{Subject: "carol", Email: "carol@example.org"}))
+
+	// Callbacks not started by this browser are refused.
+	resp, err := resty.New().SetBaseURL(httpSrv.URL).R().Get("/user/oidc/callback?state=forged&code=stolen")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+}
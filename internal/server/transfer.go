@@ -0,0 +1,204 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/formats"
+	"github.com/Dorrrke/g2-books/internal/formats/bookcsv"
+	"github.com/Dorrrke/g2-books/internal/formats/cite"
+	"github.com/Dorrrke/g2-books/internal/formats/goodreads"
+	"github.com/Dorrrke/g2-books/internal/formats/marc"
+	"github.com/Dorrrke/g2-books/internal/formats/storygraph"
+	"github.com/Dorrrke/g2-books/internal/importer"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+)
+
+const (
+	maxImportSize    = 10 << 20
+	formatCSV        = "csv"
+	formatGoodreads  = "goodreads"
+	formatStoryGraph = "storygraph"
+	formatMARC       = "marc"
+	formatMARCXML    = "marcxml"
+)
+
+var (
+	errUnsupportedFormat = errors.New(errText.UnsupportedFmtError)
+	errImportFile        = errors.New(errText.ImportFileError)
+)
+
+// ImportBooksHandler creates books for the caller from an uploaded file. The file is sent either
+// as the raw request body or as the "file" field of a multipart form. Rows matching a book the
+// caller already has, by ISBN or by title and author, are reported as duplicates and skipped.
+// With dry_run=true the rows are only validated.
+func (s *Server) ImportBooksHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := importBody(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	var rows []formats.Row
+	switch ctx.DefaultQuery("format", formatCSV) {
+	case formatCSV:
+		mapping, mapErr := bookcsv.ParseMapping(ctx.Query("mapping"))
+		if mapErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": mapErr.Error()})
+			return
+		}
+		rows, err = bookcsv.Read(body, mapping)
+	case formatGoodreads:
+		rows, err = goodreads.Read(body)
+	case formatStoryGraph:
+		rows, err = storygraph.Read(body)
+	case formatMARC:
+		rows, err = marc.Read(body)
+	case formatMARCXML:
+		rows, err = marc.ReadXML(body)
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": errUnsupportedFormat.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := importer.Import(s.store(ctx), uid, rows, dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("import books failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// ExportBooksHandler streams the caller's books in the requested format: csv, marc, marcxml or
+// one of the citation styles. Query filters narrow the export, see filterBooks.
+func (s *Server) ExportBooksHandler(ctx *gin.Context) {
+	log := logger.Get()
+	uid, ok := authUID(ctx)
+	if !ok {
+		return
+	}
+	var export func(io.Writer, []models.Book) error
+	var contentType, filename string
+	switch ctx.DefaultQuery("format", formatCSV) {
+	case formatCSV:
+		export, contentType, filename = exportCSV, "text/csv; charset=utf-8", "books.csv"
+	case formatMARC:
+		export, contentType, filename = exportMARC, marc.ContentType, "books.mrc"
+	case formatMARCXML:
+		export, contentType, filename = exportMARCXML, marc.XMLContentType, "books.xml"
+	default:
+		style, ok := cite.Lookup(ctx.Query("format"))
+		if !ok {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": errUnsupportedFormat.Error()})
+			return
+		}
+		export, contentType, filename = style.Write, style.ContentType, "books."+style.Ext
+	}
+	books, err := s.store(ctx).GetBookByUID(uid)
+	if err != nil && !errors.Is(err, storage.ErrBooksListEmpty) {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	books = filterBooks(books, ctx.Request.URL.Query())
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Status(http.StatusOK)
+	if err = export(ctx.Writer, books); err != nil {
+		log.Error().Err(err).Msg("export books failed")
+	}
+}
+
+// filterBooks keeps the books matching every filter given in query: id (repeatable), tag,
+// shelf, status, series, year, and author, which matches part of the name ignoring case.
+func filterBooks(books []models.Book, query url.Values) []models.Book {
+	ids, author := query["id"], strings.ToLower(query.Get("author"))
+	tag, shelf, status := query.Get("tag"), query.Get("shelf"), query.Get("status")
+	series, year := query.Get("series"), query.Get("year")
+	filtered := books[:0]
+	for _, book := range books {
+		switch {
+		case len(ids) != 0 && !slices.Contains(ids, book.BID),
+			author != "" && !strings.Contains(strings.ToLower(book.Author), author),
+			tag != "" && !slices.Contains(book.Tags, tag),
+			shelf != "" && !slices.Contains(book.Shelves, shelf),
+			status != "" && book.Status != status,
+			series != "" && book.Series != series,
+			year != "" && strconv.Itoa(book.Year) != year:
+			continue
+		}
+		filtered = append(filtered, book)
+	}
+	return filtered
+}
+
+func exportCSV(w io.Writer, books []models.Book) error {
+	writer := bookcsv.NewWriter(w)
+	if err := writer.WriteHeader(); err != nil {
+		return err
+	}
+	for _, book := range books {
+		if err := writer.Write(book); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func exportMARC(w io.Writer, books []models.Book) error {
+	writer := marc.NewWriter(w)
+	for _, book := range books {
+		if err := writer.Write(book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportMARCXML(w io.Writer, books []models.Book) error {
+	writer := marc.NewXMLWriter(w)
+	for _, book := range books {
+		if err := writer.Write(book); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+func importBody(ctx *gin.Context) (io.ReadCloser, error) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize)
+	if !strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		return ctx.Request.Body, nil
+	}
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return nil, errImportFile
+	}
+	return header.Open()
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Dorrrke/g2-books/internal/domain/models"
+	"github.com/Dorrrke/g2-books/internal/logger"
+	"github.com/Dorrrke/g2-books/internal/storage"
+	mocks "github.com/Dorrrke/g2-books/moks"
+)
+
+func TestImportBooksHandler(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	type want struct {
+		listFlag   bool
+		mockFlag   bool
+		statusCode int
+		body       string
+	}
+	type test struct {
+		name     string
+		query    string
+		body     string
+		existing []models.Book
+		ops      []models.BookOp
+		results  []models.BookOpResult
+		want     want
+	}
+
+	tests := []test{
+		{
+			name:  "Test ImportBooksHandler; Case 1:",
+			query: "?dry_run=true",
+			body:  "title,author\nDune,Frank Herbert\nNo Author,\n",
+			want: want{
+				listFlag:   true,
+				statusCode: http.StatusOK,
+				body: `{"dry_run":true,"total":2,"valid":1,"duplicates":0,"imported":0,"rows":[` +
+					`{"line":2,"status":"valid","lable":"Dune"},` +
+					`{"line":3,"status":"invalid","lable":"No Author","errors":["author is required"]}]}`,
+			},
+		},
+		{
+			name:  "Test ImportBooksHandler; Case 2:",
+			query: "?mapping=lable:Name,author:Writer",
+			body:  "Name,Writer\nDune,Frank Herbert\n",
+			ops: []models.BookOp{
+				{Op: models.BookOpCreate, Book: models.Book{Lable: "Dune", Author: "Frank Herbert", UID: "testUID"}},
+			},
+			results: []models.BookOpResult{{Op: models.BookOpCreate, BID: "bid1"}},
+			want: want{
+				listFlag:   true,
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				body: `{"dry_run":false,"total":1,"valid":1,"duplicates":0,"imported":1,"rows":[` +
+					`{"line":2,"status":"imported","b_id":"bid1","lable":"Dune"}]}`,
+			},
+		},
+		{
+			name:  "Test ImportBooksHandler; Case 3:",
+			query: "?mapping=pages:Pages",
+			body:  "Name,Writer\nDune,Frank Herbert\n",
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"invalid column mapping: unknown field \"pages\""}`,
+			},
+		},
+		{
+			name:  "Test ImportBooksHandler; Case 4:",
+			query: "?format=goodreads",
+			body: "Book Id,Title,Author,ISBN,ISBN13,My Rating,Date Read,Bookshelves,Exclusive Shelf\n" +
+				`1,"Dune (Dune Chronicles, #1)","Frank Herbert","=""0441172717""","=""""",5,2024/03/02,"sci-fi, read",read` +
+				"\n" + `2,Solaris,Stanislaw Lem,,,4,,"to-read, classics",to-read` + "\n" +
+				`3,"Solaris: A Novel","Lem, Stanislaw",,,0,,,to-read` + "\n",
+			existing: []models.Book{{BID: "bid0", Lable: "Dune", Author: "Herbert", ISBN: "978-0-441-17271-9"}},
+			ops: []models.BookOp{
+				{Op: models.BookOpCreate, Book: models.Book{
+					Lable: "Solaris", Author: "Stanislaw Lem", Rating: 4, Status: models.StatusToRead,
+					Shelves: []string{"classics"}, UID: "testUID",
+				}},
+			},
+			results: []models.BookOpResult{{Op: models.BookOpCreate, BID: "bid2"}},
+			want: want{
+				listFlag:   true,
+				mockFlag:   true,
+				statusCode: http.StatusOK,
+				body: `{"dry_run":false,"total":3,"valid":3,"duplicates":2,"imported":1,"rows":[` +
+					`{"line":2,"status":"duplicate","b_id":"bid0","lable":"Dune (Dune Chronicles, #1)"},` +
+					`{"line":3,"status":"imported","b_id":"bid2","lable":"Solaris"},` +
+					`{"line":4,"status":"duplicate","lable":"Solaris: A Novel"}]}`,
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			if tc.want.listFlag {
+				m.EXPECT().GetBookByUID("testUID").Return(tc.existing, nil)
+			}
+			if tc.want.mockFlag {
+				m.EXPECT().ApplyBookOps(tc.ops, false).Return(tc.results, nil)
+			}
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/books/import" + tc.query
+			req.Body = tc.body
+			req.SetHeader("Authorization", testToken(t, "testUID"))
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
+
+func TestExportBooksHandler(t *testing.T) {
+	srv := New("0.0.0.0:8080", nil, nil, WithVerifier(testVerifier()))
+	httpSrv := testServer(t, srv)
+
+	type want struct {
+		statusCode int
+		body       string
+	}
+	type test struct {
+		name  string
+		query string
+		books []models.Book
+		err   error
+		want  want
+	}
+
+	tests := []test{
+		{
+			name:  "Test ExportBooksHandler; Case 1:",
+			query: "?format=csv",
+			books: []models.Book{{BID: "bid1", Lable: "Dune", Author: "Frank Herbert", UID: "testUID"}},
+			want: want{
+				statusCode: http.StatusOK,
+				body:       "b_id,lable,author,isbn,rating,status,shelves,date_started,date_read,series,series_index,tags\nbid1,Dune,Frank Herbert,,,,,,,,,\n",
+			},
+		},
+		{
+			name: "Test ExportBooksHandler; Case 2:",
+			err:  storage.ErrBooksListEmpty,
+			want: want{
+				statusCode: http.StatusOK,
+				body:       "b_id,lable,author,isbn,rating,status,shelves,date_started,date_read,series,series_index,tags\n",
+			},
+		},
+		{
+			name:  "Test ExportBooksHandler; Case 3:",
+			query: "?format=marcxml",
+			books: []models.Book{{BID: "bid1", Lable: "Dune", Author: "Frank Herbert", UID: "testUID", Year: 1965}},
+			want: want{
+				statusCode: http.StatusOK,
+				body: `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a22000007c 4500</leader>
+    <controlfield tag="001">bid1</controlfield>
+    <datafield tag="100" ind1="1" ind2=" ">
+      <subfield code="a">Herbert, Frank</subfield>
+    </datafield>
+    <datafield tag="245" ind1="1" ind2="0">
+      <subfield code="a">Dune</subfield>
+      <subfield code="c">Frank Herbert</subfield>
+    </datafield>
+    <datafield tag="260" ind1=" " ind2=" ">
+      <subfield code="c">1965</subfield>
+    </datafield>
+  </record>
+</collection>`,
+			},
+		},
+		{
+			name:  "Test ExportBooksHandler; Case 4:",
+			query: "?format=ris&tag=sci-fi",
+			books: []models.Book{
+				{BID: "bid1", Lable: "Dune", Author: "Frank Herbert", UID: "testUID", Year: 1965, Tags: []string{"sci-fi"}},
+				{BID: "bid2", Lable: "Emma", Author: "Jane Austen", UID: "testUID", Year: 1815},
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				body:       "TY  - BOOK\r\nAU  - Herbert, Frank\r\nTI  - Dune\r\nPY  - 1965\r\nKW  - sci-fi\r\nER  - \r\n",
+			},
+		},
+		{
+			name:  "Test ExportBooksHandler; Case 5:",
+			query: "?format=apa&author=austen",
+			books: []models.Book{
+				{BID: "bid1", Lable: "Dune", Author: "Frank Herbert", UID: "testUID", Year: 1965},
+				{BID: "bid2", Lable: "Emma", Author: "Jane Austen", UID: "testUID", Year: 1815, Publisher: "John Murray"},
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				body:       "Austen, J. (1815). Emma. John Murray.\n",
+			},
+		},
+	}
+
+	logger.Get(true)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks.NewMockStorage(ctrl)
+			defer ctrl.Finish()
+			m.EXPECT().GetBookByUID("testUID").Return(tc.books, tc.err)
+			srv.storage = m
+			req := resty.New().R()
+			req.Method = http.MethodGet
+			req.URL = httpSrv.URL + "/books/export" + tc.query
+			req.SetHeader("Authorization", testToken(t, "testUID"))
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
@@ -1,51 +1,192 @@
 package storage
 
 import (
-	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 
 	"github.com/Dorrrke/g2-books/internal/domain/models"
 )
 
+// MemStorage keeps everything in memory. Like Repository, it works in the default tenant unless
+// obtained from WithTenant.
 type MemStorage struct {
-	usersMap map[string]models.User
-	booksMap map[string]models.Book
+	*memState
+	tenant string
+}
+
+// memState is the data shared by the storages of all tenants.
+type memState struct {
+	mu            sync.RWMutex
+	usersMap      map[string]models.User
+	booksMap      map[string]models.Book
+	highlightsMap map[string]models.Highlight
+	filesMap      map[string]models.BookFile
+	shelvesMap    map[shelfKey]string
+	linksMap      map[string]models.ShareLink
+	grantsMap     map[grantKey]models.Grant
+	orgsMap       map[string]models.Org
+	membersMap    map[memberKey]models.Member
+	apiKeysMap    map[string]models.APIKey
+	identitiesMap map[identityKey]models.Identity
+}
+
+// identityKey names a user of an OpenID provider.
+type identityKey struct {
+	issuer  string
+	subject string
+}
+
+// memberKey names the membership of a user in an organization.
+type memberKey struct {
+	oid string
+	uid string
+}
+
+// grantKey names the role of one user on a book or shelf of an owner.
+type grantKey struct {
+	tenant string
+	owner  string
+	kind   string
+	target string
+	uid    string
+}
+
+// shelfKey names a shelf of one user.
+type shelfKey struct {
+	tenant string
+	uid    string
+	name   string
 }
 
 func New() *MemStorage {
 	uMap := make(map[string]models.User)
 	bMap := make(map[string]models.Book)
-	return &MemStorage{
-		usersMap: uMap,
-		booksMap: bMap,
+	return &MemStorage{memState: &memState{
+		usersMap:      uMap,
+		booksMap:      bMap,
+		highlightsMap: make(map[string]models.Highlight),
+		filesMap:      make(map[string]models.BookFile),
+		shelvesMap:    make(map[shelfKey]string),
+		linksMap:      make(map[string]models.ShareLink),
+		grantsMap:     make(map[grantKey]models.Grant),
+		orgsMap:       make(map[string]models.Org),
+		membersMap:    make(map[memberKey]models.Member),
+		apiKeysMap:    make(map[string]models.APIKey),
+		identitiesMap: make(map[identityKey]models.Identity),
+	}}
+}
+
+// WithTenant returns a storage working in the library of tenant. It shares the data of ms.
+func (ms *MemStorage) WithTenant(tenant string) *MemStorage {
+	return &MemStorage{memState: ms.memState, tenant: tenant}
+}
+
+// tenantBook returns a book of the storage's tenant.
+func (ms *MemStorage) tenantBook(bID string) (models.Book, bool) {
+	book, ok := ms.booksMap[bID]
+	if !ok || book.TenantID != ms.tenant {
+		return models.Book{}, false
 	}
+	return book, true
 }
 
-func (ms *MemStorage) SaveUser(user models.User) error {
+func (ms *MemStorage) SaveUser(user models.User) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, value := range ms.usersMap {
+		if value.Email == user.Email {
+			return "", ErrUserExists
+		}
+	}
 	uid := uuid.New().String()
+	user.UID = uid
 	ms.usersMap[uid] = user
-	return nil
+	return uid, nil
 }
 
-func (ms *MemStorage) ValidateUser(user models.User) (string, error) {
+func (ms *MemStorage) ValidateUser(user models.User) (string, string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	for uid, value := range ms.usersMap {
 		if value.Email == user.Email {
-			if value.Pass != user.Pass {
-				return "", ErrInvalidAuthData
-			}
-			return uid, nil
+			return uid, value.Pass, nil
 		}
 	}
-	return "", ErrUserNotFound
+	return "", "", ErrUserNotFound
 }
 
+func (ms *MemStorage) GetUser(uid string) (models.User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	user, ok := ms.usersMap[uid]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (ms *MemStorage) UpdateUser(user models.User) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	old, ok := ms.usersMap[user.UID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	old.Name, old.Email, old.EmailVerified = user.Name, user.Email, user.EmailVerified
+	ms.usersMap[user.UID] = old
+	return nil
+}
+
+// SetUserRole gives a user an account role, like Repository.SetUserRole.
+func (ms *MemStorage) SetUserRole(uid, role string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	user, ok := ms.usersMap[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Role = role
+	ms.usersMap[uid] = user
+	return nil
+}
+
+func (ms *MemStorage) SetPassword(uid, hash string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	user, ok := ms.usersMap[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Pass, user.PasswordChangedAt = hash, time.Now()
+	ms.usersMap[uid] = user
+	return nil
+}
+
+func (ms *MemStorage) DeleteUser(uid string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.usersMap[uid]; !ok {
+		return ErrUserNotFound
+	}
+	delete(ms.usersMap, uid)
+	return nil
+}
+
+// GetBooks lists the public books of all users of the tenant.
 func (ms *MemStorage) GetBooks() ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	books := []models.Book{}
-	for bid, value := range ms.booksMap {
-		book := value
-		book.BID = bid
-		books = append(books, book)
+	for _, book := range ms.booksMap {
+		if book.TenantID == ms.tenant && !book.Delete && book.Visibility == models.VisibilityPublic {
+			books = append(books, book)
+		}
 	}
 	if len(books) == 0 {
 		return nil, ErrBooksListEmpty
@@ -53,29 +194,872 @@ func (ms *MemStorage) GetBooks() ([]models.Book, error) {
 	return books, nil
 }
 
-func (ms *MemStorage) GetBookByID(bID string) (models.Book, error) {
-	log.Printf("BID: %s\n", bID)
-	for _, val := range ms.booksMap {
-		log.Println(val.Lable, val.BID)
+func (ms *MemStorage) GetBookByUID(uid string) ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	books := []models.Book{}
+	for _, book := range ms.booksMap {
+		if book.TenantID == ms.tenant && !book.Delete && book.UID == uid {
+			books = append(books, book)
+		}
 	}
-	book, ok := ms.booksMap[bID]
+	if len(books) == 0 {
+		return nil, ErrBooksListEmpty
+	}
+	return books, nil
+}
+
+func (ms *MemStorage) GetBookByID(bID string) (models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	book, ok := ms.tenantBook(bID)
 	if !ok {
 		return models.Book{}, ErrBookNotFound
 	}
+	if book.Delete {
+		return book, ErrBookDeleted
+	}
 	return book, nil
 }
 
+func (ms *MemStorage) HarvestBooks(q models.HarvestQuery) ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var books []models.Book
+	for _, book := range ms.booksMap {
+		updated := updatedAt(book)
+		switch {
+		case book.TenantID != ms.tenant, book.Visibility != models.VisibilityPublic,
+			q.From != nil && updated.Before(*q.From),
+			q.Until != nil && !updated.Before(*q.Until),
+			q.After != nil && (updated.Before(*q.After) || updated.Equal(*q.After) && book.BID <= q.AfterID):
+			continue
+		}
+		books = append(books, book)
+	}
+	sort.Slice(books, func(i, j int) bool {
+		ti, tj := updatedAt(books[i]), updatedAt(books[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return books[i].BID < books[j].BID
+	})
+	if len(books) > q.Limit {
+		books = books[:q.Limit]
+	}
+	return books, nil
+}
+
+func updatedAt(book models.Book) time.Time {
+	if book.UpdatedAt == nil {
+		return time.Time{}
+	}
+	return *book.UpdatedAt
+}
+
 func (ms *MemStorage) SaveBook(book models.Book) error {
-	bID := uuid.New().String()
-	ms.booksMap[bID] = book
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now().UTC()
+	book.BID = uuid.New().String()
+	book.Visibility, book.TenantID = models.VisibilityPrivate, ms.tenant
+	book.CreatedAt, book.UpdatedAt = &now, &now
+	ms.booksMap[book.BID] = book
 	return nil
 }
 
 func (ms *MemStorage) DeleteBook(bID string) error {
-	_, ok := ms.booksMap[bID]
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	book, ok := ms.tenantBook(bID)
 	if !ok {
 		return ErrBookNotFound
 	}
-	delete(ms.booksMap, bID)
+	now := time.Now().UTC()
+	book.Delete, book.UpdatedAt = true, &now
+	ms.booksMap[bID] = book
+	return nil
+}
+
+// ForceDeleteBook deletes a book of any user in any tenant.
+func (ms *MemStorage) ForceDeleteBook(bID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	book, ok := ms.booksMap[bID]
+	if !ok || book.Delete {
+		return ErrBookNotFound
+	}
+	now := time.Now().UTC()
+	book.Delete, book.UpdatedAt = true, &now
+	ms.booksMap[bID] = book
+	return nil
+}
+
+// DeleteAccount removes the data of a user from every tenant, like Repository.DeleteAccount.
+func (ms *MemStorage) DeleteAccount(uid string, anonymize bool) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.strandsOrg(uid) {
+		return ErrLastAdmin
+	}
+	now := time.Now().UTC()
+	for bID, book := range ms.booksMap {
+		if book.UID != uid || book.Delete {
+			continue
+		}
+		if anonymize && book.Visibility == models.VisibilityPublic {
+			book.UID, book.Shelves, book.Status, book.Rating = models.DeletedUser, nil, "", 0
+			book.DateStarted, book.DateRead = nil, nil
+			for fID, file := range ms.filesMap {
+				if file.BID == bID && file.UID == uid {
+					file.UID = models.DeletedUser
+					ms.filesMap[fID] = file
+				}
+			}
+		} else {
+			book.Delete = true
+		}
+		book.UpdatedAt = &now
+		ms.booksMap[bID] = book
+	}
+	for hID, h := range ms.highlightsMap {
+		if h.UID == uid {
+			delete(ms.highlightsMap, hID)
+		}
+	}
+	for key := range ms.shelvesMap {
+		if key.uid == uid {
+			delete(ms.shelvesMap, key)
+		}
+	}
+	for lID, link := range ms.linksMap {
+		if link.UID == uid {
+			delete(ms.linksMap, lID)
+		}
+	}
+	for key := range ms.grantsMap {
+		if key.owner == uid || key.uid == uid {
+			delete(ms.grantsMap, key)
+		}
+	}
+	for key := range ms.membersMap {
+		if key.uid == uid {
+			delete(ms.membersMap, key)
+		}
+	}
+	for kID, key := range ms.apiKeysMap {
+		if key.UID == uid {
+			delete(ms.apiKeysMap, kID)
+		}
+	}
+	for key, id := range ms.identitiesMap {
+		if id.UID == uid {
+			delete(ms.identitiesMap, key)
+		}
+	}
+	return nil
+}
+
+// GetUsers lists the users registered locally and the users owning books, in any tenant.
+func (ms *MemStorage) GetUsers() ([]models.UserSummary, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.users(), nil
+}
+
+func (ms *MemStorage) users() []models.UserSummary {
+	byUID := make(map[string]*models.UserSummary)
+	for uid, user := range ms.usersMap {
+		byUID[uid] = &models.UserSummary{UID: uid, Name: user.Name, Email: user.Email}
+	}
+	for _, book := range ms.booksMap {
+		u, ok := byUID[book.UID]
+		if !ok {
+			u = &models.UserSummary{UID: book.UID}
+			byUID[book.UID] = u
+		}
+		if !book.Delete {
+			u.Books++
+		}
+	}
+	users := []models.UserSummary{}
+	for _, u := range byUID {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UID < users[j].UID })
+	return users
+}
+
+// GetStats counts the data of every tenant.
+func (ms *MemStorage) GetStats() (models.Stats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	st := models.Stats{
+		Users:      len(ms.users()),
+		Highlights: len(ms.highlightsMap),
+		Files:      len(ms.filesMap),
+		Orgs:       len(ms.orgsMap),
+	}
+	for _, book := range ms.booksMap {
+		switch {
+		case book.Delete:
+			st.DeletedBooks++
+		case book.Visibility == models.VisibilityPublic:
+			st.PublicBooks++
+			st.Books++
+		default:
+			st.Books++
+		}
+	}
+	for _, file := range ms.filesMap {
+		st.FilesSize += file.Size
+	}
+	return st, nil
+}
+
+// DeleteBooks purges deleted books of every tenant and returns the files they had.
+func (ms *MemStorage) DeleteBooks() ([]models.BookFile, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for bID, book := range ms.booksMap {
+		if book.Delete {
+			delete(ms.booksMap, bID)
+		}
+	}
+	for hID, h := range ms.highlightsMap {
+		if _, ok := ms.booksMap[h.BID]; !ok {
+			delete(ms.highlightsMap, hID)
+		}
+	}
+	files := []models.BookFile{}
+	for fID, file := range ms.filesMap {
+		if _, ok := ms.booksMap[file.BID]; !ok {
+			files = append(files, file)
+			delete(ms.filesMap, fID)
+		}
+	}
+	for key := range ms.grantsMap {
+		if _, ok := ms.booksMap[key.target]; key.kind == models.GrantBook && !ok {
+			delete(ms.grantsMap, key)
+		}
+	}
+	return files, nil
+}
+
+func (ms *MemStorage) ApplyBookOps(ops []models.BookOp, atomic bool) ([]models.BookOpResult, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now().UTC()
+	staged := make(map[string]models.Book)
+	results := make([]models.BookOpResult, len(ops))
+	for i, op := range ops {
+		results[i] = models.BookOpResult{Index: i, Op: op.Op, BID: op.Book.BID}
+		switch op.Op {
+		case models.BookOpCreate:
+			book := op.Book
+			book.BID = uuid.New().String()
+			book.Delete = false
+			book.Visibility, book.TenantID = models.VisibilityPrivate, ms.tenant
+			book.CreatedAt, book.UpdatedAt = &now, &now
+			staged[book.BID] = book
+			results[i].BID = book.BID
+		case models.BookOpUpdate, models.BookOpDelete:
+			book, ok := staged[op.Book.BID]
+			if !ok {
+				book, ok = ms.tenantBook(op.Book.BID)
+			}
+			if !ok || book.Delete || book.UID != op.Book.UID {
+				results[i].Error = ErrBookNotFound.Error()
+				continue
+			}
+			if op.Op == models.BookOpUpdate {
+				updated := op.Book
+				updated.BID, updated.UID, updated.CreatedAt = book.BID, book.UID, book.CreatedAt
+				updated.Cover, updated.Visibility, updated.TenantID = book.Cover, book.Visibility, book.TenantID
+				if op.KeepShelves {
+					updated.Shelves = book.Shelves
+				}
+				book = updated
+			} else {
+				book.Delete = true
+			}
+			book.UpdatedAt = &now
+			staged[book.BID] = book
+		default:
+			results[i].Error = ErrUnknownBookOp.Error()
+		}
+	}
+	if atomic {
+		for _, res := range results {
+			if res.Error != "" {
+				return results, ErrBatchAborted
+			}
+		}
+	}
+	for bID, book := range staged {
+		ms.booksMap[bID] = book
+	}
+	return results, nil
+}
+
+func (ms *MemStorage) SetBookCover(uid, bID, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	book, ok := ms.tenantBook(bID)
+	if !ok || book.Delete || book.UID != uid {
+		return ErrBookNotFound
+	}
+	now := time.Now().UTC()
+	book.Cover, book.UpdatedAt = key, &now
+	ms.booksMap[bID] = book
+	return nil
+}
+
+func (ms *MemStorage) SetBookVisibility(uid, bID, visibility string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	book, ok := ms.tenantBook(bID)
+	if !ok || book.Delete || book.UID != uid {
+		return ErrBookNotFound
+	}
+	now := time.Now().UTC()
+	book.Visibility, book.UpdatedAt = visibility, &now
+	ms.booksMap[bID] = book
+	return nil
+}
+
+func (ms *MemStorage) GetShelves(uid string) ([]models.Shelf, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.shelves(uid, ""), nil
+}
+
+func (ms *MemStorage) GetShelf(uid, name string) (models.Shelf, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	shelves := ms.shelves(uid, name)
+	if len(shelves) == 0 {
+		return models.Shelf{}, ErrShelfNotFound
+	}
+	return shelves[0], nil
+}
+
+// shelves counts the books on each shelf of uid; a non-empty name selects a single shelf.
+func (ms *MemStorage) shelves(uid, name string) []models.Shelf {
+	counts := make(map[string]int)
+	for _, book := range ms.booksMap {
+		if book.TenantID != ms.tenant || book.Delete || book.UID != uid {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, shelf := range book.Shelves {
+			if !seen[shelf] && (name == "" || shelf == name) {
+				seen[shelf] = true
+				counts[shelf]++
+			}
+		}
+	}
+	shelves := []models.Shelf{}
+	for shelf, count := range counts {
+		visibility, ok := ms.shelvesMap[shelfKey{tenant: ms.tenant, uid: uid, name: shelf}]
+		if !ok {
+			visibility = models.VisibilityPrivate
+		}
+		shelves = append(shelves, models.Shelf{UID: uid, Name: shelf, Visibility: visibility, Books: count})
+	}
+	sort.Slice(shelves, func(i, j int) bool { return shelves[i].Name < shelves[j].Name })
+	return shelves
+}
+
+func (ms *MemStorage) SetShelfVisibility(uid, name, visibility string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.shelvesMap[shelfKey{tenant: ms.tenant, uid: uid, name: name}] = visibility
+	return nil
+}
+
+func (ms *MemStorage) SaveShareLink(link models.ShareLink) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now().UTC()
+	link.TenantID, link.CreatedAt = ms.tenant, &now
+	ms.linksMap[link.LID] = link
+	return nil
+}
+
+func (ms *MemStorage) GetShareLink(lID string) (models.ShareLink, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	link, ok := ms.linksMap[lID]
+	if !ok {
+		return models.ShareLink{}, ErrShareLinkNotFound
+	}
+	return link, nil
+}
+
+func (ms *MemStorage) GetShareLinks(uid, shelf string) ([]models.ShareLink, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	links := []models.ShareLink{}
+	for _, link := range ms.linksMap {
+		if link.TenantID == ms.tenant && link.UID == uid && link.Shelf == shelf {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if !links[i].CreatedAt.Equal(*links[j].CreatedAt) {
+			return links[i].CreatedAt.Before(*links[j].CreatedAt)
+		}
+		return links[i].LID < links[j].LID
+	})
+	return links, nil
+}
+
+func (ms *MemStorage) RevokeShareLink(uid, lID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	link, ok := ms.linksMap[lID]
+	if !ok || link.TenantID != ms.tenant || link.UID != uid {
+		return ErrShareLinkNotFound
+	}
+	if link.RevokedAt == nil {
+		now := time.Now().UTC()
+		link.RevokedAt = &now
+		ms.linksMap[lID] = link
+	}
+	return nil
+}
+
+func (ms *MemStorage) SaveGrant(g models.Grant) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := grantKey{tenant: ms.tenant, owner: g.Owner, kind: g.Kind, target: g.Target, uid: g.UID}
+	if old, ok := ms.grantsMap[key]; ok {
+		g.CreatedAt = old.CreatedAt
+	} else {
+		now := time.Now().UTC()
+		g.CreatedAt = &now
+	}
+	ms.grantsMap[key] = g
+	return nil
+}
+
+func (ms *MemStorage) GetGrants(owner, kind, target string) ([]models.Grant, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	grants := []models.Grant{}
+	for key, g := range ms.grantsMap {
+		if key.tenant == ms.tenant && key.owner == owner && key.kind == kind && key.target == target {
+			grants = append(grants, g)
+		}
+	}
+	sortGrants(grants)
+	return grants, nil
+}
+
+func (ms *MemStorage) GetUserGrants(owner, uid string) ([]models.Grant, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	grants := []models.Grant{}
+	for key, g := range ms.grantsMap {
+		if key.tenant == ms.tenant && key.owner == owner && key.uid == uid {
+			grants = append(grants, g)
+		}
+	}
+	sortGrants(grants)
+	return grants, nil
+}
+
+func sortGrants(grants []models.Grant) {
+	sort.Slice(grants, func(i, j int) bool {
+		if !grants[i].CreatedAt.Equal(*grants[j].CreatedAt) {
+			return grants[i].CreatedAt.Before(*grants[j].CreatedAt)
+		}
+		return grants[i].UID < grants[j].UID
+	})
+}
+
+func (ms *MemStorage) DeleteGrant(owner, kind, target, uid string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := grantKey{tenant: ms.tenant, owner: owner, kind: kind, target: target, uid: uid}
+	if _, ok := ms.grantsMap[key]; !ok {
+		return ErrGrantNotFound
+	}
+	delete(ms.grantsMap, key)
+	return nil
+}
+
+func (ms *MemStorage) SaveOrg(org models.Org, admin string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now().UTC()
+	org.OID, org.Role, org.CreatedAt = uuid.New().String(), "", &now
+	ms.orgsMap[org.OID] = org
+	ms.membersMap[memberKey{oid: org.OID, uid: admin}] = models.Member{
+		OID: org.OID, UID: admin, Role: models.OrgAdmin, CreatedAt: &now,
+	}
+	return org.OID, nil
+}
+
+func (ms *MemStorage) GetUserOrgs(uid string) ([]models.Org, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	orgs := []models.Org{}
+	for key, m := range ms.membersMap {
+		if key.uid == uid {
+			org := ms.orgsMap[key.oid]
+			org.Role = m.Role
+			orgs = append(orgs, org)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		if !orgs[i].CreatedAt.Equal(*orgs[j].CreatedAt) {
+			return orgs[i].CreatedAt.Before(*orgs[j].CreatedAt)
+		}
+		return orgs[i].OID < orgs[j].OID
+	})
+	return orgs, nil
+}
+
+func (ms *MemStorage) GetMember(oID, uid string) (models.Member, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	m, ok := ms.membersMap[memberKey{oid: oID, uid: uid}]
+	if !ok {
+		return models.Member{}, ErrMemberNotFound
+	}
+	return m, nil
+}
+
+func (ms *MemStorage) GetMembers(oID string) ([]models.Member, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	members := []models.Member{}
+	for key, m := range ms.membersMap {
+		if key.oid == oID {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(*members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(*members[j].CreatedAt)
+		}
+		return members[i].UID < members[j].UID
+	})
+	return members, nil
+}
+
+func (ms *MemStorage) SaveMember(m models.Member) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := memberKey{oid: m.OID, uid: m.UID}
+	if old, ok := ms.membersMap[key]; ok {
+		m.CreatedAt = old.CreatedAt
+	} else {
+		now := time.Now().UTC()
+		m.CreatedAt = &now
+	}
+	ms.membersMap[key] = m
+	return nil
+}
+
+func (ms *MemStorage) DeleteMember(oID, uid string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := memberKey{oid: oID, uid: uid}
+	if _, ok := ms.membersMap[key]; !ok {
+		return ErrMemberNotFound
+	}
+	delete(ms.membersMap, key)
+	return nil
+}
+
+func (ms *MemStorage) GetIdentity(issuer, subject string) (models.Identity, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	id, ok := ms.identitiesMap[identityKey{issuer: issuer, subject: subject}]
+	if !ok {
+		return models.Identity{}, ErrIdentityNotFound
+	}
+	return id, nil
+}
+
+func (ms *MemStorage) SaveIdentity(id models.Identity) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := identityKey{issuer: id.Issuer, subject: id.Subject}
+	if _, ok := ms.identitiesMap[key]; ok {
+		return nil
+	}
+	now := time.Now().UTC()
+	id.CreatedAt = &now
+	ms.identitiesMap[key] = id
+	return nil
+}
+
+// strandsOrg reports whether removing uid leaves an organization with members but no admin.
+// Callers hold the lock.
+func (ms *MemStorage) strandsOrg(uid string) bool {
+	others, admins := make(map[string]bool), make(map[string]bool)
+	for key, m := range ms.membersMap {
+		if key.uid == uid {
+			continue
+		}
+		others[key.oid] = true
+		if m.Role == models.OrgAdmin {
+			admins[key.oid] = true
+		}
+	}
+	for key, m := range ms.membersMap {
+		if key.uid == uid && m.Role == models.OrgAdmin && others[key.oid] && !admins[key.oid] {
+			return true
+		}
+	}
+	return false
+}
+
+func (ms *MemStorage) SaveAPIKey(key models.APIKey) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now().UTC()
+	key.CreatedAt = &now
+	ms.apiKeysMap[key.KID] = key
+	return nil
+}
+
+func (ms *MemStorage) GetAPIKeys(uid string) ([]models.APIKey, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	keys := []models.APIKey{}
+	for _, key := range ms.apiKeysMap {
+		if key.UID == uid {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(*keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(*keys[j].CreatedAt)
+		}
+		return keys[i].KID < keys[j].KID
+	})
+	return keys, nil
+}
+
+func (ms *MemStorage) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for _, key := range ms.apiKeysMap {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, ErrAPIKeyNotFound
+}
+
+func (ms *MemStorage) TouchAPIKey(kID string, at time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if key, ok := ms.apiKeysMap[kID]; ok {
+		key.LastUsedAt = &at
+		ms.apiKeysMap[kID] = key
+	}
 	return nil
 }
+
+func (ms *MemStorage) DeleteAPIKey(uid, kID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key, ok := ms.apiKeysMap[kID]
+	if !ok || key.UID != uid {
+		return ErrAPIKeyNotFound
+	}
+	delete(ms.apiKeysMap, kID)
+	return nil
+}
+
+func (ms *MemStorage) SaveBookFile(file models.BookFile) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now().UTC()
+	file.CreatedAt = &now
+	ms.filesMap[file.FID] = file
+	return nil
+}
+
+func (ms *MemStorage) GetBookFiles(uid, bID string) ([]models.BookFile, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	files := []models.BookFile{}
+	for _, file := range ms.filesMap {
+		if file.UID == uid && file.BID == bID {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].CreatedAt.Equal(*files[j].CreatedAt) {
+			return files[i].CreatedAt.Before(*files[j].CreatedAt)
+		}
+		return files[i].FID < files[j].FID
+	})
+	return files, nil
+}
+
+// GetBookFilesOf returns the files of several books of uid, keyed by book ID.
+func (ms *MemStorage) GetBookFilesOf(uid string, bIDs []string) (map[string][]models.BookFile, error) {
+	files := make(map[string][]models.BookFile, len(bIDs))
+	for _, bID := range bIDs {
+		bookFiles, err := ms.GetBookFiles(uid, bID)
+		if err != nil {
+			return nil, err
+		}
+		if len(bookFiles) != 0 {
+			files[bID] = bookFiles
+		}
+	}
+	return files, nil
+}
+
+func (ms *MemStorage) GetBookFile(uid, fID string) (models.BookFile, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	file, ok := ms.filesMap[fID]
+	if !ok || file.UID != uid {
+		return models.BookFile{}, ErrFileNotFound
+	}
+	return file, nil
+}
+
+func (ms *MemStorage) DeleteBookFile(uid, fID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	file, ok := ms.filesMap[fID]
+	if !ok || file.UID != uid {
+		return ErrFileNotFound
+	}
+	delete(ms.filesMap, fID)
+	return nil
+}
+
+func (ms *MemStorage) SaveHighlights(highlights []models.Highlight) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	hashes := make(map[string]bool)
+	for _, h := range ms.highlightsMap {
+		if h.Hash != "" {
+			hashes[h.UID+"|"+h.Hash] = true
+		}
+	}
+	inserted := 0
+	for _, h := range highlights {
+		if h.Hash != "" && hashes[h.UID+"|"+h.Hash] {
+			continue
+		}
+		h.HID = uuid.New().String()
+		ms.highlightsMap[h.HID] = h
+		hashes[h.UID+"|"+h.Hash] = true
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (ms *MemStorage) GetHighlightsByBook(uid, bID string) ([]models.Highlight, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	highlights := []models.Highlight{}
+	for _, h := range ms.highlightsMap {
+		if h.UID == uid && h.BID == bID {
+			highlights = append(highlights, h)
+		}
+	}
+	sort.Slice(highlights, func(i, j int) bool { return highlightLess(highlights[i], highlights[j]) })
+	return highlights, nil
+}
+
+func (ms *MemStorage) SaveHighlight(h models.Highlight) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	h.HID = uuid.New().String()
+	ms.highlightsMap[h.HID] = h
+	return h.HID, nil
+}
+
+func (ms *MemStorage) GetHighlightByID(uid, hID string) (models.Highlight, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	h, ok := ms.highlightsMap[hID]
+	if !ok || h.UID != uid {
+		return models.Highlight{}, ErrHighlightNotFound
+	}
+	return h, nil
+}
+
+func (ms *MemStorage) SearchHighlights(uid, query, tag string) ([]models.Highlight, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	words := searchWords(query)
+	highlights := []models.Highlight{}
+	for _, h := range ms.highlightsMap {
+		if h.UID != uid || (tag != "" && !slices.Contains(h.Tags, tag)) {
+			continue
+		}
+		content := searchWords(h.Text + " " + h.Note)
+		matched := true
+		for _, word := range words {
+			if !slices.Contains(content, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			highlights = append(highlights, h)
+		}
+	}
+	sort.Slice(highlights, func(i, j int) bool { return highlightLess(highlights[i], highlights[j]) })
+	return highlights, nil
+}
+
+func (ms *MemStorage) UpdateHighlight(h models.Highlight) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	old, ok := ms.highlightsMap[h.HID]
+	if !ok || old.UID != h.UID {
+		return ErrHighlightNotFound
+	}
+	h.BID, h.CreatedAt, h.Hash = old.BID, old.CreatedAt, old.Hash
+	ms.highlightsMap[h.HID] = h
+	return nil
+}
+
+func (ms *MemStorage) DeleteHighlight(uid, hID string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	h, ok := ms.highlightsMap[hID]
+	if !ok || h.UID != uid {
+		return ErrHighlightNotFound
+	}
+	delete(ms.highlightsMap, hID)
+	return nil
+}
+
+// searchWords splits s into lower-cased words, roughly like the 'simple' text search config.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// highlightLess orders highlights like the postgres query: by creation time, undated last.
+func highlightLess(a, b models.Highlight) bool {
+	switch {
+	case a.CreatedAt == nil && b.CreatedAt == nil:
+		return a.HID < b.HID
+	case a.CreatedAt == nil:
+		return false
+	case b.CreatedAt == nil:
+		return true
+	case !a.CreatedAt.Equal(*b.CreatedAt):
+		return a.CreatedAt.Before(*b.CreatedAt)
+	}
+	return a.HID < b.HID
+}
@@ -12,6 +12,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	errText "github.com/Dorrrke/g2-books/internal/domain/errors"
@@ -19,12 +20,25 @@ import (
 	"github.com/Dorrrke/g2-books/internal/logger"
 )
 
-const ctxTimeout = 2 * time.Second
+const (
+	ctxTimeout      = 2 * time.Second
+	batchCtxTimeout = 30 * time.Second
+	// uniqueViolation is the SQLSTATE of inserts clashing with a unique index.
+	uniqueViolation = "23505"
+)
+
+const bookColumns = `bid, lable, author, delete, uid, isbn, rating, status, shelves, date_started, date_read,
+	series, series_index, tags, publisher, edition, year, pages, language, cover, created_at, updated_at, visibility,
+	tenant_id, place`
 
 var ErrBookDeleted = errors.New(errText.BookWasDeletedError)
 
+// Repository keeps its data in Postgres. Books, shelves, share links, grants, book files and
+// highlights belong to a tenant: the repository returned by NewRepo works in the default
+// tenant, WithTenant in others.
 type Repository struct {
-	conn *pgxpool.Pool
+	conn   *pgxpool.Pool
+	tenant string
 }
 
 func NewRepo(ctx context.Context, dbAddr string) (*Repository, error) {
@@ -37,6 +51,74 @@ func NewRepo(ctx context.Context, dbAddr string) (*Repository, error) {
 	}, nil
 }
 
+// BypassesRowSecurity reports whether the role the repository connects as is a superuser or
+// exempt from row-level security, which leaves tenants apart only by the filters of queries.
+func (r *Repository) BypassesRowSecurity(ctx context.Context) (bool, error) {
+	var bypass bool
+	err := r.conn.QueryRow(ctx, "SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").
+		Scan(&bypass)
+	return bypass, err
+}
+
+// WithTenant returns a repository working in the library of tenant. It shares the connections
+// of r.
+func (r *Repository) WithTenant(tenant string) *Repository {
+	return &Repository{conn: r.conn, tenant: tenant}
+}
+
+// inTenant runs fn in a transaction that row-level security limits to the repository's tenant.
+// Queries still filter by tenant where they can; the policies only back them up.
+func (r *Repository) inTenant(ctx context.Context, fn func(pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		if err := r.setTenant(ctx, tx); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// setTenant scopes the row-level security policies to the repository's tenant until tx ends.
+func (r *Repository) setTenant(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, "SELECT set_config('app.tenant_id', 'tenant:' || $1::text, true)", r.tenant)
+	return err
+}
+
+// inSystem runs fn in a transaction that row-level security lets see the data of every tenant.
+// Only maintenance and moderation work there.
+func (r *Repository) inSystem(ctx context.Context, fn func(pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT set_config('app.system', 'on', true)"); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// queryBooks runs a query on books in the repository's tenant.
+func (r *Repository) queryBooks(ctx context.Context, sql string, args ...any) ([]models.Book, error) {
+	var books []models.Book
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		books, err = scanBooks(rows)
+		return err
+	})
+	return books, err
+}
+
+// execTenant runs a statement in the repository's tenant.
+func (r *Repository) execTenant(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		var err error
+		tag, err = tx.Exec(ctx, sql, args...)
+		return err
+	})
+	return tag, err
+}
+
 func (r *Repository) SaveUser(user models.User) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
@@ -44,6 +126,10 @@ func (r *Repository) SaveUser(user models.User) (string, error) {
 	_, err := r.conn.Exec(ctx, "INSERT INTO users(uid, name, email, pass) VALUES ($1, $2, $3, $4)",
 		uid, user.Name, user.Email, user.Pass)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return "", ErrUserExists
+		}
 		return "", err
 	}
 	return uid, nil
@@ -56,28 +142,102 @@ func (r *Repository) ValidateUser(user models.User) (string, string, error) {
 	var uid string
 	var pass string
 	if err := row.Scan(&uid, &pass); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", "", ErrUserNotFound
+		}
 		return "", "", err
 	}
 	return uid, pass, nil
 }
 
+func (r *Repository) GetUser(uid string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var user models.User
+	var changed *time.Time
+	err := r.conn.QueryRow(ctx, "SELECT uid, name, email, pass, email_verified, role, password_changed_at FROM users "+
+		"WHERE uid = $1", uid).
+		Scan(&user.UID, &user.Name, &user.Email, &user.Pass, &user.EmailVerified, &user.Role, &changed)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	if changed != nil {
+		user.PasswordChangedAt = *changed
+	}
+	return user, nil
+}
+
+// UpdateUser changes the name, e-mail address and its verification of a user.
+func (r *Repository) UpdateUser(user models.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.conn.Exec(ctx, "UPDATE users SET name = $2, email = $3, email_verified = $4 WHERE uid = $1",
+		user.UID, user.Name, user.Email, user.EmailVerified)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetUserRole gives a user an account role; an empty role makes them a plain user again.
+func (r *Repository) SetUserRole(uid, role string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.conn.Exec(ctx, "UPDATE users SET role = $2 WHERE uid = $1", uid, role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetPassword replaces the password hash of a user and records when, which ends the tokens
+// issued before.
+func (r *Repository) SetPassword(uid, hash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.conn.Exec(ctx, "UPDATE users SET pass = $2, password_changed_at = now() WHERE uid = $1", uid, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *Repository) DeleteUser(uid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.conn.Exec(ctx, "DELETE FROM users WHERE uid = $1", uid)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetBooks lists the public books of all users of the tenant.
 func (r *Repository) GetBooks() ([]models.Book, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
-	rows, err := r.conn.Query(ctx, "SELECT * FROM books WHERE delete = false")
+	books, err := r.queryBooks(ctx, "SELECT "+bookColumns+` FROM books
+		WHERE tenant_id = $1 AND delete = false AND visibility = 'public'`, r.tenant)
 	if err != nil {
 		return nil, err
 	}
-	var books []models.Book
-	for rows.Next() {
-		var book models.Book
-		if err = rows.Scan(&book.BID, &book.Lable, &book.Author, &book.Delete, &book.UID); err != nil {
-			return nil, err
-		}
-		books = append(books, book)
-	}
 	if len(books) == 0 {
-		return nil, errors.New("no books in db")
+		return nil, ErrBooksListEmpty
 	}
 	return books, nil
 }
@@ -85,20 +245,13 @@ func (r *Repository) GetBooks() ([]models.Book, error) {
 func (r *Repository) GetBookByUID(uid string) ([]models.Book, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
-	rows, err := r.conn.Query(ctx, "SELECT * FROM books WHERE delete = false AND uid = $1", uid)
+	books, err := r.queryBooks(ctx, "SELECT "+bookColumns+` FROM books
+		WHERE tenant_id = $1 AND delete = false AND uid = $2`, r.tenant, uid)
 	if err != nil {
 		return nil, err
 	}
-	var books []models.Book
-	for rows.Next() {
-		var book models.Book
-		if err = rows.Scan(&book.BID, &book.Lable, &book.Author, &book.Delete, &book.UID); err != nil {
-			return nil, err
-		}
-		books = append(books, book)
-	}
 	if len(books) == 0 {
-		return nil, errors.New("no books in db")
+		return nil, ErrBooksListEmpty
 	}
 	return books, nil
 }
@@ -106,25 +259,45 @@ func (r *Repository) GetBookByUID(uid string) ([]models.Book, error) {
 func (r *Repository) GetBookByID(bID string) (models.Book, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
-	row := r.conn.QueryRow(ctx, "SELECT lable, author, delete FROM books WHERE bid = $1", bID)
-	var book models.Book
-	if err := row.Scan(&book.Lable, &book.Author, &book.Delete); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.Book{}, fmt.Errorf("book with id = %s, does not exist", bID)
-		}
+	books, err := r.queryBooks(ctx, "SELECT "+bookColumns+" FROM books WHERE tenant_id = $1 AND bid = $2",
+		r.tenant, bID)
+	if err != nil {
 		return models.Book{}, err
 	}
+	if len(books) == 0 {
+		return models.Book{}, fmt.Errorf("book with id = %s, does not exist: %w", bID, ErrBookNotFound)
+	}
+	book := books[0]
 	if book.Delete {
-		return models.Book{}, ErrBookDeleted
+		// The book is still returned so callers can report when it was deleted.
+		return book, ErrBookDeleted
 	}
 	return book, nil
 }
 
+// HarvestBooks lists public books of all users of the tenant changed within the query's time
+// range, including books deleted but not yet purged.
+func (r *Repository) HarvestBooks(q models.HarvestQuery) ([]models.Book, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.queryBooks(ctx, "SELECT "+bookColumns+` FROM books
+		WHERE tenant_id = $6 AND visibility = 'public'
+			AND ($1::timestamptz IS NULL OR updated_at >= $1)
+			AND ($2::timestamptz IS NULL OR updated_at < $2)
+			AND ($3::timestamptz IS NULL OR (updated_at, bid) > ($3, $4::text))
+		ORDER BY updated_at, bid
+		LIMIT $5`, q.From, q.Until, q.After, q.AfterID, q.Limit, r.tenant)
+}
+
 func (r *Repository) SaveBook(book models.Book) error {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
-	_, err := r.conn.Exec(ctx, "INSERT INTO books(bid, lable, author, uid) VALUES ($1, $2, $3, $4)",
-		uuid.New().String(), book.Lable, book.Author, book.UID)
+	book.BID = uuid.New().String()
+	_, err := r.execTenant(ctx, `INSERT INTO books(bid, lable, author, uid, isbn, rating, status, shelves,
+		date_started, date_read, series, series_index, tags, publisher, edition, year, pages, language, tenant_id,
+		place)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`,
+		r.bookValues(book)...)
 	if err != nil {
 		return err
 	}
@@ -132,41 +305,1013 @@ func (r *Repository) SaveBook(book models.Book) error {
 }
 
 func (r *Repository) DeleteBook(bID string) error {
-	log := logger.Get()
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
+	_, err := r.execTenant(ctx, "UPDATE books SET delete = true, updated_at = now() WHERE tenant_id = $1 AND bid = $2",
+		r.tenant, bID)
+	return err
+}
+
+func (r *Repository) ApplyBookOps(ops []models.BookOp, atomic bool) ([]models.BookOpResult, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), batchCtxTimeout)
+	defer cancel()
 	transaction, err := r.conn.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
-		err = transaction.Rollback(ctx)
-		if err != nil {
-			log.Error().Err(err).Msg("rollback failed")
+		if rbErr := transaction.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			log.Error().Err(rbErr).Msg("rollback failed")
 		}
 	}()
+	if err = r.setTenant(ctx, transaction); err != nil {
+		return nil, err
+	}
 
-	if _, err = transaction.Prepare(ctx, "update book", "UPDATE books SET delete = true WHERE bid = $1"); err != nil {
+	results := make([]models.BookOpResult, len(ops))
+	var newRows [][]any
+	batch := &pgx.Batch{}
+	var queued []int
+	for i, op := range ops {
+		results[i] = models.BookOpResult{Index: i, Op: op.Op, BID: op.Book.BID}
+		switch op.Op {
+		case models.BookOpCreate:
+			bid := uuid.New().String()
+			results[i].BID = bid
+			op.Book.BID = bid
+			newRows = append(newRows, r.bookValues(op.Book))
+		case models.BookOpUpdate:
+			batch.Queue(`UPDATE books SET lable = $2, author = $3, isbn = $5, rating = $6, status = $7,
+				shelves = CASE WHEN $21 THEN shelves ELSE $8 END, date_started = $9, date_read = $10,
+				series = $11, series_index = $12, tags = $13,
+				publisher = $14, edition = $15, year = $16, pages = $17, language = $18, place = $20,
+				updated_at = now()
+				WHERE bid = $1 AND uid = $4 AND tenant_id = $19 AND delete = false`,
+				append(r.bookValues(op.Book), op.KeepShelves)...)
+			queued = append(queued, i)
+		case models.BookOpDelete:
+			batch.Queue(`UPDATE books SET delete = true, updated_at = now()
+				WHERE bid = $1 AND uid = $2 AND tenant_id = $3 AND delete = false`,
+				op.Book.BID, op.Book.UID, r.tenant)
+			queued = append(queued, i)
+		default:
+			results[i].Error = ErrUnknownBookOp.Error()
+		}
+	}
+
+	if len(newRows) > 0 {
+		_, err = transaction.CopyFrom(ctx, pgx.Identifier{"books"}, bookInsertColumns, pgx.CopyFromRows(newRows))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if batch.Len() > 0 {
+		batchRes := transaction.SendBatch(ctx, batch)
+		for _, i := range queued {
+			tag, execErr := batchRes.Exec()
+			if execErr != nil {
+				batchRes.Close()
+				return nil, execErr
+			}
+			if tag.RowsAffected() == 0 {
+				results[i].Error = ErrBookNotFound.Error()
+			}
+		}
+		if err = batchRes.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if atomic {
+		for _, res := range results {
+			if res.Error != "" {
+				return results, ErrBatchAborted
+			}
+		}
+	}
+	if err = transaction.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SetBookCover records the blob key of the book's cover image. Covers are not part of
+// regular book updates, so clients cannot clear them by accident.
+func (r *Repository) SetBookCover(uid, bID, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.execTenant(ctx, `UPDATE books SET cover = $3, updated_at = now()
+		WHERE uid = $1 AND bid = $2 AND tenant_id = $4 AND delete = false`, uid, bID, key, r.tenant)
+	if err != nil {
 		return err
 	}
-	if _, err = transaction.Exec(ctx, "update book", bID); err != nil {
+	if tag.RowsAffected() == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// SetBookVisibility changes who may see a book. Like covers, visibility is not part of regular
+// book updates, so an update cannot publish a book by accident.
+func (r *Repository) SetBookVisibility(uid, bID, visibility string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.execTenant(ctx, `UPDATE books SET visibility = $3, updated_at = now()
+		WHERE uid = $1 AND bid = $2 AND tenant_id = $4 AND delete = false`, uid, bID, visibility, r.tenant)
+	if err != nil {
 		return err
 	}
-	return transaction.Commit(ctx)
+	if tag.RowsAffected() == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
+// shelvesQuery counts the books on each shelf of a user in tenant $3; $2 selects a single shelf.
+const shelvesQuery = `SELECT b.uid, s.name, COALESCE(v.visibility, 'private'), COUNT(DISTINCT b.bid)
+	FROM books b CROSS JOIN LATERAL unnest(b.shelves) AS s(name)
+	LEFT JOIN shelves v ON v.tenant_id = b.tenant_id AND v.uid = b.uid AND v.name = s.name
+	WHERE b.tenant_id = $3 AND b.uid = $1 AND b.delete = false AND ($2::text IS NULL OR s.name = $2)
+	GROUP BY b.uid, s.name, v.visibility
+	ORDER BY s.name`
+
+func (r *Repository) GetShelves(uid string) ([]models.Shelf, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.queryShelves(ctx, uid, nil)
+}
+
+func (r *Repository) GetShelf(uid, name string) (models.Shelf, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	shelves, err := r.queryShelves(ctx, uid, &name)
+	if err != nil {
+		return models.Shelf{}, err
+	}
+	if len(shelves) == 0 {
+		return models.Shelf{}, ErrShelfNotFound
+	}
+	return shelves[0], nil
+}
+
+func (r *Repository) queryShelves(ctx context.Context, uid string, name *string) ([]models.Shelf, error) {
+	shelves := []models.Shelf{}
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, shelvesQuery, uid, name, r.tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var shelf models.Shelf
+			if err = rows.Scan(&shelf.UID, &shelf.Name, &shelf.Visibility, &shelf.Books); err != nil {
+				return err
+			}
+			shelves = append(shelves, shelf)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shelves, nil
+}
+
+// SetShelfVisibility stores the visibility of a shelf. The setting outlives the shelf, so a
+// shelf that is emptied and filled again keeps it.
+func (r *Repository) SetShelfVisibility(uid, name, visibility string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.execTenant(ctx, `INSERT INTO shelves(tenant_id, uid, name, visibility) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, uid, name) DO UPDATE SET visibility = EXCLUDED.visibility`,
+		r.tenant, uid, name, visibility)
+	return err
 }
 
-func (r *Repository) DeleteBooks() error {
+const shareLinkColumns = "lid, uid, shelf, tenant_id, expires_at, revoked_at, created_at"
+
+// SaveShareLink stores a share link to a shelf in the repository's tenant.
+func (r *Repository) SaveShareLink(link models.ShareLink) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.execTenant(ctx, `INSERT INTO share_links(lid, uid, shelf, tenant_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`, link.LID, link.UID, link.Shelf, r.tenant, link.ExpiresAt)
+	return err
+}
+
+// GetShareLink looks a share link up in any tenant. Links are opened without login, so the link
+// itself tells which tenant the shelf belongs to.
+func (r *Repository) GetShareLink(lID string) (models.ShareLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var link models.ShareLink
+	err := r.inSystem(ctx, func(tx pgx.Tx) error {
+		var err error
+		link, err = scanShareLink(tx.QueryRow(ctx, "SELECT "+shareLinkColumns+" FROM share_links WHERE lid = $1", lID))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ShareLink{}, ErrShareLinkNotFound
+		}
+		return models.ShareLink{}, err
+	}
+	return link, nil
+}
+
+func (r *Repository) GetShareLinks(uid, shelf string) ([]models.ShareLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	links := []models.ShareLink{}
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT "+shareLinkColumns+` FROM share_links
+			WHERE tenant_id = $1 AND uid = $2 AND shelf = $3 ORDER BY created_at, lid`, r.tenant, uid, shelf)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			link, scanErr := scanShareLink(rows)
+			if scanErr != nil {
+				return scanErr
+			}
+			links = append(links, link)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// RevokeShareLink ends a share link of uid. Revoking a revoked link keeps the first revocation time.
+func (r *Repository) RevokeShareLink(uid, lID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.execTenant(ctx, `UPDATE share_links SET revoked_at = COALESCE(revoked_at, now())
+		WHERE tenant_id = $1 AND uid = $2 AND lid = $3`, r.tenant, uid, lID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrShareLinkNotFound
+	}
+	return nil
+}
+
+// SaveGrant gives a user a role on a book or shelf, replacing the role they had before.
+func (r *Repository) SaveGrant(g models.Grant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.execTenant(ctx, `INSERT INTO grants(tenant_id, owner, kind, target, uid, role)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, owner, kind, target, uid) DO UPDATE SET role = EXCLUDED.role`,
+		r.tenant, g.Owner, g.Kind, g.Target, g.UID, g.Role)
+	return err
+}
+
+const grantColumns = "owner, kind, target, uid, role, created_at"
+
+// GetGrants lists who has access to a book or shelf of owner.
+func (r *Repository) GetGrants(owner, kind, target string) ([]models.Grant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.queryGrants(ctx, "SELECT "+grantColumns+` FROM grants
+		WHERE tenant_id = $4 AND owner = $1 AND kind = $2 AND target = $3 ORDER BY created_at, uid`,
+		owner, kind, target, r.tenant)
+}
+
+// GetUserGrants lists everything owner has granted to uid.
+func (r *Repository) GetUserGrants(owner, uid string) ([]models.Grant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.queryGrants(ctx, "SELECT "+grantColumns+" FROM grants WHERE tenant_id = $3 AND owner = $1 AND uid = $2",
+		owner, uid, r.tenant)
+}
+
+// queryGrants runs a query on grants in the repository's tenant.
+func (r *Repository) queryGrants(ctx context.Context, sql string, args ...any) ([]models.Grant, error) {
+	var grants []models.Grant
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		grants, err = scanGrants(rows)
+		return err
+	})
+	return grants, err
+}
+
+func (r *Repository) DeleteGrant(owner, kind, target, uid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.execTenant(ctx, `DELETE FROM grants
+		WHERE tenant_id = $5 AND owner = $1 AND kind = $2 AND target = $3 AND uid = $4`,
+		owner, kind, target, uid, r.tenant)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+// SaveOrg creates an organization with admin as its first admin and returns its id.
+// Organizations are shared by all tenants; each one is a tenant of its own.
+func (r *Repository) SaveOrg(org models.Org, admin string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	oid := uuid.New().String()
+	err := pgx.BeginFunc(ctx, r.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "INSERT INTO orgs(oid, name) VALUES ($1, $2)", oid, org.Name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "INSERT INTO org_members(oid, uid, role) VALUES ($1, $2, $3)",
+			oid, admin, models.OrgAdmin)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return oid, nil
+}
+
+// GetUserOrgs lists the organizations uid is a member of, with uid's role in each.
+func (r *Repository) GetUserOrgs(uid string) ([]models.Org, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	rows, err := r.conn.Query(ctx, `SELECT o.oid, o.name, m.role, o.created_at
+		FROM orgs o JOIN org_members m ON m.oid = o.oid
+		WHERE m.uid = $1 ORDER BY o.created_at, o.oid`, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orgs := []models.Org{}
+	for rows.Next() {
+		var org models.Org
+		if err = rows.Scan(&org.OID, &org.Name, &org.Role, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+const memberColumns = "oid, uid, role, created_at"
+
+func (r *Repository) GetMember(oID, uid string) (models.Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var m models.Member
+	err := r.conn.QueryRow(ctx, "SELECT "+memberColumns+" FROM org_members WHERE oid = $1 AND uid = $2", oID, uid).
+		Scan(&m.OID, &m.UID, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Member{}, ErrMemberNotFound
+		}
+		return models.Member{}, err
+	}
+	return m, nil
+}
+
+func (r *Repository) GetMembers(oID string) ([]models.Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	rows, err := r.conn.Query(ctx, "SELECT "+memberColumns+" FROM org_members WHERE oid = $1 ORDER BY created_at, uid",
+		oID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := []models.Member{}
+	for rows.Next() {
+		var m models.Member
+		if err = rows.Scan(&m.OID, &m.UID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SaveMember adds a user to an organization or changes their role in it.
+func (r *Repository) SaveMember(m models.Member) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.conn.Exec(ctx, `INSERT INTO org_members(oid, uid, role) VALUES ($1, $2, $3)
+		ON CONFLICT (oid, uid) DO UPDATE SET role = EXCLUDED.role`, m.OID, m.UID, m.Role)
+	return err
+}
+
+func (r *Repository) DeleteMember(oID, uid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.conn.Exec(ctx, "DELETE FROM org_members WHERE oid = $1 AND uid = $2", oID, uid)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *Repository) GetIdentity(issuer, subject string) (models.Identity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var id models.Identity
+	err := r.conn.QueryRow(ctx, `SELECT issuer, subject, uid, email, created_at FROM identities
+		WHERE issuer = $1 AND subject = $2`, issuer, subject).
+		Scan(&id.Issuer, &id.Subject, &id.UID, &id.Email, &id.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Identity{}, ErrIdentityNotFound
+		}
+		return models.Identity{}, err
+	}
+	return id, nil
+}
+
+// SaveIdentity links an identity to its user. Linking it again keeps the first link.
+func (r *Repository) SaveIdentity(id models.Identity) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.conn.Exec(ctx, `INSERT INTO identities(issuer, subject, uid, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO NOTHING`, id.Issuer, id.Subject, id.UID, id.Email)
+	return err
+}
+
+const apiKeyColumns = `kid, uid, name, prefix, hash, scopes, tenant_id, expires_at, last_used_at, created_at`
+
+// SaveAPIKey stores an API key. Keys belong to their user rather than to a tenant.
+func (r *Repository) SaveAPIKey(key models.APIKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.conn.Exec(ctx, `INSERT INTO api_keys(kid, uid, name, prefix, hash, scopes, tenant_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.KID, key.UID, key.Name, key.Prefix, key.Hash, key.Scopes, key.TenantID, key.ExpiresAt)
+	return err
+}
+
+func (r *Repository) GetAPIKeys(uid string) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	rows, err := r.conn.Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE uid = $1 ORDER BY created_at, kid",
+		uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, scanErr := scanAPIKey(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash looks up the API key with the hash of a key a request was made with.
+func (r *Repository) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	key, err := scanAPIKey(r.conn.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = $1", hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, ErrAPIKeyNotFound
+		}
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// TouchAPIKey records that an API key was used at time at.
+func (r *Repository) TouchAPIKey(kID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.conn.Exec(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE kid = $1", kID, at)
+	return err
+}
+
+func (r *Repository) DeleteAPIKey(uid, kID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.conn.Exec(ctx, "DELETE FROM api_keys WHERE uid = $1 AND kid = $2", uid, kID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *Repository) SaveBookFile(file models.BookFile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	_, err := r.execTenant(ctx, `INSERT INTO book_files(fid, bid, uid, name, content_type, size, hash, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		file.FID, file.BID, file.UID, file.Name, file.ContentType, file.Size, file.Hash, r.tenant)
+	return err
+}
+
+func (r *Repository) GetBookFiles(uid, bID string) ([]models.BookFile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	files := []models.BookFile{}
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT "+bookFileColumns+` FROM book_files
+			WHERE tenant_id = $3 AND uid = $1 AND bid = $2 ORDER BY created_at, fid`, uid, bID, r.tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			file, scanErr := scanBookFile(rows)
+			if scanErr != nil {
+				return scanErr
+			}
+			files = append(files, file)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// GetBookFilesOf returns the files of several books of uid, keyed by book ID, in one query.
+func (r *Repository) GetBookFilesOf(uid string, bIDs []string) (map[string][]models.BookFile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	files := make(map[string][]models.BookFile)
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT "+bookFileColumns+` FROM book_files
+			WHERE tenant_id = $3 AND uid = $1 AND bid = ANY($2) ORDER BY created_at, fid`, uid, bIDs, r.tenant)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			file, scanErr := scanBookFile(rows)
+			if scanErr != nil {
+				return scanErr
+			}
+			files[file.BID] = append(files[file.BID], file)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *Repository) GetBookFile(uid, fID string) (models.BookFile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var file models.BookFile
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		var err error
+		file, err = scanBookFile(tx.QueryRow(ctx, "SELECT "+bookFileColumns+` FROM book_files
+			WHERE tenant_id = $3 AND uid = $1 AND fid = $2`, uid, fID, r.tenant))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.BookFile{}, ErrFileNotFound
+		}
+		return models.BookFile{}, err
+	}
+	return file, nil
+}
+
+func (r *Repository) DeleteBookFile(uid, fID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.execTenant(ctx, "DELETE FROM book_files WHERE tenant_id = $3 AND uid = $1 AND fid = $2",
+		uid, fID, r.tenant)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
+
+func (r *Repository) SaveHighlights(highlights []models.Highlight) (int, error) {
 	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), batchCtxTimeout)
+	defer cancel()
+	transaction, err := r.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if rbErr := transaction.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			log.Error().Err(rbErr).Msg("rollback failed")
+		}
+	}()
+	if err = r.setTenant(ctx, transaction); err != nil {
+		return 0, err
+	}
+	batch := &pgx.Batch{}
+	for _, h := range highlights {
+		h.HID = uuid.New().String()
+		batch.Queue(highlightInsert+" ON CONFLICT (uid, hash) WHERE hash <> '' DO NOTHING", r.highlightValues(h)...)
+	}
+	batchRes := transaction.SendBatch(ctx, batch)
+	inserted := 0
+	for range highlights {
+		tag, execErr := batchRes.Exec()
+		if execErr != nil {
+			batchRes.Close()
+			return 0, execErr
+		}
+		inserted += int(tag.RowsAffected())
+	}
+	if err = batchRes.Close(); err != nil {
+		return 0, err
+	}
+	return inserted, transaction.Commit(ctx)
+}
+
+func (r *Repository) SaveHighlight(h models.Highlight) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	h.HID = uuid.New().String()
+	if _, err := r.execTenant(ctx, highlightInsert, r.highlightValues(h)...); err != nil {
+		return "", err
+	}
+	return h.HID, nil
+}
+
+func (r *Repository) GetHighlightByID(uid, hID string) (models.Highlight, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var h models.Highlight
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		var err error
+		h, err = scanHighlight(tx.QueryRow(ctx, "SELECT "+highlightColumns+` FROM highlights
+			WHERE tenant_id = $3 AND uid = $1 AND hid = $2`, uid, hID, r.tenant))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Highlight{}, ErrHighlightNotFound
+		}
+		return models.Highlight{}, err
+	}
+	return h, nil
+}
+
+func (r *Repository) GetHighlightsByBook(uid, bID string) ([]models.Highlight, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.queryHighlights(ctx, "SELECT "+highlightColumns+` FROM highlights
+		WHERE tenant_id = $3 AND uid = $1 AND bid = $2 ORDER BY created_at NULLS LAST, hid`, uid, bID, r.tenant)
+}
+
+// SearchHighlights finds the user's highlights whose text or note contains every word of query.
+// A non-empty tag further limits the result to highlights carrying that tag.
+func (r *Repository) SearchHighlights(uid, query, tag string) ([]models.Highlight, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.queryHighlights(ctx, "SELECT "+highlightColumns+` FROM highlights WHERE tenant_id = $4 AND uid = $1
+		AND ($2 = '' OR to_tsvector('simple', text || ' ' || note) @@ plainto_tsquery('simple', $2))
+		AND ($3 = '' OR $3 = ANY(tags))
+		ORDER BY created_at NULLS LAST, hid`, uid, query, tag, r.tenant)
+}
+
+// queryHighlights runs a query on highlights in the repository's tenant.
+func (r *Repository) queryHighlights(ctx context.Context, sql string, args ...any) ([]models.Highlight, error) {
+	var highlights []models.Highlight
+	err := r.inTenant(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		highlights, err = scanHighlights(rows)
+		return err
+	})
+	return highlights, err
+}
+
+func (r *Repository) UpdateHighlight(h models.Highlight) error {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
 	defer cancel()
-	_, err := r.conn.Exec(ctx, "DELETE FROM books WHERE delete = true")
+	tag, err := r.execTenant(ctx, `UPDATE highlights SET kind = $3, text = $4, note = $5, color = $6, tags = $7,
+		location = $8, page = $9, percentage = $10, cfi = $11 WHERE tenant_id = $12 AND hid = $1 AND uid = $2`,
+		h.HID, h.UID, h.Kind, h.Text, h.Note, h.Color, h.Tags, h.Location, h.Page, h.Percentage, h.CFI, r.tenant)
 	if err != nil {
-		log.Error().Err(err).Msg("delete books row failed")
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrHighlightNotFound
+	}
 	return nil
 }
 
+func (r *Repository) DeleteHighlight(uid, hID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	tag, err := r.execTenant(ctx, "DELETE FROM highlights WHERE tenant_id = $3 AND uid = $1 AND hid = $2",
+		uid, hID, r.tenant)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHighlightNotFound
+	}
+	return nil
+}
+
+// ForceDeleteBook deletes a book of any user in any tenant.
+func (r *Repository) ForceDeleteBook(bID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	return r.inSystem(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE books SET delete = true, updated_at = now() WHERE bid = $1 AND delete = false",
+			bID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrBookNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteAccount removes the data of a user from every tenant. Their books are deleted, or with
+// anonymize their public books are handed to models.DeletedUser without the reading data of
+// the user. The users table is left to the auth service. A user who is the last admin of an
+// organization others belong to keeps their data, and ErrLastAdmin is returned.
+func (r *Repository) DeleteAccount(uid string, anonymize bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), batchCtxTimeout)
+	defer cancel()
+	return r.inSystem(ctx, func(tx pgx.Tx) error {
+		// An organization others belong to keeps an admin.
+		var strands bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM org_members m WHERE m.uid = $1 AND m.role = $2
+			AND EXISTS (SELECT 1 FROM org_members o WHERE o.oid = m.oid AND o.uid <> $1)
+			AND NOT EXISTS (SELECT 1 FROM org_members a WHERE a.oid = m.oid AND a.uid <> $1 AND a.role = $2))`,
+			uid, models.OrgAdmin).Scan(&strands)
+		if err != nil {
+			return err
+		}
+		if strands {
+			return ErrLastAdmin
+		}
+		if anonymize {
+			_, err = tx.Exec(ctx, `UPDATE books SET uid = $2, shelves = NULL, status = '', rating = 0,
+				date_started = NULL, date_read = NULL, updated_at = now()
+				WHERE uid = $1 AND delete = false AND visibility = 'public'`, uid, models.DeletedUser)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, `UPDATE book_files f SET uid = $2 FROM books b
+				WHERE f.bid = b.bid AND f.uid = $1 AND b.uid = $2`, uid, models.DeletedUser)
+			if err != nil {
+				return err
+			}
+		}
+		for _, sql := range []string{
+			"UPDATE books SET delete = true, updated_at = now() WHERE uid = $1 AND delete = false",
+			"DELETE FROM highlights WHERE uid = $1",
+			"DELETE FROM shelves WHERE uid = $1",
+			"DELETE FROM share_links WHERE uid = $1",
+			"DELETE FROM grants WHERE owner = $1 OR uid = $1",
+			"DELETE FROM org_members WHERE uid = $1",
+			"DELETE FROM api_keys WHERE uid = $1",
+			"DELETE FROM identities WHERE uid = $1",
+		} {
+			if _, err := tx.Exec(ctx, sql, uid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetUsers lists the users registered locally and the users owning books, in any tenant.
+func (r *Repository) GetUsers() ([]models.UserSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	users := []models.UserSummary{}
+	err := r.inSystem(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `WITH ids AS (SELECT uid FROM users UNION SELECT uid FROM books)
+			SELECT ids.uid, COALESCE(u.name, ''), COALESCE(u.email, ''),
+				(SELECT COUNT(*) FROM books b WHERE b.uid = ids.uid AND b.delete = false)
+			FROM ids LEFT JOIN users u ON u.uid = ids.uid
+			ORDER BY ids.uid`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var u models.UserSummary
+			if err = rows.Scan(&u.UID, &u.Name, &u.Email, &u.Books); err != nil {
+				return err
+			}
+			users = append(users, u)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetStats counts the data of every tenant.
+func (r *Repository) GetStats() (models.Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	var st models.Stats
+	err := r.inSystem(ctx, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `SELECT
+			(SELECT COUNT(*) FROM (SELECT uid FROM users UNION SELECT uid FROM books) ids),
+			(SELECT COUNT(*) FROM books WHERE delete = false),
+			(SELECT COUNT(*) FROM books WHERE delete = true),
+			(SELECT COUNT(*) FROM books WHERE delete = false AND visibility = 'public'),
+			(SELECT COUNT(*) FROM highlights),
+			(SELECT COUNT(*) FROM book_files),
+			(SELECT COALESCE(SUM(size), 0) FROM book_files),
+			(SELECT COUNT(*) FROM orgs)`).
+			Scan(&st.Users, &st.Books, &st.DeletedBooks, &st.PublicBooks, &st.Highlights, &st.Files,
+				&st.FilesSize, &st.Orgs)
+	})
+	return st, err
+}
+
+// DeleteBooks purges deleted books of every tenant and returns the files they had, whose blobs
+// are left to the caller. The books are locked first, so no file can be attached to them while
+// they are purged.
+func (r *Repository) DeleteBooks() ([]models.BookFile, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+	files := []models.BookFile{}
+	err := r.inSystem(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT bid FROM books WHERE delete = true FOR UPDATE"); err != nil {
+			log.Error().Err(err).Msg("lock deleted books failed")
+			return err
+		}
+		rows, err := tx.Query(ctx, `DELETE FROM book_files
+			WHERE bid IN (SELECT bid FROM books WHERE delete = true) RETURNING `+bookFileColumns)
+		if err != nil {
+			log.Error().Err(err).Msg("delete files of books failed")
+			return err
+		}
+		for rows.Next() {
+			file, scanErr := scanBookFile(rows)
+			if scanErr != nil {
+				rows.Close()
+				return scanErr
+			}
+			files = append(files, file)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, "DELETE FROM books WHERE delete = true"); err != nil {
+			log.Error().Err(err).Msg("delete books row failed")
+			return err
+		}
+		// Grants reference books loosely, so they are purged along with them.
+		_, err = tx.Exec(ctx, `DELETE FROM grants g WHERE g.kind = 'book'
+			AND NOT EXISTS (SELECT 1 FROM books b WHERE b.bid = g.target)`)
+		if err != nil {
+			log.Error().Err(err).Msg("delete grants of purged books failed")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+var bookInsertColumns = []string{ //nolint: gochecknoglobals //column list for CopyFrom
+	"bid", "lable", "author", "uid", "isbn", "rating", "status", "shelves", "date_started", "date_read",
+	"series", "series_index", "tags", "publisher", "edition", "year", "pages", "language", "tenant_id", "place",
+}
+
+// bookValues returns the values for bookInsertColumns; the book goes into the repository's tenant.
+func (r *Repository) bookValues(book models.Book) []any {
+	return []any{
+		book.BID, book.Lable, book.Author, book.UID, book.ISBN, book.Rating, book.Status,
+		book.Shelves, book.DateStarted, book.DateRead, book.Series, book.SeriesIndex, book.Tags,
+		book.Publisher, book.Edition, book.Year, book.Pages, book.Language, r.tenant, book.Place,
+	}
+}
+
+func scanBook(row pgx.Row) (models.Book, error) {
+	var book models.Book
+	err := row.Scan(&book.BID, &book.Lable, &book.Author, &book.Delete, &book.UID, &book.ISBN, &book.Rating,
+		&book.Status, &book.Shelves, &book.DateStarted, &book.DateRead, &book.Series, &book.SeriesIndex, &book.Tags,
+		&book.Publisher, &book.Edition, &book.Year, &book.Pages, &book.Language, &book.Cover,
+		&book.CreatedAt, &book.UpdatedAt, &book.Visibility, &book.TenantID, &book.Place)
+	return book, err
+}
+
+func scanGrants(rows pgx.Rows) ([]models.Grant, error) {
+	defer rows.Close()
+	grants := []models.Grant{}
+	for rows.Next() {
+		var g models.Grant
+		if err := rows.Scan(&g.Owner, &g.Kind, &g.Target, &g.UID, &g.Role, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func scanShareLink(row pgx.Row) (models.ShareLink, error) {
+	var link models.ShareLink
+	err := row.Scan(&link.LID, &link.UID, &link.Shelf, &link.TenantID, &link.ExpiresAt, &link.RevokedAt,
+		&link.CreatedAt)
+	return link, err
+}
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.KID, &key.UID, &key.Name, &key.Prefix, &key.Hash, &key.Scopes, &key.TenantID,
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+	return key, err
+}
+
+const bookFileColumns = "fid, bid, uid, name, content_type, size, hash, created_at"
+
+func scanBookFile(row pgx.Row) (models.BookFile, error) {
+	var file models.BookFile
+	err := row.Scan(&file.FID, &file.BID, &file.UID, &file.Name, &file.ContentType, &file.Size, &file.Hash,
+		&file.CreatedAt)
+	return file, err
+}
+
+const highlightColumns = `hid, bid, uid, kind, text, note, color, tags, location, page, percentage, cfi,
+	created_at, hash`
+
+const highlightInsert = `INSERT INTO highlights(hid, bid, uid, kind, text, note, color, tags, location, page,
+	percentage, cfi, created_at, hash, tenant_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+
+// highlightValues returns the values for highlightInsert; the highlight goes into the
+// repository's tenant.
+func (r *Repository) highlightValues(h models.Highlight) []any {
+	return []any{
+		h.HID, h.BID, h.UID, h.Kind, h.Text, h.Note, h.Color, h.Tags, h.Location, h.Page, h.Percentage, h.CFI,
+		h.CreatedAt, h.Hash, r.tenant,
+	}
+}
+
+func scanHighlight(row pgx.Row) (models.Highlight, error) {
+	var h models.Highlight
+	err := row.Scan(&h.HID, &h.BID, &h.UID, &h.Kind, &h.Text, &h.Note, &h.Color, &h.Tags, &h.Location, &h.Page,
+		&h.Percentage, &h.CFI, &h.CreatedAt, &h.Hash)
+	return h, err
+}
+
+func scanHighlights(rows pgx.Rows) ([]models.Highlight, error) {
+	defer rows.Close()
+	highlights := []models.Highlight{}
+	for rows.Next() {
+		h, err := scanHighlight(rows)
+		if err != nil {
+			return nil, err
+		}
+		highlights = append(highlights, h)
+	}
+	return highlights, rows.Err()
+}
+
+func scanBooks(rows pgx.Rows) ([]models.Book, error) {
+	defer rows.Close()
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
+
 func Migrations(dbAddr, migrationPath string) error {
 	migratePath := "file://" + migrationPath
 	m, err := migrate.New(migratePath, dbAddr)
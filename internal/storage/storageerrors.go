@@ -8,5 +8,17 @@ import (
 
 var ErrInvalidAuthData = errors.New(errtext.InvalidAuthDataError)
 var ErrUserNotFound = errors.New(errtext.UserNotFoundError)
+var ErrUserExists = errors.New(errtext.UserExistsError)
 var ErrBookNotFound = errors.New(errtext.BookNotFoundError)
 var ErrBooksListEmpty = errors.New(errtext.BooksListEmptyError)
+var ErrBatchAborted = errors.New(errtext.BatchAbortedError)
+var ErrUnknownBookOp = errors.New(errtext.UnknownBookOpError)
+var ErrHighlightNotFound = errors.New(errtext.HighlightNotFoundError)
+var ErrFileNotFound = errors.New(errtext.FileNotFoundError)
+var ErrShelfNotFound = errors.New(errtext.ShelfNotFoundError)
+var ErrShareLinkNotFound = errors.New(errtext.ShareLinkNotFoundError)
+var ErrGrantNotFound = errors.New(errtext.GrantNotFoundError)
+var ErrMemberNotFound = errors.New(errtext.MemberNotFoundError)
+var ErrAPIKeyNotFound = errors.New(errtext.APIKeyNotFoundError)
+var ErrIdentityNotFound = errors.New(errtext.IdentityNotFoundError)
+var ErrLastAdmin = errors.New(errtext.LastAdminError)
@@ -0,0 +1,88 @@
+// Package thumbnail validates cover images and renders their thumbnails. Covers may be JPEG,
+// PNG or WebP; thumbnails are always JPEG.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png" // register the PNG decoder
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+const (
+	ContentType = "image/jpeg"
+	// maxPixels guards against small files that decode into huge images.
+	maxPixels   = 40_000_000
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image type")
+	ErrDimensions  = errors.New("image dimensions are too large")
+)
+
+// Size is a thumbnail rendered to fit a Max by Max square.
+type Size struct {
+	Name string
+	Max  int
+}
+
+// Sizes lists the thumbnails rendered for every cover, from the smallest.
+var Sizes = []Size{ //nolint: gochecknoglobals //read-only table
+	{Name: "small", Max: 128},
+	{Name: "medium", Max: 320},
+	{Name: "large", Max: 640},
+}
+
+// Decode sniffs and decodes a cover image, returning it with its media type.
+func Decode(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return nil, "", ErrUnsupported
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.Join(ErrUnsupported, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.Join(ErrUnsupported, err)
+	}
+	return img, contentType, nil
+}
+
+// Render scales img down to fit size, keeping its aspect ratio, and encodes it as JPEG.
+// Smaller images are not enlarged. Transparent areas become white.
+func Render(img image.Image, size Size) ([]byte, error) {
+	src := img.Bounds()
+	width, height := src.Dx(), src.Dy()
+	if width > size.Max || height > size.Max {
+		if width >= height {
+			width, height = size.Max, max(1, height*size.Max/width)
+		} else {
+			width, height = max(1, width*size.Max/height), size.Max
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Over, nil)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
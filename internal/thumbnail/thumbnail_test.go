@@ -0,0 +1,90 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	var jpg bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpg, image.NewGray(image.Rect(0, 0, 8, 4)), nil))
+	// A valid PNG header claiming 10000x10000 pixels.
+	huge := encodePNG(t, 1, 1)
+	binary.BigEndian.PutUint32(huge[16:], 10000)
+	binary.BigEndian.PutUint32(huge[20:], 10000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+
+	type want struct {
+		contentType string
+		err         error
+	}
+	type test struct {
+		name string
+		data []byte
+		want want
+	}
+	tests := []test{
+		{name: "Test Decode() func; Case 1:", data: encodePNG(t, 3, 2), want: want{contentType: "image/png"}},
+		{name: "Test Decode() func; Case 2:", data: jpg.Bytes(), want: want{contentType: "image/jpeg"}},
+		{name: "Test Decode() func; Case 3:", data: []byte("GIF89a\x01\x00\x01\x00"), want: want{err: ErrUnsupported}},
+		{name: "Test Decode() func; Case 4:", data: []byte("\x89PNG\r\n\x1a\n"), want: want{err: ErrUnsupported}},
+		{name: "Test Decode() func; Case 5:", data: huge, want: want{err: ErrDimensions}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, contentType, err := Decode(tc.data)
+			if tc.want.err != nil {
+				assert.ErrorIs(t, err, tc.want.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.contentType, contentType)
+			assert.NotNil(t, img)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	type test struct {
+		name          string
+		width, height int
+		size          Size
+		want          image.Point
+	}
+	tests := []test{
+		{name: "Test Render() func; Case 1:", width: 1000, height: 500, size: Size{Max: 640}, want: image.Pt(640, 320)},
+		{name: "Test Render() func; Case 2:", width: 600, height: 900, size: Size{Max: 128}, want: image.Pt(85, 128)},
+		{name: "Test Render() func; Case 3:", width: 60, height: 90, size: Size{Max: 320}, want: image.Pt(60, 90)},
+		{name: "Test Render() func; Case 4:", width: 2000, height: 1, size: Size{Max: 128}, want: image.Pt(128, 1)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img, _, err := Decode(encodePNG(t, tc.width, tc.height))
+			require.NoError(t, err)
+			data, err := Render(img, tc.size)
+			require.NoError(t, err)
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, "jpeg", format)
+			assert.Equal(t, tc.want, image.Pt(cfg.Width, cfg.Height))
+		})
+	}
+}
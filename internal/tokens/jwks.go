@@ -0,0 +1,206 @@
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minRefresh limits how often tokens naming unknown keys make the JWKS be read again.
+	minRefresh = time.Minute
+	// maxAge is how long keys are used before the JWKS is read again, so withdrawn keys go.
+	maxAge      = time.Hour
+	jwksTimeout = 10 * time.Second
+	maxJWKSSize = 1 << 20
+)
+
+// keySet holds the keys of a JWKS by kid.
+type keySet struct {
+	source string
+	client *http.Client
+
+	mu      sync.Mutex
+	byKid   map[string]crypto.PublicKey
+	fetched time.Time
+	now     func() time.Time
+}
+
+func newKeySet(source string) *keySet {
+	return &keySet{source: source, client: &http.Client{Timeout: jwksTimeout}, now: time.Now}
+}
+
+// keys returns the key with id kid, or every key for tokens without a kid. The JWKS is read
+// again when it is old or kid is unknown, at most once every minRefresh.
+func (ks *keySet) keys(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	_, known := ks.byKid[kid]
+	age := ks.now().Sub(ks.fetched)
+	if age >= maxAge || (kid != "" && !known && age >= minRefresh) {
+		if err := ks.loadLocked(ctx); err != nil && len(ks.byKid) == 0 {
+			return nil, err
+		}
+	}
+	if kid == "" {
+		keys := make([]crypto.PublicKey, 0, len(ks.byKid))
+		for _, key := range ks.byKid {
+			keys = append(keys, key)
+		}
+		return keys, nil
+	}
+	if key, ok := ks.byKid[kid]; ok {
+		return []crypto.PublicKey{key}, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (ks *keySet) load(ctx context.Context) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.loadLocked(ctx)
+}
+
+// loadLocked reads the JWKS. The old keys are kept when it cannot be read.
+func (ks *keySet) loadLocked(ctx context.Context) error {
+	ks.fetched = ks.now()
+	data, err := ks.read(ctx)
+	if err != nil {
+		return fmt.Errorf("read jwks: %w", err)
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+	byKid := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwks key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			byKid[k.Kid] = key
+		}
+	}
+	if len(byKid) == 0 {
+		return errors.New("jwks has no signing keys")
+	}
+	ks.byKid = byKid
+	return nil
+}
+
+func (ks *keySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(ks.source, "http://") && !strings.HasPrefix(ks.source, "https://") {
+		return os.ReadFile(ks.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// jwk is a key of a JWKS as described in RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes an RSA or EC key. Keys of other types yield nil and are skipped.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		return k.ecKey()
+	default:
+		return nil, nil //nolint: nilnil //unsupported key types are skipped
+	}
+}
+
+func (k jwk) ecKey() (crypto.PublicKey, error) {
+	var curve elliptic.Curve
+	var checker ecdh.Curve
+	switch k.Crv {
+	case "P-256":
+		curve, checker = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, checker = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, checker = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	size := (curve.Params().BitSize + 7) / 8 //nolint: gomnd //bits to bytes
+	if len(x) != size || len(y) != size {
+		return nil, errors.New("invalid ec coordinates")
+	}
+	point := append(append([]byte{4}, x...), y...)
+	if _, err = checker.NewPublicKey(point); err != nil {
+		return nil, errors.New("ec point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,65 @@
+package tokens
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// revocations lists tokens that were logged out before they expire, and when the tokens of
+// users were last revoked. Tokens are kept until they expire, after which Parse rejects them
+// anyway; tokens without an expiry are kept for good, and so are users, whose uids are not
+// reused. The list lives in memory, so every server keeps its own.
+type revocations struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	users map[string]time.Time
+	now   func() time.Time
+}
+
+func newRevocations() *revocations {
+	return &revocations{until: make(map[string]time.Time), users: make(map[string]time.Time), now: time.Now}
+}
+
+// revoke rejects tokenStr until expiry. A zero expiry revokes it for good.
+func (r *revocations) revoke(tokenStr string, expiry time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for key, until := range r.until {
+		if !until.IsZero() && until.Before(now) {
+			delete(r.until, key)
+		}
+	}
+	r.until[revocationKey(tokenStr)] = expiry
+}
+
+// revoked reports whether tokenStr was revoked.
+func (r *revocations) revoked(tokenStr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	until, ok := r.until[revocationKey(tokenStr)]
+	return ok && (until.IsZero() || !until.Before(r.now()))
+}
+
+// revokeUser rejects the tokens of uid issued until now.
+func (r *revocations) revokeUser(uid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[uid] = r.now()
+}
+
+// userRevoked reports whether a token of uid issued at issued was revoked.
+func (r *revocations) userRevoked(uid string, issued time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at, ok := r.users[uid]
+	return ok && IssuedBefore(issued, at)
+}
+
+// revocationKey identifies a token without keeping the token itself around.
+func revocationKey(tokenStr string) string {
+	sum := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,276 @@
+// Package tokens verifies the JWTs the service accepts. Tokens may be signed with a shared HMAC
+// secret or, when the auth service signs with a private key, with RS256, PS256 or ES256 and
+// their larger variants. Public keys come from PEM files and from JWKS documents served over
+// HTTP or kept in a file; keys of a JWKS are picked by the kid of the token and the document is
+// read again when a token names a key not seen yet, so keys can be rotated without a restart.
+// Tokens whose users log out are revoked and rejected until they expire.
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownKey   = errors.New("token signed with an unknown key")
+	ErrExpired      = errors.New("token is expired or not valid yet")
+	ErrIssuer       = errors.New("token issued by an unexpected issuer")
+	ErrAudience     = errors.New("token issued for another audience")
+	ErrNoSecret     = errors.New("no secret to sign tokens with")
+	ErrRevoked      = errors.New("token was revoked")
+)
+
+// Config says which keys tokens may be signed with and what their claims must say.
+type Config struct {
+	// Secret is the HMAC secret shared with the auth service. HS256 tokens are rejected
+	// without it.
+	Secret string
+	// PublicKeyFile is a PEM file with the public keys or certificates of the auth service.
+	PublicKeyFile string
+	// JWKS is the URL or path of a JWKS document with the public keys of the auth service.
+	JWKS string
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// RequireExpiry rejects tokens without an exp claim.
+	RequireExpiry bool
+	// Leeway is the clock skew tolerated when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// Claims are the claims Parse fills in. Structs embedding jwt.RegisteredClaims implement it.
+type Claims interface {
+	jwt.Claims
+	VerifyExpiresAt(cmp time.Time, req bool) bool
+	VerifyNotBefore(cmp time.Time, req bool) bool
+	VerifyIssuer(cmp string, req bool) bool
+	VerifyAudience(cmp string, req bool) bool
+}
+
+// Verifier checks the signature and claims of tokens.
+type Verifier struct {
+	secret        []byte
+	keys          []crypto.PublicKey
+	jwks          *keySet
+	issuer        string
+	audience      string
+	requireExpiry bool
+	leeway        time.Duration
+	revoked       *revocations
+	now           func() time.Time
+}
+
+// New loads the keys named in cfg. A JWKS that cannot be loaded is an error, so
+// misconfigurations show at startup rather than as rejected logins.
+func New(ctx context.Context, cfg Config) (*Verifier, error) {
+	v := &Verifier{
+		issuer:        cfg.Issuer,
+		audience:      cfg.Audience,
+		requireExpiry: cfg.RequireExpiry,
+		leeway:        cfg.Leeway,
+		revoked:       newRevocations(),
+		now:           time.Now,
+	}
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+	}
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read public key: %w", err)
+		}
+		if v.keys, err = parsePEM(data); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", cfg.PublicKeyFile, err)
+		}
+	}
+	if cfg.JWKS != "" {
+		v.jwks = newKeySet(cfg.JWKS)
+		if err := v.jwks.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if v.secret == nil && len(v.keys) == 0 && v.jwks == nil {
+		return nil, errors.New("no keys to verify tokens with")
+	}
+	return v, nil
+}
+
+// NewHMAC returns a Verifier for HS256 tokens signed with secret.
+func NewHMAC(secret string) *Verifier {
+	return &Verifier{secret: []byte(secret), revoked: newRevocations(), now: time.Now}
+}
+
+// Parse verifies tokenStr and decodes its claims into claims.
+func (v *Verifier) Parse(ctx context.Context, tokenStr string, claims Claims) error {
+	parser := jwt.NewParser(jwt.WithJSONNumber())
+	token, parts, err := parser.ParseUnverified(tokenStr, claims)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	keys, err := v.candidates(ctx, token)
+	if err != nil {
+		return err
+	}
+	signed := strings.Join(parts[0:2], ".")
+	verified := false
+	for _, key := range keys {
+		if token.Method.Verify(signed, parts[2], key) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+	if err = v.validate(claims); err != nil {
+		return err
+	}
+	if v.revoked.revoked(tokenStr) {
+		return ErrRevoked
+	}
+	return nil
+}
+
+// Revoke makes Parse reject tokenStr from now on, as when its user logs out. expiry is when
+// the token expires anyway; the zero time keeps it revoked for good.
+func (v *Verifier) Revoke(tokenStr string, expiry time.Time) {
+	if !expiry.IsZero() {
+		expiry = expiry.Add(v.leeway)
+	}
+	v.revoked.revoke(tokenStr, expiry)
+}
+
+// RevokeUser rejects the tokens of uid issued until now, as when the user changes their
+// password or deletes their account; tokens issued later stay valid. Claims name their user
+// in fields of their own, so callers check UserRevoked after Parse.
+func (v *Verifier) RevokeUser(uid string) {
+	v.revoked.revokeUser(uid)
+}
+
+// UserRevoked reports whether a token of uid issued at issued, its iat claim, was revoked
+// with RevokeUser. Tokens without an iat claim are revoked with every other token.
+func (v *Verifier) UserRevoked(uid string, issued time.Time) bool {
+	return v.revoked.userRevoked(uid, issued)
+}
+
+// IssuedBefore reports whether a token issued at issued predates t. The iat claim counts
+// whole seconds, so tokens issued in the second of t do not; the zero time predates all.
+func IssuedBefore(issued, t time.Time) bool {
+	return issued.Before(t.Truncate(time.Second))
+}
+
+// Sign signs claims with the HMAC secret. The server uses it for the tokens it issues itself.
+func (v *Verifier) Sign(claims jwt.Claims) (string, error) {
+	if v.secret == nil {
+		return "", ErrNoSecret
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(v.secret)
+}
+
+// candidates returns the keys that may have signed token. Keys are only ever used with the
+// algorithm family they belong to, so a public key cannot be passed off as an HMAC secret.
+func (v *Verifier) candidates(ctx context.Context, token *jwt.Token) ([]any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.secret == nil {
+			return nil, ErrUnknownKey
+		}
+		return []any{v.secret}, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidToken, token.Method.Alg())
+	}
+	kid, _ := token.Header["kid"].(string)
+	keys := v.keys
+	if v.jwks != nil {
+		found, err := v.jwks.keys(ctx, kid)
+		if err != nil && len(keys) == 0 {
+			return nil, err
+		}
+		keys = append(found, keys...)
+	}
+	var matching []any
+	for _, key := range keys {
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				matching = append(matching, key)
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+				matching = append(matching, key)
+			}
+		}
+	}
+	if len(matching) == 0 {
+		return nil, ErrUnknownKey
+	}
+	return matching, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+	if !claims.VerifyExpiresAt(now.Add(-v.leeway), v.requireExpiry) ||
+		!claims.VerifyNotBefore(now.Add(v.leeway), false) {
+		return ErrExpired
+	}
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return ErrIssuer
+	}
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return ErrAudience
+	}
+	return nil
+}
+
+// parsePEM reads the public keys and certificates in data.
+func parsePEM(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var key any
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no public keys found")
+	}
+	return keys, nil
+}
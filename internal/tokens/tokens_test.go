@@ -0,0 +1,206 @@
+package tokens
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClaims struct {
+	jwt.RegisteredClaims
+	UserID string
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims testClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	tokenStr, err := token.SignedString(key)
+	require.NoError(t, err)
+	return tokenStr
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	pemFile := filepath.Join(t.TempDir(), "auth.pem")
+	require.NoError(t, os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	doc, err := json.Marshal(map[string]any{"keys": []any{rsaJWK("k1", &rsaKey.PublicKey)}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(jwks, doc, 0o600))
+
+	v, err := New(context.Background(), Config{
+		PublicKeyFile: pemFile, JWKS: jwks, Issuer: "auth", Audience: "g2-books",
+	})
+	require.NoError(t, err)
+	now := time.Now()
+	valid := testClaims{UserID: "testUID", RegisteredClaims: jwt.RegisteredClaims{
+		Issuer: "auth", Audience: jwt.ClaimStrings{"g2-books"}, ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}}
+	expired, early, stranger, other := valid, valid, valid, valid
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+	early.NotBefore = jwt.NewNumericDate(now.Add(time.Minute))
+	stranger.Issuer = "someone"
+	other.Audience = jwt.ClaimStrings{"other"}
+	pubDER := x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)
+
+	type test struct {
+		name  string
+		token string
+		want  error
+	}
+	tests := []test{
+		{
+			name:  "Test Verifier; Case 1:",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, valid),
+		},
+		{
+			name:  "Test Verifier; Case 2:",
+			token: sign(t, jwt.SigningMethodES256, "", ecKey, valid),
+		},
+		{
+			name:  "Test Verifier; Case 3:",
+			token: sign(t, jwt.SigningMethodRS256, "k2", rsaKey, valid),
+			want:  ErrUnknownKey,
+		},
+		{
+			name:  "Test Verifier; Case 4:",
+			token: sign(t, jwt.SigningMethodHS256, "k1", pubDER, valid),
+			want:  ErrUnknownKey,
+		},
+		{
+			name:  "Test Verifier; Case 5:",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, expired),
+			want:  ErrExpired,
+		},
+		{
+			name:  "Test Verifier; Case 6:",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, early),
+			want:  ErrExpired,
+		},
+		{
+			name:  "Test Verifier; Case 7:",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, stranger),
+			want:  ErrIssuer,
+		},
+		{
+			name:  "Test Verifier; Case 8:",
+			token: sign(t, jwt.SigningMethodRS256, "k1", rsaKey, other),
+			want:  ErrAudience,
+		},
+		{
+			name:  "Test Verifier; Case 9:",
+			token: sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, valid),
+			want:  ErrInvalidToken,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var claims testClaims
+			err := v.Parse(context.Background(), tc.token, &claims)
+			if tc.want != nil {
+				assert.ErrorIs(t, err, tc.want)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "testUID", claims.UserID)
+		})
+	}
+}
+
+func TestVerifierHMAC(t *testing.T) {
+	v := NewHMAC("secret")
+	token, err := v.Sign(testClaims{UserID: "testUID"})
+	require.NoError(t, err)
+	var claims testClaims
+	assert.NoError(t, v.Parse(context.Background(), token, &claims))
+	assert.Equal(t, "testUID", claims.UserID)
+
+	forged := sign(t, jwt.SigningMethodHS256, "", []byte("other"), testClaims{UserID: "testUID"})
+	assert.ErrorIs(t, v.Parse(context.Background(), forged, &claims), ErrInvalidToken)
+
+	v.requireExpiry = true
+	assert.ErrorIs(t, v.Parse(context.Background(), token, &claims), ErrExpired)
+
+	issued := time.Now().Add(-time.Hour)
+	assert.False(t, v.UserRevoked("testUID", issued))
+	v.RevokeUser("testUID")
+	assert.True(t, v.UserRevoked("testUID", issued))
+	assert.True(t, v.UserRevoked("testUID", time.Time{}))
+	assert.False(t, v.UserRevoked("testUID", time.Now().Add(time.Second)))
+	assert.False(t, v.UserRevoked("otherUID", issued))
+}
+
+func TestVerifierKeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	var mu sync.Mutex
+	keys := []any{rsaJWK("old", &oldKey.PublicKey)}
+	fetches := 0
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	defer jwks.Close()
+
+	v, err := New(context.Background(), Config{JWKS: jwks.URL})
+	require.NoError(t, err)
+	clock := time.Now()
+	v.jwks.now = func() time.Time { return clock }
+	var claims testClaims
+	assert.NoError(t, v.Parse(context.Background(), sign(t, jwt.SigningMethodRS256, "old", oldKey, claims), &claims))
+
+	mu.Lock()
+	keys = []any{rsaJWK("new", &newKey.PublicKey)}
+	mu.Unlock()
+	rotated := sign(t, jwt.SigningMethodRS256, "new", newKey, claims)
+	// Unknown keys are looked up at most once a minute.
+	assert.ErrorIs(t, v.Parse(context.Background(), rotated, &claims), ErrUnknownKey)
+	clock = clock.Add(2 * time.Minute)
+	assert.NoError(t, v.Parse(context.Background(), rotated, &claims))
+	mu.Lock()
+	assert.Equal(t, 2, fetches)
+	mu.Unlock()
+
+	// Withdrawn keys go once the keys are read again.
+	clock = clock.Add(2 * time.Hour)
+	err = v.Parse(context.Background(), sign(t, jwt.SigningMethodRS256, "old", oldKey, claims), &claims)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
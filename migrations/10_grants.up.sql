@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS grants(
+    owner VARCHAR(36) NOT NULL,
+    kind TEXT NOT NULL,
+    target TEXT NOT NULL,
+    uid VARCHAR(36) NOT NULL,
+    role TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (owner, kind, target, uid)
+);
+
+CREATE INDEX IF NOT EXISTS grants_owner_uid ON grants (owner, uid);
@@ -0,0 +1,52 @@
+DROP POLICY IF EXISTS highlights_purge ON highlights;
+DROP POLICY IF EXISTS highlights_tenant ON highlights;
+ALTER TABLE highlights NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE highlights DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS book_files_purge ON book_files;
+DROP POLICY IF EXISTS book_files_tenant ON book_files;
+ALTER TABLE book_files NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE book_files DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS grants_purge ON grants;
+DROP POLICY IF EXISTS grants_tenant ON grants;
+ALTER TABLE grants NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE grants DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS share_links_purge ON share_links;
+DROP POLICY IF EXISTS share_links_tenant ON share_links;
+ALTER TABLE share_links NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE share_links DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS shelves_purge ON shelves;
+DROP POLICY IF EXISTS shelves_tenant ON shelves;
+ALTER TABLE shelves NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE shelves DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS books_purge ON books;
+DROP POLICY IF EXISTS books_tenant ON books;
+ALTER TABLE books NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE books DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE highlights DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE book_files DROP COLUMN IF EXISTS tenant_id;
+
+DELETE FROM grants WHERE tenant_id <> '';
+ALTER TABLE grants DROP CONSTRAINT IF EXISTS grants_pkey;
+ALTER TABLE grants ADD PRIMARY KEY (owner, kind, target, uid);
+ALTER TABLE grants DROP COLUMN IF EXISTS tenant_id;
+
+DELETE FROM share_links WHERE tenant_id <> '';
+ALTER TABLE share_links DROP COLUMN IF EXISTS tenant_id;
+
+DELETE FROM shelves WHERE tenant_id <> '';
+ALTER TABLE shelves DROP CONSTRAINT IF EXISTS shelves_pkey;
+ALTER TABLE shelves ADD PRIMARY KEY (uid, name);
+ALTER TABLE shelves DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS books_tenant_uid;
+DELETE FROM books WHERE tenant_id <> '';
+ALTER TABLE books DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS orgs;
@@ -0,0 +1,90 @@
+CREATE TABLE IF NOT EXISTS orgs(
+    oid VARCHAR(36) PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS org_members(
+    oid VARCHAR(36) NOT NULL REFERENCES orgs (oid) ON DELETE CASCADE,
+    uid VARCHAR(36) NOT NULL,
+    role TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (oid, uid)
+);
+
+CREATE INDEX IF NOT EXISTS org_members_uid ON org_members (uid);
+
+-- Existing data stays in the default tenant, which has the empty id.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS books_tenant_uid ON books (tenant_id, uid);
+
+ALTER TABLE shelves ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE shelves DROP CONSTRAINT IF EXISTS shelves_pkey;
+ALTER TABLE shelves ADD PRIMARY KEY (tenant_id, uid, name);
+
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE grants ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE grants DROP CONSTRAINT IF EXISTS grants_pkey;
+ALTER TABLE grants ADD PRIMARY KEY (tenant_id, owner, kind, target, uid);
+
+ALTER TABLE book_files ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE highlights ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+
+-- Row-level security keeps tenants apart even when a query forgets its tenant filter. The
+-- repository sets app.tenant_id to 'tenant:' followed by the tenant id for each transaction;
+-- the prefix makes an unset or reset setting match no tenant, not even the default one.
+-- Purging deleted books spans all tenants and sets app.purge instead. FORCE makes the policies
+-- bind the owner of the tables too; superusers still bypass them, so the service must connect
+-- as an ordinary role, which it checks on startup.
+--
+-- Every table holding library data gets these policies. users belong to accounts, which span
+-- tenants; orgs and org_members decide which tenants an account may enter, so they are read
+-- before any tenant is chosen.
+ALTER TABLE books ENABLE ROW LEVEL SECURITY;
+ALTER TABLE books FORCE ROW LEVEL SECURITY;
+CREATE POLICY books_tenant ON books
+    USING ('tenant:' || tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK ('tenant:' || tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY books_purge ON books
+    USING (current_setting('app.purge', true) = 'on');
+
+ALTER TABLE shelves ENABLE ROW LEVEL SECURITY;
+ALTER TABLE shelves FORCE ROW LEVEL SECURITY;
+CREATE POLICY shelves_tenant ON shelves
+    USING ('tenant:' || tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK ('tenant:' || tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY shelves_purge ON shelves
+    USING (current_setting('app.purge', true) = 'on');
+
+ALTER TABLE share_links ENABLE ROW LEVEL SECURITY;
+ALTER TABLE share_links FORCE ROW LEVEL SECURITY;
+CREATE POLICY share_links_tenant ON share_links
+    USING ('tenant:' || tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK ('tenant:' || tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY share_links_purge ON share_links
+    USING (current_setting('app.purge', true) = 'on');
+
+ALTER TABLE grants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE grants FORCE ROW LEVEL SECURITY;
+CREATE POLICY grants_tenant ON grants
+    USING ('tenant:' || tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK ('tenant:' || tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY grants_purge ON grants
+    USING (current_setting('app.purge', true) = 'on');
+
+ALTER TABLE book_files ENABLE ROW LEVEL SECURITY;
+ALTER TABLE book_files FORCE ROW LEVEL SECURITY;
+CREATE POLICY book_files_tenant ON book_files
+    USING ('tenant:' || tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK ('tenant:' || tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY book_files_purge ON book_files
+    USING (current_setting('app.purge', true) = 'on');
+
+ALTER TABLE highlights ENABLE ROW LEVEL SECURITY;
+ALTER TABLE highlights FORCE ROW LEVEL SECURITY;
+CREATE POLICY highlights_tenant ON highlights
+    USING ('tenant:' || tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK ('tenant:' || tenant_id = current_setting('app.tenant_id', true));
+CREATE POLICY highlights_purge ON highlights
+    USING (current_setting('app.purge', true) = 'on');
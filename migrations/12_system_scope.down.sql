@@ -0,0 +1,23 @@
+DROP POLICY IF EXISTS books_system ON books;
+CREATE POLICY books_purge ON books
+    USING (current_setting('app.purge', true) = 'on');
+
+DROP POLICY IF EXISTS shelves_system ON shelves;
+CREATE POLICY shelves_purge ON shelves
+    USING (current_setting('app.purge', true) = 'on');
+
+DROP POLICY IF EXISTS share_links_system ON share_links;
+CREATE POLICY share_links_purge ON share_links
+    USING (current_setting('app.purge', true) = 'on');
+
+DROP POLICY IF EXISTS grants_system ON grants;
+CREATE POLICY grants_purge ON grants
+    USING (current_setting('app.purge', true) = 'on');
+
+DROP POLICY IF EXISTS book_files_system ON book_files;
+CREATE POLICY book_files_purge ON book_files
+    USING (current_setting('app.purge', true) = 'on');
+
+DROP POLICY IF EXISTS highlights_system ON highlights;
+CREATE POLICY highlights_purge ON highlights
+    USING (current_setting('app.purge', true) = 'on');
@@ -0,0 +1,25 @@
+-- Purging and the admin routes both work across tenants, so the purge policies become a
+-- general system scope, entered by setting app.system.
+DROP POLICY IF EXISTS books_purge ON books;
+CREATE POLICY books_system ON books
+    USING (current_setting('app.system', true) = 'on');
+
+DROP POLICY IF EXISTS shelves_purge ON shelves;
+CREATE POLICY shelves_system ON shelves
+    USING (current_setting('app.system', true) = 'on');
+
+DROP POLICY IF EXISTS share_links_purge ON share_links;
+CREATE POLICY share_links_system ON share_links
+    USING (current_setting('app.system', true) = 'on');
+
+DROP POLICY IF EXISTS grants_purge ON grants;
+CREATE POLICY grants_system ON grants
+    USING (current_setting('app.system', true) = 'on');
+
+DROP POLICY IF EXISTS book_files_purge ON book_files;
+CREATE POLICY book_files_system ON book_files
+    USING (current_setting('app.system', true) = 'on');
+
+DROP POLICY IF EXISTS highlights_purge ON highlights;
+CREATE POLICY highlights_system ON highlights
+    USING (current_setting('app.system', true) = 'on');
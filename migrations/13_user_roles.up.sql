@@ -0,0 +1,4 @@
+-- The account role tokens carry: '' for plain users, 'librarian' or 'admin'. Roles are
+-- granted by operators with the set-role command, e.g. g2-books set-role -email ... -role admin,
+-- and reach the user with their next login or token refresh.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+-- API keys belong to users, not to a library, so the table has no row-level security. Only
+-- the sha256 hash of a key is stored; prefix is the start of the key, shown to tell keys apart.
+CREATE TABLE IF NOT EXISTS api_keys(
+    kid VARCHAR(36) PRIMARY KEY,
+    uid VARCHAR(36) NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_uid ON api_keys (uid);
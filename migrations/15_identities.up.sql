@@ -0,0 +1,11 @@
+-- Users logging in through an OpenID provider are linked to a local user on their first login.
+CREATE TABLE IF NOT EXISTS identities(
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    uid VARCHAR(36) NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS identities_uid ON identities (uid);
@@ -0,0 +1,2 @@
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS place TEXT NOT NULL DEFAULT '';
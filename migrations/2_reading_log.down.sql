@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS books_uid_isbn;
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS isbn,
+    DROP COLUMN IF EXISTS rating,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS shelves,
+    DROP COLUMN IF EXISTS date_started,
+    DROP COLUMN IF EXISTS date_read;
@@ -0,0 +1,9 @@
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS isbn TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS rating REAL NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS shelves TEXT[],
+    ADD COLUMN IF NOT EXISTS date_started DATE,
+    ADD COLUMN IF NOT EXISTS date_read DATE;
+
+CREATE INDEX IF NOT EXISTS books_uid_isbn ON books (uid, isbn);
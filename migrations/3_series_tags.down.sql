@@ -0,0 +1,4 @@
+ALTER TABLE books
+    DROP COLUMN IF EXISTS series,
+    DROP COLUMN IF EXISTS series_index,
+    DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,4 @@
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS series TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS series_index REAL NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tags TEXT[];
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS highlights(
+    hid VARCHAR(36) PRIMARY KEY,
+    bid VARCHAR(36) NOT NULL REFERENCES books (bid) ON DELETE CASCADE,
+    uid VARCHAR(36) NOT NULL,
+    kind TEXT NOT NULL,
+    text TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    page INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ,
+    hash TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS highlights_uid_bid ON highlights (uid, bid);
+CREATE UNIQUE INDEX IF NOT EXISTS highlights_uid_hash ON highlights (uid, hash) WHERE hash <> '';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS highlights_search;
+
+ALTER TABLE highlights
+    DROP COLUMN IF EXISTS note,
+    DROP COLUMN IF EXISTS color,
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS percentage,
+    DROP COLUMN IF EXISTS cfi;
@@ -0,0 +1,9 @@
+ALTER TABLE highlights
+    ADD COLUMN IF NOT EXISTS note TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS color TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tags TEXT[],
+    ADD COLUMN IF NOT EXISTS percentage DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cfi TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS highlights_search ON highlights
+    USING GIN (to_tsvector('simple', text || ' ' || note));
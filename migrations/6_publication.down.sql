@@ -0,0 +1,5 @@
+ALTER TABLE books
+    DROP COLUMN IF EXISTS publisher,
+    DROP COLUMN IF EXISTS edition,
+    DROP COLUMN IF EXISTS year,
+    DROP COLUMN IF EXISTS pages;
@@ -0,0 +1,5 @@
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS publisher TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS edition TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS year INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS pages INTEGER NOT NULL DEFAULT 0;
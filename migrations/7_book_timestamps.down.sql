@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_updated_at;
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS book_files;
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS cover;
@@ -0,0 +1,16 @@
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS cover TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS book_files(
+    fid VARCHAR(36) PRIMARY KEY,
+    bid VARCHAR(36) NOT NULL REFERENCES books (bid) ON DELETE CASCADE,
+    uid VARCHAR(36) NOT NULL,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS book_files_uid_bid ON book_files (uid, bid);
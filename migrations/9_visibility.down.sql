@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS share_links;
+DROP TABLE IF EXISTS shelves;
+
+DROP INDEX IF EXISTS books_public_updated_at;
+
+ALTER TABLE books
+    DROP COLUMN IF EXISTS visibility;
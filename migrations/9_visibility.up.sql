@@ -0,0 +1,22 @@
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'private';
+
+CREATE INDEX IF NOT EXISTS books_public_updated_at ON books (updated_at) WHERE visibility = 'public';
+
+CREATE TABLE IF NOT EXISTS shelves(
+    uid VARCHAR(36) NOT NULL,
+    name TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'private',
+    PRIMARY KEY (uid, name)
+);
+
+CREATE TABLE IF NOT EXISTS share_links(
+    lid VARCHAR(36) PRIMARY KEY,
+    uid VARCHAR(36) NOT NULL,
+    shelf TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS share_links_uid_shelf ON share_links (uid, shelf);
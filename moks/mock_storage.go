@@ -6,6 +6,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	models "github.com/Dorrrke/g2-books/internal/domain/models"
 	gomock "github.com/golang/mock/gomock"
@@ -34,6 +35,49 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// ApplyBookOps mocks base method.
+func (m *MockStorage) ApplyBookOps(arg0 []models.BookOp, arg1 bool) ([]models.BookOpResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyBookOps", arg0, arg1)
+	ret0, _ := ret[0].([]models.BookOpResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyBookOps indicates an expected call of ApplyBookOps.
+func (mr *MockStorageMockRecorder) ApplyBookOps(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyBookOps", reflect.TypeOf((*MockStorage)(nil).ApplyBookOps), arg0, arg1)
+}
+
+// DeleteAPIKey mocks base method.
+func (m *MockStorage) DeleteAPIKey(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKey indicates an expected call of DeleteAPIKey.
+func (mr *MockStorageMockRecorder) DeleteAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockStorage)(nil).DeleteAPIKey), arg0, arg1)
+}
+
+// DeleteAccount mocks base method.
+func (m *MockStorage) DeleteAccount(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockStorageMockRecorder) DeleteAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStorage)(nil).DeleteAccount), arg0, arg1)
+}
+
 // DeleteBook mocks base method.
 func (m *MockStorage) DeleteBook(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -48,33 +92,148 @@ func (mr *MockStorageMockRecorder) DeleteBook(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBook", reflect.TypeOf((*MockStorage)(nil).DeleteBook), arg0)
 }
 
-// DeleteBooks mocks base method.
-func (m *MockStorage) DeleteBooks() error {
+// DeleteBookFile mocks base method.
+func (m *MockStorage) DeleteBookFile(arg0, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBooks")
+	ret := m.ctrl.Call(m, "DeleteBookFile", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// DeleteBookFile indicates an expected call of DeleteBookFile.
+func (mr *MockStorageMockRecorder) DeleteBookFile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBookFile", reflect.TypeOf((*MockStorage)(nil).DeleteBookFile), arg0, arg1)
+}
+
+// DeleteBooks mocks base method.
+func (m *MockStorage) DeleteBooks() ([]models.BookFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBooks")
+	ret0, _ := ret[0].([]models.BookFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // DeleteBooks indicates an expected call of DeleteBooks.
 func (mr *MockStorageMockRecorder) DeleteBooks() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBooks", reflect.TypeOf((*MockStorage)(nil).DeleteBooks))
 }
 
-// GetBookById mocks base method.
+// DeleteGrant mocks base method.
+func (m *MockStorage) DeleteGrant(arg0, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGrant", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGrant indicates an expected call of DeleteGrant.
+func (mr *MockStorageMockRecorder) DeleteGrant(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGrant", reflect.TypeOf((*MockStorage)(nil).DeleteGrant), arg0, arg1, arg2, arg3)
+}
+
+// DeleteHighlight mocks base method.
+func (m *MockStorage) DeleteHighlight(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteHighlight", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteHighlight indicates an expected call of DeleteHighlight.
+func (mr *MockStorageMockRecorder) DeleteHighlight(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteHighlight", reflect.TypeOf((*MockStorage)(nil).DeleteHighlight), arg0, arg1)
+}
+
+// DeleteMember mocks base method.
+func (m *MockStorage) DeleteMember(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMember indicates an expected call of DeleteMember.
+func (mr *MockStorageMockRecorder) DeleteMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMember", reflect.TypeOf((*MockStorage)(nil).DeleteMember), arg0, arg1)
+}
+
+// DeleteUser mocks base method.
+func (m *MockStorage) DeleteUser(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockStorageMockRecorder) DeleteUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockStorage)(nil).DeleteUser), arg0)
+}
+
+// ForceDeleteBook mocks base method.
+func (m *MockStorage) ForceDeleteBook(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceDeleteBook", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceDeleteBook indicates an expected call of ForceDeleteBook.
+func (mr *MockStorageMockRecorder) ForceDeleteBook(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceDeleteBook", reflect.TypeOf((*MockStorage)(nil).ForceDeleteBook), arg0)
+}
+
+// GetAPIKeyByHash mocks base method.
+func (m *MockStorage) GetAPIKeyByHash(arg0 string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", arg0)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockStorageMockRecorder) GetAPIKeyByHash(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockStorage)(nil).GetAPIKeyByHash), arg0)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockStorage) GetAPIKeys(arg0 string) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockStorageMockRecorder) GetAPIKeys(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockStorage)(nil).GetAPIKeys), arg0)
+}
+
+// GetBookByID mocks base method.
 func (m *MockStorage) GetBookByID(arg0 string) (models.Book, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBookById", arg0)
+	ret := m.ctrl.Call(m, "GetBookByID", arg0)
 	ret0, _ := ret[0].(models.Book)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetBookById indicates an expected call of GetBookById.
+// GetBookByID indicates an expected call of GetBookByID.
 func (mr *MockStorageMockRecorder) GetBookByID(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookById", reflect.TypeOf((*MockStorage)(nil).GetBookByID), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookByID", reflect.TypeOf((*MockStorage)(nil).GetBookByID), arg0)
 }
 
 // GetBookByUID mocks base method.
@@ -92,6 +251,51 @@ func (mr *MockStorageMockRecorder) GetBookByUID(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookByUID", reflect.TypeOf((*MockStorage)(nil).GetBookByUID), arg0)
 }
 
+// GetBookFile mocks base method.
+func (m *MockStorage) GetBookFile(arg0, arg1 string) (models.BookFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookFile", arg0, arg1)
+	ret0, _ := ret[0].(models.BookFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookFile indicates an expected call of GetBookFile.
+func (mr *MockStorageMockRecorder) GetBookFile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookFile", reflect.TypeOf((*MockStorage)(nil).GetBookFile), arg0, arg1)
+}
+
+// GetBookFiles mocks base method.
+func (m *MockStorage) GetBookFiles(arg0, arg1 string) ([]models.BookFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookFiles", arg0, arg1)
+	ret0, _ := ret[0].([]models.BookFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookFiles indicates an expected call of GetBookFiles.
+func (mr *MockStorageMockRecorder) GetBookFiles(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookFiles", reflect.TypeOf((*MockStorage)(nil).GetBookFiles), arg0, arg1)
+}
+
+// GetBookFilesOf mocks base method.
+func (m *MockStorage) GetBookFilesOf(arg0 string, arg1 []string) (map[string][]models.BookFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookFilesOf", arg0, arg1)
+	ret0, _ := ret[0].(map[string][]models.BookFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookFilesOf indicates an expected call of GetBookFilesOf.
+func (mr *MockStorageMockRecorder) GetBookFilesOf(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookFilesOf", reflect.TypeOf((*MockStorage)(nil).GetBookFilesOf), arg0, arg1)
+}
+
 // GetBooks mocks base method.
 func (m *MockStorage) GetBooks() ([]models.Book, error) {
 	m.ctrl.T.Helper()
@@ -107,6 +311,274 @@ func (mr *MockStorageMockRecorder) GetBooks() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBooks", reflect.TypeOf((*MockStorage)(nil).GetBooks))
 }
 
+// GetGrants mocks base method.
+func (m *MockStorage) GetGrants(arg0, arg1, arg2 string) ([]models.Grant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGrants", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.Grant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGrants indicates an expected call of GetGrants.
+func (mr *MockStorageMockRecorder) GetGrants(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGrants", reflect.TypeOf((*MockStorage)(nil).GetGrants), arg0, arg1, arg2)
+}
+
+// GetHighlightByID mocks base method.
+func (m *MockStorage) GetHighlightByID(arg0, arg1 string) (models.Highlight, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHighlightByID", arg0, arg1)
+	ret0, _ := ret[0].(models.Highlight)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHighlightByID indicates an expected call of GetHighlightByID.
+func (mr *MockStorageMockRecorder) GetHighlightByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHighlightByID", reflect.TypeOf((*MockStorage)(nil).GetHighlightByID), arg0, arg1)
+}
+
+// GetHighlightsByBook mocks base method.
+func (m *MockStorage) GetHighlightsByBook(arg0, arg1 string) ([]models.Highlight, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHighlightsByBook", arg0, arg1)
+	ret0, _ := ret[0].([]models.Highlight)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHighlightsByBook indicates an expected call of GetHighlightsByBook.
+func (mr *MockStorageMockRecorder) GetHighlightsByBook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHighlightsByBook", reflect.TypeOf((*MockStorage)(nil).GetHighlightsByBook), arg0, arg1)
+}
+
+// GetIdentity mocks base method.
+func (m *MockStorage) GetIdentity(arg0, arg1 string) (models.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentity", arg0, arg1)
+	ret0, _ := ret[0].(models.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentity indicates an expected call of GetIdentity.
+func (mr *MockStorageMockRecorder) GetIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentity", reflect.TypeOf((*MockStorage)(nil).GetIdentity), arg0, arg1)
+}
+
+// GetMember mocks base method.
+func (m *MockStorage) GetMember(arg0, arg1 string) (models.Member, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMember", arg0, arg1)
+	ret0, _ := ret[0].(models.Member)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMember indicates an expected call of GetMember.
+func (mr *MockStorageMockRecorder) GetMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMember", reflect.TypeOf((*MockStorage)(nil).GetMember), arg0, arg1)
+}
+
+// GetMembers mocks base method.
+func (m *MockStorage) GetMembers(arg0 string) ([]models.Member, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembers", arg0)
+	ret0, _ := ret[0].([]models.Member)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembers indicates an expected call of GetMembers.
+func (mr *MockStorageMockRecorder) GetMembers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembers", reflect.TypeOf((*MockStorage)(nil).GetMembers), arg0)
+}
+
+// GetShareLink mocks base method.
+func (m *MockStorage) GetShareLink(arg0 string) (models.ShareLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShareLink", arg0)
+	ret0, _ := ret[0].(models.ShareLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShareLink indicates an expected call of GetShareLink.
+func (mr *MockStorageMockRecorder) GetShareLink(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShareLink", reflect.TypeOf((*MockStorage)(nil).GetShareLink), arg0)
+}
+
+// GetShareLinks mocks base method.
+func (m *MockStorage) GetShareLinks(arg0, arg1 string) ([]models.ShareLink, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShareLinks", arg0, arg1)
+	ret0, _ := ret[0].([]models.ShareLink)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShareLinks indicates an expected call of GetShareLinks.
+func (mr *MockStorageMockRecorder) GetShareLinks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShareLinks", reflect.TypeOf((*MockStorage)(nil).GetShareLinks), arg0, arg1)
+}
+
+// GetShelf mocks base method.
+func (m *MockStorage) GetShelf(arg0, arg1 string) (models.Shelf, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShelf", arg0, arg1)
+	ret0, _ := ret[0].(models.Shelf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShelf indicates an expected call of GetShelf.
+func (mr *MockStorageMockRecorder) GetShelf(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShelf", reflect.TypeOf((*MockStorage)(nil).GetShelf), arg0, arg1)
+}
+
+// GetShelves mocks base method.
+func (m *MockStorage) GetShelves(arg0 string) ([]models.Shelf, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShelves", arg0)
+	ret0, _ := ret[0].([]models.Shelf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShelves indicates an expected call of GetShelves.
+func (mr *MockStorageMockRecorder) GetShelves(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShelves", reflect.TypeOf((*MockStorage)(nil).GetShelves), arg0)
+}
+
+// GetStats mocks base method.
+func (m *MockStorage) GetStats() (models.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStats")
+	ret0, _ := ret[0].(models.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockStorageMockRecorder) GetStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockStorage)(nil).GetStats))
+}
+
+// GetUser mocks base method.
+func (m *MockStorage) GetUser(arg0 string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", arg0)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStorageMockRecorder) GetUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStorage)(nil).GetUser), arg0)
+}
+
+// GetUserGrants mocks base method.
+func (m *MockStorage) GetUserGrants(arg0, arg1 string) ([]models.Grant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserGrants", arg0, arg1)
+	ret0, _ := ret[0].([]models.Grant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserGrants indicates an expected call of GetUserGrants.
+func (mr *MockStorageMockRecorder) GetUserGrants(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserGrants", reflect.TypeOf((*MockStorage)(nil).GetUserGrants), arg0, arg1)
+}
+
+// GetUserOrgs mocks base method.
+func (m *MockStorage) GetUserOrgs(arg0 string) ([]models.Org, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserOrgs", arg0)
+	ret0, _ := ret[0].([]models.Org)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserOrgs indicates an expected call of GetUserOrgs.
+func (mr *MockStorageMockRecorder) GetUserOrgs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserOrgs", reflect.TypeOf((*MockStorage)(nil).GetUserOrgs), arg0)
+}
+
+// GetUsers mocks base method.
+func (m *MockStorage) GetUsers() ([]models.UserSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers")
+	ret0, _ := ret[0].([]models.UserSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockStorageMockRecorder) GetUsers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockStorage)(nil).GetUsers))
+}
+
+// HarvestBooks mocks base method.
+func (m *MockStorage) HarvestBooks(arg0 models.HarvestQuery) ([]models.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HarvestBooks", arg0)
+	ret0, _ := ret[0].([]models.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HarvestBooks indicates an expected call of HarvestBooks.
+func (mr *MockStorageMockRecorder) HarvestBooks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HarvestBooks", reflect.TypeOf((*MockStorage)(nil).HarvestBooks), arg0)
+}
+
+// RevokeShareLink mocks base method.
+func (m *MockStorage) RevokeShareLink(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeShareLink", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeShareLink indicates an expected call of RevokeShareLink.
+func (mr *MockStorageMockRecorder) RevokeShareLink(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeShareLink", reflect.TypeOf((*MockStorage)(nil).RevokeShareLink), arg0, arg1)
+}
+
+// SaveAPIKey mocks base method.
+func (m *MockStorage) SaveAPIKey(arg0 models.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAPIKey", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAPIKey indicates an expected call of SaveAPIKey.
+func (mr *MockStorageMockRecorder) SaveAPIKey(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAPIKey", reflect.TypeOf((*MockStorage)(nil).SaveAPIKey), arg0)
+}
+
 // SaveBook mocks base method.
 func (m *MockStorage) SaveBook(arg0 models.Book) error {
 	m.ctrl.T.Helper()
@@ -121,6 +593,121 @@ func (mr *MockStorageMockRecorder) SaveBook(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBook", reflect.TypeOf((*MockStorage)(nil).SaveBook), arg0)
 }
 
+// SaveBookFile mocks base method.
+func (m *MockStorage) SaveBookFile(arg0 models.BookFile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBookFile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveBookFile indicates an expected call of SaveBookFile.
+func (mr *MockStorageMockRecorder) SaveBookFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBookFile", reflect.TypeOf((*MockStorage)(nil).SaveBookFile), arg0)
+}
+
+// SaveGrant mocks base method.
+func (m *MockStorage) SaveGrant(arg0 models.Grant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveGrant", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveGrant indicates an expected call of SaveGrant.
+func (mr *MockStorageMockRecorder) SaveGrant(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveGrant", reflect.TypeOf((*MockStorage)(nil).SaveGrant), arg0)
+}
+
+// SaveHighlight mocks base method.
+func (m *MockStorage) SaveHighlight(arg0 models.Highlight) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveHighlight", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveHighlight indicates an expected call of SaveHighlight.
+func (mr *MockStorageMockRecorder) SaveHighlight(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveHighlight", reflect.TypeOf((*MockStorage)(nil).SaveHighlight), arg0)
+}
+
+// SaveHighlights mocks base method.
+func (m *MockStorage) SaveHighlights(arg0 []models.Highlight) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveHighlights", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveHighlights indicates an expected call of SaveHighlights.
+func (mr *MockStorageMockRecorder) SaveHighlights(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveHighlights", reflect.TypeOf((*MockStorage)(nil).SaveHighlights), arg0)
+}
+
+// SaveIdentity mocks base method.
+func (m *MockStorage) SaveIdentity(arg0 models.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdentity", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdentity indicates an expected call of SaveIdentity.
+func (mr *MockStorageMockRecorder) SaveIdentity(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdentity", reflect.TypeOf((*MockStorage)(nil).SaveIdentity), arg0)
+}
+
+// SaveMember mocks base method.
+func (m *MockStorage) SaveMember(arg0 models.Member) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMember", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMember indicates an expected call of SaveMember.
+func (mr *MockStorageMockRecorder) SaveMember(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMember", reflect.TypeOf((*MockStorage)(nil).SaveMember), arg0)
+}
+
+// SaveOrg mocks base method.
+func (m *MockStorage) SaveOrg(arg0 models.Org, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOrg", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveOrg indicates an expected call of SaveOrg.
+func (mr *MockStorageMockRecorder) SaveOrg(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrg", reflect.TypeOf((*MockStorage)(nil).SaveOrg), arg0, arg1)
+}
+
+// SaveShareLink mocks base method.
+func (m *MockStorage) SaveShareLink(arg0 models.ShareLink) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveShareLink", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveShareLink indicates an expected call of SaveShareLink.
+func (mr *MockStorageMockRecorder) SaveShareLink(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveShareLink", reflect.TypeOf((*MockStorage)(nil).SaveShareLink), arg0)
+}
+
 // SaveUser mocks base method.
 func (m *MockStorage) SaveUser(arg0 models.User) (string, error) {
 	m.ctrl.T.Helper()
@@ -136,6 +723,119 @@ func (mr *MockStorageMockRecorder) SaveUser(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUser", reflect.TypeOf((*MockStorage)(nil).SaveUser), arg0)
 }
 
+// SearchHighlights mocks base method.
+func (m *MockStorage) SearchHighlights(arg0, arg1, arg2 string) ([]models.Highlight, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchHighlights", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.Highlight)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchHighlights indicates an expected call of SearchHighlights.
+func (mr *MockStorageMockRecorder) SearchHighlights(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchHighlights", reflect.TypeOf((*MockStorage)(nil).SearchHighlights), arg0, arg1, arg2)
+}
+
+// SetBookCover mocks base method.
+func (m *MockStorage) SetBookCover(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBookCover", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBookCover indicates an expected call of SetBookCover.
+func (mr *MockStorageMockRecorder) SetBookCover(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBookCover", reflect.TypeOf((*MockStorage)(nil).SetBookCover), arg0, arg1, arg2)
+}
+
+// SetBookVisibility mocks base method.
+func (m *MockStorage) SetBookVisibility(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBookVisibility", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBookVisibility indicates an expected call of SetBookVisibility.
+func (mr *MockStorageMockRecorder) SetBookVisibility(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBookVisibility", reflect.TypeOf((*MockStorage)(nil).SetBookVisibility), arg0, arg1, arg2)
+}
+
+// SetPassword mocks base method.
+func (m *MockStorage) SetPassword(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPassword indicates an expected call of SetPassword.
+func (mr *MockStorageMockRecorder) SetPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPassword", reflect.TypeOf((*MockStorage)(nil).SetPassword), arg0, arg1)
+}
+
+// SetShelfVisibility mocks base method.
+func (m *MockStorage) SetShelfVisibility(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetShelfVisibility", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetShelfVisibility indicates an expected call of SetShelfVisibility.
+func (mr *MockStorageMockRecorder) SetShelfVisibility(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetShelfVisibility", reflect.TypeOf((*MockStorage)(nil).SetShelfVisibility), arg0, arg1, arg2)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockStorage) TouchAPIKey(arg0 string, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockStorageMockRecorder) TouchAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockStorage)(nil).TouchAPIKey), arg0, arg1)
+}
+
+// UpdateHighlight mocks base method.
+func (m *MockStorage) UpdateHighlight(arg0 models.Highlight) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateHighlight", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateHighlight indicates an expected call of UpdateHighlight.
+func (mr *MockStorageMockRecorder) UpdateHighlight(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateHighlight", reflect.TypeOf((*MockStorage)(nil).UpdateHighlight), arg0)
+}
+
+// UpdateUser mocks base method.
+func (m *MockStorage) UpdateUser(arg0 models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockStorageMockRecorder) UpdateUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStorage)(nil).UpdateUser), arg0)
+}
+
 // ValidateUser mocks base method.
 func (m *MockStorage) ValidateUser(arg0 models.User) (string, string, error) {
 	m.ctrl.T.Helper()